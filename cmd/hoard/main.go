@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/jessevdk/go-flags"
@@ -11,8 +12,19 @@ import (
 func main() {
 	parser := flags.NewParser(nil, flags.Default)
 	parser.AddCommand("backup", "Backup files", "Backup files to AWS S3", app.NewBackup())
+	parser.AddCommand(
+		"restore", "Restore files", "Restore the latest backed up files from AWS S3", app.NewRestore(),
+	)
+	parser.AddCommand(
+		"watch", "Watch files", "Back up files to AWS S3 as they change", app.NewWatch(),
+	)
 
 	if _, err := parser.Parse(); err != nil {
+		var exitErr *app.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		switch flagsErr := err.(type) {
 		case flags.ErrorType:
 			if flagsErr == flags.ErrHelp {
@@ -3,24 +3,42 @@ package app
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
+	"go.uber.org/zap"
 
 	"github.com/mspraggs/hoard/internal/config"
 	"github.com/mspraggs/hoard/internal/db"
 	"github.com/mspraggs/hoard/internal/dirscanner"
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+	"github.com/mspraggs/hoard/internal/hook"
+	"github.com/mspraggs/hoard/internal/keygen"
+	"github.com/mspraggs/hoard/internal/metadata"
+	"github.com/mspraggs/hoard/internal/pool"
 	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/progress"
+	"github.com/mspraggs/hoard/internal/report"
 	"github.com/mspraggs/hoard/internal/store"
 	"github.com/mspraggs/hoard/internal/util"
 )
 
+const (
+	defaultProgressInterval = 30 * time.Second
+	terminalRefreshInterval = 500 * time.Millisecond
+)
+
 // Backup provides the logic to run Hoard's backup functionality.
 type Backup struct {
 	Command
 	ConfigPath string `required:"true" short:"c" long:"config" description:"The path to the YAML configuration required by hoard"`
+	ReportPath string `long:"report" description:"The path to write a JSON report of the backup run to"`
 }
 
 // NewBackup instantiates an instance of the Backup command.
@@ -36,7 +54,10 @@ func NewBackup(opts ...CommandOption) *Backup {
 
 // Execute implements the go-flags Commander interface for the backup command,
 // which uses the supplied configuration YAML to back up a set of directories to
-// a storage backend.
+// a storage backend. A report of the run is printed once it finishes, and an
+// ExitError is returned if any part of it failed. On SIGINT or SIGTERM, the
+// files being processed are abandoned, and the run is recorded and reported as
+// interrupted.
 func (b *Backup) Execute(args []string) error {
 	config := b.config
 	if config == nil {
@@ -59,71 +80,541 @@ func (b *Backup) Execute(args []string) error {
 		return err
 	}
 
-	d, err := sql.Open("postgres", config.Registry.Location)
+	d, err := openRegistry(&config.Registry)
 	if err != nil {
 		return err
 	}
 
-	err = b.uploadFiles(config, d, client)
+	ctx, stop := b.shutdownContext()
+	defer stop()
+
+	rep, err := b.uploadFiles(ctx, config, d, client)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return b.finishReport(rep)
 }
 
-func (b *Backup) uploadFiles(config *config.Config, d *sql.DB, client *s3.Client) error {
+func (b *Backup) uploadFiles(
+	ctx context.Context,
+	config *config.Config,
+	d *sql.DB,
+	client *s3.Client,
+) (*report.Report, error) {
+
 	defer d.Close()
 
 	inTxner := newTransactioner(d)
+	registry := newRegistry(inTxner, &config.Registry)
+
+	run, err := registry.StartRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.log.Infow("Started backup run", "run", run)
+
+	clock := &util.Clock{}
+	rep := &report.Report{Run: run, Start: clock.Now()}
+
+	tracker := progress.New()
+	stop := b.reportProgress(tracker, config.Progress)
+	defer func() {
+		stop()
+		rep.Hooks = report.NewHooks(tracker.Snapshot().Hooks)
+		rep.Interrupted = ctx.Err() != nil
+		rep.Finish(clock.Now())
+		b.finishRun(registry, rep)
+	}()
+
+	hooks := hook.NewRunner(hook.WithReporter(tracker))
+
+	env := hook.Env{Run: run, Stage: hook.StagePre}
+	if err := runHook(ctx, hooks, "global pre_hook", config.PreHook, env); err != nil {
+		env.Stage, env.Status = hook.StagePost, hook.StatusFailure
+		b.runPostHook(hooks, "global post_hook", config.PostHook, env)
+		rep.Error = err.Error()
+		return rep, nil
+	}
+
+	// Every directory is processed at once, sharing a single pool of workers,
+	// so that a slow directory does not hold up the others. Each directory has
+	// its own tracker, feeding the overall one, so that it can be reported on
+	// separately.
+	workers := newPool(config)
+	defer workers.Close()
+
+	rep.Directories = make([]*report.Directory, len(config.Directories))
+	wg := &sync.WaitGroup{}
+	for i := range config.Directories {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			dir := config.Directories[i]
+
+			dirTracker := progress.New(progress.WithParent(tracker, dir.Path))
+			start := clock.Now()
+
+			err := b.backUpDirectory(
+				ctx, hooks, dir, inTxner, client, dirTracker, workers, run, config,
+			)
+
+			dirReport := report.NewDirectory(
+				dir.Path, dir.Bucket, progress.Snapshot{}, dirTracker.Snapshot(),
+			)
+			dirReport.Start, dirReport.End = start, clock.Now()
+			if err != nil {
+				b.log.Warnw("Unable to process directory", "error", err, "path", dir.Path)
+				dirReport.Error = err.Error()
+			}
+			rep.Directories[i] = dirReport
+		}(i)
+	}
+	wg.Wait()
+
+	status := hook.StatusSuccess
+	for _, dirReport := range rep.Directories {
+		if dirReport.Error != "" || dirReport.Failed > 0 {
+			status = hook.StatusFailure
+		}
+	}
+
+	env.Stage, env.Status = hook.StagePost, status
+	b.runPostHook(hooks, "global post_hook", config.PostHook, env)
+
+	return rep, nil
+}
+
+// finishRun records the outcome of the provided report against its run in the
+// registry. This happens even if the run was interrupted, so it does not use
+// the run's context.
+func (b *Backup) finishRun(registry *db.Registry, rep *report.Report) {
+	if err := registry.FinishRun(context.Background(), rep.Run, string(rep.Status)); err != nil {
+		b.log.Warnw("Unable to record end of backup run", "run", rep.Run, "error", err)
+	}
+}
+
+// finishReport prints a summary of the provided report and writes it to the
+// configured report path, if any. An ExitError is returned if the run was not
+// entirely successful.
+func (b *Backup) finishReport(rep *report.Report) error {
+	if err := rep.WriteSummary(os.Stdout); err != nil {
+		b.log.Warnw("Unable to print backup report", "error", err)
+	}
+
+	if b.ReportPath != "" {
+		if err := writeReport(b.ReportPath, rep); err != nil {
+			return err
+		}
+		b.log.Infow("Wrote backup report", "path", b.ReportPath)
+	}
+
+	switch rep.Status {
+	case report.StatusPartialFailure:
+		return &ExitError{
+			Code: ExitCodePartialFailure,
+			Err:  fmt.Errorf("backup run %d partially failed", rep.Run),
+		}
+	case report.StatusFailure:
+		return &ExitError{
+			Code: ExitCodeFailure,
+			Err:  fmt.Errorf("backup run %d failed", rep.Run),
+		}
+	case report.StatusInterrupted:
+		return &ExitError{
+			Code: ExitCodeInterrupted,
+			Err:  fmt.Errorf("backup run %d interrupted", rep.Run),
+		}
+	default:
+		return nil
+	}
+}
+
+func writeReport(path string, rep *report.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := rep.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
 
-	for _, dir := range config.Directories {
-		if err := processDirectory(
+	return f.Close()
+}
+
+// backUpDirectory processes the provided directory between its pre- and
+// post-backup hooks. The directory is skipped if its pre-backup hook fails and
+// its failure policy requires it.
+func (b *Backup) backUpDirectory(
+	ctx context.Context,
+	hooks *hook.Runner,
+	dir config.DirConfig,
+	inTxner db.InTransactioner,
+	client *s3.Client,
+	tracker *progress.Tracker,
+	workers *pool.Pool,
+	run int64,
+	config *config.Config,
+) error {
+
+	env := hook.Env{Run: run, Stage: hook.StagePre, Directory: dir.Path, Bucket: dir.Bucket}
+	err := runHook(ctx, hooks, dir.Path+" pre_hook", dir.PreHook, env)
+	if err == nil {
+		err = processDirectory(
+			ctx,
+			b.log,
 			config.Uploads,
 			dir,
 			inTxner,
 			client,
+			tracker,
+			workers,
+			run,
 			config,
-		); err != nil {
-			b.log.Warnw("Unable to process directory", "error", err)
+		)
+	}
+
+	env.Stage, env.Status = hook.StagePost, hook.StatusSuccess
+	if err != nil {
+		env.Status = hook.StatusFailure
+	}
+	b.runPostHook(hooks, dir.Path+" post_hook", dir.PostHook, env)
+
+	return err
+}
+
+// runPostHook runs a post-backup hook. Its failure is logged, since there is
+// no longer a backup to skip. Post-backup hooks typically clean up after their
+// pre-backup counterparts, so they run to completion, subject to their own
+// timeouts, even if the backup was interrupted.
+func (b *Backup) runPostHook(
+	hooks *hook.Runner,
+	name string,
+	hookConfig *config.HookConfig,
+	env hook.Env,
+) {
+
+	if err := runHook(context.Background(), hooks, name, hookConfig, env); err != nil {
+		b.log.Warnw("Post-backup hook failed", "hook", name, "error", err)
+	}
+}
+
+func runHook(
+	ctx context.Context,
+	hooks *hook.Runner,
+	name string,
+	hookConfig *config.HookConfig,
+	env hook.Env,
+) error {
+
+	h, err := hookConfig.ToInternal()
+	if err != nil {
+		return err
+	}
+
+	return hooks.Run(ctx, name, h, env)
+}
+
+// reportProgress starts displaying the progress of the provided tracker,
+// interactively if standard output is a terminal and as periodic log lines
+// otherwise. The returned function stops the display after a final summary.
+func (b *Backup) reportProgress(
+	tracker *progress.Tracker,
+	config config.ProgressConfig,
+) func() {
+
+	var display progress.Display
+	interval := config.Interval
+	if progress.IsTerminal(os.Stdout) {
+		display = progress.NewTerminalDisplay(os.Stdout)
+		interval = terminalRefreshInterval
+	} else {
+		display = progress.NewLogDisplay(b.log)
+		if interval <= 0 {
+			interval = defaultProgressInterval
 		}
 	}
 
-	return nil
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracker.Run(ctx, interval, display)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
 }
 
 func processDirectory(
+	ctx context.Context,
+	log *zap.SugaredLogger,
 	uploads config.UploadConfig,
 	dir config.DirConfig,
 	inTxner db.InTransactioner,
 	client *s3.Client,
+	tracker *progress.Tracker,
+	workers *pool.Pool,
+	run int64,
 	config *config.Config,
 ) error {
 
+	queue := workers.NewQueue(pool.WithConcurrency(dir.Concurrency), pool.WithPriority(dir.Priority))
+	defer queue.Close()
+
+	scanner, _, err := newDirectoryScanner(
+		ctx, log, uploads, dir, inTxner, client, tracker, queue, run, config,
+	)
+	if err != nil {
+		return err
+	}
+
+	return scanner.Scan(ctx)
+}
+
+// newDirectoryScanner builds the scanner used to back up the provided
+// directory, along with the processor it runs on each file. The scanner
+// processes files by submitting them to the provided queue.
+func newDirectoryScanner(
+	ctx context.Context,
+	log *zap.SugaredLogger,
+	uploads config.UploadConfig,
+	dir config.DirConfig,
+	inTxner db.InTransactioner,
+	client *s3.Client,
+	tracker *progress.Tracker,
+	queue *pool.Queue,
+	run int64,
+	config *config.Config,
+) (*dirscanner.DirScanner, *processor.Processor, error) {
+
 	fs := os.DirFS(dir.Path)
 
-	registry := db.NewRegistry(
-		&util.Clock{},
-		inTxner,
-		db.NewCreatorTx(),
-		db.NewLatestFetcherTx(),
-		rng{},
+	keyGen, err := newKeyGenerator(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	baseRegistry := newRegistry(inTxner, &config.Registry)
+	var cachedRegistry db.CachedRegistry = baseRegistry
+	if batching := config.Registry.Batching; batching != nil {
+		cachedRegistry = db.NewBatchWriter(
+			baseRegistry, batching.ToInternal(directoryConcurrency(config, dir))...,
+		)
+	}
+	registry := db.NewLatestCache(
+		cachedRegistry, dir.Bucket,
+		db.WithCacheKeyPrefix(dir.KeyPrefix),
 	)
 
-	store := store.New(
-		client,
-		fs,
-		dir.Bucket,
+	rules := make([]store.StorageRule, len(dir.StorageClassRules))
+	for i, ruleConfig := range dir.StorageClassRules {
+		if rules[i], err = ruleConfig.ToInternal(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	storeOpts := []store.Option{
 		store.WithChecksumAlgorithm(uploads.ChecksumAlgorithm.ToInternal()),
 		store.WithChunkSize(uploads.MultiUploadThreshold),
 		store.WithStorageClass(dir.StorageClass.ToInternal()),
+		store.WithStorageRules(rules...),
+		store.WithReporter(tracker),
+	}
+	if uploads.MaxAttempts > 0 {
+		storeOpts = append(storeOpts, store.WithMaxAttempts(uploads.MaxAttempts))
+	}
+	if dir.ObjectLock != nil {
+		lock, err := dir.ObjectLock.ToInternal()
+		if err != nil {
+			return nil, nil, err
+		}
+		storeOpts = append(storeOpts, store.WithObjectLock(lock))
+	}
+
+	fileStore := store.New(client, fs, dir.Bucket, storeOpts...)
+
+	hashAlg, err := uploads.HashAlgorithm.ToInternal()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var readerOpts []metadata.ReaderOption
+	scannerOpts := []dirscanner.Option{
+		dirscanner.WithReporter(tracker),
+		dirscanner.WithIrregularFiles(),
+		dirscanner.WithScheduler(queue),
+	}
+	if len(dir.Include) > 0 {
+		scannerOpts = append(scannerOpts, dirscanner.WithIncludes(dir.Include...))
+	}
+	if len(dir.Exclude) > 0 {
+		scannerOpts = append(scannerOpts, dirscanner.WithExcludes(dir.Exclude...))
+	}
+	if dir.MaxSize > 0 {
+		scannerOpts = append(scannerOpts, dirscanner.WithMaxSize(dir.MaxSize))
+	}
+	if dir.MinAge > 0 {
+		scannerOpts = append(scannerOpts, dirscanner.WithMinAge(dir.MinAge))
+	}
+	if dir.MaxAge > 0 {
+		scannerOpts = append(scannerOpts, dirscanner.WithMaxAge(dir.MaxAge))
+	}
+	if dir.OneFileSystem {
+		scannerOpts = append(scannerOpts, dirscanner.WithOneFileSystem())
+	}
+	if dir.FollowSymlinks {
+		readerOpts = append(readerOpts, metadata.WithFollowSymlinks())
+		scannerOpts = append(scannerOpts, dirscanner.WithFollowSymlinks())
+	}
+	reader := metadata.NewReader(dir.Path, readerOpts...)
+
+	// The pack of small files is uploaded once every file the processor is
+	// working on is waiting for it.
+	activity := processor.NewActivity()
+	processorOpts := []processor.Option{
+		processor.WithActivity(activity),
+		processor.WithKeyGenerator(keyGen),
+		processor.WithReporter(tracker),
+		processor.WithChecksumAlgorithm(hashAlg),
+		processor.WithMetadataGetter(reader),
+		processor.WithIrregularFiles(reader, dir.Bucket),
+		processor.WithEntryKeyPrefix(dir.KeyPrefix),
+	}
+	if uploads.SingleRead {
+		processorOpts = append(processorOpts, processor.WithSingleRead())
+	}
+	if uploads.ChangedFileAttempts > 0 {
+		processorOpts = append(
+			processorOpts,
+			processor.WithMaxAttempts(uploads.ChangedFileAttempts),
+		)
+	}
+
+	if dir.DetectRenames {
+		processorOpts = append(processorOpts, processor.WithRenameDetection(dir.Bucket))
+	}
+
+	inconsistentPolicy, err := uploads.InconsistentPolicy.ToInternal()
+	if err != nil {
+		return nil, nil, err
+	}
+	processorOpts = append(processorOpts, processor.WithInconsistentPolicy(inconsistentPolicy))
+
+	detection, err := dir.ChangeDetection.ToInternal()
+	if err != nil {
+		return nil, nil, err
+	}
+	if detection == processor.ChangeDetectionChecksumEveryNRuns {
+		if dir.ChecksumEveryNRuns <= 0 {
+			return nil, nil, fmt.Errorf(
+				"change detection policy %q requires a positive checksum_every_n_runs",
+				dir.ChangeDetection,
+			)
+		}
+		processorOpts = append(
+			processorOpts,
+			processor.WithChecksumEveryNRuns(run, dir.ChecksumEveryNRuns),
+		)
+	} else {
+		processorOpts = append(processorOpts, processor.WithChangeDetection(detection))
+	}
+
+	if err := fileStore.Preflight(ctx); err != nil {
+		if !errors.Is(err, hoarderrors.ErrVersioningDisabled) || !dir.AllowUnversioned {
+			return nil, nil, fmt.Errorf(
+				"preflight checks failed for directory %q: %w", dir.Path, err,
+			)
+		}
+		log.Warnw(
+			"Bucket versioning disabled, storing each version under a unique key",
+			"bucket", dir.Bucket,
+			"path", dir.Path,
+		)
+		processorOpts = append(processorOpts, processor.WithUniqueKeys())
+	}
+	if dir.ProbeWrites {
+		err = fileStore.ProbeWrites(ctx, uuid.NewString())
+	} else {
+		err = fileStore.CheckWrites(ctx, uuid.NewString())
+	}
+	if errors.Is(err, hoarderrors.ErrProbeNotDeleted) {
+		log.Warnw("Unable to delete preflight object", "bucket", dir.Bucket, "error", err)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("preflight checks failed for directory %q: %w", dir.Path, err)
+	}
+
+	var uploader processor.Uploader = fileStore
+	if dir.Packing != nil {
+		packOpts := append(
+			dir.Packing.ToInternal(),
+			store.WithPackKeyPrefix(dir.KeyPrefix),
+			store.WithPackActivity(activity),
+		)
+		uploader = store.NewPacker(fileStore, rng{}, packOpts...)
+	}
+
+	start := time.Now()
+	if err := registry.Load(ctx); err != nil {
+		return nil, nil, fmt.Errorf("unable to load registry for directory %q: %w", dir.Path, err)
+	}
+	log.Infow("Loaded latest file versions", "path", dir.Path, "duration", time.Since(start))
+
+	p := processor.New(fs, uploader, registry, processorOpts...)
+
+	scanner := dirscanner.New(
+		fs,
+		[]dirscanner.Processor{p},
+		config.NumThreads,
+		scannerOpts...,
 	)
 
-	processor := processor.New(fs, store, registry)
+	return scanner, p, nil
+}
 
-	scanner := dirscanner.New(fs, []dirscanner.Processor{processor}, config.NumThreads)
+// newPool starts the pool of workers shared by every directory, limited
+// according to the provided configuration.
+func newPool(config *config.Config) *pool.Pool {
+	var opts []pool.Option
+	if config.MaxOpenFiles > 0 {
+		opts = append(opts, pool.WithMaxOpenFiles(config.MaxOpenFiles))
+	}
+	if config.MaxInFlightBytes > 0 {
+		opts = append(opts, pool.WithMaxInFlightBytes(config.MaxInFlightBytes))
+	}
+	return pool.New(config.NumThreads, opts...)
+}
+
+// directoryConcurrency returns the largest number of files from the provided
+// directory that can be processed at once.
+func directoryConcurrency(config *config.Config, dir config.DirConfig) int {
+	n := config.NumThreads
+	if dir.Concurrency > 0 && dir.Concurrency < n {
+		n = dir.Concurrency
+	}
+	if config.MaxOpenFiles > 0 && config.MaxOpenFiles < n {
+		n = config.MaxOpenFiles
+	}
+	return n
+}
 
-	return scanner.Scan(context.Background())
+func newKeyGenerator(dir config.DirConfig) (*keygen.Generator, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	return keygen.New(
+		dir.KeyLayout.ToInternal(),
+		keygen.WithPrefix(dir.KeyPrefix),
+		keygen.WithHost(host),
+		keygen.WithDirectory(dir.Path),
+	)
 }
 
 type rng struct{}
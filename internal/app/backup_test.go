@@ -17,6 +17,7 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"github.com/golang-migrate/migrate/v4"
 	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -142,7 +143,7 @@ func (s *BackupTestSuite) countDBFiles(location string) uint64 {
 	db, err := sql.Open("postgres", location)
 	s.Require().NoError(err)
 
-	result := db.QueryRow("SELECT count(*) FROM files.files;")
+	result := db.QueryRow("SELECT count(*) FROM files.files WHERE file_type = '';")
 	s.Require().NoError(err)
 
 	var count uint64
@@ -183,6 +184,19 @@ func createS3Bucket(s3Endpoint string) error {
 		return err
 	}
 
+	_, err = client.PutBucketVersioning(
+		context.Background(),
+		&s3.PutBucketVersioningInput{
+			Bucket: aws.String(s3BucketName),
+			VersioningConfiguration: &types.VersioningConfiguration{
+				Status: types.BucketVersioningStatusEnabled,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
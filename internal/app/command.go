@@ -4,8 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
@@ -18,9 +22,38 @@ import (
 
 	"github.com/mspraggs/hoard/internal/config"
 	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
 	"github.com/mspraggs/hoard/internal/util"
+	"github.com/mspraggs/hoard/migrations"
 )
 
+const (
+	// ExitCodePartialFailure is the exit code of a backup in which some
+	// directories or files could not be processed.
+	ExitCodePartialFailure = 2
+	// ExitCodeFailure is the exit code of a backup in which nothing could be
+	// processed.
+	ExitCodeFailure = 3
+	// ExitCodeInterrupted is the exit code of a command stopped by a signal,
+	// following the shell convention for SIGINT.
+	ExitCodeInterrupted = 130
+)
+
+// ExitError is returned by commands that should cause the process to exit with
+// a particular exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // CommandOption provides a way to configure a command.
 type CommandOption func(*Command)
 
@@ -66,6 +99,40 @@ func (c *Command) tryLockPID(lockfilePath string) (func() error, error) {
 	return func() error { return c.pidLock.Unlock() }, nil
 }
 
+// shutdownContext returns a context that is cancelled when the process receives
+// SIGINT or SIGTERM, so that the work in progress can be wound down cleanly. A
+// second signal exits the process immediately. The returned function stops
+// listening for signals.
+func (c *Command) shutdownContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			c.log.Warnw("Shutting down, signal again to exit immediately", "signal", sig.String())
+			cancel()
+		case <-done:
+			return
+		}
+
+		select {
+		case sig := <-signals:
+			c.log.Errorw("Exiting immediately", "signal", sig.String())
+			os.Exit(ExitCodeInterrupted)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
 func parseConfig(path string) (*config.Config, error) {
 	raw, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -77,6 +144,9 @@ func parseConfig(path string) (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
@@ -126,3 +196,43 @@ func newClient(config *config.StoreConfig) (*s3.Client, error) {
 func newTransactioner(d *sql.DB) db.InTransactioner {
 	return db.NewInTransactioner(d)
 }
+
+// openRegistry opens the database holding the file registry, using the driver
+// selected by the registry location. SQLite databases are created if need be
+// and brought up to date with the embedded migrations.
+func openRegistry(cfg *config.RegConfig) (*sql.DB, error) {
+	driver, source := cfg.Driver()
+	if driver != config.RegistryDriverSQLite {
+		return sql.Open(driver, source)
+	}
+
+	d, err := sql.Open(sqlite.DriverName, sqlite.DataSourceName(source))
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time, so transactions share one
+	// connection rather than failing on each other's locks.
+	d.SetMaxOpenConns(1)
+
+	err = sqlite.Migrate(context.Background(), newTransactioner(d), migrations.SQLite())
+	if err != nil {
+		d.Close()
+		return nil, fmt.Errorf("unable to migrate registry: %w", err)
+	}
+
+	return d, nil
+}
+
+func newRegistry(inTxner db.InTransactioner, cfg *config.RegConfig) *db.Registry {
+	if driver, _ := cfg.Driver(); driver == config.RegistryDriverSQLite {
+		return sqlite.NewRegistry(&util.Clock{}, inTxner, rng{})
+	}
+
+	return db.NewRegistry(
+		&util.Clock{},
+		inTxner,
+		db.NewCreatorTx(),
+		db.NewLatestFetcherTx(),
+		rng{},
+	)
+}
@@ -0,0 +1,345 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/mspraggs/hoard/internal/config"
+	"github.com/mspraggs/hoard/internal/metadata"
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+// Restore provides the logic to restore the latest backed up version of a
+// directory.
+type Restore struct {
+	Command
+	ConfigPath    string            `required:"true" short:"c" long:"config" description:"The path to the YAML configuration required by hoard"`
+	Directory     string            `required:"true" short:"d" long:"directory" description:"The configured directory to restore"`
+	Target        string            `required:"true" short:"t" long:"target" description:"The directory to restore files into"`
+	SkipOwnership bool              `long:"skip-ownership" description:"Leave restored files owned by the current user"`
+	UserMap       map[string]string `long:"map-user" description:"Restore files owned by one user as owned by another, given as from:to"`
+	GroupMap      map[string]string `long:"map-group" description:"Restore files owned by one group as owned by another, given as from:to"`
+}
+
+// NewRestore instantiates an instance of the Restore command.
+func NewRestore(opts ...CommandOption) *Restore {
+	r := &Restore{}
+
+	for _, opt := range opts {
+		opt(&r.Command)
+	}
+
+	return r
+}
+
+// Execute implements the go-flags Commander interface for the restore command,
+// which restores the latest version of every file in a configured directory
+// into a target directory and reapplies the recorded metadata of each file.
+func (r *Restore) Execute(args []string) error {
+	config := r.config
+	if config == nil {
+		var err error
+		if config, err = parseConfig(r.ConfigPath); err != nil {
+			return err
+		}
+	}
+
+	r.configureLogging(&config.Logging)
+
+	dir, err := findDirectory(config, r.Directory)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient(&config.Store)
+	if err != nil {
+		return err
+	}
+
+	d, err := openRegistry(&config.Registry)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	files, err := newRegistry(newTransactioner(d), &config.Registry).ListLatest(ctx, dir.Bucket, dir.KeyPrefix)
+	if err != nil {
+		return err
+	}
+
+	applierOpts := []metadata.ApplierOption{
+		metadata.WithUserMap(r.UserMap),
+		metadata.WithGroupMap(r.GroupMap),
+	}
+	if r.SkipOwnership {
+		applierOpts = append(applierOpts, metadata.WithSkipOwnership())
+	}
+	applier := metadata.NewApplier(applierOpts...)
+	fileStore := store.New(client, nil, dir.Bucket)
+
+	files = r.withoutStale(files)
+
+	failed := 0
+	restored := make(map[string]bool)
+	var dirs, links, symlinks []*processor.File
+	for _, file := range files {
+		if file.Type == processor.FileTypeRegular && file.LinkTarget != "" {
+			links = append(links, file)
+			continue
+		}
+		if file.Type == processor.FileTypeSymlink {
+			symlinks = append(symlinks, file)
+			continue
+		}
+		if err := r.restoreFile(ctx, fileStore, applier, file); err != nil {
+			r.log.Warnw("Unable to restore file", "path", file.LocalPath, "error", err)
+			failed++
+			continue
+		}
+		restored[file.LocalPath] = true
+		if file.Type == processor.FileTypeDirectory {
+			dirs = append(dirs, file)
+		}
+	}
+
+	// Hard links are recreated once the files they link to have been
+	// restored. If that file was not restored, the link's contents are
+	// downloaded instead.
+	for _, file := range links {
+		var err error
+		if restored[file.LinkTarget] {
+			err = r.restoreLink(file)
+		} else {
+			err = r.restoreFile(ctx, fileStore, applier, file)
+		}
+		if err != nil {
+			r.log.Warnw("Unable to restore file", "path", file.LocalPath, "error", err)
+			failed++
+		}
+	}
+
+	// Symbolic links are created once everything else has been restored, so
+	// that nothing is written through them.
+	for _, file := range symlinks {
+		if err := r.restoreFile(ctx, fileStore, applier, file); err != nil {
+			r.log.Warnw("Unable to restore file", "path", file.LocalPath, "error", err)
+			failed++
+		}
+	}
+
+	// Restoring the contents of a directory changes its modification time, so
+	// the metadata of directories is applied last, deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		r.applyMetadata(applier, dirs[i])
+	}
+
+	r.log.Infow("Finished restore", "restored", len(files)-failed, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("unable to restore %d of %d files", failed, len(files))
+	}
+
+	return nil
+}
+
+// restoreFile recreates the provided file beneath the target directory and,
+// unless it is a directory, applies its recorded metadata. Regular files are
+// downloaded, while other entries are recreated from their recorded
+// attributes.
+func (r *Restore) restoreFile(
+	ctx context.Context,
+	fileStore *store.Store,
+	applier *metadata.Applier,
+	file *processor.File,
+) error {
+
+	path, err := r.targetPath(file.LocalPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	switch file.Type {
+	case processor.FileTypeDirectory:
+		return os.MkdirAll(path, 0700)
+	case processor.FileTypeSymlink:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		if err := os.Symlink(file.LinkTarget, path); err != nil {
+			return err
+		}
+	case processor.FileTypeFIFO:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		if err := unix.Mkfifo(path, 0600); err != nil {
+			return err
+		}
+	case processor.FileTypeCharDevice, processor.FileTypeBlockDevice:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		mode := uint32(unix.S_IFCHR)
+		if file.Type == processor.FileTypeBlockDevice {
+			mode = unix.S_IFBLK
+		}
+		dev := unix.Mkdev(file.DeviceMajor, file.DeviceMinor)
+		if err := unix.Mknod(path, mode|0600, int(dev)); err != nil {
+			return err
+		}
+	default:
+		if err := r.download(ctx, fileStore, file, path); err != nil {
+			return err
+		}
+	}
+
+	r.applyMetadata(applier, file)
+
+	return nil
+}
+
+// restoreLink recreates the provided file as a hard link to the restored file
+// it shares an object with.
+func (r *Restore) restoreLink(file *processor.File) error {
+	path, err := r.targetPath(file.LocalPath)
+	if err != nil {
+		return err
+	}
+	target, err := r.targetPath(file.LinkTarget)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+
+	return os.Link(target, path)
+}
+
+func (r *Restore) download(
+	ctx context.Context,
+	fileStore *store.Store,
+	file *processor.File,
+	path string,
+) error {
+
+	// An existing symbolic link is replaced rather than written through.
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|unix.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	if err := fileStore.Download(ctx, file, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// applyMetadata applies the recorded metadata of the provided file. Failing
+// to do so is only logged, since the file itself has been restored.
+func (r *Restore) applyMetadata(applier *metadata.Applier, file *processor.File) {
+	path := filepath.Join(r.Target, filepath.FromSlash(file.LocalPath))
+	if err := applier.Apply(path, file.Metadata); err != nil {
+		r.log.Warnw("Unable to restore metadata", "path", file.LocalPath, "error", err)
+	}
+}
+
+// withoutStale returns the provided files, less any beneath a path whose latest
+// version is a symbolic link. Removed files are not recorded, so such files
+// were replaced along with their directory by the link, and restoring them
+// would mean writing through it.
+func (r *Restore) withoutStale(files []*processor.File) []*processor.File {
+	symlinks := make(map[string]bool)
+	for _, file := range files {
+		if file.Type == processor.FileTypeSymlink {
+			symlinks[file.LocalPath] = true
+		}
+	}
+
+	var current []*processor.File
+	for _, file := range files {
+		if beneathSymlink(file.LocalPath, symlinks) {
+			r.log.Infow("Skipping file replaced by symbolic link", "path", file.LocalPath)
+			continue
+		}
+		current = append(current, file)
+	}
+
+	return current
+}
+
+func beneathSymlink(localPath string, symlinks map[string]bool) bool {
+	for dir := path.Dir(localPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if symlinks[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// targetPath returns the path beneath the target directory at which the file
+// with the provided local path is restored. An error is returned if that path
+// is not beneath the target directory, or if any of the directories leading to
+// it within the target directory is a symbolic link, since writing through the
+// link could modify files outside the target directory.
+func (r *Restore) targetPath(localPath string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(localPath))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the target directory", localPath)
+	}
+
+	dir := r.Target
+	for _, name := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if name == "." {
+			continue
+		}
+		dir = filepath.Join(dir, name)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("path %q is beneath symbolic link %q", localPath, dir)
+		}
+	}
+
+	return filepath.Join(r.Target, rel), nil
+}
+
+func removeExisting(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func findDirectory(config *config.Config, path string) (*config.DirConfig, error) {
+	for i := range config.Directories {
+		if config.Directories[i].Path == path {
+			return &config.Directories[i], nil
+		}
+	}
+	return nil, fmt.Errorf("directory %q is not configured", path)
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mspraggs/hoard/internal/config"
+	"github.com/mspraggs/hoard/internal/pool"
+	"github.com/mspraggs/hoard/internal/progress"
+	"github.com/mspraggs/hoard/internal/report"
+	"github.com/mspraggs/hoard/internal/watch"
+)
+
+// maxTrackedEntries limits the number of failures, busy files and hooks the
+// watch daemon's tracker keeps, since it runs indefinitely.
+const maxTrackedEntries = 1000
+
+// Watch provides the logic to run Hoard as a daemon that backs up files as
+// they change.
+type Watch struct {
+	Command
+	ConfigPath string `required:"true" short:"c" long:"config" description:"The path to the YAML configuration required by hoard"`
+}
+
+// NewWatch instantiates an instance of the Watch command.
+func NewWatch(opts ...CommandOption) *Watch {
+	w := &Watch{}
+
+	for _, opt := range opts {
+		opt(&w.Command)
+	}
+
+	return w
+}
+
+// Execute implements the go-flags Commander interface for the watch command,
+// which watches every configured directory for changes and backs up changed
+// files until the process is interrupted. Each directory is scanned in full
+// when watching starts and periodically thereafter.
+func (w *Watch) Execute(args []string) error {
+	config := w.config
+	if config == nil {
+		var err error
+		if config, err = parseConfig(w.ConfigPath); err != nil {
+			return err
+		}
+	}
+
+	w.configureLogging(&config.Logging)
+
+	unlock, err := w.tryLockPID(config.Lockfile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	client, err := newClient(&config.Store)
+	if err != nil {
+		return err
+	}
+
+	d, err := openRegistry(&config.Registry)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx, stop := w.shutdownContext()
+	defer stop()
+
+	inTxner := newTransactioner(d)
+	registry := newRegistry(inTxner, &config.Registry)
+	run, err := registry.StartRun(ctx)
+	if err != nil {
+		return err
+	}
+	w.log.Infow("Started watch run", "run", run)
+
+	status := report.StatusFailure
+	defer func() {
+		if err := registry.FinishRun(context.Background(), run, string(status)); err != nil {
+			w.log.Warnw("Unable to record end of watch run", "run", run, "error", err)
+		}
+	}()
+
+	tracker := progress.New(progress.WithMaxEntries(maxTrackedEntries))
+	workers := newPool(config)
+	defer workers.Close()
+
+	watchers := make([]*watch.Watcher, len(config.Directories))
+	for i, dir := range config.Directories {
+		scanQueue := workers.NewQueue(pool.WithConcurrency(dir.Concurrency), pool.WithPriority(dir.Priority))
+		defer scanQueue.Close()
+
+		scanner, processor, err := newDirectoryScanner(
+			ctx, w.log, config.Uploads, dir, inTxner, client, tracker, scanQueue, run, config,
+		)
+		if err != nil {
+			return err
+		}
+
+		source, err := watch.NewInotify(dir.Path)
+		if err != nil {
+			return fmt.Errorf("unable to watch directory %q: %w", dir.Path, err)
+		}
+		defer source.Close()
+
+		queue := workers.NewQueue(pool.WithConcurrency(dir.Concurrency), pool.WithPriority(dir.Priority))
+		defer queue.Close()
+
+		watchers[i] = watch.New(
+			source, processor, scanner, watchOptions(config.Watch, scanner, queue)...,
+		)
+	}
+
+	// Stop watching every directory if any one of the watchers fails, so that
+	// the failure is noticed rather than leaving the directory unprotected.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, len(watchers))
+	for i, watcher := range watchers {
+		wg.Add(1)
+		go func(i int, watcher *watch.Watcher) {
+			defer wg.Done()
+			if errs[i] = watcher.Run(ctx); errs[i] != nil {
+				cancel()
+			}
+		}(i, watcher)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("stopped watching directory %q: %w", config.Directories[i].Path, err)
+		}
+	}
+
+	status = report.StatusInterrupted
+	return nil
+}
+
+func watchOptions(
+	cfg config.WatchConfig,
+	filter watch.Filter,
+	scheduler watch.Scheduler,
+) []watch.Option {
+
+	opts := []watch.Option{watch.WithFilter(filter), watch.WithScheduler(scheduler)}
+	if cfg.Debounce > 0 {
+		opts = append(opts, watch.WithDebounce(cfg.Debounce))
+	}
+	if cfg.RescanInterval > 0 {
+		opts = append(opts, watch.WithRescanInterval(cfg.RescanInterval))
+	}
+	if cfg.RetryInterval > 0 {
+		opts = append(opts, watch.WithRetryInterval(cfg.RetryInterval))
+	}
+	if cfg.OverflowInterval > 0 {
+		opts = append(opts, watch.WithOverflowInterval(cfg.OverflowInterval))
+	}
+	return opts
+}
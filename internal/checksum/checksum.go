@@ -0,0 +1,42 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+)
+
+// Algorithm denotes a hash algorithm used to detect changes to file contents.
+type Algorithm string
+
+const (
+	// AlgorithmCRC32 denotes the 32-bit IEEE CRC32 checksum.
+	AlgorithmCRC32 Algorithm = "CRC32"
+	// AlgorithmXXHash64 denotes the 64-bit xxHash checksum.
+	AlgorithmXXHash64 Algorithm = "XXHASH64"
+	// AlgorithmBLAKE3 denotes the 256-bit BLAKE3 hash.
+	AlgorithmBLAKE3 Algorithm = "BLAKE3"
+	// AlgorithmSHA256 denotes the SHA-256 hash.
+	AlgorithmSHA256 Algorithm = "SHA256"
+)
+
+// New returns a new hash implementing the algorithm, or an error if the
+// algorithm is not recognised.
+func (a Algorithm) New() (hash.Hash, error) {
+	switch a {
+	case AlgorithmCRC32:
+		return crc32.NewIEEE(), nil
+	case AlgorithmXXHash64:
+		return xxhash.New(), nil
+	case AlgorithmBLAKE3:
+		return blake3.New(), nil
+	case AlgorithmSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown checksum algorithm %q", a)
+	}
+}
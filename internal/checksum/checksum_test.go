@@ -0,0 +1,45 @@
+package checksum_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/checksum"
+)
+
+type ChecksumTestSuite struct {
+	suite.Suite
+}
+
+func TestChecksumTestSuite(t *testing.T) {
+	suite.Run(t, new(ChecksumTestSuite))
+}
+
+func (s *ChecksumTestSuite) TestNew() {
+	testCases := []struct {
+		alg          checksum.Algorithm
+		expectedSize int
+	}{
+		{checksum.AlgorithmCRC32, 4},
+		{checksum.AlgorithmXXHash64, 8},
+		{checksum.AlgorithmBLAKE3, 32},
+		{checksum.AlgorithmSHA256, 32},
+	}
+
+	for _, tc := range testCases {
+		s.Run(string(tc.alg), func() {
+			h, err := tc.alg.New()
+
+			s.Require().NoError(err)
+			s.Len(h.Sum(nil), tc.expectedSize)
+		})
+	}
+
+	s.Run("returns error for unknown algorithm", func() {
+		h, err := checksum.Algorithm("MD5").New()
+
+		s.Nil(h)
+		s.Error(err)
+	})
+}
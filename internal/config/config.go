@@ -1,10 +1,21 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/mspraggs/hoard/internal/checksum"
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/hook"
+	"github.com/mspraggs/hoard/internal/keygen"
+	"github.com/mspraggs/hoard/internal/processor"
 	"github.com/mspraggs/hoard/internal/store"
 )
 
@@ -30,6 +41,66 @@ const (
 	ChecksumAlgorithmCRC32 ChecksumAlgorithm = "CRC32"
 )
 
+// HashAlgorithm is the YAML configuration representation of the algorithm used
+// to detect changes to files.
+type HashAlgorithm string
+
+const (
+	// HashAlgorithmCRC32 denotes the 32-bit CRC32 checksum.
+	HashAlgorithmCRC32 HashAlgorithm = "CRC32"
+	// HashAlgorithmXXHash64 denotes the 64-bit xxHash checksum.
+	HashAlgorithmXXHash64 HashAlgorithm = "XXHASH64"
+	// HashAlgorithmBLAKE3 denotes the 256-bit BLAKE3 hash.
+	HashAlgorithmBLAKE3 HashAlgorithm = "BLAKE3"
+	// HashAlgorithmSHA256 denotes the SHA-256 hash.
+	HashAlgorithmSHA256 HashAlgorithm = "SHA256"
+)
+
+// ChangeDetection is the YAML configuration representation of the policy used
+// to decide whether a file has changed since it was last uploaded.
+type ChangeDetection string
+
+const (
+	// ChangeDetectionCTime denotes trusting the ctime of a file alone.
+	ChangeDetectionCTime ChangeDetection = "ctime"
+	// ChangeDetectionMTimeSize denotes trusting the mtime and size of a file
+	// alone, which suits filesystems with unreliable ctimes.
+	ChangeDetectionMTimeSize ChangeDetection = "mtime_size"
+	// ChangeDetectionCTimeThenChecksum denotes comparing the checksum of a file
+	// whenever its ctime changes.
+	ChangeDetectionCTimeThenChecksum ChangeDetection = "ctime_then_checksum"
+	// ChangeDetectionAlwaysChecksum denotes comparing the checksum of every
+	// file.
+	ChangeDetectionAlwaysChecksum ChangeDetection = "always_checksum"
+	// ChangeDetectionChecksumEveryNRuns denotes comparing the checksum of a
+	// file whenever its ctime changes, and otherwise once every N runs.
+	ChangeDetectionChecksumEveryNRuns ChangeDetection = "checksum_every_n_runs"
+)
+
+// InconsistentPolicy is the YAML configuration representation of what to do
+// with a file that keeps changing while it is being uploaded.
+type InconsistentPolicy string
+
+const (
+	// InconsistentPolicyMark denotes registering the file and marking it as
+	// inconsistent.
+	InconsistentPolicyMark InconsistentPolicy = "mark"
+	// InconsistentPolicySkip denotes discarding the upload and leaving the
+	// registry unchanged.
+	InconsistentPolicySkip InconsistentPolicy = "skip"
+)
+
+// HookFailurePolicy is the YAML configuration representation of what happens
+// to a backup when its pre-backup hook fails.
+type HookFailurePolicy string
+
+const (
+	// HookFailurePolicySkip denotes skipping the backup.
+	HookFailurePolicySkip HookFailurePolicy = "skip"
+	// HookFailurePolicyContinue denotes proceeding with the backup.
+	HookFailurePolicyContinue HookFailurePolicy = "continue"
+)
+
 // StorageClass is the YAML configuration representation of a configured storage
 // class.
 type StorageClass string
@@ -48,15 +119,94 @@ const (
 	StorageClassArchiveInstant StorageClass = "ARCHIVE_INSTANT"
 )
 
+// KeyLayout is the YAML configuration representation of the layout used to
+// generate object keys.
+type KeyLayout string
+
+const (
+	// KeyLayoutUUID denotes a random UUID for each new path.
+	KeyLayoutUUID KeyLayout = "UUID"
+	// KeyLayoutMirror denotes keys mirroring the local relative path.
+	KeyLayoutMirror KeyLayout = "MIRROR"
+	// KeyLayoutHost denotes keys of the form host/dir/path.
+	KeyLayoutHost KeyLayout = "HOST"
+	// KeyLayoutDate denotes keys prefixed with the date of first upload.
+	KeyLayoutDate KeyLayout = "DATE"
+	// KeyLayoutHashed denotes keys sharded by a hash of the path.
+	KeyLayoutHashed KeyLayout = "HASHED"
+)
+
 // Config contains all configuration necessary for the application to run.
 type Config struct {
-	NumThreads  int          `yaml:"num_threads"`
-	Lockfile    string       `yaml:"lock_file"`
-	Logging     LogConfig    `yaml:"logging"`
-	Registry    RegConfig    `yaml:"registry"`
-	Store       StoreConfig  `yaml:"store"`
-	Uploads     UploadConfig `yaml:"uploads"`
-	Directories []DirConfig  `yaml:"directories"`
+	NumThreads       int            `yaml:"num_threads"`
+	MaxOpenFiles     int            `yaml:"max_open_files"`
+	MaxInFlightBytes int64          `yaml:"max_in_flight_bytes"`
+	Lockfile         string         `yaml:"lock_file"`
+	Logging          LogConfig      `yaml:"logging"`
+	Registry         RegConfig      `yaml:"registry"`
+	Store            StoreConfig    `yaml:"store"`
+	Uploads          UploadConfig   `yaml:"uploads"`
+	Progress         ProgressConfig `yaml:"progress"`
+	Watch            WatchConfig    `yaml:"watch"`
+	PreHook          *HookConfig    `yaml:"pre_hook"`
+	PostHook         *HookConfig    `yaml:"post_hook"`
+	Directories      []DirConfig    `yaml:"directories"`
+}
+
+// Validate checks the parts of the configuration that would otherwise only be
+// found to be invalid once a directory is being backed up.
+func (c *Config) Validate() error {
+	for _, dir := range c.Directories {
+		if err := dir.StorageClass.validate(); err != nil {
+			return fmt.Errorf("directory %q: %w", dir.Path, err)
+		}
+		for _, rule := range dir.StorageClassRules {
+			if _, err := rule.ToInternal(); err != nil {
+				return fmt.Errorf("directory %q: %w", dir.Path, err)
+			}
+		}
+	}
+	// Directories stored in the same bucket are told apart by their key
+	// prefixes, so neither prefix may begin with the other.
+	for i, dir := range c.Directories {
+		for _, other := range c.Directories[i+1:] {
+			if dir.Bucket != other.Bucket {
+				continue
+			}
+			if strings.HasPrefix(dir.KeyPrefix, other.KeyPrefix) ||
+				strings.HasPrefix(other.KeyPrefix, dir.KeyPrefix) {
+				return fmt.Errorf(
+					"directories %q and %q share bucket %q but have overlapping key prefixes",
+					dir.Path, other.Path, dir.Bucket,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// HookConfig contains all configuration relating to a command run before or
+// after a backup.
+type HookConfig struct {
+	Command   string            `yaml:"command"`
+	Timeout   time.Duration     `yaml:"timeout"`
+	OnFailure HookFailurePolicy `yaml:"on_failure"`
+}
+
+// ProgressConfig contains all configuration relating to progress reporting.
+// When output is not an interactive terminal, progress is logged once per
+// interval.
+type ProgressConfig struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// WatchConfig contains all configuration relating to watch mode. Zero values
+// fall back to the watcher's defaults.
+type WatchConfig struct {
+	Debounce         time.Duration `yaml:"debounce"`
+	RescanInterval   time.Duration `yaml:"rescan_interval"`
+	RetryInterval    time.Duration `yaml:"retry_interval"`
+	OverflowInterval time.Duration `yaml:"overflow_interval"`
 }
 
 // LogConfig contains all configuration relating to logs.
@@ -65,9 +215,28 @@ type LogConfig struct {
 	FilePath string   `yaml:"file_path"`
 }
 
-// RegConfig contains all configuration relating to the file registry.
+// Names of the database drivers used to open the file registry.
+const (
+	RegistryDriverPostgres = "postgres"
+	RegistryDriverSQLite   = "sqlite"
+)
+
+const sqliteScheme = "sqlite://"
+
+// RegConfig contains all configuration relating to the file registry. The
+// location is either a PostgreSQL connection string or, for single-host
+// installs, the path of an SQLite database with the sqlite scheme, such as
+// sqlite:///var/lib/hoard/registry.db.
 type RegConfig struct {
-	Location string `yaml:"location"`
+	Location string       `yaml:"location"`
+	Batching *BatchConfig `yaml:"batching"`
+}
+
+// BatchConfig contains all configuration relating to grouping registry inserts
+// into batches.
+type BatchConfig struct {
+	Size     int           `yaml:"size"`
+	MaxDelay time.Duration `yaml:"max_delay"`
 }
 
 // StoreCredentials contains the credentials required to authenticate with the
@@ -91,6 +260,12 @@ type StoreConfig struct {
 type UploadConfig struct {
 	MultiUploadThreshold int64             `yaml:"multi_upload_threshold"`
 	ChecksumAlgorithm    ChecksumAlgorithm `yaml:"checksum_algorithm"`
+	MaxAttempts          int               `yaml:"max_attempts"`
+	HashAlgorithm        HashAlgorithm     `yaml:"hash_algorithm"`
+	SingleRead           bool              `yaml:"single_read"`
+
+	ChangedFileAttempts int                `yaml:"changed_file_attempts"`
+	InconsistentPolicy  InconsistentPolicy `yaml:"inconsistent_policy"`
 }
 
 // DirConfig contains all configuration required to configure a directory for
@@ -99,6 +274,189 @@ type DirConfig struct {
 	Bucket       string       `yaml:"bucket"`
 	Path         string       `yaml:"path"`
 	StorageClass StorageClass `yaml:"storage_class"`
+	KeyLayout    KeyLayout    `yaml:"key_layout"`
+	KeyPrefix    string       `yaml:"key_prefix"`
+	Packing      *PackConfig  `yaml:"packing"`
+	ObjectLock   *LockConfig  `yaml:"object_lock"`
+
+	Concurrency int `yaml:"concurrency"`
+	Priority    int `yaml:"priority"`
+
+	AllowUnversioned bool `yaml:"allow_unversioned"`
+	ProbeWrites      bool `yaml:"probe_writes"`
+	DetectRenames    bool `yaml:"detect_renames"`
+	FollowSymlinks   bool `yaml:"follow_symlinks"`
+
+	ChangeDetection    ChangeDetection `yaml:"change_detection"`
+	ChecksumEveryNRuns int64           `yaml:"checksum_every_n_runs"`
+
+	PreHook  *HookConfig `yaml:"pre_hook"`
+	PostHook *HookConfig `yaml:"post_hook"`
+
+	StorageClassRules []StorageRuleConfig `yaml:"storage_class_rules"`
+
+	Include       []string      `yaml:"include"`
+	Exclude       []string      `yaml:"exclude"`
+	MaxSize       int64         `yaml:"max_size"`
+	MinAge        time.Duration `yaml:"min_age"`
+	MaxAge        time.Duration `yaml:"max_age"`
+	OneFileSystem bool          `yaml:"one_file_system"`
+}
+
+// ObjectLockMode is the YAML configuration representation of an object lock
+// retention mode.
+type ObjectLockMode string
+
+const (
+	// ObjectLockModeGovernance denotes a retention mode that users with
+	// special permissions may override.
+	ObjectLockModeGovernance ObjectLockMode = "GOVERNANCE"
+	// ObjectLockModeCompliance denotes a retention mode that no user may
+	// override.
+	ObjectLockModeCompliance ObjectLockMode = "COMPLIANCE"
+)
+
+// LockConfig contains all configuration relating to object lock retention.
+type LockConfig struct {
+	Mode      ObjectLockMode `yaml:"mode"`
+	Retention time.Duration  `yaml:"retention"`
+	LegalHold bool           `yaml:"legal_hold"`
+}
+
+// StorageRuleConfig contains the conditions under which a file is stored using
+// a particular storage class and, optionally, chunk size.
+type StorageRuleConfig struct {
+	Glob         string        `yaml:"glob"`
+	MinSize      int64         `yaml:"min_size"`
+	MaxSize      int64         `yaml:"max_size"`
+	MinAge       time.Duration `yaml:"min_age"`
+	MaxAge       time.Duration `yaml:"max_age"`
+	StorageClass StorageClass  `yaml:"storage_class"`
+	ChunkSize    int64         `yaml:"chunk_size"`
+}
+
+// PackConfig contains all configuration relating to bundling small files into
+// pack objects.
+type PackConfig struct {
+	Threshold  int64         `yaml:"threshold"`
+	TargetSize int64         `yaml:"target_size"`
+	MaxDelay   time.Duration `yaml:"max_delay"`
+}
+
+// Driver returns the name of the database driver used to open the registry,
+// along with the data source name to pass to it.
+func (c *RegConfig) Driver() (string, string) {
+	if path := strings.TrimPrefix(c.Location, sqliteScheme); path != c.Location {
+		return RegistryDriverSQLite, path
+	}
+	return RegistryDriverPostgres, c.Location
+}
+
+// ToInternal converts the YAML representation of a key layout to the
+// equivalent internal representation.
+func (l KeyLayout) ToInternal() keygen.Layout {
+	switch l {
+	case KeyLayoutUUID, "":
+		return keygen.LayoutUUID
+	case KeyLayoutMirror:
+		return keygen.LayoutMirror
+	case KeyLayoutHost:
+		return keygen.LayoutHost
+	case KeyLayoutDate:
+		return keygen.LayoutDate
+	case KeyLayoutHashed:
+		return keygen.LayoutHashed
+	default:
+		return keygen.Layout(l)
+	}
+}
+
+// ToInternal converts the YAML representation of a storage rule to the
+// equivalent internal representation. An error is returned if the rule's glob
+// is malformed, if its storage class is unknown or if its chunk size is
+// negative or below the smallest part size accepted by the storage backend.
+func (c *StorageRuleConfig) ToInternal() (store.StorageRule, error) {
+	if _, err := path.Match(c.Glob, ""); err != nil {
+		return store.StorageRule{}, fmt.Errorf("invalid storage rule glob %q: %w", c.Glob, err)
+	}
+	if err := c.StorageClass.validate(); err != nil {
+		return store.StorageRule{}, err
+	}
+	if c.ChunkSize < 0 || (c.ChunkSize > 0 && c.ChunkSize < store.MinChunkSize) {
+		return store.StorageRule{}, fmt.Errorf(
+			"storage rule chunk size %d must be at least %d bytes",
+			c.ChunkSize, store.MinChunkSize,
+		)
+	}
+
+	return store.StorageRule{
+		Glob:         c.Glob,
+		MinSize:      c.MinSize,
+		MaxSize:      c.MaxSize,
+		MinAge:       c.MinAge,
+		MaxAge:       c.MaxAge,
+		StorageClass: c.StorageClass.ToInternal(),
+		ChunkSize:    c.ChunkSize,
+	}, nil
+}
+
+// ToInternal converts the YAML representation of the object lock
+// configuration to the equivalent internal representation. An error is
+// returned if the mode is unrecognised or is set without a retention period.
+func (c *LockConfig) ToInternal() (store.ObjectLock, error) {
+	lock := store.ObjectLock{
+		Retention: c.Retention,
+		LegalHold: c.LegalHold,
+	}
+
+	switch c.Mode {
+	case ObjectLockModeGovernance:
+		lock.Mode = types.ObjectLockModeGovernance
+	case ObjectLockModeCompliance:
+		lock.Mode = types.ObjectLockModeCompliance
+	case "":
+		return lock, nil
+	default:
+		return store.ObjectLock{}, fmt.Errorf("unknown object lock mode %q", c.Mode)
+	}
+
+	if c.Retention <= 0 {
+		return store.ObjectLock{}, errors.New("object lock mode requires a positive retention period")
+	}
+
+	return lock, nil
+}
+
+// ToInternal converts the YAML representation of the packing configuration to
+// the equivalent set of packer options.
+func (c *PackConfig) ToInternal() []store.PackerOption {
+	var opts []store.PackerOption
+	if c.Threshold > 0 {
+		opts = append(opts, store.WithPackThreshold(c.Threshold))
+	}
+	if c.TargetSize > 0 {
+		opts = append(opts, store.WithPackTargetSize(c.TargetSize))
+	}
+	if c.MaxDelay > 0 {
+		opts = append(opts, store.WithPackMaxDelay(c.MaxDelay))
+	}
+	return opts
+}
+
+// ToInternal converts the YAML representation of a batching configuration to
+// the equivalent set of batch writer options. The batch size is capped at the
+// provided number of files that may be registered at once, since a batch that
+// cannot fill is only written once the maximum delay has elapsed.
+func (c *BatchConfig) ToInternal(concurrency int) []db.BatchWriterOption {
+	size := c.Size
+	if size <= 0 || size > concurrency {
+		size = concurrency
+	}
+	opts := []db.BatchWriterOption{db.WithBatchSize(size)}
+	if c.MaxDelay > 0 {
+		opts = append(opts, db.WithBatchMaxDelay(c.MaxDelay))
+	}
+	return opts
 }
 
 // ToInternal converts the YAML representation of a log level to the equivalent
@@ -128,6 +486,90 @@ func (a ChecksumAlgorithm) ToInternal() store.ChecksumAlgorithm {
 	}
 }
 
+// ToInternal converts the YAML representation of a hash algorithm to the
+// equivalent internal representation. An error is returned if the algorithm is
+// not recognised.
+func (a HashAlgorithm) ToInternal() (checksum.Algorithm, error) {
+	switch a {
+	case HashAlgorithmCRC32, "":
+		return checksum.AlgorithmCRC32, nil
+	case HashAlgorithmXXHash64:
+		return checksum.AlgorithmXXHash64, nil
+	case HashAlgorithmBLAKE3:
+		return checksum.AlgorithmBLAKE3, nil
+	case HashAlgorithmSHA256:
+		return checksum.AlgorithmSHA256, nil
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q", a)
+	}
+}
+
+// ToInternal converts the YAML representation of a change detection policy to
+// the equivalent internal representation. An error is returned if the policy
+// is not recognised.
+func (d ChangeDetection) ToInternal() (processor.ChangeDetection, error) {
+	switch d {
+	case ChangeDetectionCTimeThenChecksum, "":
+		return processor.ChangeDetectionCTimeThenChecksum, nil
+	case ChangeDetectionCTime:
+		return processor.ChangeDetectionCTime, nil
+	case ChangeDetectionMTimeSize:
+		return processor.ChangeDetectionMTimeSize, nil
+	case ChangeDetectionAlwaysChecksum:
+		return processor.ChangeDetectionAlwaysChecksum, nil
+	case ChangeDetectionChecksumEveryNRuns:
+		return processor.ChangeDetectionChecksumEveryNRuns, nil
+	default:
+		return "", fmt.Errorf("unknown change detection policy %q", d)
+	}
+}
+
+// ToInternal converts the YAML representation of an inconsistent file policy to
+// the equivalent internal representation. An error is returned if the policy
+// is not recognised.
+func (p InconsistentPolicy) ToInternal() (processor.InconsistentPolicy, error) {
+	switch p {
+	case InconsistentPolicyMark, "":
+		return processor.InconsistentPolicyMark, nil
+	case InconsistentPolicySkip:
+		return processor.InconsistentPolicySkip, nil
+	default:
+		return "", fmt.Errorf("unknown inconsistent file policy %q", p)
+	}
+}
+
+// ToInternal converts the YAML representation of a hook to the equivalent
+// internal representation. A nil hook converts to a nil hook. An error is
+// returned if the failure policy is not recognised.
+func (c *HookConfig) ToInternal() (*hook.Hook, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	h := &hook.Hook{
+		Command: c.Command,
+		Timeout: c.Timeout,
+	}
+
+	switch c.OnFailure {
+	case HookFailurePolicySkip, "":
+		h.OnFailure = hook.FailurePolicySkip
+	case HookFailurePolicyContinue:
+		h.OnFailure = hook.FailurePolicyContinue
+	default:
+		return nil, fmt.Errorf("unknown hook failure policy %q", c.OnFailure)
+	}
+
+	return h, nil
+}
+
+func (c StorageClass) validate() error {
+	if c != "" && c.ToInternal() == "" {
+		return fmt.Errorf("unknown storage class %q", c)
+	}
+	return nil
+}
+
 // ToInternal converts the YAML represetnation of a storage class to the
 // equivalent internal represenation.
 func (c StorageClass) ToInternal() store.StorageClass {
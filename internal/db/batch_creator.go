@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const createFilesPrefix = `-- name: CreateFiles :many
+INSERT INTO files.files (
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+) VALUES
+`
+
+const createFilesSuffix = `
+RETURNING id, key, local_path, checksum, change_time, bucket, etag, version, pack_id, pack_offset, pack_length, key_layout, storage_class, retain_until, legal_hold, checksum_algorithm, size, modify_time, inconsistent, metadata, file_type, link_target, device_major, device_minor, extents, created_at_timestamp
+`
+
+const (
+	fileRowColumns = 26
+	// MaxBatchRows is the largest number of rows inserted by a single
+	// statement, which keeps the number of query parameters well below the
+	// PostgreSQL limit of 65535.
+	MaxBatchRows = 1000
+)
+
+// BatchCreatorTx provides the logic to insert several files into a database
+// with a single statement within a transaction.
+type BatchCreatorTx struct{}
+
+// NewBatchCreatorTx instantiates a new BatchCreatorTx instance.
+func NewBatchCreatorTx() *BatchCreatorTx {
+	return &BatchCreatorTx{}
+}
+
+// CreateBatch inserts the provided rows into the database using the provided
+// transaction. The inserted rows are returned in the same order as the
+// provided rows. Batches larger than MaxBatchRows are inserted using several
+// statements.
+func (c *BatchCreatorTx) CreateBatch(
+	ctx context.Context,
+	tx Tx,
+	files []*FileRow,
+) ([]*FileRow, error) {
+
+	inserted := make([]*FileRow, 0, len(files))
+	for start := 0; start < len(files); start += MaxBatchRows {
+		end := start + MaxBatchRows
+		if end > len(files) {
+			end = len(files)
+		}
+
+		rows, err := c.insert(ctx, tx, files[start:end])
+		if err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, rows...)
+	}
+
+	return inserted, nil
+}
+
+func (c *BatchCreatorTx) insert(ctx context.Context, tx Tx, files []*FileRow) ([]*FileRow, error) {
+	var query strings.Builder
+	query.WriteString(createFilesPrefix)
+	args := make([]interface{}, 0, len(files)*fileRowColumns)
+
+	for i, file := range files {
+		if i > 0 {
+			query.WriteString(",\n")
+		}
+		query.WriteString("\t(")
+		for j := 0; j < fileRowColumns; j++ {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "$%d", i*fileRowColumns+j+1)
+		}
+		query.WriteString(")")
+
+		args = append(
+			args,
+			file.ID,
+			file.Key,
+			file.LocalPath,
+			file.Checksum,
+			file.CTime,
+			file.Bucket,
+			file.ETag,
+			file.Version,
+			file.PackID,
+			file.PackOffset,
+			file.PackLength,
+			file.KeyLayout,
+			file.StorageClass,
+			file.RetainUntil,
+			file.LegalHold,
+			file.ChecksumAlgorithm,
+			file.Size,
+			file.MTime,
+			file.Inconsistent,
+			file.Metadata,
+			file.FileType,
+			file.LinkTarget,
+			file.DeviceMajor,
+			file.DeviceMinor,
+			file.Extents,
+			file.CreatedAtTimestamp,
+		)
+	}
+	query.WriteString(createFilesSuffix)
+
+	rows, err := tx.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// PostgreSQL makes no promise about the order of the rows returned by a
+	// multi-row insert, so they are matched to the inputs using their IDs.
+	byID := make(map[string]*FileRow, len(files))
+	for rows.Next() {
+		var row FileRow
+		if err := rows.Scan(
+			&row.ID,
+			&row.Key,
+			&row.LocalPath,
+			&row.Checksum,
+			&row.CTime,
+			&row.Bucket,
+			&row.ETag,
+			&row.Version,
+			&row.PackID,
+			&row.PackOffset,
+			&row.PackLength,
+			&row.KeyLayout,
+			&row.StorageClass,
+			&row.RetainUntil,
+			&row.LegalHold,
+			&row.ChecksumAlgorithm,
+			&row.Size,
+			&row.MTime,
+			&row.Inconsistent,
+			&row.Metadata,
+			&row.FileType,
+			&row.LinkTarget,
+			&row.DeviceMajor,
+			&row.DeviceMinor,
+			&row.Extents,
+			&row.CreatedAtTimestamp,
+		); err != nil {
+			return nil, err
+		}
+		byID[row.ID] = &row
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	inserted := make([]*FileRow, len(files))
+	for i, file := range files {
+		row, ok := byID[file.ID]
+		if !ok {
+			return nil, fmt.Errorf("no row returned for file %q", file.LocalPath)
+		}
+		inserted[i] = row
+	}
+
+	return inserted, nil
+}
@@ -0,0 +1,202 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const batchInsertPrefix = `
+INSERT INTO files.files \(
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+\) VALUES
+`
+
+const batchInsertSuffix = `
+RETURNING id, key, local_path, checksum, change_time, bucket, etag, version, pack_id, pack_offset, pack_length, key_layout, storage_class, retain_until, legal_hold, checksum_algorithm, size, modify_time, inconsistent, metadata, file_type, link_target, device_major, device_minor, extents, created_at_timestamp
+`
+
+type BatchCreatorTestSuite struct {
+	dbTestSuite
+}
+
+func TestBatchCreatorTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchCreatorTestSuite))
+}
+
+func (s *BatchCreatorTestSuite) TestCreateBatch() {
+	fileRows := []*db.FileRow{
+		{
+			ID:                 "some-id",
+			Key:                "some-key",
+			LocalPath:          "/some/path",
+			Bucket:             "some-bucket",
+			Metadata:           db.Metadata(`{"mode":420}`),
+			CreatedAtTimestamp: time.Unix(1, 0).UTC(),
+		},
+		{
+			ID:                 "other-id",
+			Key:                "other-key",
+			LocalPath:          "/some/other/path",
+			Bucket:             "some-bucket",
+			Metadata:           db.Metadata(`{"mode":420}`),
+			CreatedAtTimestamp: time.Unix(1, 0).UTC(),
+		},
+	}
+
+	s.Run("inserts provided rows in one statement", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(rows, fileRows[1], fileRows[0])
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(batchInsertQuery(2)).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		creator := db.NewBatchCreatorTx()
+
+		var insertedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRows, err = creator.CreateBatch(context.Background(), tx, fileRows)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRows, insertedRows)
+		s.NoError(mock.ExpectationsWereMet())
+	})
+
+	s.Run("splits large batches across statements", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		manyRows := make([]*db.FileRow, db.MaxBatchRows+1)
+		firstRows := sqlmock.NewRows(insertRows)
+		for i := range manyRows {
+			manyRows[i] = &db.FileRow{ID: fmt.Sprintf("id-%d", i), Metadata: db.Metadata(`{}`)}
+			if i < db.MaxBatchRows {
+				addFileRowsToRows(firstRows, manyRows[i])
+			}
+		}
+		lastRows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(lastRows, manyRows[db.MaxBatchRows])
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(batchInsertQuery(db.MaxBatchRows)).WillReturnRows(firstRows)
+		mock.ExpectQuery(batchInsertQuery(1)).WillReturnRows(lastRows)
+		mock.ExpectCommit()
+
+		creator := db.NewBatchCreatorTx()
+
+		var insertedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRows, err = creator.CreateBatch(context.Background(), tx, manyRows)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(manyRows, insertedRows)
+		s.NoError(mock.ExpectationsWereMet())
+	})
+
+	s.Run("handles missing returned row", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(rows, fileRows[0])
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(batchInsertQuery(2)).WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		creator := db.NewBatchCreatorTx()
+
+		var insertedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRows, err = creator.CreateBatch(context.Background(), tx, fileRows)
+			return err
+		})
+
+		s.ErrorContains(err, "/some/other/path")
+		s.Nil(insertedRows)
+	})
+
+	s.Run("handles error from query", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		expectedErr := errors.New("oh no")
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(batchInsertQuery(2)).WillReturnError(expectedErr)
+		mock.ExpectRollback()
+
+		creator := db.NewBatchCreatorTx()
+
+		var insertedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRows, err = creator.CreateBatch(context.Background(), tx, fileRows)
+			return err
+		})
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(insertedRows)
+	})
+}
+
+func batchInsertQuery(numRows int) string {
+	values := make([]string, numRows)
+	for i := range values {
+		params := make([]string, 26)
+		for j := range params {
+			params[j] = fmt.Sprintf(`\$%d`, i*26+j+1)
+		}
+		values[i] = `\(` + strings.Join(params, ", ") + `\)`
+	}
+	return batchInsertPrefix + strings.Join(values, ",\n") + batchInsertSuffix
+}
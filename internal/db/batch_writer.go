@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+//go:generate mockgen -destination=./mocks/batch_writer.go -package=mocks -source=$GOFILE
+
+const (
+	defaultBatchSize     = 500
+	defaultBatchMaxDelay = time.Second
+)
+
+// BatchRegistry defines the interface required of a registry whose file
+// creations are batched.
+type BatchRegistry interface {
+	Create(ctx context.Context, file *processor.File) (*processor.File, error)
+	CreateBatch(ctx context.Context, files []*processor.File) ([]*processor.File, error)
+	FetchLatest(ctx context.Context, bucket, keyPrefix, path string) (*processor.File, error)
+	FetchLatestBySize(
+		ctx context.Context,
+		bucket, keyPrefix string,
+		size int64,
+	) ([]*processor.File, error)
+	StreamLatest(
+		ctx context.Context,
+		bucket, keyPrefix string,
+		since time.Time,
+		fn func(*processor.File) error,
+	) error
+}
+
+// BatchWriterOption is the type used to implement the functional options
+// pattern for the BatchWriter type.
+type BatchWriterOption func(*BatchWriter)
+
+// BatchWriter groups the files created through it into batches, registering
+// each batch within a single transaction. A batch is written once it reaches
+// the configured size or once the maximum delay has elapsed since its first
+// file was added, whichever comes first.
+//
+// Each call to Create blocks until the batch holding its file has been
+// committed, so a file is never reported as registered before its row is
+// durable. If a batch cannot be written, its files are registered one at a
+// time so that a single bad row does not fail the rest of the batch, and each
+// caller receives the error for its own file. All other calls are passed
+// straight through to the underlying registry.
+type BatchWriter struct {
+	BatchRegistry
+	size     int
+	maxDelay time.Duration
+	log      *zap.SugaredLogger
+	mu       sync.Mutex
+	current  *batch
+}
+
+type batch struct {
+	files   []*processor.File
+	timer   *time.Timer
+	once    sync.Once
+	done    chan struct{}
+	created []*processor.File
+	errs    []error
+}
+
+// NewBatchWriter instantiates a new BatchWriter that registers files using the
+// provided registry.
+func NewBatchWriter(registry BatchRegistry, opts ...BatchWriterOption) *BatchWriter {
+	w := &BatchWriter{
+		BatchRegistry: registry,
+		size:          defaultBatchSize,
+		maxDelay:      defaultBatchMaxDelay,
+		log:           util.MustNewLogger(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// WithBatchSize returns an option that sets the number of files at which a
+// batch is considered full and is written. Since each call to Create blocks
+// until its batch is committed, the size should not exceed the number of
+// callers that may create files at once, or batches will only ever be written
+// once the maximum delay has elapsed.
+func WithBatchSize(size int) BatchWriterOption {
+	return func(w *BatchWriter) {
+		w.size = size
+	}
+}
+
+// WithBatchMaxDelay returns an option that sets the maximum time a batch may
+// remain open after its first file is added before it is written, regardless
+// of its size.
+func WithBatchMaxDelay(delay time.Duration) BatchWriterOption {
+	return func(w *BatchWriter) {
+		w.maxDelay = delay
+	}
+}
+
+// Create adds the provided file to the currently open batch and blocks until
+// that batch has been committed.
+func (w *BatchWriter) Create(ctx context.Context, file *processor.File) (*processor.File, error) {
+	b, i := w.add(file)
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if b.errs != nil && b.errs[i] != nil {
+		return nil, b.errs[i]
+	}
+
+	return b.created[i], nil
+}
+
+func (w *BatchWriter) add(file *processor.File) (*batch, int) {
+	w.mu.Lock()
+
+	b := w.current
+	if b == nil {
+		b = w.newBatch()
+		w.current = b
+	}
+
+	i := len(b.files)
+	b.files = append(b.files, file)
+
+	full := len(b.files) >= w.size
+	if full {
+		w.current = nil
+	}
+
+	w.mu.Unlock()
+
+	if full {
+		w.flush(b)
+	}
+
+	return b, i
+}
+
+func (w *BatchWriter) newBatch() *batch {
+	b := &batch{done: make(chan struct{})}
+	b.timer = time.AfterFunc(w.maxDelay, func() {
+		w.seal(b)
+		w.flush(b)
+	})
+
+	return b
+}
+
+func (w *BatchWriter) seal(b *batch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.current == b {
+		w.current = nil
+	}
+}
+
+func (w *BatchWriter) flush(b *batch) {
+	b.once.Do(func() {
+		defer close(b.done)
+
+		b.timer.Stop()
+
+		// The batch is shared by several callers, so a single caller's context
+		// must not be able to abort its commit.
+		ctx := context.Background()
+
+		var err error
+		b.created, err = w.CreateBatch(ctx, b.files)
+		if err == nil {
+			return
+		}
+
+		w.log.Warnw(
+			"Unable to register batch, registering files individually",
+			"num_files", len(b.files),
+			"error", err,
+		)
+
+		b.created = make([]*processor.File, len(b.files))
+		b.errs = make([]error, len(b.files))
+		for i, file := range b.files {
+			b.created[i], b.errs[i] = w.BatchRegistry.Create(ctx, file)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/mocks"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+type BatchWriterTestSuite struct {
+	suite.Suite
+	controller   *gomock.Controller
+	mockRegistry *mocks.MockBatchRegistry
+}
+
+func TestBatchWriterTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchWriterTestSuite))
+}
+
+func (s *BatchWriterTestSuite) SetupTest() {
+	s.controller = gomock.NewController(s.T())
+	s.mockRegistry = mocks.NewMockBatchRegistry(s.controller)
+}
+
+func (s *BatchWriterTestSuite) TestCreate() {
+	ctx := context.Background()
+	files := []*processor.File{
+		{LocalPath: "foo"},
+		{LocalPath: "bar"},
+	}
+
+	s.Run("writes batch once full", func() {
+		s.mockRegistry.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Len(2)).
+			DoAndReturn(func(ctx context.Context, files []*processor.File) ([]*processor.File, error) {
+				return created(files), nil
+			})
+
+		writer := db.NewBatchWriter(
+			s.mockRegistry, db.WithBatchSize(2), db.WithBatchMaxDelay(time.Hour),
+		)
+
+		results, errs := s.createAll(ctx, writer, files)
+
+		for i, file := range files {
+			s.Require().NoError(errs[i])
+			s.Equal(file.LocalPath, results[i].LocalPath)
+			s.Equal("created", results[i].Version)
+		}
+	})
+	s.Run("writes batch after delay", func() {
+		s.mockRegistry.EXPECT().
+			CreateBatch(gomock.Any(), []*processor.File{files[0]}).
+			Return(created(files[:1]), nil)
+
+		writer := db.NewBatchWriter(
+			s.mockRegistry, db.WithBatchSize(10), db.WithBatchMaxDelay(10*time.Millisecond),
+		)
+
+		result, err := writer.Create(ctx, files[0])
+
+		s.Require().NoError(err)
+		s.Equal(created(files[:1])[0], result)
+	})
+	s.Run("reports errors per file when batch fails", func() {
+		expectedErr := errors.New("oh no")
+
+		s.mockRegistry.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Len(2)).
+			Return(nil, errors.New("batch failed"))
+		s.mockRegistry.EXPECT().
+			Create(gomock.Any(), files[0]).
+			Return(created(files[:1])[0], nil)
+		s.mockRegistry.EXPECT().
+			Create(gomock.Any(), files[1]).
+			Return(nil, expectedErr)
+
+		writer := db.NewBatchWriter(
+			s.mockRegistry, db.WithBatchSize(2), db.WithBatchMaxDelay(time.Hour),
+		)
+
+		results, errs := s.createAll(ctx, writer, files)
+
+		s.Require().NoError(errs[0])
+		s.Equal(created(files[:1])[0], results[0])
+		s.ErrorIs(errs[1], expectedErr)
+		s.Nil(results[1])
+	})
+	s.Run("returns when context is done", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		s.mockRegistry.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Any()).
+			Return(created(files[:1]), nil).
+			AnyTimes()
+
+		writer := db.NewBatchWriter(
+			s.mockRegistry, db.WithBatchSize(10), db.WithBatchMaxDelay(time.Millisecond),
+		)
+
+		result, err := writer.Create(ctx, files[0])
+
+		s.ErrorIs(err, context.Canceled)
+		s.Nil(result)
+		time.Sleep(10 * time.Millisecond)
+	})
+}
+
+func (s *BatchWriterTestSuite) createAll(
+	ctx context.Context,
+	writer *db.BatchWriter,
+	files []*processor.File,
+) ([]*processor.File, []error) {
+
+	results := make([]*processor.File, len(files))
+	errs := make([]error, len(files))
+
+	wg := &sync.WaitGroup{}
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file *processor.File) {
+			defer wg.Done()
+			results[i], errs[i] = writer.Create(ctx, file)
+		}(i, file)
+	}
+	wg.Wait()
+
+	return results, errs
+}
+
+func created(files []*processor.File) []*processor.File {
+	result := make([]*processor.File, len(files))
+	for i, file := range files {
+		copied := *file
+		copied.Version = "created"
+		result[i] = &copied
+	}
+	return result
+}
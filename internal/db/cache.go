@@ -0,0 +1,311 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+//go:generate mockgen -destination=./mocks/cache.go -package=mocks -source=$GOFILE
+
+const (
+	defaultRefreshInterval = time.Minute
+	// refreshOverlap is how far before the previous refresh each refresh
+	// reaches back, to allow for clock skew between writers and for versions
+	// created in transactions that were still in flight.
+	refreshOverlap = time.Minute
+)
+
+// CachedRegistry defines the interface required of a registry whose latest
+// file versions are cached.
+type CachedRegistry interface {
+	Create(ctx context.Context, file *processor.File) (*processor.File, error)
+	FetchLatest(ctx context.Context, bucket, keyPrefix, path string) (*processor.File, error)
+	FetchLatestBySize(
+		ctx context.Context,
+		bucket, keyPrefix string,
+		size int64,
+	) ([]*processor.File, error)
+	StreamLatest(
+		ctx context.Context,
+		bucket, keyPrefix string,
+		since time.Time,
+		fn func(*processor.File) error,
+	) error
+}
+
+// LatestCacheOption is the type used to implement the functional options
+// pattern for the LatestCache type.
+type LatestCacheOption func(*LatestCache)
+
+// LatestCache holds the latest version of every file stored for a directory in
+// memory, so that scanning it does not require a query per file. A directory's
+// files are those stored in its bucket under keys beginning with its key
+// prefix.
+//
+// The cache is loaded with a single query and kept fresh as follows. Versions
+// created through the cache are stored in it immediately. Once the refresh
+// interval has elapsed, the next lookup fetches every version created since
+// shortly before the previous refresh, so that versions registered by other
+// writers are never more than one interval out of date. Versions are streamed
+// without holding the cache's lock, so lookups are served from the cache as it
+// stands while a refresh runs. Once the cache is loaded, paths missing from it
+// are treated as having no previous version, and are otherwise looked up
+// individually.
+//
+// The cached files are also indexed by size, so that the candidates for a
+// renamed file can be found without a query per new file.
+type LatestCache struct {
+	registry        CachedRegistry
+	bucket          string
+	keyPrefix       string
+	clock           Clock
+	refreshInterval time.Duration
+	refreshMu       sync.Mutex
+	mu              sync.Mutex
+	files           map[string]*processor.File
+	sizes           map[int64]map[string]bool
+	loaded          bool
+	refreshedAt     time.Time
+	refreshing      bool
+	created         map[string]bool
+}
+
+// NewLatestCache instantiates a new, empty LatestCache for the provided
+// bucket.
+func NewLatestCache(
+	registry CachedRegistry,
+	bucket string,
+	opts ...LatestCacheOption,
+) *LatestCache {
+
+	c := &LatestCache{
+		registry:        registry,
+		bucket:          bucket,
+		clock:           &util.Clock{},
+		refreshInterval: defaultRefreshInterval,
+		files:           make(map[string]*processor.File),
+		sizes:           make(map[int64]map[string]bool),
+		created:         make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithCacheClock returns an option that sets the clock a LatestCache uses to
+// decide when to refresh.
+func WithCacheClock(clock Clock) LatestCacheOption {
+	return func(c *LatestCache) {
+		c.clock = clock
+	}
+}
+
+// WithRefreshInterval returns an option that sets how often a LatestCache
+// fetches versions created by other writers.
+func WithRefreshInterval(interval time.Duration) LatestCacheOption {
+	return func(c *LatestCache) {
+		c.refreshInterval = interval
+	}
+}
+
+// WithCacheKeyPrefix returns an option that restricts the files a LatestCache
+// holds to those with keys beginning with the provided prefix, which are those
+// stored for the same directory.
+func WithCacheKeyPrefix(prefix string) LatestCacheOption {
+	return func(c *LatestCache) {
+		c.keyPrefix = prefix
+	}
+}
+
+// Load fills the cache with the latest version of every file in its bucket
+// under its key prefix, discarding anything it held before. The versions are gathered before the
+// cache is replaced, so Load should not be called while versions are being
+// created through the cache.
+func (c *LatestCache) Load(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	now := c.clock.Now()
+	loaded := NewLatestCache(c.registry, c.bucket, WithCacheKeyPrefix(c.keyPrefix))
+	err := c.registry.StreamLatest(ctx, c.bucket, c.keyPrefix, time.Time{}, func(file *processor.File) error {
+		loaded.put(file)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.files = loaded.files
+	c.sizes = loaded.sizes
+	c.created = make(map[string]bool)
+	c.loaded = true
+	c.refreshedAt = now
+
+	return nil
+}
+
+// FetchLatest returns the latest version of the file with the provided path,
+// from the cache if possible.
+func (c *LatestCache) FetchLatest(ctx context.Context, path string) (*processor.File, error) {
+	if err := c.refreshIfDue(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	file, ok := c.files[path]
+	loaded := c.loaded
+	c.mu.Unlock()
+
+	if ok {
+		copied := *file
+		return &copied, nil
+	}
+	if loaded {
+		return nil, nil
+	}
+
+	file, err := c.registry.FetchLatest(ctx, c.bucket, c.keyPrefix, path)
+	if err != nil {
+		return nil, err
+	}
+	if file != nil {
+		c.store(file)
+	}
+
+	return file, nil
+}
+
+// FetchLatestBySize returns the latest versions of the files in the provided
+// bucket with the provided size, ordered by path. Only files with keys
+// beginning with the cache's key prefix are returned from its own bucket, and
+// once the cache is loaded these are served from the cache. Other files are
+// fetched from the registry.
+func (c *LatestCache) FetchLatestBySize(
+	ctx context.Context,
+	bucket string,
+	size int64,
+) ([]*processor.File, error) {
+
+	c.mu.Lock()
+	if bucket != c.bucket {
+		c.mu.Unlock()
+		return c.registry.FetchLatestBySize(ctx, bucket, "", size)
+	}
+	if !c.loaded {
+		c.mu.Unlock()
+		return c.registry.FetchLatestBySize(ctx, bucket, c.keyPrefix, size)
+	}
+
+	var files []*processor.File
+	for path := range c.sizes[size] {
+		copied := *c.files[path]
+		files = append(files, &copied)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].LocalPath < files[j].LocalPath
+	})
+
+	return files, nil
+}
+
+// Create registers the provided file and stores the created version in the
+// cache.
+func (c *LatestCache) Create(ctx context.Context, file *processor.File) (*processor.File, error) {
+	created, err := c.registry.Create(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if created.Bucket == c.bucket && strings.HasPrefix(created.Key, c.keyPrefix) {
+		c.store(created)
+	}
+
+	return created, nil
+}
+
+// refreshIfDue fetches the versions created since shortly before the previous
+// refresh, if the refresh interval has elapsed and no other refresh is running.
+// The versions are streamed without holding the cache's lock and stored once
+// the stream completes, except for paths with versions created through the
+// cache in the meantime, which are at least as recent.
+func (c *LatestCache) refreshIfDue(ctx context.Context) error {
+	c.mu.Lock()
+	now := c.clock.Now()
+	if c.refreshing || now.Sub(c.refreshedAt) < c.refreshInterval {
+		c.mu.Unlock()
+		return nil
+	}
+	since := time.Time{}
+	if !c.refreshedAt.IsZero() {
+		since = c.refreshedAt.Add(-refreshOverlap)
+	}
+	c.refreshing = true
+	c.mu.Unlock()
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	var files []*processor.File
+	err := c.registry.StreamLatest(ctx, c.bucket, c.keyPrefix, since, func(file *processor.File) error {
+		files = append(files, file)
+		return nil
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refreshing = false
+	if err != nil {
+		c.created = make(map[string]bool)
+		return err
+	}
+	for _, file := range files {
+		if !c.created[file.LocalPath] {
+			c.put(file)
+		}
+	}
+	c.created = make(map[string]bool)
+	c.refreshedAt = now
+
+	return nil
+}
+
+func (c *LatestCache) store(file *processor.File) {
+	copied := *file
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.put(&copied)
+	if c.refreshing {
+		c.created[file.LocalPath] = true
+	}
+}
+
+// put stores the provided file in the cache and its size index. The caller must
+// hold the cache's lock.
+func (c *LatestCache) put(file *processor.File) {
+	if prev, ok := c.files[file.LocalPath]; ok {
+		delete(c.sizes[prev.Size], file.LocalPath)
+	}
+	c.files[file.LocalPath] = file
+
+	paths, ok := c.sizes[file.Size]
+	if !ok {
+		paths = make(map[string]bool)
+		c.sizes[file.Size] = paths
+	}
+	paths[file.LocalPath] = true
+}
@@ -0,0 +1,298 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/mocks"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+type LatestCacheTestSuite struct {
+	suite.Suite
+	controller   *gomock.Controller
+	mockRegistry *mocks.MockCachedRegistry
+}
+
+func TestLatestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(LatestCacheTestSuite))
+}
+
+func (s *LatestCacheTestSuite) SetupTest() {
+	s.controller = gomock.NewController(s.T())
+	s.mockRegistry = mocks.NewMockCachedRegistry(s.controller)
+}
+
+func (s *LatestCacheTestSuite) TestFetchLatest() {
+	ctx := context.Background()
+	bucket := "some-bucket"
+	start := time.Unix(1000, 0)
+	file := &processor.File{LocalPath: "foo", Bucket: bucket, Version: "1"}
+
+	s.Run("serves loaded files without querying", func() {
+		now := start
+		clock := fakeClock(func() time.Time { return now })
+
+		s.expectStream(bucket, "", time.Time{}, nil, file)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+		s.Require().NoError(cache.Load(ctx))
+
+		fetched, err := cache.FetchLatest(ctx, "foo")
+
+		s.Require().NoError(err)
+		s.Equal(file, fetched)
+		s.NotSame(file, fetched)
+	})
+	s.Run("treats missing files as new once loaded", func() {
+		now := start
+		clock := fakeClock(func() time.Time { return now })
+
+		s.expectStream(bucket, "", time.Time{}, nil, file)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+		s.Require().NoError(cache.Load(ctx))
+
+		fetched, err := cache.FetchLatest(ctx, "bar")
+
+		s.Require().NoError(err)
+		s.Nil(fetched)
+	})
+	s.Run("fetches missing files individually before loading", func() {
+		now := start
+		clock := fakeClock(func() time.Time { return now })
+		other := &processor.File{LocalPath: "bar", Bucket: bucket}
+
+		s.expectStream(bucket, "", time.Time{}, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, bucket, "", "bar").Return(other, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, bucket, "", "baz").Return(nil, nil).Times(2)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+
+		fetched, err := cache.FetchLatest(ctx, "bar")
+		s.Require().NoError(err)
+		s.Equal(other, fetched)
+		fetched, err = cache.FetchLatest(ctx, "bar")
+		s.Require().NoError(err)
+		s.Equal(other, fetched)
+
+		for i := 0; i < 2; i++ {
+			fetched, err = cache.FetchLatest(ctx, "baz")
+			s.Require().NoError(err)
+			s.Nil(fetched)
+		}
+	})
+	s.Run("refreshes versions created by other writers", func() {
+		now := start
+		clock := fakeClock(func() time.Time { return now })
+		updated := &processor.File{LocalPath: "foo", Bucket: bucket, Version: "2"}
+
+		s.expectStream(bucket, "", time.Time{}, nil, file)
+		s.expectStream(bucket, "", start.Add(-time.Minute), nil, updated)
+
+		cache := db.NewLatestCache(
+			s.mockRegistry, bucket,
+			db.WithCacheClock(clock),
+			db.WithRefreshInterval(10*time.Second),
+		)
+		s.Require().NoError(cache.Load(ctx))
+
+		now = start.Add(5 * time.Second)
+		fetched, err := cache.FetchLatest(ctx, "foo")
+		s.Require().NoError(err)
+		s.Equal(file, fetched)
+
+		now = start.Add(10 * time.Second)
+		fetched, err = cache.FetchLatest(ctx, "foo")
+		s.Require().NoError(err)
+		s.Equal(updated, fetched)
+	})
+	s.Run("serves cached files while refreshing", func() {
+		var mu sync.Mutex
+		now := start
+		clock := fakeClock(func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		})
+		created := &processor.File{LocalPath: "foo", Bucket: bucket, Version: "3"}
+		streaming := make(chan struct{})
+		resume := make(chan struct{})
+
+		s.expectStream(bucket, "", time.Time{}, nil, file)
+		s.mockRegistry.EXPECT().
+			StreamLatest(gomock.Any(), bucket, "", start.Add(-time.Minute), gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				bucket, keyPrefix string,
+				since time.Time,
+				fn func(*processor.File) error,
+			) error {
+				close(streaming)
+				<-resume
+				return fn(&processor.File{LocalPath: "foo", Bucket: bucket, Version: "2"})
+			})
+		s.mockRegistry.EXPECT().Create(ctx, created).Return(created, nil)
+
+		cache := db.NewLatestCache(
+			s.mockRegistry, bucket,
+			db.WithCacheClock(clock),
+			db.WithRefreshInterval(10*time.Second),
+		)
+		s.Require().NoError(cache.Load(ctx))
+
+		mu.Lock()
+		now = start.Add(10 * time.Second)
+		mu.Unlock()
+		errs := make(chan error)
+		go func() {
+			_, err := cache.FetchLatest(ctx, "bar")
+			errs <- err
+		}()
+		<-streaming
+
+		fetched, err := cache.FetchLatest(ctx, "foo")
+		s.Require().NoError(err)
+		s.Equal(file, fetched)
+		_, err = cache.Create(ctx, created)
+		s.Require().NoError(err)
+
+		close(resume)
+		s.Require().NoError(<-errs)
+
+		fetched, err = cache.FetchLatest(ctx, "foo")
+		s.Require().NoError(err)
+		s.Equal(created, fetched)
+	})
+	s.Run("handles error from refresh", func() {
+		now := start
+		clock := fakeClock(func() time.Time { return now })
+		expectedErr := errors.New("oh no")
+
+		s.expectStream(bucket, "", time.Time{}, expectedErr)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+
+		fetched, err := cache.FetchLatest(ctx, "foo")
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(fetched)
+	})
+}
+
+func (s *LatestCacheTestSuite) TestFetchLatestBySize() {
+	ctx := context.Background()
+	bucket := "some-bucket"
+	clock := fakeClock(func() time.Time { return time.Unix(1000, 0) })
+
+	s.Run("serves files in directory from cache", func() {
+		foo := &processor.File{Key: "dir/foo", LocalPath: "foo", Bucket: bucket, Size: 3}
+		bar := &processor.File{Key: "dir/bar", LocalPath: "bar", Bucket: bucket, Size: 3}
+		larger := &processor.File{Key: "dir/baz", LocalPath: "baz", Bucket: bucket, Size: 4}
+		resized := &processor.File{Key: "dir/qux", LocalPath: "qux", Bucket: bucket, Size: 4}
+
+		s.expectStream(bucket, "dir/", time.Time{}, nil, foo, bar, larger, resized)
+		resized.Size = 3
+		s.mockRegistry.EXPECT().Create(ctx, resized).Return(resized, nil)
+
+		cache := db.NewLatestCache(
+			s.mockRegistry, bucket,
+			db.WithCacheClock(clock),
+			db.WithCacheKeyPrefix("dir/"),
+		)
+		s.Require().NoError(cache.Load(ctx))
+		_, err := cache.Create(ctx, resized)
+		s.Require().NoError(err)
+
+		files, err := cache.FetchLatestBySize(ctx, bucket, 3)
+
+		s.Require().NoError(err)
+		s.Equal([]*processor.File{bar, foo, resized}, files)
+	})
+	s.Run("fetches files in other bucket from registry", func() {
+		file := &processor.File{Key: "foo", LocalPath: "foo", Bucket: "other-bucket", Size: 3}
+
+		s.expectStream(bucket, "", time.Time{}, nil)
+		s.mockRegistry.EXPECT().
+			FetchLatestBySize(ctx, "other-bucket", "", int64(3)).
+			Return([]*processor.File{file}, nil)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+		s.Require().NoError(cache.Load(ctx))
+
+		files, err := cache.FetchLatestBySize(ctx, "other-bucket", 3)
+
+		s.Require().NoError(err)
+		s.Equal([]*processor.File{file}, files)
+	})
+}
+
+func (s *LatestCacheTestSuite) TestCreate() {
+	ctx := context.Background()
+	bucket := "some-bucket"
+	now := time.Unix(1000, 0)
+	clock := fakeClock(func() time.Time { return now })
+
+	s.Run("stores created files", func() {
+		file := &processor.File{LocalPath: "foo", Bucket: bucket, Version: "2"}
+
+		s.expectStream(bucket, "", time.Time{}, nil)
+		s.mockRegistry.EXPECT().Create(ctx, file).Return(file, nil)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+		s.Require().NoError(cache.Load(ctx))
+
+		created, err := cache.Create(ctx, file)
+		s.Require().NoError(err)
+		s.Equal(file, created)
+
+		fetched, err := cache.FetchLatest(ctx, "foo")
+		s.Require().NoError(err)
+		s.Equal(file, fetched)
+	})
+	s.Run("handles error from registry", func() {
+		file := &processor.File{LocalPath: "foo", Bucket: bucket}
+		expectedErr := errors.New("oh no")
+
+		s.mockRegistry.EXPECT().Create(ctx, file).Return(nil, expectedErr)
+
+		cache := db.NewLatestCache(s.mockRegistry, bucket, db.WithCacheClock(clock))
+
+		created, err := cache.Create(ctx, file)
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(created)
+	})
+}
+
+func (s *LatestCacheTestSuite) expectStream(
+	bucket, keyPrefix string,
+	since time.Time,
+	err error,
+	files ...*processor.File,
+) {
+
+	s.mockRegistry.EXPECT().
+		StreamLatest(gomock.Any(), bucket, keyPrefix, since, gomock.Any()).
+		DoAndReturn(func(
+			ctx context.Context,
+			bucket, keyPrefix string,
+			since time.Time,
+			fn func(*processor.File) error,
+		) error {
+			for _, file := range files {
+				copied := *file
+				if err := fn(&copied); err != nil {
+					return err
+				}
+			}
+			return err
+		})
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// CreateBatch inserts the provided files into the registry database within a
+// single transaction, so that either all of them are registered or none are.
+// The created files are returned in the same order as the provided files.
+func (r *Registry) CreateBatch(
+	ctx context.Context,
+	files []*processor.File,
+) ([]*processor.File, error) {
+
+	fileRows := make([]*FileRow, len(files))
+	for i, file := range files {
+		fileRows[i] = newFileRowFromDomain(r.idGen.GenerateID(), file)
+	}
+
+	var createdFileRows []*FileRow
+	err := r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
+		now := r.clock.Now()
+		for _, fileRow := range fileRows {
+			fileRow.CreatedAtTimestamp = now
+		}
+
+		var err error
+		createdFileRows, err = r.batchCreator.CreateBatch(ctx, tx, fileRows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]*processor.File, len(createdFileRows))
+	for i, row := range createdFileRows {
+		created[i] = row.toDomain()
+	}
+
+	return created, nil
+}
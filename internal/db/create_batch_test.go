@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *RegistryTestSuite) TestCreateBatch() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	timestamp := time.Unix(1, 0)
+	clock := fakeClock(func() time.Time { return timestamp })
+
+	ids := []string{"some-id", "other-id"}
+	newIDGen := func() db.IDGenerator {
+		next := 0
+		return fakeIDGenerator(func() string {
+			id := ids[next]
+			next++
+			return id
+		})
+	}
+
+	inputFiles := []*processor.File{
+		{Key: "some-key", LocalPath: "some/path"},
+		{Key: "other-key", LocalPath: "some/other/path"},
+	}
+
+	s.Run("creates file rows in one transaction", func() {
+		expectedFileRows := []*db.FileRow{
+			{
+				ID:                 "some-id",
+				Key:                "some-key",
+				LocalPath:          "some/path",
+				CreatedAtTimestamp: timestamp,
+			},
+			{
+				ID:                 "other-id",
+				Key:                "other-key",
+				LocalPath:          "some/other/path",
+				CreatedAtTimestamp: timestamp,
+			},
+		}
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockBatchCreator.EXPECT().
+			CreateBatch(ctx, gomock.Any(), expectedFileRows).Return(expectedFileRows, nil)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, newIDGen(),
+			db.WithBatchCreator(s.mockBatchCreator),
+		)
+
+		createdFiles, err := registry.CreateBatch(ctx, inputFiles)
+
+		s.Require().NoError(err)
+		s.Equal(inputFiles, createdFiles)
+	})
+
+	s.Run("handles error from batch creator", func() {
+		expectedErr := errors.New("oh no")
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockBatchCreator.EXPECT().
+			CreateBatch(ctx, gomock.Any(), gomock.Any()).Return(nil, expectedErr)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, newIDGen(),
+			db.WithBatchCreator(s.mockBatchCreator),
+		)
+
+		createdFiles, err := registry.CreateBatch(ctx, inputFiles)
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(createdFiles)
+	})
+}
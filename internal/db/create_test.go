@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/metadata"
 	"github.com/mspraggs/hoard/internal/processor"
 )
 
@@ -45,6 +46,99 @@ func (s *RegistryTestSuite) TestCreate() {
 		s.Equal(expectedFile, createdFile)
 	})
 
+	s.Run("encodes and decodes metadata", func() {
+		timestamp := time.Unix(1, 0)
+		md := &metadata.Metadata{
+			Mode:   0644,
+			UID:    1000,
+			User:   "someone",
+			MTime:  time.Unix(2, 0).UTC(),
+			Xattrs: map[string][]byte{"user.hoard": []byte("value")},
+		}
+		inputFile := &processor.File{
+			Key:      key,
+			Metadata: md,
+		}
+
+		clock := fakeClock(func() time.Time { return timestamp })
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockCreator.EXPECT().
+			Create(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, tx db.Tx, row *db.FileRow) (*db.FileRow, error) {
+				s.JSONEq(
+					`{"mode":420,"uid":1000,"gid":0,"user":"someone","mtime":"1970-01-01T00:00:02Z",`+
+						`"atime":"0001-01-01T00:00:00Z","xattrs":{"user.hoard":"dmFsdWU="}}`,
+					string(row.Metadata),
+				)
+				return row, nil
+			})
+
+		registry := db.NewRegistry(clock, s.mockInTransactioner, s.mockCreator, nil, idGen)
+
+		createdFile, err := registry.Create(ctx, inputFile)
+
+		s.Require().NoError(err)
+		s.Equal(md, createdFile.Metadata)
+	})
+
+	s.Run("encodes and decodes extents", func() {
+		timestamp := time.Unix(1, 0)
+		inputFile := &processor.File{
+			Key:     key,
+			Size:    4096,
+			Extents: []processor.Extent{{Offset: 1024, Length: 512}},
+		}
+
+		clock := fakeClock(func() time.Time { return timestamp })
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockCreator.EXPECT().
+			Create(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, tx db.Tx, row *db.FileRow) (*db.FileRow, error) {
+				s.JSONEq(`[{"offset":1024,"length":512}]`, string(row.Extents))
+				return row, nil
+			})
+
+		registry := db.NewRegistry(clock, s.mockInTransactioner, s.mockCreator, nil, idGen)
+
+		createdFile, err := registry.Create(ctx, inputFile)
+
+		s.Require().NoError(err)
+		s.Equal(inputFile.Extents, createdFile.Extents)
+	})
+
+	s.Run("records entry type", func() {
+		timestamp := time.Unix(1, 0)
+		inputFile := &processor.File{
+			Type:        processor.FileTypeCharDevice,
+			LocalPath:   "dev/null",
+			DeviceMajor: 1,
+			DeviceMinor: 3,
+		}
+		expectedFileRow := &db.FileRow{
+			ID:                 id,
+			LocalPath:          "dev/null",
+			FileType:           "char_device",
+			DeviceMajor:        1,
+			DeviceMinor:        3,
+			CreatedAtTimestamp: timestamp,
+		}
+
+		clock := fakeClock(func() time.Time { return timestamp })
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockCreator.EXPECT().
+			Create(ctx, gomock.Any(), expectedFileRow).Return(expectedFileRow, nil)
+
+		registry := db.NewRegistry(clock, s.mockInTransactioner, s.mockCreator, nil, idGen)
+
+		createdFile, err := registry.Create(ctx, inputFile)
+
+		s.Require().NoError(err)
+		s.Equal(inputFile, createdFile)
+	})
+
 	s.Run("handles error", func() {
 		expectedErr := errors.New("oh no")
 
@@ -14,11 +14,28 @@ INSERT INTO files.files (
 	bucket,
 	etag,
 	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
 	created_at_timestamp
 ) VALUES (
-	$1, $2, $3, $4, $5, $6, $7, $8, $9
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26
 )
-RETURNING id, key, local_path, checksum, change_time, bucket, etag, version, created_at_timestamp
+RETURNING id, key, local_path, checksum, change_time, bucket, etag, version, pack_id, pack_offset, pack_length, key_layout, storage_class, retain_until, legal_hold, checksum_algorithm, size, modify_time, inconsistent, metadata, file_type, link_target, device_major, device_minor, extents, created_at_timestamp
 `
 
 // CreatorTx provides the logic to insert a file into a database within a
@@ -49,6 +66,23 @@ func (c *CreatorTx) Create(
 		file.Bucket,
 		file.ETag,
 		file.Version,
+		file.PackID,
+		file.PackOffset,
+		file.PackLength,
+		file.KeyLayout,
+		file.StorageClass,
+		file.RetainUntil,
+		file.LegalHold,
+		file.ChecksumAlgorithm,
+		file.Size,
+		file.MTime,
+		file.Inconsistent,
+		file.Metadata,
+		file.FileType,
+		file.LinkTarget,
+		file.DeviceMajor,
+		file.DeviceMinor,
+		file.Extents,
 		file.CreatedAtTimestamp,
 	)
 	var insertedFile FileRow
@@ -61,6 +95,23 @@ func (c *CreatorTx) Create(
 		&insertedFile.Bucket,
 		&insertedFile.ETag,
 		&insertedFile.Version,
+		&insertedFile.PackID,
+		&insertedFile.PackOffset,
+		&insertedFile.PackLength,
+		&insertedFile.KeyLayout,
+		&insertedFile.StorageClass,
+		&insertedFile.RetainUntil,
+		&insertedFile.LegalHold,
+		&insertedFile.ChecksumAlgorithm,
+		&insertedFile.Size,
+		&insertedFile.MTime,
+		&insertedFile.Inconsistent,
+		&insertedFile.Metadata,
+		&insertedFile.FileType,
+		&insertedFile.LinkTarget,
+		&insertedFile.DeviceMajor,
+		&insertedFile.DeviceMinor,
+		&insertedFile.Extents,
 		&insertedFile.CreatedAtTimestamp,
 	)
 	if err != nil {
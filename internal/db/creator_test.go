@@ -21,11 +21,28 @@ INSERT INTO files.files \(
 	bucket,
 	etag,
 	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
 	created_at_timestamp
 \) VALUES \(
-	\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9
+	\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10, \$11, \$12, \$13, \$14, \$15, \$16, \$17, \$18, \$19, \$20, \$21, \$22, \$23, \$24, \$25, \$26
 \)
-RETURNING id, key, local_path, checksum, change_time, bucket, etag, version, created_at_timestamp
+RETURNING id, key, local_path, checksum, change_time, bucket, etag, version, pack_id, pack_offset, pack_length, key_layout, storage_class, retain_until, legal_hold, checksum_algorithm, size, modify_time, inconsistent, metadata, file_type, link_target, device_major, device_minor, extents, created_at_timestamp
 `
 
 var insertRows = []string{
@@ -37,6 +54,23 @@ var insertRows = []string{
 	"bucket",
 	"etag",
 	"version",
+	"pack_id",
+	"pack_offset",
+	"pack_length",
+	"key_layout",
+	"storage_class",
+	"retain_until",
+	"legal_hold",
+	"checksum_algorithm",
+	"size",
+	"modify_time",
+	"inconsistent",
+	"metadata",
+	"file_type",
+	"link_target",
+	"device_major",
+	"device_minor",
+	"extents",
 	"created_at_timestamp",
 }
 
@@ -53,11 +87,20 @@ func (s *CreatorTestSuite) TestCreate() {
 		ID:                 "some-id",
 		Key:                "some-key",
 		LocalPath:          "/some/path",
-		Checksum:           42,
+		Checksum:           db.Checksum{0, 0, 0, 42},
+		ChecksumAlgorithm:  "CRC32",
 		CTime:              time.Unix(123, 456).UTC(),
 		Bucket:             "some-bucket",
 		ETag:               "some-etag",
 		Version:            "some-version",
+		PackID:             "some-pack-id",
+		PackOffset:         1024,
+		PackLength:         512,
+		KeyLayout:          "uuid",
+		StorageClass:       "DEEP_ARCHIVE",
+		RetainUntil:        sql.NullTime{Time: time.Unix(1000, 0).UTC(), Valid: true},
+		LegalHold:          true,
+		Metadata:           db.Metadata(`{"mode":420,"uid":1000,"gid":1000}`),
 		CreatedAtTimestamp: time.Unix(1, 0).UTC(),
 	}
 
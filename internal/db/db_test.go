@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"database/sql"
+	"database/sql/driver"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -38,7 +39,31 @@ func addFileRowsToRows(rows *sqlmock.Rows, fileRows ...*db.FileRow) {
 			row.Bucket,
 			row.ETag,
 			row.Version,
+			row.PackID,
+			row.PackOffset,
+			row.PackLength,
+			row.KeyLayout,
+			row.StorageClass,
+			nullTimeValue(row.RetainUntil),
+			row.LegalHold,
+			row.ChecksumAlgorithm,
+			row.Size,
+			row.MTime,
+			row.Inconsistent,
+			row.Metadata,
+			row.FileType,
+			row.LinkTarget,
+			row.DeviceMajor,
+			row.DeviceMinor,
+			row.Extents,
 			row.CreatedAtTimestamp,
 		)
 	}
 }
+
+func nullTimeValue(t sql.NullTime) driver.Value {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time
+}
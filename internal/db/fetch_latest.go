@@ -7,12 +7,17 @@ import (
 )
 
 // FetchLatest retrieves the latest version of a file with the provided path
-// from the database.
-func (r *Registry) FetchLatest(ctx context.Context, path string) (*processor.File, error) {
+// from the database, considering only versions stored in the provided bucket
+// under keys beginning with the provided prefix.
+func (r *Registry) FetchLatest(
+	ctx context.Context,
+	bucket, keyPrefix, path string,
+) (*processor.File, error) {
+
 	var latestFileRow *FileRow
 	err := r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
 		var err error
-		latestFileRow, err = r.latestFetcher.FetchLatest(ctx, tx, path)
+		latestFileRow, err = r.latestFetcher.FetchLatest(ctx, tx, bucket, keyPrefix, path)
 		return err
 	})
 	if err != nil {
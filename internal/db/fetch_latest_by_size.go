@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// FetchLatestBySize retrieves the latest version of every file stored in the
+// provided bucket under a key beginning with the provided prefix, whose latest
+// version has the provided size.
+func (r *Registry) FetchLatestBySize(
+	ctx context.Context,
+	bucket, keyPrefix string,
+	size int64,
+) ([]*processor.File, error) {
+
+	var fileRows []*FileRow
+	err := r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
+		var err error
+		fileRows, err = r.sizeFetcher.FetchLatestBySize(ctx, tx, bucket, keyPrefix, size)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*processor.File, len(fileRows))
+	for i, row := range fileRows {
+		files[i] = row.toDomain()
+	}
+
+	return files, nil
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *RegistryTestSuite) TestFetchLatestBySize() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
+	size := int64(1024)
+	clock := fakeClock(func() time.Time { return time.Unix(1, 0) })
+
+	s.Run("fetches file rows in transaction", func() {
+		expectedFiles := []*processor.File{
+			{LocalPath: "some/path", Size: size},
+			{LocalPath: "some/other/path", Size: size},
+		}
+		fileRows := []*db.FileRow{
+			{LocalPath: "some/path", Size: size},
+			{LocalPath: "some/other/path", Size: size},
+		}
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockSizeFetcher.EXPECT().
+			FetchLatestBySize(ctx, gomock.Any(), bucket, keyPrefix, size).Return(fileRows, nil)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithSizeFetcher(s.mockSizeFetcher),
+		)
+
+		files, err := registry.FetchLatestBySize(ctx, bucket, keyPrefix, size)
+
+		s.Require().NoError(err)
+		s.Equal(expectedFiles, files)
+	})
+
+	s.Run("handles error from size fetcher", func() {
+		expectedErr := errors.New("oh no")
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockSizeFetcher.EXPECT().
+			FetchLatestBySize(ctx, gomock.Any(), bucket, keyPrefix, size).Return(nil, expectedErr)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithSizeFetcher(s.mockSizeFetcher),
+		)
+
+		files, err := registry.FetchLatestBySize(ctx, bucket, keyPrefix, size)
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(files)
+	})
+}
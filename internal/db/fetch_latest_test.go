@@ -12,6 +12,8 @@ import (
 
 func (s *RegistryTestSuite) TestFetchLatest() {
 	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
 	path := "path/to/file"
 
 	s.Run("fetches latest file row in transaction", func() {
@@ -27,11 +29,11 @@ func (s *RegistryTestSuite) TestFetchLatest() {
 		s.mockInTransactioner.EXPECT().
 			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
 		s.mockLatestFetcher.EXPECT().
-			FetchLatest(ctx, gomock.Any(), path).Return(expectedFileRow, nil)
+			FetchLatest(ctx, gomock.Any(), bucket, keyPrefix, path).Return(expectedFileRow, nil)
 
 		registry := db.NewRegistry(clock, s.mockInTransactioner, nil, s.mockLatestFetcher, nil)
 
-		latestFile, err := registry.FetchLatest(ctx, path)
+		latestFile, err := registry.FetchLatest(ctx, bucket, keyPrefix, path)
 
 		s.Require().NoError(err)
 		s.Equal(expectedFile, latestFile)
@@ -44,11 +46,11 @@ func (s *RegistryTestSuite) TestFetchLatest() {
 		s.mockInTransactioner.EXPECT().
 			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
 		s.mockLatestFetcher.EXPECT().
-			FetchLatest(ctx, gomock.Any(), path).Return(nil, nil)
+			FetchLatest(ctx, gomock.Any(), bucket, keyPrefix, path).Return(nil, nil)
 
 		registry := db.NewRegistry(clock, s.mockInTransactioner, nil, s.mockLatestFetcher, nil)
 
-		latestFile, err := registry.FetchLatest(ctx, path)
+		latestFile, err := registry.FetchLatest(ctx, bucket, keyPrefix, path)
 
 		s.Require().NoError(err)
 		s.Nil(latestFile)
@@ -66,7 +68,7 @@ func (s *RegistryTestSuite) TestFetchLatest() {
 
 			registry := db.NewRegistry(clock, s.mockInTransactioner, nil, nil, nil)
 
-			latestFile, err := registry.FetchLatest(ctx, path)
+			latestFile, err := registry.FetchLatest(ctx, bucket, keyPrefix, path)
 
 			s.Nil(latestFile)
 			s.ErrorIs(err, expectedErr)
@@ -78,11 +80,11 @@ func (s *RegistryTestSuite) TestFetchLatest() {
 			s.mockInTransactioner.EXPECT().
 				InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
 			s.mockLatestFetcher.EXPECT().
-				FetchLatest(ctx, gomock.Any(), path).Return(nil, expectedErr)
+				FetchLatest(ctx, gomock.Any(), bucket, keyPrefix, path).Return(nil, expectedErr)
 
 			registry := db.NewRegistry(clock, s.mockInTransactioner, nil, s.mockLatestFetcher, nil)
 
-			latestFile, err := registry.FetchLatest(ctx, path)
+			latestFile, err := registry.FetchLatest(ctx, bucket, keyPrefix, path)
 
 			s.Nil(latestFile)
 			s.ErrorIs(err, expectedErr)
@@ -1,49 +1,186 @@
 package db
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/mspraggs/hoard/internal/metadata"
 	"github.com/mspraggs/hoard/internal/processor"
 )
 
-// Checksum defines a CRC32 checksum as an unsigned 32-bit integer.
-type Checksum uint32
+// Checksum defines the checksum of a file's contents as a byte slice. Entries
+// without an object body have no checksum, which is stored as an empty value
+// rather than NULL.
+type Checksum []byte
+
+// Value converts the checksum to a value the database driver can store in a
+// non-nullable binary column.
+func (c Checksum) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte{}, nil
+	}
+	return []byte(c), nil
+}
+
+// Scan reads a checksum stored in a binary column, treating an empty value as
+// no checksum.
+func (c *Checksum) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+	case []byte:
+		*c = append(Checksum(nil), v...)
+	case string:
+		*c = append(Checksum(nil), v...)
+	default:
+		return fmt.Errorf("unable to scan %T into checksum", src)
+	}
+	return nil
+}
+
+// Metadata defines the JSON encoding of a file's POSIX metadata. A nil value is
+// stored as NULL.
+type Metadata []byte
+
+// Value converts the metadata to a value the database driver can store in a
+// JSONB column.
+func (m Metadata) Value() (driver.Value, error) {
+	return jsonValue(m), nil
+}
+
+// Scan reads metadata stored in a JSONB column, which may be NULL.
+func (m *Metadata) Scan(src interface{}) error {
+	return scanJSON((*[]byte)(m), src, "metadata")
+}
+
+// Extents defines the JSON encoding of the data extents of a sparse file. A nil
+// value, stored as NULL, denotes a file that is not sparse.
+type Extents []byte
+
+// Value converts the extents to a value the database driver can store in a
+// JSONB column.
+func (e Extents) Value() (driver.Value, error) {
+	return jsonValue(e), nil
+}
+
+// Scan reads extents stored in a JSONB column, which may be NULL.
+func (e *Extents) Scan(src interface{}) error {
+	return scanJSON((*[]byte)(e), src, "extents")
+}
+
+func jsonValue(encoded []byte) driver.Value {
+	if encoded == nil {
+		return nil
+	}
+	return string(encoded)
+}
+
+func scanJSON(dst *[]byte, src interface{}, name string) error {
+	switch v := src.(type) {
+	case nil:
+		*dst = nil
+	case []byte:
+		*dst = append([]byte(nil), v...)
+	case string:
+		*dst = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan %T into %s", src, name)
+	}
+	return nil
+}
 
 // FileRow is the database representation of a file.
 type FileRow struct {
-	ID                 string    `db:"id"`
-	Key                string    `db:"key"`
-	LocalPath          string    `db:"local_path"`
-	Checksum           Checksum  `db:"checksum"`
-	CTime              time.Time `db:"change_time"`
-	Bucket             string    `db:"bucket"`
-	ETag               string    `db:"etag"`
-	Version            string    `db:"version"`
-	CreatedAtTimestamp time.Time `db:"created_at_timestamp"`
+	ID                 string       `db:"id"`
+	Key                string       `db:"key"`
+	LocalPath          string       `db:"local_path"`
+	Checksum           Checksum     `db:"checksum"`
+	CTime              time.Time    `db:"change_time"`
+	Bucket             string       `db:"bucket"`
+	ETag               string       `db:"etag"`
+	Version            string       `db:"version"`
+	PackID             string       `db:"pack_id"`
+	PackOffset         int64        `db:"pack_offset"`
+	PackLength         int64        `db:"pack_length"`
+	KeyLayout          string       `db:"key_layout"`
+	StorageClass       string       `db:"storage_class"`
+	RetainUntil        sql.NullTime `db:"retain_until"`
+	LegalHold          bool         `db:"legal_hold"`
+	ChecksumAlgorithm  string       `db:"checksum_algorithm"`
+	Size               int64        `db:"size"`
+	MTime              time.Time    `db:"modify_time"`
+	Inconsistent       bool         `db:"inconsistent"`
+	Metadata           Metadata     `db:"metadata"`
+	FileType           string       `db:"file_type"`
+	LinkTarget         string       `db:"link_target"`
+	DeviceMajor        int64        `db:"device_major"`
+	DeviceMinor        int64        `db:"device_minor"`
+	Extents            Extents      `db:"extents"`
+	CreatedAtTimestamp time.Time    `db:"created_at_timestamp"`
 }
 
 func (r *FileRow) toDomain() *processor.File {
 	return &processor.File{
-		Key:       r.Key,
-		LocalPath: r.LocalPath,
-		Checksum:  r.Checksum.toDomain(),
-		CTime:     r.CTime,
-		Bucket:    r.Bucket,
-		ETag:      r.ETag,
-		Version:   r.Version,
+		Type:              processor.FileType(r.FileType),
+		Key:               r.Key,
+		LocalPath:         r.LocalPath,
+		Checksum:          r.Checksum.toDomain(),
+		ChecksumAlgorithm: r.ChecksumAlgorithm,
+		CTime:             r.CTime,
+		MTime:             r.MTime,
+		Size:              r.Size,
+		Bucket:            r.Bucket,
+		ETag:              r.ETag,
+		Version:           r.Version,
+		PackID:            r.PackID,
+		PackOffset:        r.PackOffset,
+		PackLength:        r.PackLength,
+		KeyLayout:         r.KeyLayout,
+		StorageClass:      r.StorageClass,
+		RetainUntil:       r.RetainUntil.Time,
+		LegalHold:         r.LegalHold,
+		Inconsistent:      r.Inconsistent,
+		Metadata:          r.Metadata.toDomain(),
+		LinkTarget:        r.LinkTarget,
+		DeviceMajor:       uint32(r.DeviceMajor),
+		DeviceMinor:       uint32(r.DeviceMinor),
+		Extents:           r.Extents.toDomain(),
 	}
 }
 
 func newFileRowFromDomain(id string, file *processor.File) *FileRow {
 	return &FileRow{
-		ID:        id,
-		Key:       file.Key,
-		LocalPath: file.LocalPath,
-		Checksum:  newChecksumFromDomain(file.Checksum),
-		CTime:     file.CTime,
-		Bucket:    file.Bucket,
-		ETag:      file.ETag,
-		Version:   file.Version,
+		ID:                id,
+		Key:               file.Key,
+		LocalPath:         file.LocalPath,
+		Checksum:          newChecksumFromDomain(file.Checksum),
+		ChecksumAlgorithm: file.ChecksumAlgorithm,
+		CTime:             file.CTime,
+		MTime:             file.MTime,
+		Size:              file.Size,
+		Bucket:            file.Bucket,
+		ETag:              file.ETag,
+		Version:           file.Version,
+		PackID:            file.PackID,
+		PackOffset:        file.PackOffset,
+		PackLength:        file.PackLength,
+		KeyLayout:         file.KeyLayout,
+		StorageClass:      file.StorageClass,
+		RetainUntil: sql.NullTime{
+			Time:  file.RetainUntil,
+			Valid: !file.RetainUntil.IsZero(),
+		},
+		LegalHold:    file.LegalHold,
+		Inconsistent: file.Inconsistent,
+		Metadata:     newMetadataFromDomain(file.Metadata),
+		FileType:     string(file.Type),
+		LinkTarget:   file.LinkTarget,
+		DeviceMajor:  int64(file.DeviceMajor),
+		DeviceMinor:  int64(file.DeviceMinor),
+		Extents:      newExtentsFromDomain(file.Extents),
 	}
 }
 
@@ -52,5 +189,65 @@ func newChecksumFromDomain(checksum processor.Checksum) Checksum {
 }
 
 func (c Checksum) toDomain() processor.Checksum {
+	if len(c) == 0 {
+		return nil
+	}
 	return processor.Checksum(c)
 }
+
+func newMetadataFromDomain(md *metadata.Metadata) Metadata {
+	if md == nil {
+		return nil
+	}
+	// Encoding can only fail for unsupported types, which Metadata does not
+	// contain.
+	encoded, _ := json.Marshal(md)
+	return Metadata(encoded)
+}
+
+// toDomain decodes the metadata. Metadata that is missing or cannot be decoded
+// is treated as unknown.
+func (m Metadata) toDomain() *metadata.Metadata {
+	if m == nil {
+		return nil
+	}
+	var md metadata.Metadata
+	if err := json.Unmarshal(m, &md); err != nil {
+		return nil
+	}
+	return &md
+}
+
+type extentJSON struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+func newExtentsFromDomain(extents []processor.Extent) Extents {
+	if extents == nil {
+		return nil
+	}
+	encodable := make([]extentJSON, len(extents))
+	for i, extent := range extents {
+		encodable[i] = extentJSON{Offset: extent.Offset, Length: extent.Length}
+	}
+	encoded, _ := json.Marshal(encodable)
+	return Extents(encoded)
+}
+
+// toDomain decodes the extents. Extents that cannot be decoded are treated as
+// missing, so the file is treated as not being sparse.
+func (e Extents) toDomain() []processor.Extent {
+	if e == nil {
+		return nil
+	}
+	var decoded []extentJSON
+	if err := json.Unmarshal(e, &decoded); err != nil {
+		return nil
+	}
+	extents := make([]processor.Extent, len(decoded))
+	for i, extent := range decoded {
+		extents[i] = processor.Extent{Offset: extent.Offset, Length: extent.Length}
+	}
+	return extents
+}
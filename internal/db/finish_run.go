@@ -0,0 +1,13 @@
+package db
+
+import (
+	"context"
+)
+
+// FinishRun records the end of the backup run with the provided sequential
+// number in the registry, along with its outcome.
+func (r *Registry) FinishRun(ctx context.Context, run int64, status string) error {
+	return r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
+		return r.runFinisher.FinishRun(ctx, tx, run, r.clock.Now(), status)
+	})
+}
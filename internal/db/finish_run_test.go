@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+func (s *RegistryTestSuite) TestFinishRun() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	timestamp := time.Unix(1, 0)
+	clock := fakeClock(func() time.Time { return timestamp })
+
+	s.Run("finishes run in transaction", func() {
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockRunFinisher.EXPECT().
+			FinishRun(ctx, gomock.Any(), int64(42), timestamp, "success").Return(nil)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithRunFinisher(s.mockRunFinisher),
+		)
+
+		err := registry.FinishRun(ctx, 42, "success")
+
+		s.Require().NoError(err)
+	})
+
+	s.Run("handles error from run finisher", func() {
+		expectedErr := errors.New("oh no")
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockRunFinisher.EXPECT().
+			FinishRun(ctx, gomock.Any(), int64(42), timestamp, "failure").Return(expectedErr)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithRunFinisher(s.mockRunFinisher),
+		)
+
+		err := registry.FinishRun(ctx, 42, "failure")
+
+		s.ErrorIs(err, expectedErr)
+	})
+}
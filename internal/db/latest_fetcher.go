@@ -15,9 +15,28 @@ SELECT
 	bucket,
 	etag,
 	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
 	created_at_timestamp
 FROM files.files
-WHERE local_path = $1
+WHERE bucket = $1
+	AND starts_with(key, $2)
+	AND local_path = $3
 ORDER BY created_at_timestamp DESC
 LIMIT 1
 `
@@ -31,14 +50,15 @@ func NewLatestFetcherTx() *LatestFetcherTx {
 	return &LatestFetcherTx{}
 }
 
-// FetchLatest returns the most recent version of a file with the provided path.
+// FetchLatest returns the most recent version of a file with the provided path,
+// stored in the provided bucket under a key beginning with the provided prefix.
 func (lf *LatestFetcherTx) FetchLatest(
 	ctx context.Context,
 	tx Tx,
-	path string,
+	bucket, keyPrefix, path string,
 ) (*FileRow, error) {
 
-	row := tx.QueryRowContext(ctx, getLatestFile, path)
+	row := tx.QueryRowContext(ctx, getLatestFile, bucket, keyPrefix, path)
 
 	var selectedFile FileRow
 	if err := row.Scan(
@@ -50,6 +70,23 @@ func (lf *LatestFetcherTx) FetchLatest(
 		&selectedFile.Bucket,
 		&selectedFile.ETag,
 		&selectedFile.Version,
+		&selectedFile.PackID,
+		&selectedFile.PackOffset,
+		&selectedFile.PackLength,
+		&selectedFile.KeyLayout,
+		&selectedFile.StorageClass,
+		&selectedFile.RetainUntil,
+		&selectedFile.LegalHold,
+		&selectedFile.ChecksumAlgorithm,
+		&selectedFile.Size,
+		&selectedFile.MTime,
+		&selectedFile.Inconsistent,
+		&selectedFile.Metadata,
+		&selectedFile.FileType,
+		&selectedFile.LinkTarget,
+		&selectedFile.DeviceMajor,
+		&selectedFile.DeviceMinor,
+		&selectedFile.Extents,
 		&selectedFile.CreatedAtTimestamp,
 	); err != nil {
 		if err == sql.ErrNoRows {
@@ -22,9 +22,28 @@ SELECT
 	bucket,
 	etag,
 	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
 	created_at_timestamp
 FROM files.files
-WHERE local_path = \$1
+WHERE bucket = \$1
+	AND starts_with\(key, \$2\)
+	AND local_path = \$3
 ORDER BY created_at_timestamp DESC
 LIMIT 1
 `
@@ -38,6 +57,8 @@ func TestLatestFetcherTestSuite(t *testing.T) {
 }
 
 func (s *LatestFetcherTestSuite) TestCreate() {
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
 	path := "/some/path"
 
 	fileRows := []*db.FileRow{
@@ -45,7 +66,8 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 			ID:                 "some-id",
 			Key:                "some-key",
 			LocalPath:          path,
-			Checksum:           42,
+			Checksum:           db.Checksum{0, 0, 0, 42},
+			ChecksumAlgorithm:  "CRC32",
 			Bucket:             "some-bucket",
 			ETag:               "some-etag",
 			Version:            "some-version",
@@ -55,7 +77,8 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 			ID:                 "some-other-id",
 			Key:                "some-key",
 			LocalPath:          path,
-			Checksum:           43,
+			Checksum:           db.Checksum{0, 0, 0, 43},
+			ChecksumAlgorithm:  "CRC32",
 			Bucket:             "some-bucket",
 			ETag:               "some-other-etag",
 			Version:            "some-version",
@@ -72,7 +95,7 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 		addFileRowsToRows(rows, fileRows[1])
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(selectQuery).WithArgs(path).WillReturnRows(rows)
+		mock.ExpectQuery(selectQuery).WithArgs(bucket, keyPrefix, path).WillReturnRows(rows)
 		mock.ExpectCommit()
 
 		latestFetcher := db.NewLatestFetcherTx()
@@ -80,7 +103,7 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 		var fetchedRow *db.FileRow
 		err = s.inTransaction(d, func(tx *sql.Tx) error {
 			var err error
-			fetchedRow, err = latestFetcher.FetchLatest(context.Background(), tx, path)
+			fetchedRow, err = latestFetcher.FetchLatest(context.Background(), tx, bucket, keyPrefix, path)
 			if err != nil {
 				return err
 			}
@@ -101,7 +124,7 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 		rows := sqlmock.NewRows(insertRows)
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(selectQuery).WithArgs(missingPath).WillReturnRows(rows)
+		mock.ExpectQuery(selectQuery).WithArgs(bucket, keyPrefix, missingPath).WillReturnRows(rows)
 		mock.ExpectCommit()
 
 		latestFetcher := db.NewLatestFetcherTx()
@@ -109,7 +132,9 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 		var fetchedRow *db.FileRow
 		err = s.inTransaction(d, func(tx *sql.Tx) error {
 			var err error
-			fetchedRow, err = latestFetcher.FetchLatest(context.Background(), tx, missingPath)
+			fetchedRow, err = latestFetcher.FetchLatest(
+				context.Background(), tx, bucket, keyPrefix, missingPath,
+			)
 			if err != nil {
 				return err
 			}
@@ -128,7 +153,7 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 		defer d.Close()
 
 		mock.ExpectBegin()
-		mock.ExpectQuery(selectQuery).WithArgs(path).WillReturnError(expectedErr)
+		mock.ExpectQuery(selectQuery).WithArgs(bucket, keyPrefix, path).WillReturnError(expectedErr)
 		mock.ExpectRollback()
 
 		latestFetcher := db.NewLatestFetcherTx()
@@ -136,7 +161,7 @@ func (s *LatestFetcherTestSuite) TestCreate() {
 		var fetchedRow *db.FileRow
 		err = s.inTransaction(d, func(tx *sql.Tx) error {
 			var err error
-			fetchedRow, err = latestFetcher.FetchLatest(context.Background(), tx, path)
+			fetchedRow, err = latestFetcher.FetchLatest(context.Background(), tx, bucket, keyPrefix, path)
 			if err != nil {
 				return err
 			}
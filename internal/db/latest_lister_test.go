@@ -0,0 +1,138 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const selectLatestListQuery = `
+SELECT
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+FROM files.files f
+WHERE bucket = \$1
+	AND starts_with\(key, \$2\)
+	AND created_at_timestamp = \(
+		SELECT MAX\(created_at_timestamp\)
+		FROM files.files
+		WHERE bucket = f.bucket
+			AND starts_with\(key, \$2\)
+			AND local_path = f.local_path
+	\)
+ORDER BY local_path
+`
+
+type LatestListerTestSuite struct {
+	dbTestSuite
+}
+
+func TestLatestListerTestSuite(t *testing.T) {
+	suite.Run(t, new(LatestListerTestSuite))
+}
+
+func (s *LatestListerTestSuite) TestListLatest() {
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
+
+	fileRows := []*db.FileRow{
+		{
+			ID:                 "some-id",
+			Key:                "some-key",
+			LocalPath:          "/some/path",
+			Checksum:           db.Checksum{0, 0, 0, 42},
+			ChecksumAlgorithm:  "CRC32",
+			Bucket:             bucket,
+			CreatedAtTimestamp: time.Unix(1, 0).UTC(),
+		},
+		{
+			ID:                 "some-other-id",
+			Key:                "some-other-key",
+			LocalPath:          "/some/path/2",
+			Checksum:           db.Checksum{0, 0, 0, 43},
+			ChecksumAlgorithm:  "CRC32",
+			Bucket:             bucket,
+			CreatedAtTimestamp: time.Unix(2, 0).UTC(),
+		},
+	}
+
+	s.Run("returns matching rows", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(rows, fileRows...)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectLatestListQuery).WithArgs(bucket, keyPrefix).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		latestLister := db.NewLatestListerTx()
+
+		var fetchedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			fetchedRows, err = latestLister.ListLatest(context.Background(), tx, bucket, keyPrefix)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRows, fetchedRows)
+	})
+
+	s.Run("returns error from query", func() {
+		expectedErr := errors.New("fail")
+
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectLatestListQuery).WithArgs(bucket, keyPrefix).WillReturnError(expectedErr)
+		mock.ExpectRollback()
+
+		latestLister := db.NewLatestListerTx()
+
+		var fetchedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			fetchedRows, err = latestLister.ListLatest(context.Background(), tx, bucket, keyPrefix)
+			return err
+		})
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(fetchedRows)
+	})
+}
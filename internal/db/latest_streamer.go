@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const streamLatestFiles = `-- name: StreamLatestFiles :many
+SELECT DISTINCT ON (local_path)
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+FROM files.files
+WHERE bucket = $1
+	AND starts_with(key, $2)
+	AND created_at_timestamp > $3
+ORDER BY local_path, created_at_timestamp DESC
+`
+
+// LatestStreamerTx provides the logic to stream the most recent versions of
+// files within a transaction.
+type LatestStreamerTx struct{}
+
+// NewLatestStreamerTx instantiates a new LatestStreamerTx instance.
+func NewLatestStreamerTx() *LatestStreamerTx {
+	return &LatestStreamerTx{}
+}
+
+// StreamLatest calls the provided function with the most recent version of
+// every file stored in the provided bucket under a key beginning with the
+// provided prefix, considering only versions created after the provided time. Rows are passed to the function as they are read,
+// so that the result set is never held in memory all at once.
+func (ls *LatestStreamerTx) StreamLatest(
+	ctx context.Context,
+	tx Tx,
+	bucket, keyPrefix string,
+	since time.Time,
+	fn func(*FileRow) error,
+) error {
+
+	rows, err := tx.QueryContext(ctx, streamLatestFiles, bucket, keyPrefix, since)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row FileRow
+		if err := rows.Scan(
+			&row.ID,
+			&row.Key,
+			&row.LocalPath,
+			&row.Checksum,
+			&row.CTime,
+			&row.Bucket,
+			&row.ETag,
+			&row.Version,
+			&row.PackID,
+			&row.PackOffset,
+			&row.PackLength,
+			&row.KeyLayout,
+			&row.StorageClass,
+			&row.RetainUntil,
+			&row.LegalHold,
+			&row.ChecksumAlgorithm,
+			&row.Size,
+			&row.MTime,
+			&row.Inconsistent,
+			&row.Metadata,
+			&row.FileType,
+			&row.LinkTarget,
+			&row.DeviceMajor,
+			&row.DeviceMinor,
+			&row.Extents,
+			&row.CreatedAtTimestamp,
+		); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
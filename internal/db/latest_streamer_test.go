@@ -0,0 +1,167 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const selectLatestStreamQuery = `
+SELECT DISTINCT ON \(local_path\)
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+FROM files.files
+WHERE bucket = \$1
+	AND starts_with\(key, \$2\)
+	AND created_at_timestamp > \$3
+ORDER BY local_path, created_at_timestamp DESC
+`
+
+type LatestStreamerTestSuite struct {
+	dbTestSuite
+}
+
+func TestLatestStreamerTestSuite(t *testing.T) {
+	suite.Run(t, new(LatestStreamerTestSuite))
+}
+
+func (s *LatestStreamerTestSuite) TestStreamLatest() {
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
+	since := time.Unix(1, 0).UTC()
+
+	fileRows := []*db.FileRow{
+		{
+			ID:                 "some-id",
+			Key:                "some-key",
+			LocalPath:          "/some/path",
+			Checksum:           db.Checksum{0, 0, 0, 42},
+			ChecksumAlgorithm:  "CRC32",
+			Bucket:             bucket,
+			CreatedAtTimestamp: time.Unix(2, 0).UTC(),
+		},
+		{
+			ID:                 "some-other-id",
+			Key:                "some-other-key",
+			LocalPath:          "/some/path/2",
+			Checksum:           db.Checksum{0, 0, 0, 43},
+			ChecksumAlgorithm:  "CRC32",
+			Bucket:             bucket,
+			CreatedAtTimestamp: time.Unix(3, 0).UTC(),
+		},
+	}
+
+	s.Run("passes each row to function", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(rows, fileRows...)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectLatestStreamQuery).WithArgs(bucket, keyPrefix, since).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		latestStreamer := db.NewLatestStreamerTx()
+
+		var streamedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix, since,
+				func(row *db.FileRow) error {
+					streamedRows = append(streamedRows, row)
+					return nil
+				},
+			)
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRows, streamedRows)
+	})
+
+	s.Run("stops at error from function", func() {
+		expectedErr := errors.New("fail")
+
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(rows, fileRows...)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectLatestStreamQuery).WithArgs(bucket, keyPrefix, since).WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		latestStreamer := db.NewLatestStreamerTx()
+
+		calls := 0
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix, since,
+				func(row *db.FileRow) error {
+					calls++
+					return expectedErr
+				},
+			)
+		})
+
+		s.ErrorIs(err, expectedErr)
+		s.Equal(1, calls)
+	})
+
+	s.Run("returns error from query", func() {
+		expectedErr := errors.New("fail")
+
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectLatestStreamQuery).WithArgs(bucket, keyPrefix, since).WillReturnError(expectedErr)
+		mock.ExpectRollback()
+
+		latestStreamer := db.NewLatestStreamerTx()
+
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix, since,
+				func(row *db.FileRow) error { return nil },
+			)
+		})
+
+		s.ErrorIs(err, expectedErr)
+	})
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// ListLatest retrieves the latest version of every file stored in the provided
+// bucket under a key beginning with the provided prefix.
+func (r *Registry) ListLatest(
+	ctx context.Context,
+	bucket, keyPrefix string,
+) ([]*processor.File, error) {
+
+	var fileRows []*FileRow
+	err := r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
+		var err error
+		fileRows, err = r.latestLister.ListLatest(ctx, tx, bucket, keyPrefix)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*processor.File, len(fileRows))
+	for i, row := range fileRows {
+		files[i] = row.toDomain()
+	}
+
+	return files, nil
+}
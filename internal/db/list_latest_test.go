@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *RegistryTestSuite) TestListLatest() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
+	clock := fakeClock(func() time.Time { return time.Unix(1, 0) })
+
+	s.Run("fetches file rows in transaction", func() {
+		expectedFiles := []*processor.File{
+			{LocalPath: "some/path"},
+			{LocalPath: "some/other/path"},
+		}
+		fileRows := []*db.FileRow{
+			{LocalPath: "some/path"},
+			{LocalPath: "some/other/path"},
+		}
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockLatestLister.EXPECT().
+			ListLatest(ctx, gomock.Any(), bucket, keyPrefix).Return(fileRows, nil)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithLatestLister(s.mockLatestLister),
+		)
+
+		files, err := registry.ListLatest(ctx, bucket, keyPrefix)
+
+		s.Require().NoError(err)
+		s.Equal(expectedFiles, files)
+	})
+
+	s.Run("handles error from latest lister", func() {
+		expectedErr := errors.New("oh no")
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockLatestLister.EXPECT().
+			ListLatest(ctx, gomock.Any(), bucket, keyPrefix).Return(nil, expectedErr)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithLatestLister(s.mockLatestLister),
+		)
+
+		files, err := registry.ListLatest(ctx, bucket, keyPrefix)
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(files)
+	})
+}
@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: batch_writer.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	processor "github.com/mspraggs/hoard/internal/processor"
+)
+
+// MockBatchRegistry is a mock of BatchRegistry interface.
+type MockBatchRegistry struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchRegistryMockRecorder
+}
+
+// MockBatchRegistryMockRecorder is the mock recorder for MockBatchRegistry.
+type MockBatchRegistryMockRecorder struct {
+	mock *MockBatchRegistry
+}
+
+// NewMockBatchRegistry creates a new mock instance.
+func NewMockBatchRegistry(ctrl *gomock.Controller) *MockBatchRegistry {
+	mock := &MockBatchRegistry{ctrl: ctrl}
+	mock.recorder = &MockBatchRegistryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchRegistry) EXPECT() *MockBatchRegistryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockBatchRegistry) Create(ctx context.Context, file *processor.File) (*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, file)
+	ret0, _ := ret[0].(*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockBatchRegistryMockRecorder) Create(ctx, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockBatchRegistry)(nil).Create), ctx, file)
+}
+
+// CreateBatch mocks base method.
+func (m *MockBatchRegistry) CreateBatch(ctx context.Context, files []*processor.File) ([]*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", ctx, files)
+	ret0, _ := ret[0].([]*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockBatchRegistryMockRecorder) CreateBatch(ctx, files interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockBatchRegistry)(nil).CreateBatch), ctx, files)
+}
+
+// FetchLatest mocks base method.
+func (m *MockBatchRegistry) FetchLatest(ctx context.Context, bucket, keyPrefix, path string) (*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLatest", ctx, bucket, keyPrefix, path)
+	ret0, _ := ret[0].(*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLatest indicates an expected call of FetchLatest.
+func (mr *MockBatchRegistryMockRecorder) FetchLatest(ctx, bucket, keyPrefix, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatest", reflect.TypeOf((*MockBatchRegistry)(nil).FetchLatest), ctx, bucket, keyPrefix, path)
+}
+
+// FetchLatestBySize mocks base method.
+func (m *MockBatchRegistry) FetchLatestBySize(ctx context.Context, bucket, keyPrefix string, size int64) ([]*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLatestBySize", ctx, bucket, keyPrefix, size)
+	ret0, _ := ret[0].([]*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLatestBySize indicates an expected call of FetchLatestBySize.
+func (mr *MockBatchRegistryMockRecorder) FetchLatestBySize(ctx, bucket, keyPrefix, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatestBySize", reflect.TypeOf((*MockBatchRegistry)(nil).FetchLatestBySize), ctx, bucket, keyPrefix, size)
+}
+
+// StreamLatest mocks base method.
+func (m *MockBatchRegistry) StreamLatest(ctx context.Context, bucket, keyPrefix string, since time.Time, fn func(*processor.File) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamLatest", ctx, bucket, keyPrefix, since, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamLatest indicates an expected call of StreamLatest.
+func (mr *MockBatchRegistryMockRecorder) StreamLatest(ctx, bucket, keyPrefix, since, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamLatest", reflect.TypeOf((*MockBatchRegistry)(nil).StreamLatest), ctx, bucket, keyPrefix, since, fn)
+}
@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: cache.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	processor "github.com/mspraggs/hoard/internal/processor"
+)
+
+// MockCachedRegistry is a mock of CachedRegistry interface.
+type MockCachedRegistry struct {
+	ctrl     *gomock.Controller
+	recorder *MockCachedRegistryMockRecorder
+}
+
+// MockCachedRegistryMockRecorder is the mock recorder for MockCachedRegistry.
+type MockCachedRegistryMockRecorder struct {
+	mock *MockCachedRegistry
+}
+
+// NewMockCachedRegistry creates a new mock instance.
+func NewMockCachedRegistry(ctrl *gomock.Controller) *MockCachedRegistry {
+	mock := &MockCachedRegistry{ctrl: ctrl}
+	mock.recorder = &MockCachedRegistryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCachedRegistry) EXPECT() *MockCachedRegistryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockCachedRegistry) Create(ctx context.Context, file *processor.File) (*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, file)
+	ret0, _ := ret[0].(*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockCachedRegistryMockRecorder) Create(ctx, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockCachedRegistry)(nil).Create), ctx, file)
+}
+
+// FetchLatest mocks base method.
+func (m *MockCachedRegistry) FetchLatest(ctx context.Context, bucket, keyPrefix, path string) (*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLatest", ctx, bucket, keyPrefix, path)
+	ret0, _ := ret[0].(*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLatest indicates an expected call of FetchLatest.
+func (mr *MockCachedRegistryMockRecorder) FetchLatest(ctx, bucket, keyPrefix, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatest", reflect.TypeOf((*MockCachedRegistry)(nil).FetchLatest), ctx, bucket, keyPrefix, path)
+}
+
+// FetchLatestBySize mocks base method.
+func (m *MockCachedRegistry) FetchLatestBySize(ctx context.Context, bucket, keyPrefix string, size int64) ([]*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLatestBySize", ctx, bucket, keyPrefix, size)
+	ret0, _ := ret[0].([]*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLatestBySize indicates an expected call of FetchLatestBySize.
+func (mr *MockCachedRegistryMockRecorder) FetchLatestBySize(ctx, bucket, keyPrefix, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatestBySize", reflect.TypeOf((*MockCachedRegistry)(nil).FetchLatestBySize), ctx, bucket, keyPrefix, size)
+}
+
+// StreamLatest mocks base method.
+func (m *MockCachedRegistry) StreamLatest(ctx context.Context, bucket, keyPrefix string, since time.Time, fn func(*processor.File) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamLatest", ctx, bucket, keyPrefix, since, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamLatest indicates an expected call of StreamLatest.
+func (mr *MockCachedRegistryMockRecorder) StreamLatest(ctx, bucket, keyPrefix, since, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamLatest", reflect.TypeOf((*MockCachedRegistry)(nil).StreamLatest), ctx, bucket, keyPrefix, since, fn)
+}
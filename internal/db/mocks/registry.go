@@ -111,18 +111,131 @@ func (m *MockLatestFetcher) EXPECT() *MockLatestFetcherMockRecorder {
 }
 
 // FetchLatest mocks base method.
-func (m *MockLatestFetcher) FetchLatest(ctx context.Context, tx db.Tx, path string) (*db.FileRow, error) {
+func (m *MockLatestFetcher) FetchLatest(ctx context.Context, tx db.Tx, bucket, keyPrefix, path string) (*db.FileRow, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchLatest", ctx, tx, path)
+	ret := m.ctrl.Call(m, "FetchLatest", ctx, tx, bucket, keyPrefix, path)
 	ret0, _ := ret[0].(*db.FileRow)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FetchLatest indicates an expected call of FetchLatest.
-func (mr *MockLatestFetcherMockRecorder) FetchLatest(ctx, tx, path interface{}) *gomock.Call {
+func (mr *MockLatestFetcherMockRecorder) FetchLatest(ctx, tx, bucket, keyPrefix, path interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatest", reflect.TypeOf((*MockLatestFetcher)(nil).FetchLatest), ctx, tx, path)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatest", reflect.TypeOf((*MockLatestFetcher)(nil).FetchLatest), ctx, tx, bucket, keyPrefix, path)
+}
+
+// MockSizeFetcher is a mock of SizeFetcher interface.
+type MockSizeFetcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockSizeFetcherMockRecorder
+}
+
+// MockSizeFetcherMockRecorder is the mock recorder for MockSizeFetcher.
+type MockSizeFetcherMockRecorder struct {
+	mock *MockSizeFetcher
+}
+
+// NewMockSizeFetcher creates a new mock instance.
+func NewMockSizeFetcher(ctrl *gomock.Controller) *MockSizeFetcher {
+	mock := &MockSizeFetcher{ctrl: ctrl}
+	mock.recorder = &MockSizeFetcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSizeFetcher) EXPECT() *MockSizeFetcherMockRecorder {
+	return m.recorder
+}
+
+// FetchLatestBySize mocks base method.
+func (m *MockSizeFetcher) FetchLatestBySize(ctx context.Context, tx db.Tx, bucket, keyPrefix string, size int64) ([]*db.FileRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLatestBySize", ctx, tx, bucket, keyPrefix, size)
+	ret0, _ := ret[0].([]*db.FileRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLatestBySize indicates an expected call of FetchLatestBySize.
+func (mr *MockSizeFetcherMockRecorder) FetchLatestBySize(ctx, tx, bucket, keyPrefix, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatestBySize", reflect.TypeOf((*MockSizeFetcher)(nil).FetchLatestBySize), ctx, tx, bucket, keyPrefix, size)
+}
+
+// MockLatestLister is a mock of LatestLister interface.
+type MockLatestLister struct {
+	ctrl     *gomock.Controller
+	recorder *MockLatestListerMockRecorder
+}
+
+// MockLatestListerMockRecorder is the mock recorder for MockLatestLister.
+type MockLatestListerMockRecorder struct {
+	mock *MockLatestLister
+}
+
+// NewMockLatestLister creates a new mock instance.
+func NewMockLatestLister(ctrl *gomock.Controller) *MockLatestLister {
+	mock := &MockLatestLister{ctrl: ctrl}
+	mock.recorder = &MockLatestListerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLatestLister) EXPECT() *MockLatestListerMockRecorder {
+	return m.recorder
+}
+
+// ListLatest mocks base method.
+func (m *MockLatestLister) ListLatest(ctx context.Context, tx db.Tx, bucket, keyPrefix string) ([]*db.FileRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLatest", ctx, tx, bucket, keyPrefix)
+	ret0, _ := ret[0].([]*db.FileRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLatest indicates an expected call of ListLatest.
+func (mr *MockLatestListerMockRecorder) ListLatest(ctx, tx, bucket, keyPrefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLatest", reflect.TypeOf((*MockLatestLister)(nil).ListLatest), ctx, tx, bucket, keyPrefix)
+}
+
+// MockLatestStreamer is a mock of LatestStreamer interface.
+type MockLatestStreamer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLatestStreamerMockRecorder
+}
+
+// MockLatestStreamerMockRecorder is the mock recorder for MockLatestStreamer.
+type MockLatestStreamerMockRecorder struct {
+	mock *MockLatestStreamer
+}
+
+// NewMockLatestStreamer creates a new mock instance.
+func NewMockLatestStreamer(ctrl *gomock.Controller) *MockLatestStreamer {
+	mock := &MockLatestStreamer{ctrl: ctrl}
+	mock.recorder = &MockLatestStreamerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLatestStreamer) EXPECT() *MockLatestStreamerMockRecorder {
+	return m.recorder
+}
+
+// StreamLatest mocks base method.
+func (m *MockLatestStreamer) StreamLatest(ctx context.Context, tx db.Tx, bucket, keyPrefix string, since time.Time, fn func(*db.FileRow) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamLatest", ctx, tx, bucket, keyPrefix, since, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamLatest indicates an expected call of StreamLatest.
+func (mr *MockLatestStreamerMockRecorder) StreamLatest(ctx, tx, bucket, keyPrefix, since, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamLatest", reflect.TypeOf((*MockLatestStreamer)(nil).StreamLatest), ctx, tx, bucket, keyPrefix, since, fn)
 }
 
 // MockCreator is a mock of Creator interface.
@@ -163,6 +276,119 @@ func (mr *MockCreatorMockRecorder) Create(ctx, tx, file interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockCreator)(nil).Create), ctx, tx, file)
 }
 
+// MockBatchCreator is a mock of BatchCreator interface.
+type MockBatchCreator struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchCreatorMockRecorder
+}
+
+// MockBatchCreatorMockRecorder is the mock recorder for MockBatchCreator.
+type MockBatchCreatorMockRecorder struct {
+	mock *MockBatchCreator
+}
+
+// NewMockBatchCreator creates a new mock instance.
+func NewMockBatchCreator(ctrl *gomock.Controller) *MockBatchCreator {
+	mock := &MockBatchCreator{ctrl: ctrl}
+	mock.recorder = &MockBatchCreatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchCreator) EXPECT() *MockBatchCreatorMockRecorder {
+	return m.recorder
+}
+
+// CreateBatch mocks base method.
+func (m *MockBatchCreator) CreateBatch(ctx context.Context, tx db.Tx, files []*db.FileRow) ([]*db.FileRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", ctx, tx, files)
+	ret0, _ := ret[0].([]*db.FileRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockBatchCreatorMockRecorder) CreateBatch(ctx, tx, files interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockBatchCreator)(nil).CreateBatch), ctx, tx, files)
+}
+
+// MockRunStarter is a mock of RunStarter interface.
+type MockRunStarter struct {
+	ctrl     *gomock.Controller
+	recorder *MockRunStarterMockRecorder
+}
+
+// MockRunStarterMockRecorder is the mock recorder for MockRunStarter.
+type MockRunStarterMockRecorder struct {
+	mock *MockRunStarter
+}
+
+// NewMockRunStarter creates a new mock instance.
+func NewMockRunStarter(ctrl *gomock.Controller) *MockRunStarter {
+	mock := &MockRunStarter{ctrl: ctrl}
+	mock.recorder = &MockRunStarterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRunStarter) EXPECT() *MockRunStarterMockRecorder {
+	return m.recorder
+}
+
+// StartRun mocks base method.
+func (m *MockRunStarter) StartRun(ctx context.Context, tx db.Tx, startedAt time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartRun", ctx, tx, startedAt)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartRun indicates an expected call of StartRun.
+func (mr *MockRunStarterMockRecorder) StartRun(ctx, tx, startedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartRun", reflect.TypeOf((*MockRunStarter)(nil).StartRun), ctx, tx, startedAt)
+}
+
+// MockRunFinisher is a mock of RunFinisher interface.
+type MockRunFinisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockRunFinisherMockRecorder
+}
+
+// MockRunFinisherMockRecorder is the mock recorder for MockRunFinisher.
+type MockRunFinisherMockRecorder struct {
+	mock *MockRunFinisher
+}
+
+// NewMockRunFinisher creates a new mock instance.
+func NewMockRunFinisher(ctrl *gomock.Controller) *MockRunFinisher {
+	mock := &MockRunFinisher{ctrl: ctrl}
+	mock.recorder = &MockRunFinisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRunFinisher) EXPECT() *MockRunFinisherMockRecorder {
+	return m.recorder
+}
+
+// FinishRun mocks base method.
+func (m *MockRunFinisher) FinishRun(ctx context.Context, tx db.Tx, run int64, finishedAt time.Time, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishRun", ctx, tx, run, finishedAt, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishRun indicates an expected call of FinishRun.
+func (mr *MockRunFinisherMockRecorder) FinishRun(ctx, tx, run, finishedAt, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishRun", reflect.TypeOf((*MockRunFinisher)(nil).FinishRun), ctx, tx, run, finishedAt, status)
+}
+
 // MockIDGenerator is a mock of IDGenerator interface.
 type MockIDGenerator struct {
 	ctrl     *gomock.Controller
@@ -21,7 +21,36 @@ type InTransactioner interface {
 // LatestFetcher defines the interface required to fetch the latest version of a
 // file within a database transaction.
 type LatestFetcher interface {
-	FetchLatest(ctx context.Context, tx Tx, path string) (*FileRow, error)
+	FetchLatest(ctx context.Context, tx Tx, bucket, keyPrefix, path string) (*FileRow, error)
+}
+
+// SizeFetcher defines the interface required to fetch the latest versions of
+// files with a given size within a database transaction.
+type SizeFetcher interface {
+	FetchLatestBySize(
+		ctx context.Context,
+		tx Tx,
+		bucket, keyPrefix string,
+		size int64,
+	) ([]*FileRow, error)
+}
+
+// LatestLister defines the interface required to list the latest versions of
+// all files in a bucket within a database transaction.
+type LatestLister interface {
+	ListLatest(ctx context.Context, tx Tx, bucket, keyPrefix string) ([]*FileRow, error)
+}
+
+// LatestStreamer defines the interface required to stream the latest versions
+// of the files in a bucket within a database transaction.
+type LatestStreamer interface {
+	StreamLatest(
+		ctx context.Context,
+		tx Tx,
+		bucket, keyPrefix string,
+		since time.Time,
+		fn func(*FileRow) error,
+	) error
 }
 
 // Creator defines the interface required to create a file within a database
@@ -30,6 +59,24 @@ type Creator interface {
 	Create(ctx context.Context, tx Tx, file *FileRow) (*FileRow, error)
 }
 
+// BatchCreator defines the interface required to create several files within a
+// database transaction.
+type BatchCreator interface {
+	CreateBatch(ctx context.Context, tx Tx, files []*FileRow) ([]*FileRow, error)
+}
+
+// RunStarter defines the interface required to record the start of a backup run
+// within a database transaction.
+type RunStarter interface {
+	StartRun(ctx context.Context, tx Tx, startedAt time.Time) (int64, error)
+}
+
+// RunFinisher defines the interface required to record the end of a backup run
+// within a database transaction.
+type RunFinisher interface {
+	FinishRun(ctx context.Context, tx Tx, run int64, finishedAt time.Time, status string) error
+}
+
 // IDGenerator defines the interface required to generate a request ID for a
 // given file upload.
 type IDGenerator interface {
@@ -40,14 +87,28 @@ type IDGenerator interface {
 // file uploads. The registry maintains a record of details associated with a
 // file upload, including a file version string and the timestamp at which the
 // file was uploaded.
+//
+// Paths are recorded relative to the directory being backed up, so several
+// directories stored in one bucket are told apart by the prefix of their
+// files' keys. Lookups are therefore made within a bucket and key prefix.
 type Registry struct {
-	clock         Clock
-	idGen         IDGenerator
-	inTxner       InTransactioner
-	latestFetcher LatestFetcher
-	creator       Creator
+	clock          Clock
+	idGen          IDGenerator
+	inTxner        InTransactioner
+	latestFetcher  LatestFetcher
+	creator        Creator
+	runStarter     RunStarter
+	runFinisher    RunFinisher
+	sizeFetcher    SizeFetcher
+	latestLister   LatestLister
+	latestStreamer LatestStreamer
+	batchCreator   BatchCreator
 }
 
+// RegistryOption is the type used to implement the functional options pattern
+// for the Registry type.
+type RegistryOption func(*Registry)
+
 // NewRegistry instantiates a new Registry using the provided Clock,
 // InTransactioner and IDGenerator instances.
 func NewRegistry(
@@ -56,13 +117,73 @@ func NewRegistry(
 	creator Creator,
 	latestFetcher LatestFetcher,
 	idGen IDGenerator,
+	opts ...RegistryOption,
 ) *Registry {
 
-	return &Registry{
-		clock:         clock,
-		idGen:         idGen,
-		inTxner:       inTxner,
-		latestFetcher: latestFetcher,
-		creator:       creator,
+	r := &Registry{
+		clock:          clock,
+		idGen:          idGen,
+		inTxner:        inTxner,
+		latestFetcher:  latestFetcher,
+		creator:        creator,
+		runStarter:     NewRunStarterTx(),
+		runFinisher:    NewRunFinisherTx(),
+		sizeFetcher:    NewSizeFetcherTx(),
+		latestLister:   NewLatestListerTx(),
+		latestStreamer: NewLatestStreamerTx(),
+		batchCreator:   NewBatchCreatorTx(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// WithRunStarter returns an option that sets the RunStarter used to record the
+// start of backup runs.
+func WithRunStarter(runStarter RunStarter) RegistryOption {
+	return func(r *Registry) {
+		r.runStarter = runStarter
+	}
+}
+
+// WithRunFinisher returns an option that sets the RunFinisher used to record
+// the end of backup runs.
+func WithRunFinisher(runFinisher RunFinisher) RegistryOption {
+	return func(r *Registry) {
+		r.runFinisher = runFinisher
+	}
+}
+
+// WithSizeFetcher returns an option that sets the SizeFetcher used to find
+// files by size.
+func WithSizeFetcher(sizeFetcher SizeFetcher) RegistryOption {
+	return func(r *Registry) {
+		r.sizeFetcher = sizeFetcher
+	}
+}
+
+// WithLatestLister returns an option that sets the LatestLister used to list
+// the latest versions of files.
+func WithLatestLister(latestLister LatestLister) RegistryOption {
+	return func(r *Registry) {
+		r.latestLister = latestLister
+	}
+}
+
+// WithLatestStreamer returns an option that sets the LatestStreamer used to
+// stream the latest versions of files.
+func WithLatestStreamer(latestStreamer LatestStreamer) RegistryOption {
+	return func(r *Registry) {
+		r.latestStreamer = latestStreamer
+	}
+}
+
+// WithBatchCreator returns an option that sets the BatchCreator used to create
+// several files at once.
+func WithBatchCreator(batchCreator BatchCreator) RegistryOption {
+	return func(r *Registry) {
+		r.batchCreator = batchCreator
 	}
 }
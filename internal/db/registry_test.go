@@ -18,6 +18,12 @@ type RegistryTestSuite struct {
 	mockCreator         *mocks.MockCreator
 	mockLatestFetcher   *mocks.MockLatestFetcher
 	mockInTransactioner *mocks.MockInTransactioner
+	mockRunStarter      *mocks.MockRunStarter
+	mockRunFinisher     *mocks.MockRunFinisher
+	mockSizeFetcher     *mocks.MockSizeFetcher
+	mockLatestLister    *mocks.MockLatestLister
+	mockLatestStreamer  *mocks.MockLatestStreamer
+	mockBatchCreator    *mocks.MockBatchCreator
 }
 
 type MockClock struct {
@@ -33,6 +39,12 @@ func (s *RegistryTestSuite) SetupTest() {
 	s.mockCreator = mocks.NewMockCreator(s.controller)
 	s.mockLatestFetcher = mocks.NewMockLatestFetcher(s.controller)
 	s.mockInTransactioner = mocks.NewMockInTransactioner(s.controller)
+	s.mockRunStarter = mocks.NewMockRunStarter(s.controller)
+	s.mockRunFinisher = mocks.NewMockRunFinisher(s.controller)
+	s.mockSizeFetcher = mocks.NewMockSizeFetcher(s.controller)
+	s.mockLatestLister = mocks.NewMockLatestLister(s.controller)
+	s.mockLatestStreamer = mocks.NewMockLatestStreamer(s.controller)
+	s.mockBatchCreator = mocks.NewMockBatchCreator(s.controller)
 }
 
 type fakeIDGenerator func() string
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const finishRun = `-- name: FinishRun :exec
+UPDATE files.runs
+SET finished_at_timestamp = $2, status = $3
+WHERE id = $1
+`
+
+// RunFinisherTx provides the logic to record the end of a backup run within a
+// transaction.
+type RunFinisherTx struct{}
+
+// NewRunFinisherTx instantiates a new RunFinisherTx instance.
+func NewRunFinisherTx() *RunFinisherTx {
+	return &RunFinisherTx{}
+}
+
+// FinishRun records the time at which the run with the provided ID finished,
+// along with its outcome, using the provided transaction.
+func (rf *RunFinisherTx) FinishRun(
+	ctx context.Context,
+	tx Tx,
+	run int64,
+	finishedAt time.Time,
+	status string,
+) error {
+
+	result, err := tx.ExecContext(ctx, finishRun, run, finishedAt, status)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no run with ID %d", run)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const finishRunQuery = `
+UPDATE files.runs
+SET finished_at_timestamp = \$2, status = \$3
+WHERE id = \$1
+`
+
+type RunFinisherTestSuite struct {
+	dbTestSuite
+}
+
+func TestRunFinisherTestSuite(t *testing.T) {
+	suite.Run(t, new(RunFinisherTestSuite))
+}
+
+func (s *RunFinisherTestSuite) TestFinishRun() {
+	finishedAt := time.Unix(123, 456).UTC()
+
+	s.Run("updates run", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(finishRunQuery).
+			WithArgs(int64(7), finishedAt, "interrupted").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		runFinisher := db.NewRunFinisherTx()
+
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return runFinisher.FinishRun(context.Background(), tx, 7, finishedAt, "interrupted")
+		})
+
+		s.Require().NoError(err)
+		s.NoError(mock.ExpectationsWereMet())
+	})
+
+	s.Run("handles missing run", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(finishRunQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		runFinisher := db.NewRunFinisherTx()
+
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return runFinisher.FinishRun(context.Background(), tx, 7, finishedAt, "success")
+		})
+
+		s.ErrorContains(err, "no run with ID 7")
+	})
+
+	s.Run("handles error from transaction", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		expectedErr := errors.New("oh no")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(finishRunQuery).WillReturnError(expectedErr)
+		mock.ExpectRollback()
+
+		runFinisher := db.NewRunFinisherTx()
+
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return runFinisher.FinishRun(context.Background(), tx, 7, finishedAt, "success")
+		})
+
+		s.ErrorIs(err, expectedErr)
+	})
+}
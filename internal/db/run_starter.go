@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const startRun = `-- name: StartRun :one
+INSERT INTO files.runs (
+	started_at_timestamp
+) VALUES (
+	$1
+)
+RETURNING id
+`
+
+// RunStarterTx provides the logic to record the start of a backup run within a
+// transaction.
+type RunStarterTx struct{}
+
+// NewRunStarterTx instantiates a new RunStarterTx instance.
+func NewRunStarterTx() *RunStarterTx {
+	return &RunStarterTx{}
+}
+
+// StartRun inserts a new run into the database using the provided transaction
+// and returns its sequential ID.
+func (rs *RunStarterTx) StartRun(
+	ctx context.Context,
+	tx Tx,
+	startedAt time.Time,
+) (int64, error) {
+
+	row := tx.QueryRowContext(ctx, startRun, startedAt)
+
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
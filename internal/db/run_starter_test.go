@@ -0,0 +1,82 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const startRunQuery = `
+INSERT INTO files.runs \(
+	started_at_timestamp
+\) VALUES \(
+	\$1
+\)
+RETURNING id
+`
+
+type RunStarterTestSuite struct {
+	dbTestSuite
+}
+
+func TestRunStarterTestSuite(t *testing.T) {
+	suite.Run(t, new(RunStarterTestSuite))
+}
+
+func (s *RunStarterTestSuite) TestStartRun() {
+	startedAt := time.Unix(123, 456).UTC()
+
+	s.Run("inserts run and returns its ID", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows([]string{"id"}).AddRow(int64(7))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(startRunQuery).WithArgs(startedAt).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		runStarter := db.NewRunStarterTx()
+
+		var run int64
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			run, err = runStarter.StartRun(context.Background(), tx, startedAt)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(int64(7), run)
+	})
+
+	s.Run("handles error from transaction", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows([]string{"id"})
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(startRunQuery).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		runStarter := db.NewRunStarterTx()
+
+		var run int64
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			run, err = runStarter.StartRun(context.Background(), tx, startedAt)
+			return err
+		})
+
+		s.ErrorIs(err, sql.ErrNoRows)
+		s.Zero(run)
+	})
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+)
+
+const getLatestFilesBySize = `-- name: GetLatestFilesBySize :many
+SELECT
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+FROM files.files f
+WHERE bucket = $1
+	AND starts_with(key, $2)
+	AND size = $3
+	AND created_at_timestamp = (
+		SELECT MAX(created_at_timestamp)
+		FROM files.files
+		WHERE bucket = f.bucket
+			AND starts_with(key, $2)
+			AND local_path = f.local_path
+	)
+`
+
+// SizeFetcherTx provides the logic to fetch the most recent versions of files
+// with a given size within a transaction.
+type SizeFetcherTx struct{}
+
+// NewSizeFetcherTx instantiates a new SizeFetcherTx instance.
+func NewSizeFetcherTx() *SizeFetcherTx {
+	return &SizeFetcherTx{}
+}
+
+// FetchLatestBySize returns the most recent version of every file stored in
+// the provided bucket under a key beginning with the provided prefix, where
+// that version has the provided size.
+func (sf *SizeFetcherTx) FetchLatestBySize(
+	ctx context.Context,
+	tx Tx,
+	bucket, keyPrefix string,
+	size int64,
+) ([]*FileRow, error) {
+
+	rows, err := tx.QueryContext(ctx, getLatestFilesBySize, bucket, keyPrefix, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fileRows []*FileRow
+	for rows.Next() {
+		var row FileRow
+		if err := rows.Scan(
+			&row.ID,
+			&row.Key,
+			&row.LocalPath,
+			&row.Checksum,
+			&row.CTime,
+			&row.Bucket,
+			&row.ETag,
+			&row.Version,
+			&row.PackID,
+			&row.PackOffset,
+			&row.PackLength,
+			&row.KeyLayout,
+			&row.StorageClass,
+			&row.RetainUntil,
+			&row.LegalHold,
+			&row.ChecksumAlgorithm,
+			&row.Size,
+			&row.MTime,
+			&row.Inconsistent,
+			&row.Metadata,
+			&row.FileType,
+			&row.LinkTarget,
+			&row.DeviceMajor,
+			&row.DeviceMinor,
+			&row.Extents,
+			&row.CreatedAtTimestamp,
+		); err != nil {
+			return nil, err
+		}
+		fileRows = append(fileRows, &row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fileRows, nil
+}
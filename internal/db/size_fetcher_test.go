@@ -0,0 +1,145 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const selectBySizeQuery = `
+SELECT
+	id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp
+FROM files.files f
+WHERE bucket = \$1
+	AND starts_with\(key, \$2\)
+	AND size = \$3
+	AND created_at_timestamp = \(
+		SELECT MAX\(created_at_timestamp\)
+		FROM files.files
+		WHERE bucket = f.bucket
+			AND starts_with\(key, \$2\)
+			AND local_path = f.local_path
+	\)
+`
+
+type SizeFetcherTestSuite struct {
+	dbTestSuite
+}
+
+func TestSizeFetcherTestSuite(t *testing.T) {
+	suite.Run(t, new(SizeFetcherTestSuite))
+}
+
+func (s *SizeFetcherTestSuite) TestFetchLatestBySize() {
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
+	size := int64(1024)
+
+	fileRows := []*db.FileRow{
+		{
+			ID:                 "some-id",
+			Key:                "some-key",
+			LocalPath:          "/some/path",
+			Checksum:           db.Checksum{0, 0, 0, 42},
+			ChecksumAlgorithm:  "CRC32",
+			Bucket:             bucket,
+			Size:               size,
+			CreatedAtTimestamp: time.Unix(1, 0).UTC(),
+		},
+		{
+			ID:                 "some-other-id",
+			Key:                "some-other-key",
+			LocalPath:          "/some/other/path",
+			Checksum:           db.Checksum{0, 0, 0, 43},
+			ChecksumAlgorithm:  "CRC32",
+			Bucket:             bucket,
+			Size:               size,
+			CreatedAtTimestamp: time.Unix(2, 0).UTC(),
+		},
+	}
+
+	s.Run("returns matching rows", func() {
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		rows := sqlmock.NewRows(insertRows)
+		addFileRowsToRows(rows, fileRows...)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectBySizeQuery).WithArgs(bucket, keyPrefix, size).WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		sizeFetcher := db.NewSizeFetcherTx()
+
+		var fetchedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			fetchedRows, err = sizeFetcher.FetchLatestBySize(
+				context.Background(), tx, bucket, keyPrefix, size,
+			)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRows, fetchedRows)
+	})
+
+	s.Run("returns error from query", func() {
+		expectedErr := errors.New("fail")
+
+		d, mock, err := sqlmock.New()
+		s.Require().NoError(err)
+		defer d.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectBySizeQuery).WithArgs(bucket, keyPrefix, size).WillReturnError(expectedErr)
+		mock.ExpectRollback()
+
+		sizeFetcher := db.NewSizeFetcherTx()
+
+		var fetchedRows []*db.FileRow
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			fetchedRows, err = sizeFetcher.FetchLatestBySize(
+				context.Background(), tx, bucket, keyPrefix, size,
+			)
+			return err
+		})
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(fetchedRows)
+	})
+}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const createFilesPrefix = `
+INSERT INTO files (
+	` + fileColumns + `
+) VALUES
+`
+
+const createFilesSuffix = `
+RETURNING ` + fileColumns
+
+// MaxBatchRows is the largest number of rows inserted by a single statement,
+// which keeps the number of query parameters below the SQLite limit of 32766.
+const MaxBatchRows = 1000
+
+// BatchCreatorTx provides the logic to insert several files into an SQLite
+// database with a single statement within a transaction.
+type BatchCreatorTx struct{}
+
+// NewBatchCreatorTx instantiates a new BatchCreatorTx instance.
+func NewBatchCreatorTx() *BatchCreatorTx {
+	return &BatchCreatorTx{}
+}
+
+// CreateBatch inserts the provided rows into the database using the provided
+// transaction. The inserted rows are returned in the same order as the
+// provided rows. Batches larger than MaxBatchRows are inserted using several
+// statements.
+func (c *BatchCreatorTx) CreateBatch(
+	ctx context.Context,
+	tx db.Tx,
+	files []*db.FileRow,
+) ([]*db.FileRow, error) {
+
+	inserted := make([]*db.FileRow, 0, len(files))
+	for start := 0; start < len(files); start += MaxBatchRows {
+		end := start + MaxBatchRows
+		if end > len(files) {
+			end = len(files)
+		}
+
+		rows, err := c.insert(ctx, tx, files[start:end])
+		if err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, rows...)
+	}
+
+	return inserted, nil
+}
+
+func (c *BatchCreatorTx) insert(ctx context.Context, tx db.Tx, files []*db.FileRow) ([]*db.FileRow, error) {
+	placeholders := "(" + strings.Repeat("?, ", fileRowColumns-1) + "?)"
+
+	var query strings.Builder
+	query.WriteString(createFilesPrefix)
+	args := make([]interface{}, 0, len(files)*fileRowColumns)
+
+	for i, file := range files {
+		if i > 0 {
+			query.WriteString(",\n")
+		}
+		query.WriteString("\t" + placeholders)
+		args = append(args, fileRowArgs(file)...)
+	}
+	query.WriteString(createFilesSuffix)
+
+	rows, err := tx.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// SQLite makes no promise about the order of the rows returned by a
+	// multi-row insert either, so they are matched to the inputs using their
+	// IDs.
+	byID := make(map[string]*db.FileRow, len(files))
+	for rows.Next() {
+		row, err := scanFileRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[row.ID] = row
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	inserted := make([]*db.FileRow, len(files))
+	for i, file := range files {
+		row, ok := byID[file.ID]
+		if !ok {
+			return nil, fmt.Errorf("no row returned for file %q", file.LocalPath)
+		}
+		inserted[i] = row
+	}
+
+	return inserted, nil
+}
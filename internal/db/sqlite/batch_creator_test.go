@@ -0,0 +1,90 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type BatchCreatorTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestBatchCreatorTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchCreatorTestSuite))
+}
+
+func (s *BatchCreatorTestSuite) TestCreateBatch() {
+	createdAt := time.Unix(3, 0).UTC()
+
+	s.Run("inserts provided rows in order", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		fileRows := []*db.FileRow{
+			newFileRow("id-b", "/some/path/b", createdAt),
+			newFileRow("id-a", "/some/path/a", createdAt),
+		}
+
+		batchCreator := sqlite.NewBatchCreatorTx()
+
+		var insertedRows []*db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRows, err = batchCreator.CreateBatch(context.Background(), tx, fileRows)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRows, insertedRows)
+	})
+
+	s.Run("splits large batches across statements", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		fileRows := make([]*db.FileRow, sqlite.MaxBatchRows+1)
+		for i := range fileRows {
+			id := fmt.Sprintf("id-%d", i)
+			fileRows[i] = newFileRow(id, "/some/path/"+id, createdAt)
+		}
+
+		batchCreator := sqlite.NewBatchCreatorTx()
+
+		var insertedRows []*db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRows, err = batchCreator.CreateBatch(context.Background(), tx, fileRows)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRows, insertedRows)
+	})
+
+	s.Run("handles error from query", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		fileRow := newFileRow("some-id", "/some/path", createdAt)
+		s.insertFiles(d, fileRow)
+
+		batchCreator := sqlite.NewBatchCreatorTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			_, err := batchCreator.CreateBatch(
+				context.Background(), tx, []*db.FileRow{fileRow},
+			)
+			return err
+		})
+
+		s.Error(err)
+	})
+}
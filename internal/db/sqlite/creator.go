@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const createFile = `
+INSERT INTO files (
+	` + fileColumns + `
+) VALUES (
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING ` + fileColumns
+
+// CreatorTx provides the logic to insert a file into an SQLite database within
+// a transaction.
+type CreatorTx struct{}
+
+// NewCreatorTx instantiates a new CreatorTx instance.
+func NewCreatorTx() *CreatorTx {
+	return &CreatorTx{}
+}
+
+// Create inserts the provided row into the database using the provided
+// transaction.
+func (c *CreatorTx) Create(
+	ctx context.Context,
+	tx db.Tx,
+	file *db.FileRow,
+) (*db.FileRow, error) {
+
+	row := tx.QueryRowContext(ctx, createFile, fileRowArgs(file)...)
+
+	return scanFileRow(row)
+}
@@ -0,0 +1,109 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type CreatorTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestCreatorTestSuite(t *testing.T) {
+	suite.Run(t, new(CreatorTestSuite))
+}
+
+func (s *CreatorTestSuite) TestCreate() {
+	fileRow := &db.FileRow{
+		ID:                "some-id",
+		Key:               "some-key",
+		LocalPath:         "/some/path",
+		Checksum:          db.Checksum{0, 0, 0, 42},
+		CTime:             time.Unix(1, 2).UTC(),
+		Bucket:            "some-bucket",
+		ETag:              "some-etag",
+		Version:           "some-version",
+		PackID:            "some-pack",
+		PackOffset:        10,
+		PackLength:        20,
+		KeyLayout:         "mirror",
+		StorageClass:      "STANDARD_IA",
+		RetainUntil:       sql.NullTime{Time: time.Unix(3, 0).UTC(), Valid: true},
+		LegalHold:         true,
+		ChecksumAlgorithm: "SHA256",
+		Size:              30,
+		MTime:             time.Unix(4, 5).UTC(),
+		Inconsistent:      true,
+		Metadata:          db.Metadata(`{"uid":1000}`),
+		FileType:          "symlink",
+		LinkTarget:        "/some/target",
+		DeviceMajor:       8,
+		DeviceMinor:       1,
+		Extents:           db.Extents(`[{"offset":0,"length":10}]`),
+		// Times in other zones are stored in UTC, so that they can be
+		// compared as text.
+		CreatedAtTimestamp: time.Unix(6, 7).In(time.FixedZone("some-zone", 3600)),
+	}
+
+	s.Run("inserts provided row", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		creator := sqlite.NewCreatorTx()
+
+		var insertedRow *db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRow, err = creator.Create(context.Background(), tx, fileRow)
+			return err
+		})
+
+		expectedRow := *fileRow
+		expectedRow.CreatedAtTimestamp = fileRow.CreatedAtTimestamp.UTC()
+
+		s.Require().NoError(err)
+		s.Equal(&expectedRow, insertedRow)
+	})
+
+	s.Run("inserts row without optional values", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		fileRow := newFileRow("some-id", "/some/path", time.Unix(6, 0).UTC())
+
+		creator := sqlite.NewCreatorTx()
+
+		var insertedRow *db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			insertedRow, err = creator.Create(context.Background(), tx, fileRow)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(fileRow, insertedRow)
+	})
+
+	s.Run("handles error from transaction", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		s.insertFiles(d, fileRow)
+
+		creator := sqlite.NewCreatorTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			_, err := creator.Create(context.Background(), tx, fileRow)
+			return err
+		})
+
+		s.Error(err)
+	})
+}
@@ -0,0 +1,49 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const getLatestFile = `
+SELECT
+	` + fileColumns + `
+FROM files
+WHERE bucket = ?
+	AND substr(key, 1, length(?)) = ?
+	AND local_path = ?
+ORDER BY created_at_timestamp DESC
+LIMIT 1
+`
+
+// LatestFetcherTx provides the logic to fetch the most recent version of a
+// file with a given path from an SQLite database within a transaction.
+type LatestFetcherTx struct{}
+
+// NewLatestFetcherTx instantiates a new LatestFetcherTx instance.
+func NewLatestFetcherTx() *LatestFetcherTx {
+	return &LatestFetcherTx{}
+}
+
+// FetchLatest returns the most recent version of a file with the provided path,
+// stored in the provided bucket under a key beginning with the provided prefix.
+func (lf *LatestFetcherTx) FetchLatest(
+	ctx context.Context,
+	tx db.Tx,
+	bucket, keyPrefix, path string,
+) (*db.FileRow, error) {
+
+	row, err := scanFileRow(tx.QueryRowContext(
+		ctx, getLatestFile, bucket, keyPrefix, keyPrefix, path,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return row, nil
+}
@@ -0,0 +1,84 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type LatestFetcherTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestLatestFetcherTestSuite(t *testing.T) {
+	suite.Run(t, new(LatestFetcherTestSuite))
+}
+
+func (s *LatestFetcherTestSuite) TestFetchLatest() {
+	s.Run("returns latest row", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		// The versions differ only in their fractional seconds, which must
+		// still be ordered correctly when the times are compared as text.
+		older := newFileRow("id-1", "/some/path", time.Unix(2, 0).UTC())
+		latest := newFileRow("id-2", "/some/path", time.Unix(2, 500).UTC())
+		other := newFileRow("id-3", "/some/other/path", time.Unix(3, 0).UTC())
+		s.insertFiles(d, older, latest, other)
+
+		latestFetcher := sqlite.NewLatestFetcherTx()
+
+		var row *db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			row, err = latestFetcher.FetchLatest(
+				context.Background(), tx, "some-bucket", "key-", "/some/path",
+			)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal(latest, row)
+	})
+
+	s.Run("returns nil when no matching rows", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		latestFetcher := sqlite.NewLatestFetcherTx()
+
+		var row *db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			row, err = latestFetcher.FetchLatest(
+				context.Background(), tx, "some-bucket", "key-", "/some/path",
+			)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Nil(row)
+	})
+
+	s.Run("returns error from query", func() {
+		d := s.openDB()
+		defer d.Close()
+
+		latestFetcher := sqlite.NewLatestFetcherTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			_, err := latestFetcher.FetchLatest(
+				context.Background(), tx, "some-bucket", "key-", "/some/path",
+			)
+			return err
+		})
+
+		s.Error(err)
+	})
+}
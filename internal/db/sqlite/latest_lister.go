@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const listLatestFiles = `
+SELECT
+	` + fileColumns + `
+FROM files f
+WHERE bucket = ?
+	AND substr(key, 1, length(?)) = ?
+	AND created_at_timestamp = (
+		SELECT MAX(created_at_timestamp)
+		FROM files
+		WHERE bucket = f.bucket
+			AND substr(key, 1, length(?)) = ?
+			AND local_path = f.local_path
+	)
+ORDER BY local_path
+`
+
+// LatestListerTx provides the logic to list the most recent versions of files
+// in an SQLite database within a transaction.
+type LatestListerTx struct{}
+
+// NewLatestListerTx instantiates a new LatestListerTx instance.
+func NewLatestListerTx() *LatestListerTx {
+	return &LatestListerTx{}
+}
+
+// ListLatest returns the most recent version of every file stored in the
+// provided bucket under a key beginning with the provided prefix, ordered by
+// local path.
+func (ll *LatestListerTx) ListLatest(
+	ctx context.Context,
+	tx db.Tx,
+	bucket, keyPrefix string,
+) ([]*db.FileRow, error) {
+
+	return queryFileRows(
+		ctx, tx, listLatestFiles, bucket, keyPrefix, keyPrefix, keyPrefix, keyPrefix,
+	)
+}
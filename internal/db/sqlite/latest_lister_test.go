@@ -0,0 +1,64 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type LatestListerTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestLatestListerTestSuite(t *testing.T) {
+	suite.Run(t, new(LatestListerTestSuite))
+}
+
+func (s *LatestListerTestSuite) TestListLatest() {
+	bucket := "some-bucket"
+	keyPrefix := "key-"
+
+	s.Run("returns matching rows", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		superseded := newFileRow("id-1", "/some/path/b", time.Unix(1, 0).UTC())
+		latest := newFileRow("id-2", "/some/path/b", time.Unix(2, 0).UTC())
+		other := newFileRow("id-3", "/some/path/a", time.Unix(3, 0).UTC())
+		otherBucket := newFileRow("id-4", "/some/path/c", time.Unix(4, 0).UTC())
+		otherBucket.Bucket = "some-other-bucket"
+		s.insertFiles(d, superseded, latest, other, otherBucket)
+
+		latestLister := sqlite.NewLatestListerTx()
+
+		var rows []*db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			rows, err = latestLister.ListLatest(context.Background(), tx, bucket, keyPrefix)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal([]*db.FileRow{other, latest}, rows)
+	})
+
+	s.Run("returns error from query", func() {
+		d := s.openDB()
+		defer d.Close()
+
+		latestLister := sqlite.NewLatestListerTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			_, err := latestLister.ListLatest(context.Background(), tx, bucket, keyPrefix)
+			return err
+		})
+
+		s.Error(err)
+	})
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const streamLatestFiles = `
+SELECT
+	` + fileColumns + `
+FROM (
+	SELECT
+		*,
+		ROW_NUMBER() OVER (
+			PARTITION BY local_path
+			ORDER BY created_at_timestamp DESC
+		) AS version_rank
+	FROM files
+	WHERE bucket = ?
+		AND substr(key, 1, length(?)) = ?
+		AND created_at_timestamp > ?
+)
+WHERE version_rank = 1
+ORDER BY local_path
+`
+
+// LatestStreamerTx provides the logic to stream the most recent versions of
+// files from an SQLite database within a transaction.
+type LatestStreamerTx struct{}
+
+// NewLatestStreamerTx instantiates a new LatestStreamerTx instance.
+func NewLatestStreamerTx() *LatestStreamerTx {
+	return &LatestStreamerTx{}
+}
+
+// StreamLatest calls the provided function with the most recent version of
+// every file stored in the provided bucket under a key beginning with the
+// provided prefix, considering only versions created after the provided time. Rows are passed to the function as they are read,
+// so that the result set is never held in memory all at once.
+func (ls *LatestStreamerTx) StreamLatest(
+	ctx context.Context,
+	tx db.Tx,
+	bucket, keyPrefix string,
+	since time.Time,
+	fn func(*db.FileRow) error,
+) error {
+
+	rows, err := tx.QueryContext(ctx, streamLatestFiles, bucket, keyPrefix, keyPrefix, since.UTC())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scanFileRow(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
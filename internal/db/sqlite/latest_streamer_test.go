@@ -0,0 +1,128 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type LatestStreamerTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestLatestStreamerTestSuite(t *testing.T) {
+	suite.Run(t, new(LatestStreamerTestSuite))
+}
+
+func (s *LatestStreamerTestSuite) TestStreamLatest() {
+	bucket := "some-bucket"
+	keyPrefix := "key-"
+	since := time.Unix(2, 0).UTC()
+
+	insertFiles := func(d *sql.DB) []*db.FileRow {
+		before := newFileRow("id-1", "/some/path/c", time.Unix(1, 0).UTC())
+		superseded := newFileRow("id-2", "/some/path/b", time.Unix(3, 0).UTC())
+		latest := newFileRow("id-3", "/some/path/b", time.Unix(4, 0).UTC())
+		other := newFileRow("id-4", "/some/path/a", time.Unix(5, 0).UTC())
+		otherBucket := newFileRow("id-5", "/some/path/d", time.Unix(6, 0).UTC())
+		otherBucket.Bucket = "some-other-bucket"
+		s.insertFiles(d, before, superseded, latest, other, otherBucket)
+
+		return []*db.FileRow{other, latest}
+	}
+
+	s.Run("passes each row to function", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		expectedRows := insertFiles(d)
+
+		latestStreamer := sqlite.NewLatestStreamerTx()
+
+		var streamedRows []*db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix, since,
+				func(row *db.FileRow) error {
+					streamedRows = append(streamedRows, row)
+					return nil
+				},
+			)
+		})
+
+		s.Require().NoError(err)
+		s.Equal(expectedRows, streamedRows)
+	})
+
+	s.Run("compares times in other zones in UTC", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		expectedRows := insertFiles(d)
+
+		latestStreamer := sqlite.NewLatestStreamerTx()
+
+		var streamedRows []*db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix,
+				since.In(time.FixedZone("some-zone", -3600)),
+				func(row *db.FileRow) error {
+					streamedRows = append(streamedRows, row)
+					return nil
+				},
+			)
+		})
+
+		s.Require().NoError(err)
+		s.Equal(expectedRows, streamedRows)
+	})
+
+	s.Run("stops at error from function", func() {
+		expectedErr := errors.New("fail")
+
+		d := s.newDB()
+		defer d.Close()
+
+		insertFiles(d)
+
+		latestStreamer := sqlite.NewLatestStreamerTx()
+
+		calls := 0
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix, since,
+				func(row *db.FileRow) error {
+					calls++
+					return expectedErr
+				},
+			)
+		})
+
+		s.ErrorIs(err, expectedErr)
+		s.Equal(1, calls)
+	})
+
+	s.Run("returns error from query", func() {
+		d := s.openDB()
+		defer d.Close()
+
+		latestStreamer := sqlite.NewLatestStreamerTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			return latestStreamer.StreamLatest(
+				context.Background(), tx, bucket, keyPrefix, since,
+				func(row *db.FileRow) error { return nil },
+			)
+		})
+
+		s.Error(err)
+	})
+}
@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const upSuffix = ".up.sql"
+
+const createSchemaMigrations = `
+CREATE TABLE IF NOT EXISTS schema_migrations (version uint64, dirty bool);
+CREATE UNIQUE INDEX IF NOT EXISTS version_unique ON schema_migrations (version);
+`
+
+const selectSchemaVersion = `
+SELECT version, dirty FROM schema_migrations LIMIT 1
+`
+
+const setSchemaVersion = `
+DELETE FROM schema_migrations;
+INSERT INTO schema_migrations (version, dirty) VALUES (?, FALSE);
+`
+
+// migration is a single up migration, identified by the version at the start
+// of its file name.
+type migration struct {
+	version uint64
+	name    string
+}
+
+// Migrate applies the up migrations in the provided filesystem that are newer
+// than the database's current version, each in its own transaction. Versions
+// are recorded in a schema_migrations table of the same form as the one used
+// by golang-migrate, so databases migrated by either tool remain compatible.
+// An error is returned if a previous migration was left incomplete.
+func Migrate(ctx context.Context, inTxner db.InTransactioner, migrations fs.FS) error {
+	pending, err := upMigrations(migrations)
+	if err != nil {
+		return err
+	}
+
+	var current uint64
+	err = inTxner.InTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		if _, err := tx.ExecContext(ctx, createSchemaMigrations); err != nil {
+			return err
+		}
+
+		current, err = currentVersion(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read schema version: %w", err)
+	}
+
+	for _, m := range pending {
+		if m.version <= current {
+			continue
+		}
+
+		contents, err := fs.ReadFile(migrations, m.name)
+		if err != nil {
+			return err
+		}
+
+		err = inTxner.InTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(contents)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, setSchemaVersion, m.version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to apply migration %q: %w", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// currentVersion returns the version of the last migration applied to the
+// database, or zero if none has been.
+func currentVersion(ctx context.Context, tx db.Tx) (uint64, error) {
+	var version uint64
+	var dirty bool
+	err := tx.QueryRowContext(ctx, selectSchemaVersion).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("migration to version %d was left incomplete", version)
+	}
+
+	return version, nil
+}
+
+// upMigrations returns the up migrations in the provided filesystem, ordered by
+// version.
+func upMigrations(migrations fs.FS) ([]migration, error) {
+	names, err := fs.Glob(migrations, "*"+upSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	ups := make([]migration, len(names))
+	for i, name := range names {
+		prefix := name
+		if end := strings.IndexAny(name, "_."); end >= 0 {
+			prefix = name[:end]
+		}
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse version of migration %q: %w", name, err)
+		}
+		ups[i] = migration{version: version, name: name}
+	}
+
+	sort.Slice(ups, func(i, j int) bool {
+		return ups[i].version < ups[j].version
+	})
+
+	return ups, nil
+}
@@ -0,0 +1,88 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type MigrateTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestMigrateTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
+
+func (s *MigrateTestSuite) TestMigrate() {
+	ctx := context.Background()
+	first := fstest.MapFS{
+		"001_init.up.sql":   {Data: []byte("CREATE TABLE foo (id INTEGER);")},
+		"001_init.down.sql": {Data: []byte("DROP TABLE foo;")},
+	}
+	second := fstest.MapFS{
+		"001_init.up.sql":    first["001_init.up.sql"],
+		"001_init.down.sql":  first["001_init.down.sql"],
+		"002_add_bar.up.sql": {Data: []byte("CREATE TABLE bar (id INTEGER);")},
+	}
+
+	s.Run("applies migrations and records version", func() {
+		d := s.openDB()
+		defer d.Close()
+		inTxner := db.NewInTransactioner(d)
+
+		s.Require().NoError(sqlite.Migrate(ctx, inTxner, second))
+
+		var version int64
+		var dirty bool
+		row := d.QueryRow("SELECT version, dirty FROM schema_migrations")
+		s.Require().NoError(row.Scan(&version, &dirty))
+		s.Equal(int64(2), version)
+		s.False(dirty)
+		_, err := d.Exec("INSERT INTO bar (id) VALUES (1)")
+		s.NoError(err)
+	})
+	s.Run("applies only newer migrations", func() {
+		d := s.openDB()
+		defer d.Close()
+		inTxner := db.NewInTransactioner(d)
+
+		s.Require().NoError(sqlite.Migrate(ctx, inTxner, first))
+		s.Require().NoError(sqlite.Migrate(ctx, inTxner, second))
+		s.Require().NoError(sqlite.Migrate(ctx, inTxner, second))
+
+		var count int
+		row := d.QueryRow("SELECT COUNT(*) FROM schema_migrations")
+		s.Require().NoError(row.Scan(&count))
+		s.Equal(1, count)
+	})
+	s.Run("rolls back failed migration", func() {
+		d := s.openDB()
+		defer d.Close()
+		inTxner := db.NewInTransactioner(d)
+		broken := fstest.MapFS{
+			"001_init.up.sql": {Data: []byte("CREATE TABLE foo (id INTEGER); NOT SQL;")},
+		}
+
+		s.Error(sqlite.Migrate(ctx, inTxner, broken))
+
+		_, err := d.Exec("CREATE TABLE foo (id INTEGER)")
+		s.NoError(err)
+	})
+	s.Run("refuses database left dirty", func() {
+		d := s.openDB()
+		defer d.Close()
+		inTxner := db.NewInTransactioner(d)
+
+		s.Require().NoError(sqlite.Migrate(ctx, inTxner, first))
+		_, err := d.Exec("UPDATE schema_migrations SET dirty = TRUE")
+		s.Require().NoError(err)
+
+		s.Error(sqlite.Migrate(ctx, inTxner, second))
+	})
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const finishRun = `
+UPDATE runs
+SET finished_at_timestamp = ?, status = ?
+WHERE id = ?
+`
+
+// RunFinisherTx provides the logic to record the end of a backup run in an
+// SQLite database within a transaction.
+type RunFinisherTx struct{}
+
+// NewRunFinisherTx instantiates a new RunFinisherTx instance.
+func NewRunFinisherTx() *RunFinisherTx {
+	return &RunFinisherTx{}
+}
+
+// FinishRun records the time at which the run with the provided ID finished,
+// along with its outcome, using the provided transaction.
+func (rf *RunFinisherTx) FinishRun(
+	ctx context.Context,
+	tx db.Tx,
+	run int64,
+	finishedAt time.Time,
+	status string,
+) error {
+
+	result, err := tx.ExecContext(ctx, finishRun, finishedAt.UTC(), status, run)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no run with ID %d", run)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type RunFinisherTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestRunFinisherTestSuite(t *testing.T) {
+	suite.Run(t, new(RunFinisherTestSuite))
+}
+
+func (s *RunFinisherTestSuite) TestFinishRun() {
+	startedAt := time.Unix(123, 0).UTC()
+	finishedAt := time.Unix(123, 456).UTC()
+
+	s.Run("updates run", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		var run int64
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			run, err = sqlite.NewRunStarterTx().StartRun(context.Background(), tx, startedAt)
+			return err
+		})
+		s.Require().NoError(err)
+
+		runFinisher := sqlite.NewRunFinisherTx()
+
+		err = s.inTransaction(d, func(tx *sql.Tx) error {
+			return runFinisher.FinishRun(context.Background(), tx, run, finishedAt, "interrupted")
+		})
+		s.Require().NoError(err)
+
+		var storedFinishedAt time.Time
+		var status string
+		err = d.QueryRow(
+			"SELECT finished_at_timestamp, status FROM runs WHERE id = ?", run,
+		).Scan(&storedFinishedAt, &status)
+
+		s.Require().NoError(err)
+		s.True(finishedAt.Equal(storedFinishedAt))
+		s.Equal("interrupted", status)
+	})
+
+	s.Run("handles missing run", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		runFinisher := sqlite.NewRunFinisherTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			return runFinisher.FinishRun(context.Background(), tx, 7, finishedAt, "success")
+		})
+
+		s.EqualError(err, "no run with ID 7")
+	})
+
+	s.Run("handles error from transaction", func() {
+		d := s.openDB()
+		defer d.Close()
+
+		runFinisher := sqlite.NewRunFinisherTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			return runFinisher.FinishRun(context.Background(), tx, 7, finishedAt, "success")
+		})
+
+		s.Error(err)
+	})
+}
@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const startRun = `
+INSERT INTO runs (
+	started_at_timestamp
+) VALUES (
+	?
+)
+RETURNING id
+`
+
+// RunStarterTx provides the logic to record the start of a backup run in an
+// SQLite database within a transaction.
+type RunStarterTx struct{}
+
+// NewRunStarterTx instantiates a new RunStarterTx instance.
+func NewRunStarterTx() *RunStarterTx {
+	return &RunStarterTx{}
+}
+
+// StartRun inserts a new run into the database using the provided transaction
+// and returns its sequential ID.
+func (rs *RunStarterTx) StartRun(
+	ctx context.Context,
+	tx db.Tx,
+	startedAt time.Time,
+) (int64, error) {
+
+	row := tx.QueryRowContext(ctx, startRun, startedAt.UTC())
+
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
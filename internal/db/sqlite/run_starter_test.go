@@ -0,0 +1,62 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type RunStarterTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestRunStarterTestSuite(t *testing.T) {
+	suite.Run(t, new(RunStarterTestSuite))
+}
+
+func (s *RunStarterTestSuite) TestStartRun() {
+	startedAt := time.Unix(123, 456).UTC()
+
+	s.Run("inserts runs and returns their IDs", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		runStarter := sqlite.NewRunStarterTx()
+
+		var ids []int64
+		for i := 0; i < 2; i++ {
+			err := s.inTransaction(d, func(tx *sql.Tx) error {
+				id, err := runStarter.StartRun(context.Background(), tx, startedAt)
+				ids = append(ids, id)
+				return err
+			})
+			s.Require().NoError(err)
+		}
+
+		var storedStartedAt time.Time
+		err := d.QueryRow("SELECT started_at_timestamp FROM runs WHERE id = 2").Scan(&storedStartedAt)
+
+		s.Require().NoError(err)
+		s.Equal([]int64{1, 2}, ids)
+		s.True(startedAt.Equal(storedStartedAt))
+	})
+
+	s.Run("handles error from transaction", func() {
+		d := s.openDB()
+		defer d.Close()
+
+		runStarter := sqlite.NewRunStarterTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			_, err := runStarter.StartRun(context.Background(), tx, startedAt)
+			return err
+		})
+
+		s.Error(err)
+	})
+}
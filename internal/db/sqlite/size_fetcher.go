@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+const getLatestFilesBySize = `
+SELECT
+	` + fileColumns + `
+FROM files f
+WHERE bucket = ?
+	AND substr(key, 1, length(?)) = ?
+	AND size = ?
+	AND created_at_timestamp = (
+		SELECT MAX(created_at_timestamp)
+		FROM files
+		WHERE bucket = f.bucket
+			AND substr(key, 1, length(?)) = ?
+			AND local_path = f.local_path
+	)
+`
+
+// SizeFetcherTx provides the logic to fetch the most recent versions of files
+// with a given size from an SQLite database within a transaction.
+type SizeFetcherTx struct{}
+
+// NewSizeFetcherTx instantiates a new SizeFetcherTx instance.
+func NewSizeFetcherTx() *SizeFetcherTx {
+	return &SizeFetcherTx{}
+}
+
+// FetchLatestBySize returns the most recent version of every file stored in
+// the provided bucket under a key beginning with the provided prefix, where
+// that version has the provided size.
+func (sf *SizeFetcherTx) FetchLatestBySize(
+	ctx context.Context,
+	tx db.Tx,
+	bucket, keyPrefix string,
+	size int64,
+) ([]*db.FileRow, error) {
+
+	return queryFileRows(
+		ctx, tx, getLatestFilesBySize,
+		bucket, keyPrefix, keyPrefix, size, keyPrefix, keyPrefix,
+	)
+}
@@ -0,0 +1,70 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+)
+
+type SizeFetcherTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestSizeFetcherTestSuite(t *testing.T) {
+	suite.Run(t, new(SizeFetcherTestSuite))
+}
+
+func (s *SizeFetcherTestSuite) TestFetchLatestBySize() {
+	bucket := "some-bucket"
+	keyPrefix := "key-"
+	size := int64(100)
+
+	s.Run("returns matching rows", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		superseded := newFileRow("id-1", "/some/path", time.Unix(1, 0).UTC())
+		resized := newFileRow("id-2", "/some/path", time.Unix(2, 0).UTC())
+		resized.Size = 200
+		matching := newFileRow("id-3", "/some/other/path", time.Unix(3, 0).UTC())
+		otherBucket := newFileRow("id-4", "/some/third/path", time.Unix(4, 0).UTC())
+		otherBucket.Bucket = "some-other-bucket"
+		s.insertFiles(d, superseded, resized, matching, otherBucket)
+
+		sizeFetcher := sqlite.NewSizeFetcherTx()
+
+		var rows []*db.FileRow
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			var err error
+			rows, err = sizeFetcher.FetchLatestBySize(
+				context.Background(), tx, bucket, keyPrefix, size,
+			)
+			return err
+		})
+
+		s.Require().NoError(err)
+		s.Equal([]*db.FileRow{matching}, rows)
+	})
+
+	s.Run("returns error from query", func() {
+		d := s.openDB()
+		defer d.Close()
+
+		sizeFetcher := sqlite.NewSizeFetcherTx()
+
+		err := s.inTransaction(d, func(tx *sql.Tx) error {
+			_, err := sizeFetcher.FetchLatestBySize(
+				context.Background(), tx, bucket, keyPrefix, size,
+			)
+			return err
+		})
+
+		s.Error(err)
+	})
+}
@@ -0,0 +1,194 @@
+// Package sqlite provides an implementation of the registry's database
+// components for SQLite, allowing single-host installs to keep the registry in
+// a local file rather than in PostgreSQL.
+//
+// SQLite has no native time type, so times are stored as text. Every time
+// written to or compared against the database is converted to UTC first, so
+// that the text compares in the same order as the times it represents.
+package sqlite
+
+import (
+	"context"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+// DriverName is the name under which the pure-Go SQLite database driver
+// imported by this package is registered.
+const DriverName = "sqlite"
+
+const fileColumns = `id,
+	key,
+	local_path,
+	checksum,
+	change_time,
+	bucket,
+	etag,
+	version,
+	pack_id,
+	pack_offset,
+	pack_length,
+	key_layout,
+	storage_class,
+	retain_until,
+	legal_hold,
+	checksum_algorithm,
+	size,
+	modify_time,
+	inconsistent,
+	metadata,
+	file_type,
+	link_target,
+	device_major,
+	device_minor,
+	extents,
+	created_at_timestamp`
+
+const fileRowColumns = 26
+
+// DataSourceName returns the data source name used to open the SQLite database
+// stored at the provided path. Times are written in SQLite's own format, and
+// connections wait for up to five seconds for locks held by other processes
+// to be released rather than failing immediately.
+func DataSourceName(path string) string {
+	return "file:" + path + "?_pragma=busy_timeout(5000)&_time_format=sqlite"
+}
+
+// NewRegistry instantiates a db.Registry that stores files in an SQLite
+// database, using the provided Clock, InTransactioner and IDGenerator
+// instances. Any options provided are applied after those selecting the SQLite
+// components.
+func NewRegistry(
+	clock db.Clock,
+	inTxner db.InTransactioner,
+	idGen db.IDGenerator,
+	opts ...db.RegistryOption,
+) *db.Registry {
+
+	sqliteOpts := []db.RegistryOption{
+		db.WithRunStarter(NewRunStarterTx()),
+		db.WithRunFinisher(NewRunFinisherTx()),
+		db.WithSizeFetcher(NewSizeFetcherTx()),
+		db.WithLatestLister(NewLatestListerTx()),
+		db.WithLatestStreamer(NewLatestStreamerTx()),
+		db.WithBatchCreator(NewBatchCreatorTx()),
+	}
+
+	return db.NewRegistry(
+		clock,
+		inTxner,
+		NewCreatorTx(),
+		NewLatestFetcherTx(),
+		idGen,
+		append(sqliteOpts, opts...)...,
+	)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFileRow(s scanner) (*db.FileRow, error) {
+	var row db.FileRow
+	if err := s.Scan(
+		&row.ID,
+		&row.Key,
+		&row.LocalPath,
+		&row.Checksum,
+		&row.CTime,
+		&row.Bucket,
+		&row.ETag,
+		&row.Version,
+		&row.PackID,
+		&row.PackOffset,
+		&row.PackLength,
+		&row.KeyLayout,
+		&row.StorageClass,
+		&row.RetainUntil,
+		&row.LegalHold,
+		&row.ChecksumAlgorithm,
+		&row.Size,
+		&row.MTime,
+		&row.Inconsistent,
+		&row.Metadata,
+		&row.FileType,
+		&row.LinkTarget,
+		&row.DeviceMajor,
+		&row.DeviceMinor,
+		&row.Extents,
+		&row.CreatedAtTimestamp,
+	); err != nil {
+		return nil, err
+	}
+
+	// The driver parses times into the local zone where the offsets agree,
+	// so they are returned in UTC, just as they were written.
+	row.CTime = row.CTime.UTC()
+	row.MTime = row.MTime.UTC()
+	row.CreatedAtTimestamp = row.CreatedAtTimestamp.UTC()
+	if row.RetainUntil.Valid {
+		row.RetainUntil.Time = row.RetainUntil.Time.UTC()
+	}
+
+	return &row, nil
+}
+
+func fileRowArgs(file *db.FileRow) []interface{} {
+	var retainUntil interface{}
+	if file.RetainUntil.Valid {
+		retainUntil = file.RetainUntil.Time.UTC()
+	}
+
+	return []interface{}{
+		file.ID,
+		file.Key,
+		file.LocalPath,
+		file.Checksum,
+		file.CTime.UTC(),
+		file.Bucket,
+		file.ETag,
+		file.Version,
+		file.PackID,
+		file.PackOffset,
+		file.PackLength,
+		file.KeyLayout,
+		file.StorageClass,
+		retainUntil,
+		file.LegalHold,
+		file.ChecksumAlgorithm,
+		file.Size,
+		file.MTime.UTC(),
+		file.Inconsistent,
+		file.Metadata,
+		file.FileType,
+		file.LinkTarget,
+		file.DeviceMajor,
+		file.DeviceMinor,
+		file.Extents,
+		file.CreatedAtTimestamp.UTC(),
+	}
+}
+
+func queryFileRows(ctx context.Context, tx db.Tx, query string, args ...interface{}) ([]*db.FileRow, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fileRows []*db.FileRow
+	for rows.Next() {
+		row, err := scanFileRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		fileRows = append(fileRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fileRows, nil
+}
@@ -0,0 +1,248 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/db/sqlite"
+	"github.com/mspraggs/hoard/internal/metadata"
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/migrations"
+)
+
+type sqliteTestSuite struct {
+	suite.Suite
+}
+
+// openDB opens an empty SQLite database in a temporary directory.
+func (s *sqliteTestSuite) openDB() *sql.DB {
+	path := filepath.Join(s.T().TempDir(), "registry.db")
+	d, err := sql.Open(sqlite.DriverName, sqlite.DataSourceName(path))
+	s.Require().NoError(err)
+
+	return d
+}
+
+// newDB opens an SQLite database in a temporary directory and applies the
+// SQLite migrations to it.
+func (s *sqliteTestSuite) newDB() *sql.DB {
+	d := s.openDB()
+
+	err := sqlite.Migrate(context.Background(), db.NewInTransactioner(d), migrations.SQLite())
+	s.Require().NoError(err)
+
+	return d
+}
+
+func (s *sqliteTestSuite) inTransaction(d *sql.DB, fn func(tx *sql.Tx) error) error {
+	return db.NewInTransactioner(d).InTransaction(
+		context.Background(),
+		func(ctx context.Context, tx db.Tx) error {
+			return fn(tx.(*sql.Tx))
+		},
+	)
+}
+
+// insertFiles inserts the provided rows, failing the test on error.
+func (s *sqliteTestSuite) insertFiles(d *sql.DB, files ...*db.FileRow) {
+	creator := sqlite.NewCreatorTx()
+	err := s.inTransaction(d, func(tx *sql.Tx) error {
+		for _, file := range files {
+			if _, err := creator.Create(context.Background(), tx, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+}
+
+func newFileRow(id, path string, createdAt time.Time) *db.FileRow {
+	return &db.FileRow{
+		ID:                 id,
+		Key:                "key-" + id,
+		LocalPath:          path,
+		Checksum:           db.Checksum{0, 0, 0, 42},
+		CTime:              time.Unix(1, 0).UTC(),
+		Bucket:             "some-bucket",
+		ETag:               "etag-" + id,
+		Version:            "version-" + id,
+		KeyLayout:          "uuid",
+		ChecksumAlgorithm:  "CRC32",
+		Size:               100,
+		MTime:              time.Unix(2, 0).UTC(),
+		CreatedAtTimestamp: createdAt,
+	}
+}
+
+type RegistryTestSuite struct {
+	sqliteTestSuite
+}
+
+func TestRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type fakeIDGenerator struct {
+	id string
+}
+
+func (g *fakeIDGenerator) GenerateID() string {
+	return g.id
+}
+
+type sequentialIDGenerator struct {
+	n int
+}
+
+func (g *sequentialIDGenerator) GenerateID() string {
+	g.n++
+	return fmt.Sprint("id-", g.n)
+}
+
+func (s *RegistryTestSuite) TestNewRegistry() {
+	ctx := context.Background()
+
+	s.Run("uses SQLite components", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		clock := &fakeClock{now: time.Unix(10, 0).UTC()}
+		registry := sqlite.NewRegistry(
+			clock, db.NewInTransactioner(d), &fakeIDGenerator{id: "some-id"},
+		)
+
+		run, err := registry.StartRun(ctx)
+		s.Require().NoError(err)
+		s.Equal(int64(1), run)
+
+		file := &processor.File{
+			Key:               "some-key",
+			LocalPath:         "/some/path",
+			Checksum:          processor.Checksum{0, 0, 0, 42},
+			ChecksumAlgorithm: "CRC32",
+			CTime:             time.Unix(1, 0).UTC(),
+			MTime:             time.Unix(2, 0).UTC(),
+			Size:              100,
+			Bucket:            "some-bucket",
+			ETag:              "some-etag",
+			Version:           "some-version",
+		}
+		created, err := registry.Create(ctx, file)
+		s.Require().NoError(err)
+		s.Equal("/some/path", created.LocalPath)
+
+		listed, err := registry.ListLatest(ctx, "some-bucket", "")
+		s.Require().NoError(err)
+		s.Require().Len(listed, 1)
+		s.Equal(created, listed[0])
+
+		s.NoError(registry.FinishRun(ctx, run, "success"))
+	})
+}
+
+func (s *RegistryTestSuite) TestDirectoriesSharingBucket() {
+	ctx := context.Background()
+
+	s.Run("keeps files of each directory apart", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		registry := sqlite.NewRegistry(
+			&fakeClock{now: time.Unix(10, 0).UTC()},
+			db.NewInTransactioner(d),
+			&sequentialIDGenerator{},
+		)
+
+		var created []*processor.File
+		for _, keyPrefix := range []string{"first/", "second/"} {
+			file, err := registry.Create(ctx, &processor.File{
+				Key:               keyPrefix + "some-key",
+				LocalPath:         "some/path",
+				Checksum:          processor.Checksum{0, 0, 0, 42},
+				ChecksumAlgorithm: "CRC32",
+				Size:              100,
+				Bucket:            "some-bucket",
+			})
+			s.Require().NoError(err)
+			created = append(created, file)
+		}
+
+		for i, keyPrefix := range []string{"first/", "second/"} {
+			fetched, err := registry.FetchLatest(ctx, "some-bucket", keyPrefix, "some/path")
+			s.Require().NoError(err)
+			s.Equal(created[i], fetched)
+
+			listed, err := registry.ListLatest(ctx, "some-bucket", keyPrefix)
+			s.Require().NoError(err)
+			s.Equal([]*processor.File{created[i]}, listed)
+
+			sized, err := registry.FetchLatestBySize(ctx, "some-bucket", keyPrefix, 100)
+			s.Require().NoError(err)
+			s.Equal([]*processor.File{created[i]}, sized)
+
+			cache := db.NewLatestCache(
+				registry, "some-bucket",
+				db.WithCacheKeyPrefix(keyPrefix),
+			)
+			s.Require().NoError(cache.Load(ctx))
+
+			cached, err := cache.FetchLatest(ctx, "some/path")
+			s.Require().NoError(err)
+			s.Equal(created[i], cached)
+		}
+	})
+}
+
+func (s *RegistryTestSuite) TestEntryRoundTrip() {
+	ctx := context.Background()
+
+	s.Run("skips unchanged entries registered previously", func() {
+		d := s.newDB()
+		defer d.Close()
+
+		root := s.T().TempDir()
+		s.Require().NoError(os.Mkdir(filepath.Join(root, "dir"), 0755))
+		s.Require().NoError(os.Symlink("some/target", filepath.Join(root, "link")))
+
+		registry := sqlite.NewRegistry(
+			&fakeClock{now: time.Unix(10, 0).UTC()},
+			db.NewInTransactioner(d),
+			&sequentialIDGenerator{},
+		)
+		entries := metadata.NewReader(root)
+		p := processor.New(
+			os.DirFS(root), nil, db.NewLatestCache(registry, "some-bucket"),
+			processor.WithIrregularFiles(entries, "some-bucket"),
+		)
+
+		for _, path := range []string{"dir", "link"} {
+			created, err := p.Process(ctx, path)
+			s.Require().NoError(err)
+
+			skipped, err := p.Process(ctx, path)
+			s.Require().NoError(err)
+			s.Equal(created, skipped)
+		}
+
+		listed, err := registry.ListLatest(ctx, "some-bucket", "")
+		s.Require().NoError(err)
+		s.Len(listed, 2)
+	})
+}
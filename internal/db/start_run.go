@@ -0,0 +1,21 @@
+package db
+
+import (
+	"context"
+)
+
+// StartRun records the start of a backup run in the registry and returns the
+// run's sequential number.
+func (r *Registry) StartRun(ctx context.Context) (int64, error) {
+	var run int64
+	err := r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
+		var err error
+		run, err = r.runStarter.StartRun(ctx, tx, r.clock.Now())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return run, nil
+}
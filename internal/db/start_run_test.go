@@ -0,0 +1,68 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/db"
+)
+
+func (s *RegistryTestSuite) TestStartRun() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	timestamp := time.Unix(1, 0)
+	clock := fakeClock(func() time.Time { return timestamp })
+
+	s.Run("starts run in transaction", func() {
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockRunStarter.EXPECT().StartRun(ctx, gomock.Any(), timestamp).Return(int64(42), nil)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithRunStarter(s.mockRunStarter),
+		)
+
+		run, err := registry.StartRun(ctx)
+
+		s.Require().NoError(err)
+		s.Equal(int64(42), run)
+	})
+
+	s.Run("handles error", func() {
+		expectedErr := errors.New("oh no")
+
+		s.Run("from in transactioner", func() {
+			s.mockInTransactioner.EXPECT().
+				InTransaction(ctx, gomock.Any()).Return(expectedErr)
+
+			registry := db.NewRegistry(
+				clock, s.mockInTransactioner, nil, nil, nil,
+				db.WithRunStarter(s.mockRunStarter),
+			)
+
+			run, err := registry.StartRun(ctx)
+
+			s.Zero(run)
+			s.ErrorIs(err, expectedErr)
+		})
+		s.Run("from run starter", func() {
+			s.mockInTransactioner.EXPECT().
+				InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+			s.mockRunStarter.EXPECT().
+				StartRun(ctx, gomock.Any(), timestamp).Return(int64(0), expectedErr)
+
+			registry := db.NewRegistry(
+				clock, s.mockInTransactioner, nil, nil, nil,
+				db.WithRunStarter(s.mockRunStarter),
+			)
+
+			run, err := registry.StartRun(ctx)
+
+			s.Zero(run)
+			s.ErrorIs(err, expectedErr)
+		})
+	})
+}
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// StreamLatest calls the provided function with the latest version of every
+// file stored in the provided bucket under a key beginning with the provided
+// prefix that was created after the provided time.
+// All files are read within a single transaction and query.
+func (r *Registry) StreamLatest(
+	ctx context.Context,
+	bucket, keyPrefix string,
+	since time.Time,
+	fn func(*processor.File) error,
+) error {
+
+	return r.inTxner.InTransaction(ctx, func(ctx context.Context, tx Tx) error {
+		return r.latestStreamer.StreamLatest(ctx, tx, bucket, keyPrefix, since, func(row *FileRow) error {
+			return fn(row.toDomain())
+		})
+	})
+}
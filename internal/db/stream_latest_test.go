@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/db"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *RegistryTestSuite) TestStreamLatest() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	bucket := "some-bucket"
+	keyPrefix := "some-prefix/"
+	since := time.Unix(2, 0)
+	clock := fakeClock(func() time.Time { return time.Unix(1, 0) })
+
+	s.Run("streams files in transaction", func() {
+		expectedFiles := []*processor.File{
+			{LocalPath: "some/path"},
+			{LocalPath: "some/other/path"},
+		}
+		fileRows := []*db.FileRow{
+			{LocalPath: "some/path"},
+			{LocalPath: "some/other/path"},
+		}
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockLatestStreamer.EXPECT().
+			StreamLatest(ctx, gomock.Any(), bucket, keyPrefix, since, gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				tx db.Tx,
+				bucket, keyPrefix string,
+				since time.Time,
+				fn func(*db.FileRow) error,
+			) error {
+				for _, row := range fileRows {
+					if err := fn(row); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithLatestStreamer(s.mockLatestStreamer),
+		)
+
+		var files []*processor.File
+		err := registry.StreamLatest(ctx, bucket, keyPrefix, since, func(file *processor.File) error {
+			files = append(files, file)
+			return nil
+		})
+
+		s.Require().NoError(err)
+		s.Equal(expectedFiles, files)
+	})
+
+	s.Run("handles error from latest streamer", func() {
+		expectedErr := errors.New("oh no")
+
+		s.mockInTransactioner.EXPECT().
+			InTransaction(ctx, gomock.Any()).DoAndReturn(fakeInTransaction)
+		s.mockLatestStreamer.EXPECT().
+			StreamLatest(ctx, gomock.Any(), bucket, keyPrefix, since, gomock.Any()).
+			Return(expectedErr)
+
+		registry := db.NewRegistry(
+			clock, s.mockInTransactioner, nil, nil, nil,
+			db.WithLatestStreamer(s.mockLatestStreamer),
+		)
+
+		err := registry.StreamLatest(ctx, bucket, keyPrefix, since, func(*processor.File) error {
+			return nil
+		})
+
+		s.ErrorIs(err, expectedErr)
+	})
+}
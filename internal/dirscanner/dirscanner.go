@@ -2,23 +2,59 @@ package dirscanner
 
 import (
 	"context"
+	"errors"
 	"io/fs"
+	"os"
+	"path"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/mspraggs/hoard/internal/ignore"
 	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/progress"
 	"github.com/mspraggs/hoard/internal/util"
 )
 
 //go:generate mockgen -destination=./mocks/dirscanner.go -package=mocks -source=$GOFILE
 
+// IgnoreFileName is the name of the files holding gitignore-style patterns for
+// the paths to exclude from the directory they are found in.
+const IgnoreFileName = ".hoardignore"
+
 // Processor is the interface required to handle file uploads produced by the
 // DirScanner instance.
 type Processor interface {
 	Process(ctx context.Context, path string) (*processor.File, error)
 }
 
+// Resetter is implemented by processors that keep state for the duration of a
+// scan, such as the hard links found so far, which is cleared once each scan
+// completes.
+type Resetter interface {
+	Reset()
+}
+
+// Reporter is the interface required to report the progress of a scan.
+type Reporter interface {
+	FileScanned(ctx context.Context, path string, size int64)
+	FileStarted(ctx context.Context, path string)
+	FileFailed(ctx context.Context, path string, err error)
+	PathExcluded(ctx context.Context, path string, isDir bool)
+}
+
+// Scheduler is the interface required to run the processing of files on a
+// worker pool shared with other scanners.
+type Scheduler interface {
+	Submit(ctx context.Context, size int64, fn func(worker int)) error
+	Wait()
+}
+
+// Option is the type used to implement the functional options pattern for the
+// DirScanner type.
+type Option func(*DirScanner)
+
 // DirScanner encapsulates the logic for scanning a directory hierarchy and
 // generating FileUpload objects from the files within.
 type DirScanner struct {
@@ -28,52 +64,136 @@ type DirScanner struct {
 	pathQueue         chan string
 	wg                *sync.WaitGroup
 	log               *zap.SugaredLogger
+	reporter          Reporter
+	scheduler         Scheduler
+	irregular         bool
+	followSymlinks    bool
+	includes          []ignore.Pattern
+	excludes          []ignore.Pattern
+	ignores           map[string][]ignore.Pattern
+	clock             Clock
+	maxSize           int64
+	minAge            time.Duration
+	maxAge            time.Duration
+	oneFileSystem     bool
+	rootDev           uint64
+	hasRootDev        bool
 }
 
 // New instantiates a new directory scanner instance with the provided options.
-func New(fs fs.FS, processors []Processor, numThreads int) *DirScanner {
-	return &DirScanner{
+func New(fs fs.FS, processors []Processor, numThreads int, opts ...Option) *DirScanner {
+	s := &DirScanner{
 		fs:                fs,
 		numHandlerThreads: numThreads,
 		processors:        processors,
 		pathQueue:         make(chan string),
 		wg:                &sync.WaitGroup{},
 		log:               util.MustNewLogger(),
+		clock:             &util.Clock{},
+		ignores:           make(map[string][]ignore.Pattern),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithReporter returns an option that reports the progress of each scan to the
+// provided reporter. Each file is reported as scanned as soon as the walk finds
+// it, so the reporter learns the total size of the hierarchy as the walk runs
+// ahead of the files being processed.
+func WithReporter(reporter Reporter) Option {
+	return func(s *DirScanner) {
+		s.reporter = reporter
+	}
+}
+
+// WithScheduler returns an option that causes a DirScanner to submit the files
+// it finds to the provided scheduler rather than processing them with workers
+// of its own.
+func WithScheduler(scheduler Scheduler) Option {
+	return func(s *DirScanner) {
+		s.scheduler = scheduler
+	}
+}
+
+// WithIrregularFiles returns an option that causes a DirScanner to pass
+// symbolic links, directories, named pipes and device nodes to its processors
+// along with regular files. Sockets are always skipped, as is the root of the
+// scanned hierarchy.
+func WithIrregularFiles() Option {
+	return func(s *DirScanner) {
+		s.irregular = true
+	}
+}
+
+// WithFollowSymlinks returns an option that causes a DirScanner to follow
+// symbolic links, scanning the files and directories they point to as though
+// they were found at the location of the link. Links that point to one of
+// their own ancestors are skipped, so that loops are not followed forever.
+func WithFollowSymlinks() Option {
+	return func(s *DirScanner) {
+		s.followSymlinks = true
+	}
+}
+
+// WithIncludes returns an option that restricts a DirScanner to the files
+// matching at least one of the provided gitignore-style patterns, or lying in a
+// directory that does. Directories are only traversed if they may hold a
+// matching file.
+func WithIncludes(patterns ...string) Option {
+	return func(s *DirScanner) {
+		s.includes = ignore.ParseList(patterns)
+	}
+}
+
+// WithExcludes returns an option that causes a DirScanner to skip the paths
+// matching the provided gitignore-style patterns. Excluded directories are
+// pruned without being traversed. Patterns in ignore files found in the
+// scanned hierarchy take precedence over these.
+func WithExcludes(patterns ...string) Option {
+	return func(s *DirScanner) {
+		s.excludes = ignore.ParseList(patterns)
 	}
 }
 
 // Scan traverses the filesystem and runs all registered processors on all
-// regular files.
+// regular files, and on other entries if irregular files are enabled. Paths
+// that are excluded, either by the scanner's patterns and filters or by the
+// ignore files found along the way, are skipped before being queued. Processors
+// implementing Resetter are reset once the scan completes.
 func (s *DirScanner) Scan(ctx context.Context) error {
 	s.pathQueue = make(chan string)
+	s.ignores = make(map[string][]ignore.Pattern)
+	s.loadRootDev()
+	defer s.resetProcessors()
+
+	if s.scheduler != nil {
+		return s.scheduleScan(ctx)
+	}
 
 	for i := 0; i < s.numHandlerThreads; i++ {
 		s.wg.Add(1)
-		go s.uploadFileUploads(ctx)
+		go s.uploadFileUploads(progress.WithWorker(ctx, i))
 	}
 
-	err := fs.WalkDir(s.fs, ".", func(path string, d fs.DirEntry, err error) error {
-		select {
-		case <-ctx.Done():
-			return context.Canceled
-		default:
-		}
-
-		s.log.Debugw("Handling path", "path", path)
-		if err != nil {
-			s.log.Warnw("Skipping file due to error", "error", err, "path", path)
-			return nil
+	visit := func(path string, d fs.DirEntry) error {
+		if s.reporter != nil {
+			s.reporter.FileScanned(ctx, path, fileSize(d))
 		}
 
-		if !d.Type().IsRegular() {
-			s.log.Infow("Skipping irregular file type", "path", path, "type", d.Type())
+		// The workers stop taking paths once the context is done, so the walk
+		// must stop too rather than wait for them forever.
+		select {
+		case s.pathQueue <- path:
 			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		}
+	}
 
-		s.pathQueue <- path
-
-		return nil
-	})
+	err := s.walk(ctx, ".", visit, s.reportExcluded(ctx))
 
 	close(s.pathQueue)
 
@@ -82,6 +202,77 @@ func (s *DirScanner) Scan(ctx context.Context) error {
 	return err
 }
 
+// scheduleScan traverses the filesystem, submitting each file to be processed
+// to the scanner's scheduler, then waits for every submitted file to be
+// processed.
+func (s *DirScanner) scheduleScan(ctx context.Context) error {
+	visit := func(path string, d fs.DirEntry) error {
+		size := fileSize(d)
+		if s.reporter != nil {
+			s.reporter.FileScanned(ctx, path, size)
+		}
+
+		return s.scheduler.Submit(ctx, size, func(worker int) {
+			s.processPath(progress.WithWorker(ctx, worker), path)
+		})
+	}
+
+	err := s.walk(ctx, ".", visit, s.reportExcluded(ctx))
+
+	s.scheduler.Wait()
+
+	return err
+}
+
+func (s *DirScanner) resetProcessors() {
+	for _, p := range s.processors {
+		if r, ok := p.(Resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+func (s *DirScanner) reportExcluded(ctx context.Context) func(string, bool) {
+	return func(path string, isDir bool) {
+		s.log.Infow("Skipping excluded path", "path", path, "directory", isDir)
+		if s.reporter != nil {
+			s.reporter.PathExcluded(ctx, path, isDir)
+		}
+	}
+}
+
+// Excluded reports whether the entry with the provided path would be skipped
+// by a scan, either because it or one of its ancestors is excluded or because
+// it is of a type the scanner does not process. Changes to ignore files are
+// picked up when they are themselves checked. Excluded must not be called
+// concurrently with Scan.
+func (s *DirScanner) Excluded(name string) bool {
+	if path.Base(name) == IgnoreFileName {
+		delete(s.ignores, path.Dir(name))
+	}
+
+	for i, dir := range ancestors(name) {
+		if i > 0 && s.excluded(dir, true) {
+			return true
+		}
+		if i > 0 && s.hasFilters() {
+			if info, err := fs.Stat(s.fs, dir); err == nil && s.filteredInfo(info) {
+				return true
+			}
+		}
+		s.loadIgnoreFile(dir)
+	}
+
+	info, err := fs.Stat(s.fs, name)
+	if err != nil {
+		return false
+	}
+	if s.excluded(name, info.IsDir()) || s.filteredInfo(info) {
+		return true
+	}
+	return !s.shouldVisit(name, fs.FileInfoToDirEntry(info))
+}
+
 func (s *DirScanner) uploadFileUploads(ctx context.Context) {
 	defer s.wg.Done()
 
@@ -91,16 +282,238 @@ func (s *DirScanner) uploadFileUploads(ctx context.Context) {
 			if !ok {
 				return
 			}
-			for _, p := range s.processors {
-				file, err := p.Process(ctx, path)
-				if err != nil {
-					s.log.Warnw("Error processing file", "error", err, "path", path)
-				} else {
-					s.log.Infow("Successfully processed file", "file", file)
-				}
-			}
+			s.processPath(ctx, path)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+func (s *DirScanner) processPath(ctx context.Context, path string) {
+	if ctx.Err() != nil {
+		return
+	}
+	if s.reporter != nil {
+		s.reporter.FileStarted(ctx, path)
+	}
+	for _, p := range s.processors {
+		file, err := p.Process(ctx, path)
+		if err != nil && ctx.Err() != nil {
+			s.log.Infow("Abandoned file on cancellation", "error", err, "path", path)
+		} else if err != nil {
+			s.log.Warnw("Error processing file", "error", err, "path", path)
+			if s.reporter != nil {
+				s.reporter.FileFailed(ctx, path, err)
+			}
+		} else {
+			s.log.Infow("Successfully processed file", "file", file)
+		}
+	}
+}
+
+// walk traverses the hierarchy beneath the provided root, calling visit for
+// each entry that should be processed and exclude, if provided, for each
+// excluded path.
+func (s *DirScanner) walk(
+	ctx context.Context,
+	root string,
+	visit func(string, fs.DirEntry) error,
+	exclude func(string, bool),
+) error {
+
+	return fs.WalkDir(s.fs, root, func(path string, d fs.DirEntry, err error) error {
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		default:
+		}
+
+		s.log.Debugw("Handling path", "path", path)
+		if err != nil {
+			s.log.Warnw("Skipping file due to error", "error", err, "path", path)
+			return nil
+		}
+
+		if path != "." && (s.excluded(path, d.IsDir()) || s.filtered(d)) {
+			if exclude != nil {
+				exclude(path, d.IsDir())
+			}
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			s.loadIgnoreFile(path)
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 && s.followSymlinks {
+			return s.followSymlink(ctx, path, visit, exclude)
+		}
+
+		if s.shouldVisit(path, d) {
+			return visit(path, d)
+		}
+
+		return nil
+	})
+}
+
+// followSymlink scans the target of the symbolic link with the provided path.
+func (s *DirScanner) followSymlink(
+	ctx context.Context,
+	path string,
+	visit func(string, fs.DirEntry) error,
+	exclude func(string, bool),
+) error {
+
+	info, err := fs.Stat(s.fs, path)
+	if err != nil {
+		s.log.Warnw("Skipping broken symbolic link", "error", err, "path", path)
+		return nil
+	}
+
+	if (info.IsDir() && s.excluded(path, true)) || s.filteredInfo(info) {
+		if exclude != nil {
+			exclude(path, info.IsDir())
+		}
+		return nil
+	}
+
+	if !info.IsDir() {
+		if d := fs.FileInfoToDirEntry(info); s.shouldVisit(path, d) {
+			return visit(path, d)
+		}
+		return nil
+	}
+
+	if s.isLoop(path, info) {
+		s.log.Warnw("Skipping symbolic link to ancestor directory", "path", path)
+		return nil
+	}
+
+	return s.walk(ctx, path, visit, exclude)
+}
+
+// excluded reports whether the provided path is excluded, either by the
+// scanner's patterns or by the ignore files in its ancestors, or is not
+// included by the scanner's include patterns. Patterns in deeper ignore files
+// take precedence over those in shallower ones. Directories that cannot hold an
+// included path are treated as excluded, so that they are pruned.
+func (s *DirScanner) excluded(name string, isDir bool) bool {
+	dirs := ancestors(name)
+
+	patterns := s.excludes
+	for _, dir := range dirs {
+		patterns = append(patterns[:len(patterns):len(patterns)], s.ignores[dir]...)
+	}
+	if ignore.Matches(patterns, name, isDir) {
+		return true
+	}
+
+	if len(s.includes) == 0 {
+		return false
+	}
+	if isDir {
+		return !s.mayInclude(name)
+	}
+	if ignore.Matches(s.includes, name, false) {
+		return false
+	}
+	for _, dir := range dirs[1:] {
+		if ignore.Matches(s.includes, dir, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// mayInclude reports whether the provided directory may hold a path matched by
+// one of the scanner's include patterns.
+func (s *DirScanner) mayInclude(dir string) bool {
+	for _, p := range s.includes {
+		if !p.Negated() && p.MayMatchWithin(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIgnoreFile reads the patterns in the ignore file within the provided
+// directory, if there is one.
+func (s *DirScanner) loadIgnoreFile(dir string) {
+	if s.ignores == nil {
+		return
+	}
+	if _, ok := s.ignores[dir]; ok {
+		return
+	}
+
+	f, err := s.fs.Open(path.Join(dir, IgnoreFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		s.ignores[dir] = nil
+		return
+	}
+	if err != nil {
+		s.log.Warnw("Unable to open ignore file", "error", err, "path", dir)
+		return
+	}
+	defer f.Close()
+
+	patterns, err := ignore.Parse(dir, f)
+	if err != nil {
+		s.log.Warnw("Unable to read ignore file", "error", err, "path", dir)
+		return
+	}
+	s.ignores[dir] = patterns
+}
+
+// ancestors returns the directories containing the provided path, starting
+// with the root of the scanned hierarchy.
+func ancestors(name string) []string {
+	dirs := []string{"."}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' {
+			dirs = append(dirs, name[:i])
+		}
+	}
+	return dirs
+}
+
+// isLoop reports whether the provided directory, found by following the
+// symbolic link with the provided path, is one of the link's ancestors.
+func (s *DirScanner) isLoop(name string, info fs.FileInfo) bool {
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if ancestor, err := fs.Stat(s.fs, dir); err == nil && os.SameFile(ancestor, info) {
+			return true
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+func (s *DirScanner) shouldVisit(path string, d fs.DirEntry) bool {
+	mode := d.Type()
+	if mode.IsRegular() {
+		return true
+	}
+	if !s.irregular || mode&(fs.ModeSocket|fs.ModeIrregular) != 0 {
+		s.log.Infow("Skipping irregular file type", "path", path, "type", mode)
+		return false
+	}
+	return path != "."
+}
+
+// fileSize returns the size of a regular file. Other entries have no object
+// body, so their size is treated as zero.
+func fileSize(d fs.DirEntry) int64 {
+	if !d.Type().IsRegular() {
+		return 0
+	}
+	info, err := d.Info()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/psanford/memfs"
@@ -13,6 +16,7 @@ import (
 
 	"github.com/mspraggs/hoard/internal/dirscanner"
 	"github.com/mspraggs/hoard/internal/dirscanner/mocks"
+	"github.com/mspraggs/hoard/internal/pool"
 	"github.com/mspraggs/hoard/internal/processor"
 )
 
@@ -55,6 +59,70 @@ func (s *DirScannerTestSuite) TestScan() {
 		dirScanner.Scan(ctx)
 	})
 
+	s.Run("reports progress", func() {
+		ctx := context.Background()
+
+		fs := s.newMemFS(paths)
+		reporter := mocks.NewMockReporter(s.controller)
+
+		s.newHandlerCallsFromPaths(ctx, paths)
+		for _, path := range paths {
+			reporter.EXPECT().FileScanned(ctx, path, int64(0))
+			reporter.EXPECT().FileStarted(gomock.Any(), path)
+		}
+
+		dirScanner := dirscanner.New(
+			fs,
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithReporter(reporter),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+
+	s.Run("submits files to scheduler", func() {
+		ctx := context.Background()
+
+		fs := s.newMemFS(paths)
+		workers := pool.New(numThreads)
+		defer workers.Close()
+
+		s.newHandlerCallsFromPaths(ctx, paths)
+
+		dirScanner := dirscanner.New(
+			fs,
+			[]dirscanner.Processor{s.mockProcessor},
+			0,
+			dirscanner.WithScheduler(workers.NewQueue()),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+
+	s.Run("resets processors once scan completes", func() {
+		ctx := context.Background()
+
+		fs := s.newMemFS(paths)
+		resetter := mocks.NewMockResetter(s.controller)
+		processor := &resettingProcessor{s.mockProcessor, resetter}
+
+		reset := resetter.EXPECT().Reset()
+		for _, call := range s.newHandlerCallsFromPaths(ctx, paths) {
+			reset.After(call)
+		}
+
+		dirScanner := dirscanner.New(fs, []dirscanner.Processor{processor}, numThreads)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+
 	s.Run("stops handlers upon context canceled", func() {
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
@@ -68,6 +136,34 @@ func (s *DirScannerTestSuite) TestScan() {
 		s.Require().ErrorIs(context.Canceled, err)
 	})
 
+	s.Run("stops walking when context is canceled during scan", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fs := s.newMemFS(paths)
+
+		s.mockProcessor.EXPECT().
+			Process(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, path string) (*processor.File, error) {
+				cancel()
+				return nil, ctx.Err()
+			})
+
+		dirScanner := dirscanner.New(fs, []dirscanner.Processor{s.mockProcessor}, 1)
+
+		done := make(chan error)
+		go func() {
+			done <- dirScanner.Scan(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			s.ErrorIs(err, context.Canceled)
+		case <-time.After(time.Second):
+			s.FailNow("scan did not stop after context was canceled")
+		}
+	})
+
 	s.Run("handles error", func() {
 		expectedErr := errors.New("oh no")
 		paths := []string{"foo"}
@@ -89,7 +185,7 @@ func (s *DirScannerTestSuite) TestScan() {
 			ctx := context.Background()
 
 			s.mockProcessor.EXPECT().
-				Process(ctx, paths[0]).Return(nil, expectedErr)
+				Process(gomock.Any(), paths[0]).Return(nil, expectedErr)
 
 			dirScanner := dirscanner.New(
 				fs,
@@ -102,6 +198,289 @@ func (s *DirScannerTestSuite) TestScan() {
 	})
 }
 
+func (s *DirScannerTestSuite) TestScanIrregularFiles() {
+	numThreads := 2
+	root := s.T().TempDir()
+
+	s.Require().NoError(os.MkdirAll(filepath.Join(root, "dir/empty"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(root, "dir/file"), []byte{1}, 0644))
+	s.Require().NoError(os.Symlink("file", filepath.Join(root, "dir/link")))
+	s.Require().NoError(syscall.Mkfifo(filepath.Join(root, "fifo"), 0644))
+
+	s.Run("skips irregular files by default", func() {
+		ctx := context.Background()
+
+		s.newHandlerCallsFromPaths(ctx, []string{"dir/file"})
+
+		dirScanner := dirscanner.New(
+			os.DirFS(root),
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("passes irregular files to processors", func() {
+		ctx := context.Background()
+
+		s.newHandlerCallsFromPaths(
+			ctx, []string{"dir", "dir/empty", "dir/file", "dir/link", "fifo"},
+		)
+
+		dirScanner := dirscanner.New(
+			os.DirFS(root),
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithIrregularFiles(),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+}
+
+func (s *DirScannerTestSuite) TestScanFollowSymlinks() {
+	numThreads := 2
+	root := s.T().TempDir()
+
+	s.Require().NoError(os.MkdirAll(filepath.Join(root, "dir"), 0755))
+	s.Require().NoError(os.MkdirAll(filepath.Join(root, "other"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(root, "other/file"), []byte{1}, 0644))
+	s.Require().NoError(os.Symlink("../other", filepath.Join(root, "dir/other")))
+	s.Require().NoError(os.Symlink("..", filepath.Join(root, "dir/loop")))
+	s.Require().NoError(os.Symlink("missing", filepath.Join(root, "dir/broken")))
+
+	s.Run("follows links and skips loops", func() {
+		ctx := context.Background()
+
+		s.newHandlerCallsFromPaths(ctx, []string{"dir/other/file", "other/file"})
+
+		dirScanner := dirscanner.New(
+			os.DirFS(root),
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithFollowSymlinks(),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+}
+
+func (s *DirScannerTestSuite) TestScanPatterns() {
+	numThreads := 2
+
+	s.Run("prunes excluded directories", func() {
+		ctx := context.Background()
+		reporter := mocks.NewMockReporter(s.controller)
+
+		memFS := s.newMemFS([]string{"foo.txt", "foo.tmp", "cache/bar.txt", "sub/cache"})
+		s.newHandlerCallsFromPaths(ctx, []string{"foo.txt", "sub/cache"})
+
+		for _, path := range []string{"foo.txt", "sub/cache"} {
+			reporter.EXPECT().FileScanned(ctx, path, int64(0))
+			reporter.EXPECT().FileStarted(gomock.Any(), path)
+		}
+		reporter.EXPECT().PathExcluded(ctx, "foo.tmp", false)
+		reporter.EXPECT().PathExcluded(ctx, "cache", true)
+
+		dirScanner := dirscanner.New(
+			memFS,
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithReporter(reporter),
+			dirscanner.WithExcludes("*.tmp", "cache/"),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("processes only included files", func() {
+		ctx := context.Background()
+
+		memFS := s.newMemFS([]string{"foo.txt", "foo.tmp", "docs/bar.tmp", "other/baz.tmp"})
+		s.newHandlerCallsFromPaths(ctx, []string{"foo.txt", "docs/bar.tmp"})
+
+		dirScanner := dirscanner.New(
+			memFS,
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithIncludes("*.txt", "/docs"),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("prunes directories that cannot hold included files", func() {
+		ctx := context.Background()
+		reporter := mocks.NewMockReporter(s.controller)
+
+		memFS := s.newMemFS([]string{"docs/a.md", "docs/sub/b.md", "other/c.md"})
+		s.newHandlerCallsFromPaths(ctx, []string{"docs/a.md"})
+
+		reporter.EXPECT().FileScanned(ctx, "docs/a.md", int64(0))
+		reporter.EXPECT().FileStarted(gomock.Any(), "docs/a.md")
+		reporter.EXPECT().PathExcluded(ctx, "docs/sub", true)
+		reporter.EXPECT().PathExcluded(ctx, "other", true)
+
+		dirScanner := dirscanner.New(
+			memFS,
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithReporter(reporter),
+			dirscanner.WithIncludes("/docs/*.md"),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("honours ignore files", func() {
+		ctx := context.Background()
+
+		memFS := s.newMemFS([]string{"foo.log", "sub/bar.log", "sub/keep.log", "sub/baz.txt"})
+		err := memFS.WriteFile(dirscanner.IgnoreFileName, []byte("*.log\n"), fs.FileMode(0))
+		s.Require().NoError(err)
+		err = memFS.WriteFile(
+			"sub/"+dirscanner.IgnoreFileName, []byte("!keep.log\n*.txt\n"), fs.FileMode(0),
+		)
+		s.Require().NoError(err)
+		s.newHandlerCallsFromPaths(ctx, []string{
+			dirscanner.IgnoreFileName,
+			"sub/" + dirscanner.IgnoreFileName,
+			"sub/keep.log",
+		})
+
+		dirScanner := dirscanner.New(
+			memFS,
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithExcludes("!baz.txt"),
+		)
+
+		err = dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+}
+
+func (s *DirScannerTestSuite) TestExcluded() {
+	memFS := s.newMemFS([]string{"foo.txt", "foo.tmp", "cache/bar.txt", "sub/baz.log"})
+	err := memFS.WriteFile("sub/"+dirscanner.IgnoreFileName, []byte("*.log\n"), fs.FileMode(0))
+	s.Require().NoError(err)
+
+	dirScanner := dirscanner.New(
+		memFS,
+		[]dirscanner.Processor{s.mockProcessor},
+		1,
+		dirscanner.WithExcludes("*.tmp", "cache/"),
+	)
+
+	s.False(dirScanner.Excluded("foo.txt"))
+	s.True(dirScanner.Excluded("foo.tmp"))
+	s.True(dirScanner.Excluded("cache/bar.txt"))
+	s.True(dirScanner.Excluded("sub/baz.log"))
+	s.True(dirScanner.Excluded("sub"))
+	s.False(dirScanner.Excluded("missing"))
+
+	s.Run("reloads changed ignore files", func() {
+		err := memFS.WriteFile("sub/"+dirscanner.IgnoreFileName, []byte{}, fs.FileMode(0))
+		s.Require().NoError(err)
+
+		s.False(dirScanner.Excluded("sub/" + dirscanner.IgnoreFileName))
+		s.False(dirScanner.Excluded("sub/baz.log"))
+	})
+}
+
+func (s *DirScannerTestSuite) TestScanFilters() {
+	numThreads := 2
+	root := s.T().TempDir()
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := fakeClock(func() time.Time { return now })
+
+	files := []struct {
+		path string
+		size int
+		age  time.Duration
+	}{
+		{"small", 10, 48 * time.Hour},
+		{"large", 1000, 48 * time.Hour},
+		{"new", 10, time.Minute},
+		{"dir/old", 10, 400 * 24 * time.Hour},
+	}
+	s.Require().NoError(os.MkdirAll(filepath.Join(root, "dir"), 0755))
+	for _, f := range files {
+		path := filepath.Join(root, f.path)
+		s.Require().NoError(os.WriteFile(path, make([]byte, f.size), 0644))
+		mtime := now.Add(-f.age)
+		s.Require().NoError(os.Chtimes(path, mtime, mtime))
+	}
+
+	s.Run("skips large files", func() {
+		ctx := context.Background()
+
+		s.newHandlerCallsFromPaths(ctx, []string{"small", "new", "dir/old"})
+
+		dirScanner := dirscanner.New(
+			os.DirFS(root),
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithMaxSize(100),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("skips files by age", func() {
+		ctx := context.Background()
+		reporter := mocks.NewMockReporter(s.controller)
+
+		s.newHandlerCallsFromPaths(ctx, []string{"large", "small"})
+
+		reporter.EXPECT().FileScanned(ctx, gomock.Any(), gomock.Any()).Times(2)
+		reporter.EXPECT().FileStarted(gomock.Any(), gomock.Any()).Times(2)
+		reporter.EXPECT().PathExcluded(ctx, "new", false)
+		reporter.EXPECT().PathExcluded(ctx, "dir/old", false)
+
+		dirScanner := dirscanner.New(
+			os.DirFS(root),
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithReporter(reporter),
+			dirscanner.WithClock(clock),
+			dirscanner.WithMinAge(time.Hour),
+			dirscanner.WithMaxAge(365*24*time.Hour),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("stays on root filesystem", func() {
+		ctx := context.Background()
+
+		s.newHandlerCallsFromPaths(ctx, []string{"dir/old", "large", "new", "small"})
+
+		dirScanner := dirscanner.New(
+			os.DirFS(root),
+			[]dirscanner.Processor{s.mockProcessor},
+			numThreads,
+			dirscanner.WithOneFileSystem(),
+		)
+
+		err := dirScanner.Scan(ctx)
+
+		s.Require().NoError(err)
+	})
+}
+
 func (s *DirScannerTestSuite) newMemFS(paths []string) *memfs.FS {
 	memFS := memfs.New()
 
@@ -127,12 +506,17 @@ func (s *DirScannerTestSuite) newHandlerCallsFromPaths(
 			LocalPath: path,
 		}
 		calls[i] = s.mockProcessor.EXPECT().
-			Process(ctx, path).Return(file, nil)
+			Process(gomock.Any(), path).Return(file, nil)
 	}
 
 	return calls
 }
 
+type resettingProcessor struct {
+	*mocks.MockProcessor
+	*mocks.MockResetter
+}
+
 type fakeBadFS struct {
 	err error
 }
@@ -157,6 +541,12 @@ func (s *fakeSalter) Salt(path string) ([]byte, error) {
 	return s.salt, nil
 }
 
+type fakeClock func() time.Time
+
+func (c fakeClock) Now() time.Time {
+	return c()
+}
+
 type fakeVersionCalculator struct {
 	version string
 	err     error
@@ -0,0 +1,124 @@
+package dirscanner
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// Clock defines the interface required to fetch the current time, against
+// which the ages of files are measured.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock returns an option that sets the clock a DirScanner uses to
+// determine the ages of files.
+func WithClock(clock Clock) Option {
+	return func(s *DirScanner) {
+		s.clock = clock
+	}
+}
+
+// WithMaxSize returns an option that causes a DirScanner to skip regular files
+// larger than the provided number of bytes.
+func WithMaxSize(size int64) Option {
+	return func(s *DirScanner) {
+		s.maxSize = size
+	}
+}
+
+// WithMinAge returns an option that causes a DirScanner to skip regular files
+// modified more recently than the provided duration ago.
+func WithMinAge(age time.Duration) Option {
+	return func(s *DirScanner) {
+		s.minAge = age
+	}
+}
+
+// WithMaxAge returns an option that causes a DirScanner to skip regular files
+// last modified longer than the provided duration ago.
+func WithMaxAge(age time.Duration) Option {
+	return func(s *DirScanner) {
+		s.maxAge = age
+	}
+}
+
+// WithOneFileSystem returns an option that prevents a DirScanner from
+// descending into directories on a different filesystem from the root of the
+// scanned hierarchy, such as network shares mounted beneath it.
+func WithOneFileSystem() Option {
+	return func(s *DirScanner) {
+		s.oneFileSystem = true
+	}
+}
+
+// hasFilters reports whether any of the scanner's size, age or filesystem
+// filters are enabled.
+func (s *DirScanner) hasFilters() bool {
+	return s.maxSize > 0 || s.minAge > 0 || s.maxAge > 0 || s.oneFileSystem
+}
+
+// filtered reports whether the provided entry is excluded by the scanner's
+// size, age or filesystem filters.
+func (s *DirScanner) filtered(d fs.DirEntry) bool {
+	if !s.hasFilters() {
+		return false
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	return s.filteredInfo(info)
+}
+
+// filteredInfo reports whether the file described by the provided info is
+// excluded by the scanner's size, age or filesystem filters. Size and age
+// filters only apply to regular files, while the filesystem filter only applies
+// to directories, since mount points are always directories.
+func (s *DirScanner) filteredInfo(info fs.FileInfo) bool {
+	if info.IsDir() {
+		if !s.oneFileSystem || !s.hasRootDev {
+			return false
+		}
+		dev, ok := deviceOf(info)
+		return ok && dev != s.rootDev
+	}
+
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if s.maxSize > 0 && info.Size() > s.maxSize {
+		return true
+	}
+
+	age := s.clock.Now().Sub(info.ModTime())
+	if s.minAge > 0 && age < s.minAge {
+		return true
+	}
+	return s.maxAge > 0 && age > s.maxAge
+}
+
+// loadRootDev records the device holding the root of the scanned hierarchy,
+// if the filesystem reports one.
+func (s *DirScanner) loadRootDev() {
+	s.hasRootDev = false
+	if !s.oneFileSystem {
+		return
+	}
+
+	info, err := fs.Stat(s.fs, ".")
+	if err != nil {
+		s.log.Warnw("Unable to determine filesystem of root directory", "error", err)
+		return
+	}
+	s.rootDev, s.hasRootDev = deviceOf(info)
+}
+
+func deviceOf(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
@@ -49,3 +49,158 @@ func (mr *MockProcessorMockRecorder) Process(ctx, path interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Process", reflect.TypeOf((*MockProcessor)(nil).Process), ctx, path)
 }
+
+// MockResetter is a mock of Resetter interface.
+type MockResetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockResetterMockRecorder
+}
+
+// MockResetterMockRecorder is the mock recorder for MockResetter.
+type MockResetterMockRecorder struct {
+	mock *MockResetter
+}
+
+// NewMockResetter creates a new mock instance.
+func NewMockResetter(ctrl *gomock.Controller) *MockResetter {
+	mock := &MockResetter{ctrl: ctrl}
+	mock.recorder = &MockResetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockResetter) EXPECT() *MockResetterMockRecorder {
+	return m.recorder
+}
+
+// Reset mocks base method.
+func (m *MockResetter) Reset() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Reset")
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockResetterMockRecorder) Reset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockResetter)(nil).Reset))
+}
+
+// MockReporter is a mock of Reporter interface.
+type MockReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockReporterMockRecorder
+}
+
+// MockReporterMockRecorder is the mock recorder for MockReporter.
+type MockReporterMockRecorder struct {
+	mock *MockReporter
+}
+
+// NewMockReporter creates a new mock instance.
+func NewMockReporter(ctrl *gomock.Controller) *MockReporter {
+	mock := &MockReporter{ctrl: ctrl}
+	mock.recorder = &MockReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReporter) EXPECT() *MockReporterMockRecorder {
+	return m.recorder
+}
+
+// FileFailed mocks base method.
+func (m *MockReporter) FileFailed(ctx context.Context, path string, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileFailed", ctx, path, err)
+}
+
+// FileFailed indicates an expected call of FileFailed.
+func (mr *MockReporterMockRecorder) FileFailed(ctx, path, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileFailed", reflect.TypeOf((*MockReporter)(nil).FileFailed), ctx, path, err)
+}
+
+// FileScanned mocks base method.
+func (m *MockReporter) FileScanned(ctx context.Context, path string, size int64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileScanned", ctx, path, size)
+}
+
+// FileScanned indicates an expected call of FileScanned.
+func (mr *MockReporterMockRecorder) FileScanned(ctx, path, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileScanned", reflect.TypeOf((*MockReporter)(nil).FileScanned), ctx, path, size)
+}
+
+// FileStarted mocks base method.
+func (m *MockReporter) FileStarted(ctx context.Context, path string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileStarted", ctx, path)
+}
+
+// FileStarted indicates an expected call of FileStarted.
+func (mr *MockReporterMockRecorder) FileStarted(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileStarted", reflect.TypeOf((*MockReporter)(nil).FileStarted), ctx, path)
+}
+
+// PathExcluded mocks base method.
+func (m *MockReporter) PathExcluded(ctx context.Context, path string, isDir bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PathExcluded", ctx, path, isDir)
+}
+
+// PathExcluded indicates an expected call of PathExcluded.
+func (mr *MockReporterMockRecorder) PathExcluded(ctx, path, isDir interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PathExcluded", reflect.TypeOf((*MockReporter)(nil).PathExcluded), ctx, path, isDir)
+}
+
+// MockScheduler is a mock of Scheduler interface.
+type MockScheduler struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchedulerMockRecorder
+}
+
+// MockSchedulerMockRecorder is the mock recorder for MockScheduler.
+type MockSchedulerMockRecorder struct {
+	mock *MockScheduler
+}
+
+// NewMockScheduler creates a new mock instance.
+func NewMockScheduler(ctrl *gomock.Controller) *MockScheduler {
+	mock := &MockScheduler{ctrl: ctrl}
+	mock.recorder = &MockSchedulerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockScheduler) EXPECT() *MockSchedulerMockRecorder {
+	return m.recorder
+}
+
+// Submit mocks base method.
+func (m *MockScheduler) Submit(ctx context.Context, size int64, fn func(int)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Submit", ctx, size, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Submit indicates an expected call of Submit.
+func (mr *MockSchedulerMockRecorder) Submit(ctx, size, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Submit", reflect.TypeOf((*MockScheduler)(nil).Submit), ctx, size, fn)
+}
+
+// Wait mocks base method.
+func (m *MockScheduler) Wait() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Wait")
+}
+
+// Wait indicates an expected call of Wait.
+func (mr *MockSchedulerMockRecorder) Wait() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockScheduler)(nil).Wait))
+}
@@ -10,4 +10,22 @@ var (
 	// ErrNotFound is returned whenever a resource cannot be found. For example,
 	// a resource may not have been found within a database.
 	ErrNotFound = errors.New("requested resource not found")
+	// ErrVersioningDisabled is returned whenever a storage bucket does not
+	// have versioning enabled, meaning that overwriting an object would
+	// destroy its only copy.
+	ErrVersioningDisabled = errors.New("bucket versioning is not enabled")
+	// ErrChecksumMismatch is returned whenever the checksum computed by the
+	// storage backend for an uploaded object differs from the checksum
+	// computed locally.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrCorruptFile is returned whenever the contents of a file changed
+	// without a change in its ctime, which suggests that the file was
+	// corrupted on disk rather than modified.
+	ErrCorruptFile = errors.New("file contents changed without a change in ctime")
+	// ErrInconsistentFile is returned whenever a file with no previous version
+	// is skipped because it changed during every attempt to upload it.
+	ErrInconsistentFile = errors.New("file changed during every upload attempt")
+	// ErrProbeNotDeleted is returned whenever an object written to check that a
+	// bucket accepts writes could not be deleted afterwards.
+	ErrProbeNotDeleted = errors.New("preflight object could not be deleted")
 )
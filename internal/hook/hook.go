@@ -0,0 +1,255 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+//go:generate mockgen -destination=./mocks/hook.go -package=mocks -source=$GOFILE
+
+const defaultShell = "/bin/sh"
+
+// Stage denotes the point in a backup at which a hook is run.
+type Stage string
+
+const (
+	// StagePre denotes a hook that runs before a backup.
+	StagePre Stage = "pre"
+	// StagePost denotes a hook that runs after a backup.
+	StagePost Stage = "post"
+)
+
+// Status denotes the outcome of a backup, as passed to post-backup hooks.
+type Status string
+
+const (
+	// StatusSuccess denotes a backup that completed without error.
+	StatusSuccess Status = "success"
+	// StatusFailure denotes a backup that failed or was skipped.
+	StatusFailure Status = "failure"
+)
+
+// FailurePolicy denotes what happens to a backup when its pre-backup hook
+// fails.
+type FailurePolicy string
+
+const (
+	// FailurePolicySkip skips the backup whenever its hook fails.
+	FailurePolicySkip FailurePolicy = "skip"
+	// FailurePolicyContinue proceeds with the backup even if its hook fails.
+	FailurePolicyContinue FailurePolicy = "continue"
+)
+
+// Hook encapsulates a shell command run before or after a backup. A zero
+// timeout means the command may run indefinitely.
+type Hook struct {
+	Command   string
+	Timeout   time.Duration
+	OnFailure FailurePolicy
+}
+
+// Env describes the backup a hook is run for. It is passed to the hook's
+// command as a set of environment variables.
+type Env struct {
+	Run       int64
+	Stage     Stage
+	Directory string
+	Bucket    string
+	Status    Status
+}
+
+// Vars returns the environment variables describing the backup, in the form
+// expected by exec.Cmd.
+func (e Env) Vars() []string {
+	vars := []string{
+		"HOARD_RUN=" + strconv.FormatInt(e.Run, 10),
+		"HOARD_HOOK_STAGE=" + string(e.Stage),
+	}
+	if e.Directory != "" {
+		vars = append(vars, "HOARD_DIRECTORY="+e.Directory)
+	}
+	if e.Bucket != "" {
+		vars = append(vars, "HOARD_BUCKET="+e.Bucket)
+	}
+	if e.Status != "" {
+		vars = append(vars, "HOARD_STATUS="+string(e.Status))
+	}
+	return vars
+}
+
+// Reporter specifies the interface required to report the outcome of hooks.
+type Reporter interface {
+	HookFinished(ctx context.Context, name string, duration time.Duration, err error)
+}
+
+// Clock defines the interface required to measure how long a hook runs for.
+type Clock interface {
+	Now() time.Time
+}
+
+// Option is the type used to implement the functional options pattern for the
+// Runner type.
+type Option func(*Runner)
+
+// Runner runs hook commands, logging their output and reporting their outcome.
+type Runner struct {
+	log      *zap.SugaredLogger
+	clock    Clock
+	reporter Reporter
+	shell    string
+}
+
+// NewRunner instantiates a new Runner instance.
+func NewRunner(opts ...Option) *Runner {
+	r := &Runner{
+		log:      util.MustNewLogger(),
+		clock:    &util.Clock{},
+		reporter: nopReporter{},
+		shell:    defaultShell,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// WithReporter returns an option for setting the reporter a Runner notifies
+// when a hook finishes.
+func WithReporter(reporter Reporter) Option {
+	return func(r *Runner) {
+		r.reporter = reporter
+	}
+}
+
+// WithClock returns an option for setting the clock a Runner uses to time
+// hooks.
+func WithClock(clock Clock) Option {
+	return func(r *Runner) {
+		r.clock = clock
+	}
+}
+
+// Run runs the provided hook, if any, using the runner's shell. The output of
+// the command is written to the log line by line. Every outcome is reported,
+// but an error is only returned if the hook failed and its failure policy
+// requires the backup to be skipped.
+func (r *Runner) Run(ctx context.Context, name string, h *Hook, env Env) error {
+	if h == nil || h.Command == "" {
+		return nil
+	}
+
+	r.log.Infow("Running hook", "hook", name, "command", h.Command)
+
+	start := r.clock.Now()
+	err := r.run(ctx, name, h, env)
+	duration := r.clock.Now().Sub(start)
+	r.reporter.HookFinished(ctx, name, duration, err)
+
+	if err == nil {
+		r.log.Infow("Hook succeeded", "hook", name, "duration", duration)
+		return nil
+	}
+
+	r.log.Warnw("Hook failed", "hook", name, "duration", duration, "error", err)
+	if h.OnFailure == FailurePolicyContinue {
+		return nil
+	}
+
+	return fmt.Errorf("hook %q failed: %w", name, err)
+}
+
+func (r *Runner) run(ctx context.Context, name string, h *Hook, env Env) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	stdout := &logWriter{log: r.log, hook: name, stream: "stdout"}
+	stderr := &logWriter{log: r.log, hook: name, stream: "stderr"}
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command(r.shell, "-c", h.Command)
+	cmd.Env = append(os.Environ(), env.Vars()...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Run the command in its own process group, so that any processes it
+	// starts are killed along with it if the hook times out.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+
+	err := cmd.Wait()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s", h.Timeout)
+		}
+		return ctxErr
+	}
+
+	return err
+}
+
+// logWriter writes each complete line written to it to the log.
+type logWriter struct {
+	log    *zap.SugaredLogger
+	hook   string
+	stream string
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any incomplete final line to the log.
+func (w *logWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *logWriter) writeLine(line []byte) {
+	w.log.Infow("Hook output", "hook", w.hook, "stream", w.stream, "line", string(line))
+}
+
+type nopReporter struct{}
+
+func (r nopReporter) HookFinished(ctx context.Context, name string, duration time.Duration, err error) {
+}
@@ -0,0 +1,125 @@
+package hook_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/hook"
+	"github.com/mspraggs/hoard/internal/hook/mocks"
+)
+
+type contextKey string
+
+type HookTestSuite struct {
+	suite.Suite
+	controller   *gomock.Controller
+	mockReporter *mocks.MockReporter
+}
+
+func TestHookTestSuite(t *testing.T) {
+	suite.Run(t, new(HookTestSuite))
+}
+
+func (s *HookTestSuite) SetupTest() {
+	s.controller = gomock.NewController(s.T())
+	s.mockReporter = mocks.NewMockReporter(s.controller)
+}
+
+type fakeClock func() time.Time
+
+func (fn fakeClock) Now() time.Time {
+	return fn()
+}
+
+func (s *HookTestSuite) TestRun() {
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	env := hook.Env{
+		Run:       42,
+		Stage:     hook.StagePost,
+		Directory: "/some/dir",
+		Bucket:    "some-bucket",
+		Status:    hook.StatusSuccess,
+	}
+
+	s.Run("runs command with environment describing backup", func() {
+		out := filepath.Join(s.T().TempDir(), "env")
+		h := &hook.Hook{
+			Command: `echo "$HOARD_RUN $HOARD_HOOK_STAGE $HOARD_DIRECTORY $HOARD_BUCKET $HOARD_STATUS" > ` + out,
+		}
+		s.mockReporter.EXPECT().HookFinished(ctx, "name", gomock.Any(), nil)
+
+		runner := hook.NewRunner(hook.WithReporter(s.mockReporter))
+
+		err := runner.Run(ctx, "name", h, env)
+
+		s.Require().NoError(err)
+		contents, err := os.ReadFile(out)
+		s.Require().NoError(err)
+		s.Equal("42 post /some/dir some-bucket success\n", string(contents))
+	})
+	s.Run("reports duration", func() {
+		now := time.Unix(100, 0)
+		clock := fakeClock(func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		})
+		s.mockReporter.EXPECT().HookFinished(ctx, "name", time.Second, nil)
+
+		runner := hook.NewRunner(hook.WithReporter(s.mockReporter), hook.WithClock(clock))
+
+		err := runner.Run(ctx, "name", &hook.Hook{Command: "true"}, env)
+
+		s.NoError(err)
+	})
+	s.Run("ignores missing hook", func() {
+		runner := hook.NewRunner(hook.WithReporter(s.mockReporter))
+
+		err := runner.Run(ctx, "name", nil, env)
+
+		s.NoError(err)
+	})
+	s.Run("handles failing command", func() {
+		s.Run("that skips backup", func() {
+			h := &hook.Hook{Command: "exit 3", OnFailure: hook.FailurePolicySkip}
+			s.mockReporter.EXPECT().HookFinished(ctx, "name", gomock.Any(), gomock.Not(nil))
+
+			runner := hook.NewRunner(hook.WithReporter(s.mockReporter))
+
+			err := runner.Run(ctx, "name", h, env)
+
+			s.ErrorContains(err, "exit status 3")
+		})
+		s.Run("that continues backup", func() {
+			h := &hook.Hook{Command: "exit 3", OnFailure: hook.FailurePolicyContinue}
+			s.mockReporter.EXPECT().HookFinished(ctx, "name", gomock.Any(), gomock.Not(nil))
+
+			runner := hook.NewRunner(hook.WithReporter(s.mockReporter))
+
+			err := runner.Run(ctx, "name", h, env)
+
+			s.NoError(err)
+		})
+		s.Run("that times out", func() {
+			h := &hook.Hook{
+				Command:   "sleep 10 & wait",
+				Timeout:   50 * time.Millisecond,
+				OnFailure: hook.FailurePolicySkip,
+			}
+			s.mockReporter.EXPECT().HookFinished(ctx, "name", gomock.Any(), gomock.Not(nil))
+
+			runner := hook.NewRunner(hook.WithReporter(s.mockReporter))
+
+			start := time.Now()
+			err := runner.Run(ctx, "name", h, env)
+
+			s.ErrorContains(err, "timed out")
+			s.Less(time.Since(start), 5*time.Second)
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+)
+
+const anySegments = "**"
+
+// Pattern is a single gitignore-style pattern. Patterns containing a slash
+// other than a trailing one are matched against the path relative to the
+// directory the pattern was defined in, while other patterns are matched
+// against the name of a file at any depth beneath that directory. A trailing
+// slash restricts a pattern to directories, "**" matches any number of
+// directories and a leading "!" negates a pattern.
+type Pattern struct {
+	base     string
+	segments []string
+	negated  bool
+	dirOnly  bool
+}
+
+// ParsePattern parses a single pattern defined in the provided base
+// directory, given as a slash-separated path relative to the root of the
+// scanned hierarchy. The returned flag is false if the line holds no pattern,
+// because it is blank or a comment.
+func ParsePattern(base, line string) (Pattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+
+	p := Pattern{base: path.Clean(base)}
+	if p.base == "." {
+		p.base = ""
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	p.segments = strings.Split(line, "/")
+	if !anchored {
+		p.segments = append([]string{anySegments}, p.segments...)
+	}
+
+	return p, true
+}
+
+// Parse reads one pattern per line from the provided reader, skipping blank
+// lines and comments. The patterns are relative to the provided base
+// directory.
+func Parse(base string, r io.Reader) ([]Pattern, error) {
+	var patterns []Pattern
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := ParsePattern(base, scanner.Text()); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
+
+// ParseList parses each of the provided patterns relative to the root of the
+// scanned hierarchy.
+func ParseList(lines []string) []Pattern {
+	var patterns []Pattern
+	for _, line := range lines {
+		if p, ok := ParsePattern("", line); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Negated reports whether the pattern re-includes the paths it matches.
+func (p Pattern) Negated() bool {
+	return p.negated
+}
+
+// Match reports whether the pattern matches the provided slash-separated path,
+// relative to the root of the scanned hierarchy.
+func (p Pattern) Match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	rel := name
+	if p.base != "" {
+		if !strings.HasPrefix(name, p.base+"/") {
+			return false
+		}
+		rel = name[len(p.base)+1:]
+	}
+
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+// MayMatchWithin reports whether the pattern may match the provided
+// slash-separated directory, one of its ancestors or any path beneath it, so
+// that a directory for which it reports false can be pruned without being
+// traversed in search of matching paths.
+func (p Pattern) MayMatchWithin(dir string) bool {
+	if dir == "." || dir == p.base || strings.HasPrefix(p.base, dir+"/") {
+		return true
+	}
+
+	rel := dir
+	if p.base != "" {
+		if !strings.HasPrefix(dir, p.base+"/") {
+			return false
+		}
+		rel = dir[len(p.base)+1:]
+	}
+
+	return matchPrefix(p.segments, strings.Split(rel, "/"))
+}
+
+// Matches reports whether the provided path is matched by the provided
+// patterns. Later patterns take precedence, so the last pattern to match
+// decides the outcome.
+func Matches(patterns []Pattern, name string, isDir bool) bool {
+	matched := false
+	for _, p := range patterns {
+		if p.Match(name, isDir) {
+			matched = !p.negated
+		}
+	}
+	return matched
+}
+
+// matchPrefix reports whether the provided pattern segments may match a path
+// starting with the provided parts, or one of their leading subsets.
+func matchPrefix(patterns, parts []string) bool {
+	if len(patterns) == 0 || len(parts) == 0 || patterns[0] == anySegments {
+		return true
+	}
+	if ok, err := path.Match(patterns[0], parts[0]); err != nil || !ok {
+		return false
+	}
+
+	return matchPrefix(patterns[1:], parts[1:])
+}
+
+func matchSegments(patterns, parts []string) bool {
+	if len(patterns) == 0 {
+		return len(parts) == 0
+	}
+
+	if patterns[0] == anySegments {
+		// A trailing "**" matches everything within a directory, but not the
+		// directory itself.
+		if len(patterns) == 1 {
+			return len(parts) > 0
+		}
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(patterns[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, err := path.Match(patterns[0], parts[0]); err != nil || !ok {
+		return false
+	}
+
+	return matchSegments(patterns[1:], parts[1:])
+}
@@ -0,0 +1,120 @@
+package ignore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/ignore"
+)
+
+type IgnoreTestSuite struct {
+	suite.Suite
+}
+
+func TestIgnoreTestSuite(t *testing.T) {
+	suite.Run(t, new(IgnoreTestSuite))
+}
+
+func (s *IgnoreTestSuite) TestMatch() {
+	testCases := []struct {
+		name    string
+		base    string
+		pattern string
+		path    string
+		isDir   bool
+		match   bool
+	}{
+		{"name at any depth", "", "*.tmp", "a/b/c.tmp", false, true},
+		{"name at top level", "", "*.tmp", "c.tmp", false, true},
+		{"different name", "", "*.tmp", "a/c.txt", false, false},
+		{"directory name", "", "node_modules/", "a/node_modules", true, true},
+		{"directory only", "", "build/", "a/build", false, false},
+		{"anchored", "", "/build", "build", true, true},
+		{"anchored nested", "", "/build", "a/build", true, false},
+		{"path with slash", "", "docs/*.md", "docs/a.md", false, true},
+		{"path with slash nested", "", "docs/*.md", "x/docs/a.md", false, false},
+		{"leading double star", "", "**/cache", "a/b/cache", true, true},
+		{"middle double star", "", "a/**/z", "a/b/c/z", false, true},
+		{"middle double star empty", "", "a/**/z", "a/z", false, true},
+		{"trailing double star", "", "logs/**", "logs/a/b", false, true},
+		{"trailing double star directory", "", "logs/**", "logs", true, false},
+		{"relative to base", "sub", "*.log", "sub/x/a.log", false, true},
+		{"outside base", "sub", "*.log", "other/a.log", false, false},
+		{"anchored to base", "sub", "/out", "sub/out", true, true},
+		{"character class", "", "file[0-9]", "file7", false, true},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			p, ok := ignore.ParsePattern(tc.base, tc.pattern)
+
+			s.Require().True(ok)
+			s.Equal(tc.match, p.Match(tc.path, tc.isDir))
+		})
+	}
+}
+
+func (s *IgnoreTestSuite) TestMayMatchWithin() {
+	testCases := []struct {
+		name    string
+		base    string
+		pattern string
+		dir     string
+		match   bool
+	}{
+		{"root", "", "/docs/*.md", ".", true},
+		{"name at any depth", "", "*.md", "a/b", true},
+		{"matching directory", "", "/docs", "docs", true},
+		{"beneath matching directory", "", "/docs", "docs/a/b", true},
+		{"leading directory", "", "/docs/*.md", "docs", true},
+		{"other directory", "", "/docs/*.md", "other", false},
+		{"too deep", "", "/docs/*.md", "docs/sub", false},
+		{"double star", "", "docs/**/*.md", "docs/a/b", true},
+		{"ancestor of base", "sub/dir", "/out", "sub", true},
+		{"outside base", "sub", "/out", "other", false},
+		{"relative to base", "sub", "/out", "sub/out/x", true},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			p, ok := ignore.ParsePattern(tc.base, tc.pattern)
+
+			s.Require().True(ok)
+			s.Equal(tc.match, p.MayMatchWithin(tc.dir))
+		})
+	}
+}
+
+func (s *IgnoreTestSuite) TestParse() {
+	input := strings.Join([]string{
+		"# comment",
+		"",
+		"*.tmp   ",
+		"!keep.tmp",
+		`\#literal`,
+	}, "\n")
+
+	patterns, err := ignore.Parse("", strings.NewReader(input))
+
+	s.Require().NoError(err)
+	s.Require().Len(patterns, 3)
+	s.False(patterns[0].Negated())
+	s.True(patterns[1].Negated())
+	s.True(patterns[2].Match("#literal", false))
+}
+
+func (s *IgnoreTestSuite) TestMatches() {
+	patterns := ignore.ParseList([]string{"*.tmp", "!keep.tmp"})
+
+	s.Run("matches excluded path", func() {
+		s.True(ignore.Matches(patterns, "a/b.tmp", false))
+	})
+	s.Run("later negation takes precedence", func() {
+		s.False(ignore.Matches(patterns, "a/keep.tmp", false))
+	})
+	s.Run("does not match other paths", func() {
+		s.False(ignore.Matches(patterns, "a/b.txt", false))
+	})
+}
@@ -0,0 +1,131 @@
+package keygen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+// Layout denotes a strategy for laying out object keys within a storage bucket.
+type Layout string
+
+const (
+	// LayoutUUID assigns a random UUID to each new path.
+	LayoutUUID Layout = "uuid"
+	// LayoutMirror mirrors the path of a file relative to its directory.
+	LayoutMirror Layout = "mirror"
+	// LayoutHost prefixes the path of a file with the host name and the
+	// directory containing it.
+	LayoutHost Layout = "host"
+	// LayoutDate prefixes the path of a file with the date on which it was
+	// first uploaded.
+	LayoutDate Layout = "date"
+	// LayoutHashed prefixes the path of a file with two levels of shards
+	// derived from a hash of the path.
+	LayoutHashed Layout = "hashed"
+)
+
+// Clock defines the interface required to fetch the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Option is the type used to implement the functional options pattern for the
+// Generator type.
+type Option func(*Generator)
+
+// Generator encapsulates the logic required to generate object keys for files
+// according to a particular layout.
+type Generator struct {
+	layout    Layout
+	prefix    string
+	host      string
+	directory string
+	clock     Clock
+}
+
+// New instantiates a new Generator using the provided layout. An error is
+// returned if the layout is not recognised.
+func New(layout Layout, opts ...Option) (*Generator, error) {
+	switch layout {
+	case LayoutUUID, LayoutMirror, LayoutHost, LayoutDate, LayoutHashed:
+	default:
+		return nil, fmt.Errorf("unknown key layout %q", layout)
+	}
+
+	g := &Generator{
+		layout: layout,
+		clock:  &util.Clock{},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g, nil
+}
+
+// WithPrefix returns an option that prepends the provided prefix to all
+// generated keys.
+func WithPrefix(prefix string) Option {
+	return func(g *Generator) {
+		g.prefix = prefix
+	}
+}
+
+// WithHost returns an option that sets the host name used by the host layout.
+func WithHost(host string) Option {
+	return func(g *Generator) {
+		g.host = host
+	}
+}
+
+// WithDirectory returns an option that sets the directory path used by the host
+// layout.
+func WithDirectory(directory string) Option {
+	return func(g *Generator) {
+		g.directory = directory
+	}
+}
+
+// WithClock returns an option that sets the clock used by the date layout.
+func WithClock(clock Clock) Option {
+	return func(g *Generator) {
+		g.clock = clock
+	}
+}
+
+// GenerateKey returns a key for the file with the provided path in accordance
+// with the KeyGenerator interface.
+func (g *Generator) GenerateKey(p string) string {
+	var key string
+
+	switch g.layout {
+	case LayoutMirror:
+		key = p
+	case LayoutHost:
+		key = path.Join(g.host, strings.TrimPrefix(g.directory, "/"), p)
+	case LayoutDate:
+		key = path.Join(g.clock.Now().UTC().Format("2006/01/02"), p)
+	case LayoutHashed:
+		sum := sha256.Sum256([]byte(p))
+		shard := hex.EncodeToString(sum[:2])
+		key = path.Join(shard[:2], shard[2:], p)
+	default:
+		key = uuid.NewString()
+	}
+
+	return g.prefix + key
+}
+
+// Layout returns the name of the layout used to generate keys in accordance
+// with the KeyGenerator interface.
+func (g *Generator) Layout() string {
+	return string(g.layout)
+}
@@ -0,0 +1,99 @@
+package keygen_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/keygen"
+)
+
+type GeneratorTestSuite struct {
+	suite.Suite
+}
+
+func TestGeneratorTestSuite(t *testing.T) {
+	suite.Run(t, new(GeneratorTestSuite))
+}
+
+type fakeClock func() time.Time
+
+func (fn fakeClock) Now() time.Time {
+	return fn()
+}
+
+func (s *GeneratorTestSuite) TestGenerateKey() {
+	path := "path/to/file"
+	clock := fakeClock(func() time.Time { return time.Date(2022, 6, 5, 1, 2, 3, 0, time.UTC) })
+
+	testCases := []struct {
+		name        string
+		layout      keygen.Layout
+		opts        []keygen.Option
+		expectedKey string
+	}{
+		{
+			name:        "mirror",
+			layout:      keygen.LayoutMirror,
+			expectedKey: "path/to/file",
+		},
+		{
+			name:   "host",
+			layout: keygen.LayoutHost,
+			opts: []keygen.Option{
+				keygen.WithHost("some-host"),
+				keygen.WithDirectory("/some/dir"),
+			},
+			expectedKey: "some-host/some/dir/path/to/file",
+		},
+		{
+			name:        "date",
+			layout:      keygen.LayoutDate,
+			opts:        []keygen.Option{keygen.WithClock(clock)},
+			expectedKey: "2022/06/05/path/to/file",
+		},
+		{
+			name:        "hashed",
+			layout:      keygen.LayoutHashed,
+			expectedKey: "c1/0e/path/to/file",
+		},
+		{
+			name:        "mirror with prefix",
+			layout:      keygen.LayoutMirror,
+			opts:        []keygen.Option{keygen.WithPrefix("some-prefix/")},
+			expectedKey: "some-prefix/path/to/file",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			g, err := keygen.New(tc.layout, tc.opts...)
+			s.Require().NoError(err)
+
+			s.Equal(tc.expectedKey, g.GenerateKey(path))
+			s.Equal(string(tc.layout), g.Layout())
+		})
+	}
+
+	s.Run("uuid", func() {
+		g, err := keygen.New(keygen.LayoutUUID, keygen.WithPrefix("some-prefix/"))
+		s.Require().NoError(err)
+
+		key := g.GenerateKey(path)
+
+		s.Require().Regexp("^some-prefix/", key)
+		_, err = uuid.Parse(key[len("some-prefix/"):])
+		s.NoError(err)
+	})
+}
+
+func (s *GeneratorTestSuite) TestNew() {
+	s.Run("returns error for unknown layout", func() {
+		g, err := keygen.New(keygen.Layout("foo"))
+
+		s.Nil(g)
+		s.ErrorContains(err, "unknown key layout")
+	})
+}
@@ -0,0 +1,156 @@
+package metadata
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// ApplierOption is the type used to implement the functional options pattern
+// for the Applier type.
+type ApplierOption func(*Applier)
+
+// Applier reapplies recorded metadata to restored files.
+type Applier struct {
+	skipOwnership bool
+	userMap       map[string]string
+	groupMap      map[string]string
+	lookupUser    func(name string) (int, error)
+	lookupGroup   func(name string) (int, error)
+}
+
+// NewApplier instantiates a new Applier instance.
+func NewApplier(opts ...ApplierOption) *Applier {
+	a := &Applier{
+		lookupUser:  lookupUser,
+		lookupGroup: lookupGroup,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// WithSkipOwnership returns an option that causes an Applier to leave the
+// ownership of restored files unchanged. This is required when restoring as a
+// user other than root.
+func WithSkipOwnership() ApplierOption {
+	return func(a *Applier) {
+		a.skipOwnership = true
+	}
+}
+
+// WithUserMap returns an option for remapping the owners of restored files.
+// Keys and values may be user names or numeric IDs.
+func WithUserMap(userMap map[string]string) ApplierOption {
+	return func(a *Applier) {
+		a.userMap = userMap
+	}
+}
+
+// WithGroupMap returns an option for remapping the groups of restored files.
+// Keys and values may be group names or numeric IDs.
+func WithGroupMap(groupMap map[string]string) ApplierOption {
+	return func(a *Applier) {
+		a.groupMap = groupMap
+	}
+}
+
+// Apply applies the provided metadata to the file with the provided path. The
+// owner and group are resolved by name where possible, so that ownership is
+// preserved on systems with different numeric IDs. Every piece of metadata is
+// applied even if an earlier one fails, and the first error is returned.
+func (a *Applier) Apply(path string, md *Metadata) error {
+	if md == nil {
+		return nil
+	}
+
+	var firstErr error
+	record := func(err error, what string) {
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("unable to restore %s of %q: %w", what, path, err)
+		}
+	}
+
+	if !a.skipOwnership {
+		uid := resolveID(md.User, md.UID, a.userMap, a.lookupUser)
+		gid := resolveID(md.Group, md.GID, a.groupMap, a.lookupGroup)
+		record(unix.Lchown(path, uid, gid), "ownership")
+	}
+
+	// Changing ownership clears the setuid and setgid bits, so the mode is
+	// applied afterwards. Symbolic links have no mode of their own.
+	if md.Mode&unix.S_IFMT != unix.S_IFLNK {
+		record(unix.Chmod(path, md.Mode&07777), "mode")
+	}
+
+	for name, value := range md.Xattrs {
+		record(unix.Lsetxattr(path, name, value, 0), "extended attributes")
+	}
+	if len(md.ACL) > 0 {
+		record(unix.Lsetxattr(path, xattrACLAccess, md.ACL, 0), "ACL")
+	}
+	if len(md.DefaultACL) > 0 {
+		record(unix.Lsetxattr(path, xattrACLDefault, md.DefaultACL, 0), "default ACL")
+	}
+
+	times := []unix.Timespec{
+		unix.NsecToTimespec(md.ATime.UnixNano()),
+		unix.NsecToTimespec(md.MTime.UnixNano()),
+	}
+	record(
+		unix.UtimesNanoAt(unix.AT_FDCWD, path, times, unix.AT_SYMLINK_NOFOLLOW),
+		"timestamps",
+	)
+
+	return firstErr
+}
+
+// resolveID returns the numeric ID a restored file should be owned by. The
+// recorded name or ID is first remapped, if a mapping is provided, and the
+// result is looked up by name. The recorded ID is used if the name is unknown.
+func resolveID(
+	name string,
+	id int,
+	mapping map[string]string,
+	lookup func(string) (int, error),
+) int {
+
+	target := name
+	if mapped, ok := mapping[name]; ok && name != "" {
+		target = mapped
+	} else if mapped, ok := mapping[strconv.Itoa(id)]; ok {
+		target = mapped
+	}
+
+	if n, err := strconv.Atoi(target); err == nil {
+		return n
+	}
+	if target != "" {
+		if n, err := lookup(target); err == nil {
+			return n
+		}
+	}
+
+	return id
+}
+
+func lookupUser(name string) (int, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+func lookupGroup(name string) (int, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
@@ -0,0 +1,239 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// xattrACLAccess is the extended attribute holding the POSIX access ACL of
+	// a file.
+	xattrACLAccess = "system.posix_acl_access"
+	// xattrACLDefault is the extended attribute holding the default POSIX ACL
+	// of a directory.
+	xattrACLDefault = "system.posix_acl_default"
+)
+
+// Metadata contains the POSIX metadata of a file. POSIX ACLs are held in their
+// extended attribute encoding, separately from the other extended attributes.
+type Metadata struct {
+	Mode       uint32            `json:"mode"`
+	UID        int               `json:"uid"`
+	GID        int               `json:"gid"`
+	User       string            `json:"user,omitempty"`
+	Group      string            `json:"group,omitempty"`
+	MTime      time.Time         `json:"mtime"`
+	ATime      time.Time         `json:"atime"`
+	Xattrs     map[string][]byte `json:"xattrs,omitempty"`
+	ACL        []byte            `json:"acl,omitempty"`
+	DefaultACL []byte            `json:"default_acl,omitempty"`
+}
+
+// Equal reports whether two sets of metadata are the same, ignoring access
+// times, which change whenever a file is read.
+func (m *Metadata) Equal(other *Metadata) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+
+	if m.Mode != other.Mode || m.UID != other.UID || m.GID != other.GID ||
+		!m.MTime.Equal(other.MTime) || !bytes.Equal(m.ACL, other.ACL) ||
+		!bytes.Equal(m.DefaultACL, other.DefaultACL) ||
+		len(m.Xattrs) != len(other.Xattrs) {
+		return false
+	}
+	for name, value := range m.Xattrs {
+		otherValue, ok := other.Xattrs[name]
+		if !ok || !bytes.Equal(value, otherValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReaderOption is the type used to implement the functional options pattern
+// for the Reader type.
+type ReaderOption func(*Reader)
+
+// Reader reads the metadata of files relative to a root directory.
+type Reader struct {
+	root   string
+	follow bool
+}
+
+// NewReader instantiates a new Reader for files within the provided root
+// directory.
+func NewReader(root string, opts ...ReaderOption) *Reader {
+	r := &Reader{root: root}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// WithFollowSymlinks returns an option that causes a Reader to report the
+// metadata of the targets of symbolic links rather than of the links
+// themselves.
+func WithFollowSymlinks() ReaderOption {
+	return func(r *Reader) {
+		r.follow = true
+	}
+}
+
+// Stat returns information about the file with the provided path relative to
+// the reader's root directory. Symbolic links are only followed if the reader
+// is configured to follow them.
+func (r *Reader) Stat(path string) (fs.FileInfo, error) {
+	if r.follow {
+		return os.Stat(r.fullPath(path))
+	}
+	return os.Lstat(r.fullPath(path))
+}
+
+// ReadLink returns the target of the symbolic link with the provided path
+// relative to the reader's root directory.
+func (r *Reader) ReadLink(path string) (string, error) {
+	return os.Readlink(r.fullPath(path))
+}
+
+// GetMetadata returns the metadata of the file with the provided path relative
+// to the reader's root directory. Symbolic links are only followed if the
+// reader is configured to follow them.
+func (r *Reader) GetMetadata(path string) (*Metadata, error) {
+	fullPath := r.fullPath(path)
+
+	stat := unix.Lstat
+	if r.follow {
+		stat = unix.Stat
+	}
+
+	var st unix.Stat_t
+	if err := stat(fullPath, &st); err != nil {
+		return nil, err
+	}
+
+	md := &Metadata{
+		Mode:  st.Mode,
+		UID:   int(st.Uid),
+		GID:   int(st.Gid),
+		MTime: time.Unix(st.Mtim.Unix()).UTC(),
+		ATime: time.Unix(st.Atim.Unix()).UTC(),
+	}
+	if u, err := user.LookupId(strconv.Itoa(md.UID)); err == nil {
+		md.User = u.Username
+	}
+	if g, err := user.LookupGroupId(strconv.Itoa(md.GID)); err == nil {
+		md.Group = g.Name
+	}
+
+	xattrs, err := readXattrs(fullPath, r.follow)
+	if err != nil {
+		return nil, err
+	}
+	md.ACL = xattrs[xattrACLAccess]
+	md.DefaultACL = xattrs[xattrACLDefault]
+	delete(xattrs, xattrACLAccess)
+	delete(xattrs, xattrACLDefault)
+	if len(xattrs) > 0 {
+		md.Xattrs = xattrs
+	}
+
+	return md, nil
+}
+
+func (r *Reader) fullPath(path string) string {
+	return filepath.Join(r.root, filepath.FromSlash(path))
+}
+
+func readXattrs(path string, follow bool) (map[string][]byte, error) {
+	listxattr, getxattr := unix.Llistxattr, unix.Lgetxattr
+	if follow {
+		listxattr, getxattr = unix.Listxattr, unix.Getxattr
+	}
+
+	names, err := listXattrs(path, listxattr)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := getXattr(path, name, getxattr)
+		if errors.Is(err, unix.ENODATA) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		xattrs[name] = value
+	}
+
+	return xattrs, nil
+}
+
+func listXattrs(path string, listxattr func(string, []byte) (int, error)) ([]string, error) {
+	for {
+		size, err := listxattr(path, nil)
+		if err != nil {
+			if errors.Is(err, unix.ENOTSUP) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, size)
+		size, err = listxattr(path, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The attributes changed between calls, so try again.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, name := range bytes.Split(buf[:size], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+func getXattr(
+	path, name string,
+	getxattr func(string, string, []byte) (int, error),
+) ([]byte, error) {
+
+	for {
+		size, err := getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size)
+		size, err = getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	}
+}
@@ -0,0 +1,186 @@
+package metadata_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+
+	"github.com/mspraggs/hoard/internal/metadata"
+)
+
+type MetadataTestSuite struct {
+	suite.Suite
+}
+
+func TestMetadataTestSuite(t *testing.T) {
+	suite.Run(t, new(MetadataTestSuite))
+}
+
+func (s *MetadataTestSuite) writeFile(dir, name string) string {
+	path := filepath.Join(dir, name)
+	s.Require().NoError(os.WriteFile(path, []byte{1, 2, 3}, 0640))
+	return path
+}
+
+// setXattr sets a user extended attribute, skipping the test if the temporary
+// filesystem does not support them.
+func (s *MetadataTestSuite) setXattr(path, name string, value []byte) {
+	err := unix.Setxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EPERM) {
+		s.T().Skip("extended attributes not supported")
+	}
+	s.Require().NoError(err)
+}
+
+func (s *MetadataTestSuite) TestGetMetadata() {
+	dir := s.T().TempDir()
+	path := s.writeFile(dir, "file")
+	mtime := time.Unix(1000, 500).UTC()
+	atime := time.Unix(2000, 0).UTC()
+	s.Require().NoError(os.Chtimes(path, atime, mtime))
+
+	md, err := metadata.NewReader(dir).GetMetadata("file")
+
+	s.Require().NoError(err)
+	s.Equal(uint32(0640), md.Mode&0777)
+	s.Equal(os.Getuid(), md.UID)
+	s.Equal(os.Getgid(), md.GID)
+	s.Equal(mtime, md.MTime)
+	s.Equal(atime, md.ATime)
+
+	s.Run("includes extended attributes", func() {
+		s.setXattr(path, "user.hoard", []byte("value"))
+
+		md, err := metadata.NewReader(dir).GetMetadata("file")
+
+		s.Require().NoError(err)
+		s.Equal(map[string][]byte{"user.hoard": []byte("value")}, md.Xattrs)
+	})
+}
+
+func (s *MetadataTestSuite) TestSymlinks() {
+	dir := s.T().TempDir()
+	s.writeFile(dir, "file")
+	s.Require().NoError(os.Symlink("file", filepath.Join(dir, "link")))
+
+	s.Run("reads links without following them", func() {
+		reader := metadata.NewReader(dir)
+
+		info, err := reader.Stat("link")
+		s.Require().NoError(err)
+		target, err := reader.ReadLink("link")
+		s.Require().NoError(err)
+		md, err := reader.GetMetadata("link")
+		s.Require().NoError(err)
+
+		s.Equal(os.ModeSymlink, info.Mode().Type())
+		s.Equal("file", target)
+		s.Equal(uint32(unix.S_IFLNK), md.Mode&unix.S_IFMT)
+	})
+	s.Run("follows links if configured", func() {
+		reader := metadata.NewReader(dir, metadata.WithFollowSymlinks())
+
+		info, err := reader.Stat("link")
+		s.Require().NoError(err)
+		md, err := reader.GetMetadata("link")
+		s.Require().NoError(err)
+
+		s.True(info.Mode().IsRegular())
+		s.Equal(uint32(0640), md.Mode&0777)
+	})
+}
+
+func (s *MetadataTestSuite) TestApply() {
+	md := &metadata.Metadata{
+		Mode:  unix.S_IFREG | 0604,
+		UID:   os.Getuid(),
+		GID:   os.Getgid(),
+		MTime: time.Unix(1000, 500).UTC(),
+		ATime: time.Unix(2000, 0).UTC(),
+	}
+
+	s.Run("applies mode and timestamps", func() {
+		dir := s.T().TempDir()
+		path := s.writeFile(dir, "file")
+
+		err := metadata.NewApplier(metadata.WithSkipOwnership()).Apply(path, md)
+
+		s.Require().NoError(err)
+		applied, err := metadata.NewReader(dir).GetMetadata("file")
+		s.Require().NoError(err)
+		s.Equal(md.Mode, applied.Mode)
+		s.Equal(md.MTime, applied.MTime)
+		s.Equal(md.ATime, applied.ATime)
+	})
+	s.Run("applies remapped ownership", func() {
+		dir := s.T().TempDir()
+		path := s.writeFile(dir, "file")
+		md := *md
+		md.UID, md.GID = 12345, 23456
+
+		applier := metadata.NewApplier(
+			metadata.WithUserMap(map[string]string{"12345": strconv.Itoa(os.Getuid())}),
+			metadata.WithGroupMap(map[string]string{"23456": strconv.Itoa(os.Getgid())}),
+		)
+		err := applier.Apply(path, &md)
+
+		s.Require().NoError(err)
+		applied, err := metadata.NewReader(dir).GetMetadata("file")
+		s.Require().NoError(err)
+		s.Equal(os.Getuid(), applied.UID)
+		s.Equal(os.Getgid(), applied.GID)
+	})
+	s.Run("applies extended attributes", func() {
+		dir := s.T().TempDir()
+		path := s.writeFile(dir, "file")
+		s.setXattr(path, "user.probe", []byte("probe"))
+		md := *md
+		md.Xattrs = map[string][]byte{"user.hoard": []byte("value")}
+
+		err := metadata.NewApplier(metadata.WithSkipOwnership()).Apply(path, &md)
+
+		s.Require().NoError(err)
+		value := make([]byte, 16)
+		n, err := unix.Getxattr(path, "user.hoard", value)
+		s.Require().NoError(err)
+		s.Equal("value", string(value[:n]))
+	})
+}
+
+func (s *MetadataTestSuite) TestEqual() {
+	md := &metadata.Metadata{
+		Mode:   0644,
+		MTime:  time.Unix(1000, 0),
+		ATime:  time.Unix(2000, 0),
+		Xattrs: map[string][]byte{"user.hoard": []byte("value")},
+	}
+
+	s.Run("ignores access time", func() {
+		other := *md
+		other.ATime = time.Unix(3000, 0)
+
+		s.True(md.Equal(&other))
+	})
+	s.Run("compares mode", func() {
+		other := *md
+		other.Mode = 0600
+
+		s.False(md.Equal(&other))
+	})
+	s.Run("compares extended attributes", func() {
+		other := *md
+		other.Xattrs = map[string][]byte{"user.hoard": []byte("other")}
+
+		s.False(md.Equal(&other))
+	})
+	s.Run("handles missing metadata", func() {
+		s.False(md.Equal(nil))
+		s.True((*metadata.Metadata)(nil).Equal(nil))
+	})
+}
@@ -0,0 +1,303 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const defaultQueueDepth = 1024
+
+// ErrClosed is returned when work is submitted to a pool or queue that has been
+// closed.
+var ErrClosed = errors.New("worker pool closed")
+
+// Option is the type used to implement the functional options pattern for the
+// Pool type.
+type Option func(*Pool)
+
+// QueueOption is the type used to implement the functional options pattern for
+// the Queue type.
+type QueueOption func(*Queue)
+
+// Pool is a fixed set of workers that run tasks taken from any number of
+// queues. Each time a worker becomes free, it takes the oldest task from the
+// queue with the highest priority that is below its concurrency limit, taking
+// turns between queues of equal priority. Limits on the number of files open
+// and the number of bytes in flight across all queues are applied on top.
+type Pool struct {
+	maxOpenFiles     int
+	maxInFlightBytes int64
+	mu               sync.Mutex
+	cond             *sync.Cond
+	queues           []*Queue
+	cursor           int
+	openFiles        int
+	inFlightBytes    int64
+	closed           bool
+	wg               sync.WaitGroup
+}
+
+// Queue holds the tasks submitted by a single producer, such as the scan of a
+// directory, until a worker in its pool is free to run them.
+type Queue struct {
+	pool        *Pool
+	concurrency int
+	priority    int
+	depth       int
+	pending     []task
+	running     int
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+type task struct {
+	size int64
+	fn   func(worker int)
+}
+
+// New starts a pool with the provided number of workers.
+func New(numWorkers int, opts ...Option) *Pool {
+	p := &Pool{}
+	p.cond = sync.NewCond(&p.mu)
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	for i := 0; i < numWorkers; i++ {
+		p.wg.Add(1)
+		go p.work(i)
+	}
+
+	return p
+}
+
+// WithMaxOpenFiles returns an option that limits the number of tasks, each of
+// which processes a single file, running at once across all queues.
+func WithMaxOpenFiles(n int) Option {
+	return func(p *Pool) {
+		p.maxOpenFiles = n
+	}
+}
+
+// WithMaxInFlightBytes returns an option that limits the total size of the
+// files being processed at once across all queues. A file larger than the
+// limit is still processed, but only once nothing else is in flight.
+func WithMaxInFlightBytes(n int64) Option {
+	return func(p *Pool) {
+		p.maxInFlightBytes = n
+	}
+}
+
+// WithConcurrency returns an option that limits the number of tasks from a
+// queue that may run at once. A limit of zero leaves the queue bounded only by
+// the size of the pool.
+func WithConcurrency(n int) QueueOption {
+	return func(q *Queue) {
+		q.concurrency = n
+	}
+}
+
+// WithPriority returns an option that sets the priority of a queue. Free
+// workers always take tasks from the queue with the highest priority that is
+// able to run one.
+func WithPriority(priority int) QueueOption {
+	return func(q *Queue) {
+		q.priority = priority
+	}
+}
+
+// NewQueue registers a new queue with the pool.
+func (p *Pool) NewQueue(opts ...QueueOption) *Queue {
+	q := &Queue{pool: p, depth: defaultQueueDepth}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queues = append(p.queues, q)
+
+	return q
+}
+
+// Close removes the queue from its pool once every task submitted to it has
+// been run, so that a long-lived pool does not accumulate queues that are no
+// longer used. Tasks submitted after the queue is closed are rejected.
+func (q *Queue) Close() {
+	p := q.pool
+
+	p.mu.Lock()
+	q.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	q.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, other := range p.queues {
+		if other != q {
+			continue
+		}
+		p.queues = append(p.queues[:i], p.queues[i+1:]...)
+		if p.cursor > i {
+			p.cursor--
+		}
+		break
+	}
+}
+
+// Close stops the pool once every submitted task has been run.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Submit adds a task to process a file of the provided size to the queue. The
+// task is passed the ID of the worker running it. Submit blocks while the
+// queue is full, so that producers cannot run arbitrarily far ahead of the
+// workers, and returns early if the provided context is done.
+func (q *Queue) Submit(ctx context.Context, size int64, fn func(worker int)) error {
+	p := q.pool
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(q.pending) >= q.depth {
+		stop := p.wakeOnDone(ctx)
+		defer stop()
+
+		for len(q.pending) >= q.depth && ctx.Err() == nil && !p.closed && !q.closed {
+			p.cond.Wait()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.closed || q.closed {
+		return ErrClosed
+	}
+
+	q.pending = append(q.pending, task{size: size, fn: fn})
+	q.wg.Add(1)
+	p.cond.Broadcast()
+
+	return nil
+}
+
+// Wait blocks until every task submitted to the queue has been run.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+func (p *Pool) work(worker int) {
+	defer p.wg.Done()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		q := p.next()
+		if q == nil {
+			if p.closed && p.idle() {
+				return
+			}
+			p.cond.Wait()
+			continue
+		}
+
+		t := q.pending[0]
+		q.pending = q.pending[1:]
+		q.running++
+		p.openFiles++
+		p.inFlightBytes += t.size
+		p.cond.Broadcast()
+		p.mu.Unlock()
+
+		t.fn(worker)
+
+		p.mu.Lock()
+		q.running--
+		p.openFiles--
+		p.inFlightBytes -= t.size
+		q.wg.Done()
+		p.cond.Broadcast()
+	}
+}
+
+// next returns the queue whose oldest task should be run next, or nil if no
+// task can be run. The caller must hold the pool's lock.
+func (p *Pool) next() *Queue {
+	if p.maxOpenFiles > 0 && p.openFiles >= p.maxOpenFiles {
+		return nil
+	}
+
+	var best *Queue
+	bestIndex := 0
+	for i := range p.queues {
+		index := (p.cursor + i) % len(p.queues)
+		q := p.queues[index]
+		if !p.runnable(q) {
+			continue
+		}
+		if best == nil || q.priority > best.priority {
+			best, bestIndex = q, index
+		}
+	}
+	if best != nil {
+		p.cursor = bestIndex + 1
+	}
+
+	return best
+}
+
+func (p *Pool) runnable(q *Queue) bool {
+	if len(q.pending) == 0 {
+		return false
+	}
+	if q.concurrency > 0 && q.running >= q.concurrency {
+		return false
+	}
+
+	size := q.pending[0].size
+	return p.maxInFlightBytes <= 0 ||
+		p.inFlightBytes == 0 ||
+		p.inFlightBytes+size <= p.maxInFlightBytes
+}
+
+func (p *Pool) idle() bool {
+	for _, q := range p.queues {
+		if len(q.pending) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// wakeOnDone wakes every goroutine waiting on the pool once the provided
+// context is done, so that they can notice it. The returned function stops
+// watching the context without blocking, so it may be called with the pool's
+// lock held.
+func (p *Pool) wakeOnDone(ctx context.Context) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.cond.Broadcast()
+			p.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
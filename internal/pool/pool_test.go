@@ -0,0 +1,207 @@
+package pool_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/pool"
+)
+
+type PoolTestSuite struct {
+	suite.Suite
+}
+
+func TestPoolTestSuite(t *testing.T) {
+	suite.Run(t, new(PoolTestSuite))
+}
+
+// gauge records the largest number of tasks running at once.
+type gauge struct {
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (g *gauge) track(fn func()) {
+	g.mu.Lock()
+	g.current++
+	if g.current > g.max {
+		g.max = g.current
+	}
+	g.mu.Unlock()
+
+	fn()
+
+	g.mu.Lock()
+	g.current--
+	g.mu.Unlock()
+}
+
+func (s *PoolTestSuite) TestSubmit() {
+	ctx := context.Background()
+
+	s.Run("runs every task from every queue", func() {
+		p := pool.New(4)
+		defer p.Close()
+
+		first := p.NewQueue()
+		second := p.NewQueue()
+
+		mu := &sync.Mutex{}
+		var ran []int
+		for i := 0; i < 10; i++ {
+			q := first
+			if i%2 == 1 {
+				q = second
+			}
+			i := i
+			s.Require().NoError(q.Submit(ctx, 0, func(int) {
+				mu.Lock()
+				defer mu.Unlock()
+				ran = append(ran, i)
+			}))
+		}
+		first.Wait()
+		second.Wait()
+
+		s.ElementsMatch([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, ran)
+	})
+	s.Run("limits concurrency per queue", func() {
+		p := pool.New(4)
+		defer p.Close()
+
+		limited := p.NewQueue(pool.WithConcurrency(1))
+		g := &gauge{}
+		release := make(chan struct{})
+
+		for i := 0; i < 4; i++ {
+			s.Require().NoError(limited.Submit(ctx, 0, func(int) {
+				g.track(func() { <-release })
+			}))
+		}
+		close(release)
+		limited.Wait()
+
+		s.Equal(1, g.max)
+	})
+	s.Run("serves higher priority queues first", func() {
+		p := pool.New(1)
+		defer p.Close()
+
+		low := p.NewQueue(pool.WithPriority(0))
+		high := p.NewQueue(pool.WithPriority(1))
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.Require().NoError(low.Submit(ctx, 0, func(int) {
+			close(started)
+			<-release
+		}))
+		<-started
+
+		var order []string
+		s.Require().NoError(low.Submit(ctx, 0, func(int) { order = append(order, "low") }))
+		s.Require().NoError(high.Submit(ctx, 0, func(int) { order = append(order, "high") }))
+		close(release)
+		low.Wait()
+		high.Wait()
+
+		s.Equal([]string{"high", "low"}, order)
+	})
+	s.Run("limits open files across queues", func() {
+		p := pool.New(4, pool.WithMaxOpenFiles(2))
+		defer p.Close()
+
+		queues := []*pool.Queue{p.NewQueue(), p.NewQueue()}
+		g := &gauge{}
+		release := make(chan struct{})
+
+		for i := 0; i < 8; i++ {
+			s.Require().NoError(queues[i%2].Submit(ctx, 0, func(int) {
+				g.track(func() { <-release })
+			}))
+		}
+		close(release)
+		queues[0].Wait()
+		queues[1].Wait()
+
+		s.LessOrEqual(g.max, 2)
+	})
+	s.Run("limits bytes in flight", func() {
+		p := pool.New(4, pool.WithMaxInFlightBytes(100))
+		defer p.Close()
+
+		q := p.NewQueue()
+		g := &gauge{}
+		release := make(chan struct{})
+
+		for _, size := range []int64{60, 60, 200} {
+			s.Require().NoError(q.Submit(ctx, size, func(int) {
+				g.track(func() { <-release })
+			}))
+		}
+		close(release)
+		q.Wait()
+
+		s.Equal(1, g.max)
+	})
+	s.Run("returns error when context is done", func() {
+		p := pool.New(1)
+		defer p.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := p.NewQueue().Submit(ctx, 0, func(int) {})
+
+		s.ErrorIs(err, context.Canceled)
+	})
+	s.Run("returns error when closed", func() {
+		p := pool.New(1)
+		q := p.NewQueue()
+		p.Close()
+
+		err := q.Submit(ctx, 0, func(int) {})
+
+		s.ErrorIs(err, pool.ErrClosed)
+	})
+	s.Run("returns error when queue closed", func() {
+		p := pool.New(1)
+		defer p.Close()
+
+		q := p.NewQueue()
+		q.Close()
+
+		err := q.Submit(ctx, 0, func(int) {})
+
+		s.ErrorIs(err, pool.ErrClosed)
+	})
+}
+
+func (s *PoolTestSuite) TestClose() {
+	ctx := context.Background()
+
+	s.Run("runs pending tasks before removing queue", func() {
+		p := pool.New(1)
+		defer p.Close()
+
+		q := p.NewQueue()
+		other := p.NewQueue()
+
+		ran := 0
+		for i := 0; i < 4; i++ {
+			s.Require().NoError(q.Submit(ctx, 0, func(int) { ran++ }))
+		}
+		q.Close()
+
+		s.Equal(4, ran)
+
+		s.Require().NoError(other.Submit(ctx, 0, func(int) { ran++ }))
+		other.Wait()
+
+		s.Equal(5, ran)
+	})
+}
@@ -0,0 +1,53 @@
+package processor
+
+import "sync"
+
+// Activity counts the calls to Process that are under way and not blocked
+// waiting for another file to be processed, so that an uploader holding files
+// back can tell when every caller is waiting for it. It may be shared between
+// the processor and uploader of a single directory.
+type Activity struct {
+	mu       sync.Mutex
+	active   int
+	watchers []func(active int)
+}
+
+// NewActivity instantiates a new Activity with no calls under way.
+func NewActivity() *Activity {
+	return &Activity{}
+}
+
+// Active returns the number of calls under way that are not blocked.
+func (a *Activity) Active() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.active
+}
+
+// Watch registers a function called with the number of active calls each time
+// it falls.
+func (a *Activity) Watch(fn func(active int)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.watchers = append(a.watchers, fn)
+}
+
+func (a *Activity) add(n int) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.active += n
+	active := a.active
+	watchers := a.watchers
+	a.mu.Unlock()
+
+	if n < 0 {
+		for _, fn := range watchers {
+			fn(active)
+		}
+	}
+}
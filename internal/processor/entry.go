@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// processEntry registers a directory entry that is not a regular file. The
+// entry is skipped if it matches its previous version.
+func (p *Processor) processEntry(ctx context.Context, path string, info fs.FileInfo) (*File, error) {
+	file := &File{
+		Type:      fileTypeOf(info.Mode()),
+		Key:       p.entryKeyPrefix,
+		LocalPath: path,
+		Bucket:    p.entryBucket,
+		CTime:     ctimeOf(info),
+		MTime:     mtimeOf(info),
+	}
+
+	switch file.Type {
+	case FileTypeRegular:
+		return nil, fmt.Errorf("unsupported file type %q for path %q", info.Mode().Type(), path)
+	case FileTypeSymlink:
+		target, err := p.entries.ReadLink(path)
+		if err != nil {
+			return nil, err
+		}
+		file.LinkTarget = target
+	case FileTypeCharDevice, FileTypeBlockDevice:
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			file.DeviceMajor = unix.Major(uint64(stat.Rdev))
+			file.DeviceMinor = unix.Minor(uint64(stat.Rdev))
+		}
+	}
+
+	if p.mdGetter != nil {
+		md, err := p.mdGetter.GetMetadata(path)
+		if err != nil {
+			return nil, err
+		}
+		file.Metadata = md
+	}
+	p.log.Infow(
+		"Fetched attributes for entry",
+		"type", file.Type,
+		"mtime", file.MTime,
+		"path", path,
+	)
+
+	prevFile, err := p.registry.FetchLatest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if prevFile != nil && sameEntry(file, prevFile) {
+		return p.skip(ctx, prevFile), nil
+	}
+
+	file, err = p.registry.Create(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	p.log.Infow(
+		"Stored entry in file registry",
+		"path", file.LocalPath,
+		"type", file.Type,
+	)
+	p.reporter.FileUploaded(ctx, path, prevFile != nil)
+
+	return file, nil
+}
+
+// fileTypeOf returns the type of entry with the provided mode. Regular files
+// and types that cannot be recorded, such as sockets, are reported as
+// FileTypeRegular.
+func fileTypeOf(mode fs.FileMode) FileType {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return FileTypeSymlink
+	case mode.IsDir():
+		return FileTypeDirectory
+	case mode&fs.ModeNamedPipe != 0:
+		return FileTypeFIFO
+	case mode&fs.ModeCharDevice != 0:
+		return FileTypeCharDevice
+	case mode&fs.ModeDevice != 0:
+		return FileTypeBlockDevice
+	}
+	return FileTypeRegular
+}
+
+func sameEntry(file, prevFile *File) bool {
+	return file.Type == prevFile.Type &&
+		file.Key == prevFile.Key &&
+		file.LinkTarget == prevFile.LinkTarget &&
+		file.DeviceMajor == prevFile.DeviceMajor &&
+		file.DeviceMinor == prevFile.DeviceMinor &&
+		file.MTime.Equal(prevFile.MTime) &&
+		file.Metadata.Equal(prevFile.Metadata)
+}
@@ -0,0 +1,155 @@
+package processor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/metadata"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *ProcessorTestSuite) TestProcessIrregularFiles() {
+	bucket := "some-bucket"
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	root := s.T().TempDir()
+	s.Require().NoError(os.Mkdir(filepath.Join(root, "dir"), 0755))
+	s.Require().NoError(os.Symlink("some/target", filepath.Join(root, "link")))
+
+	fs := os.DirFS(root)
+	entries := metadata.NewReader(root)
+
+	linkInfo, err := os.Lstat(filepath.Join(root, "link"))
+	s.Require().NoError(err)
+	linkMTime := linkInfo.ModTime().UTC().Truncate(time.Microsecond)
+
+	s.Run("registers symbolic link with target", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "link").Return(nil, nil)
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				s.Equal(processor.FileTypeSymlink, file.Type)
+				s.Equal("link", file.LocalPath)
+				s.Equal("some/target", file.LinkTarget)
+				s.Equal(bucket, file.Bucket)
+				s.Equal(linkMTime, file.MTime)
+				s.Empty(file.Key)
+				return file, nil
+			})
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithIrregularFiles(entries, bucket),
+		)
+
+		file, err := processor.Process(ctx, "link")
+
+		s.Require().NoError(err)
+		s.Equal("some/target", file.LinkTarget)
+	})
+	s.Run("skips unchanged symbolic link", func() {
+		prevFile := &processor.File{
+			Type:       processor.FileTypeSymlink,
+			LocalPath:  "link",
+			LinkTarget: "some/target",
+			Bucket:     bucket,
+			MTime:      linkMTime,
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "link").Return(prevFile, nil)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithIrregularFiles(entries, bucket),
+		)
+
+		file, err := processor.Process(ctx, "link")
+
+		s.Require().NoError(err)
+		s.Equal(prevFile, file)
+	})
+	s.Run("registers changed symbolic link", func() {
+		prevFile := &processor.File{
+			Type:       processor.FileTypeSymlink,
+			LocalPath:  "link",
+			LinkTarget: "old/target",
+			Bucket:     bucket,
+			MTime:      linkMTime,
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "link").Return(prevFile, nil)
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				return file, nil
+			})
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithIrregularFiles(entries, bucket),
+		)
+
+		file, err := processor.Process(ctx, "link")
+
+		s.Require().NoError(err)
+		s.Equal("some/target", file.LinkTarget)
+	})
+	s.Run("records key prefix as key of entry", func() {
+		prevFile := &processor.File{
+			Type:       processor.FileTypeSymlink,
+			LocalPath:  "link",
+			LinkTarget: "some/target",
+			Bucket:     bucket,
+			MTime:      linkMTime,
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "link").Return(prevFile, nil)
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				return file, nil
+			})
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithIrregularFiles(entries, bucket),
+			processor.WithEntryKeyPrefix("some-prefix/"),
+		)
+
+		file, err := processor.Process(ctx, "link")
+
+		s.Require().NoError(err)
+		s.Equal("some-prefix/", file.Key)
+	})
+	s.Run("registers directory", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "dir").Return(nil, nil)
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				return file, nil
+			})
+
+		p := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithIrregularFiles(entries, bucket),
+		)
+
+		file, err := p.Process(ctx, "dir")
+
+		s.Require().NoError(err)
+		s.Equal(processor.FileTypeDirectory, file.Type)
+		s.Equal("dir", file.LocalPath)
+	})
+	s.Run("handles missing path", func() {
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithIrregularFiles(entries, bucket),
+		)
+
+		file, err := processor.Process(ctx, "missing")
+
+		s.ErrorIs(err, os.ErrNotExist)
+		s.Nil(file)
+	})
+}
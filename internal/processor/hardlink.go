@@ -0,0 +1,139 @@
+package processor
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"syscall"
+)
+
+// inode identifies a file independently of the paths linking to it. The
+// modification time and size are included so that a file rewritten since its
+// first link was processed, or a new file reusing the inode of a deleted one,
+// is not mistaken for the version already registered.
+type inode struct {
+	dev   uint64
+	ino   uint64
+	mtime int64
+	size  int64
+}
+
+// linkTracker records the files with several hard links found while
+// processing a directory, so that each is only uploaded once.
+type linkTracker struct {
+	mu    sync.Mutex
+	links map[inode]*hardLink
+}
+
+// hardLink holds the outcome of processing the first link found to a file.
+type hardLink struct {
+	done chan struct{}
+	file *File
+}
+
+func newLinkTracker() *linkTracker {
+	return &linkTracker{links: make(map[inode]*hardLink)}
+}
+
+// claim returns the hard link entry for the file with the provided info and
+// reports whether the caller found the first link to it, in which case it must
+// call finish once the file has been processed. Nil is returned for files with
+// a single link.
+func (t *linkTracker) claim(info fs.FileInfo) (*hardLink, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return nil, false
+	}
+	id := inode{
+		dev:   uint64(stat.Dev),
+		ino:   uint64(stat.Ino),
+		mtime: info.ModTime().UnixNano(),
+		size:  info.Size(),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if link, ok := t.links[id]; ok {
+		return link, false
+	}
+	link := &hardLink{done: make(chan struct{})}
+	t.links[id] = link
+
+	return link, true
+}
+
+// reset forgets every hard link found so far.
+func (t *linkTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.links = make(map[inode]*hardLink)
+}
+
+// finish records the registered version of the first link, which is nil if it
+// could not be processed.
+func (l *hardLink) finish(file *File) {
+	l.file = file
+	close(l.done)
+}
+
+// wait returns the registered version of the first link once it has been
+// processed.
+func (l *hardLink) wait(ctx context.Context) (*File, error) {
+	select {
+	case <-l.done:
+		return l.file, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// registerLink registers the provided file as a hard link to the provided
+// target, sharing its object rather than uploading the same contents again.
+//
+// Which link is processed first varies from run to run, so every link is
+// registered against the same representative: the target itself if it was
+// registered as the file's contents, or otherwise the path the target was
+// registered as a link to. The link is skipped if its previous version already
+// refers to the representative and the target's object, which includes the
+// representative finding that it was registered as such.
+func (p *Processor) registerLink(ctx context.Context, file, target *File) (*File, error) {
+	linkTarget := target.LocalPath
+	if target.LinkTarget != "" {
+		linkTarget = target.LinkTarget
+	}
+	if linkTarget == file.LocalPath {
+		linkTarget = ""
+	}
+
+	prevFile, err := p.registry.FetchLatest(ctx, file.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+	if prevFile != nil && prevFile.LinkTarget == linkTarget &&
+		prevFile.Key == target.Key && prevFile.Version == target.Version &&
+		prevFile.PackID == target.PackID && prevFile.Metadata.Equal(file.Metadata) {
+		return p.skip(ctx, prevFile), nil
+	}
+
+	p.log.Infow(
+		"Registering hard link to previously processed file",
+		"path", file.LocalPath,
+		"target", linkTarget,
+	)
+
+	linked := *target
+	linked.LocalPath = file.LocalPath
+	linked.LinkTarget = linkTarget
+	linked.Metadata = file.Metadata
+	linked.Hash = nil
+
+	registered, err := p.registry.Create(ctx, &linked)
+	if err != nil {
+		return nil, err
+	}
+	p.reporter.FileSkipped(ctx, file.LocalPath)
+
+	return registered, nil
+}
@@ -0,0 +1,248 @@
+package processor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *ProcessorTestSuite) TestProcessHardLinks() {
+	body := []byte{1, 2, 3}
+	ctime := time.Unix(123, 456).UTC()
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	root := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(root, "first"), body, 0644))
+	s.Require().NoError(os.Link(filepath.Join(root, "first"), filepath.Join(root, "second")))
+	s.Require().NoError(os.WriteFile(filepath.Join(root, "single"), body, 0644))
+
+	fs := os.DirFS(root)
+	keyGen := fakeKeyGenerator(func() string { return "key" })
+	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
+	returnFile := func(ctx context.Context, file *processor.File) (*processor.File, error) {
+		return file, nil
+	}
+	doUpload := func(ctx context.Context, file *processor.File) (*processor.File, error) {
+		file.Hash.Write(body)
+		file.Bucket = "some-bucket"
+		file.Version = "some-version"
+		return file, nil
+	}
+
+	s.Run("uploads first link and registers others as references", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "first").Return(nil, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "second").Return(nil, nil)
+		s.mockUploader.EXPECT().Upload(ctx, gomock.Any()).DoAndReturn(doUpload)
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile).Times(2)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+		)
+
+		first, err := processor.Process(ctx, "first")
+		s.Require().NoError(err)
+		second, err := processor.Process(ctx, "second")
+		s.Require().NoError(err)
+
+		s.Equal("second", second.LocalPath)
+		s.Equal("first", second.LinkTarget)
+		s.Equal(first.Key, second.Key)
+		s.Equal(first.Version, second.Version)
+		s.Equal(first.Checksum, second.Checksum)
+	})
+	s.Run("skips link already referring to object", func() {
+		prevFirst := &processor.File{
+			Key:       "key",
+			LocalPath: "first",
+			CTime:     ctime,
+			Version:   "some-version",
+		}
+		prevSecond := &processor.File{
+			Key:        "key",
+			LocalPath:  "second",
+			CTime:      ctime,
+			Version:    "some-version",
+			LinkTarget: "first",
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "first").Return(prevFirst, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "second").Return(prevSecond, nil)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithChangeDetection(processor.ChangeDetectionCTime),
+		)
+
+		_, err := processor.Process(ctx, "first")
+		s.Require().NoError(err)
+		second, err := processor.Process(ctx, "second")
+
+		s.Require().NoError(err)
+		s.Equal(prevSecond, second)
+	})
+	s.Run("skips links when processed in other order", func() {
+		prevFirst := &processor.File{
+			Key:       "key",
+			LocalPath: "first",
+			CTime:     ctime,
+			Version:   "some-version",
+		}
+		prevSecond := &processor.File{
+			Key:        "key",
+			LocalPath:  "second",
+			CTime:      ctime,
+			Version:    "some-version",
+			LinkTarget: "first",
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "first").Return(prevFirst, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "second").Return(prevSecond, nil)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithChangeDetection(processor.ChangeDetectionCTime),
+		)
+
+		second, err := processor.Process(ctx, "second")
+		s.Require().NoError(err)
+		first, err := processor.Process(ctx, "first")
+
+		s.Require().NoError(err)
+		s.Equal(prevSecond, second)
+		s.Equal(prevFirst, first)
+	})
+	s.Run("registers links against representative of first link", func() {
+		s.Require().NoError(os.Link(filepath.Join(root, "first"), filepath.Join(root, "third")))
+		defer os.Remove(filepath.Join(root, "third"))
+
+		prevSecond := &processor.File{
+			Key:        "key",
+			LocalPath:  "second",
+			CTime:      ctime,
+			Version:    "some-version",
+			LinkTarget: "first",
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "second").Return(prevSecond, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "third").Return(nil, nil)
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithChangeDetection(processor.ChangeDetectionCTime),
+		)
+
+		_, err := processor.Process(ctx, "second")
+		s.Require().NoError(err)
+		third, err := processor.Process(ctx, "third")
+
+		s.Require().NoError(err)
+		s.Equal("first", third.LinkTarget)
+	})
+	s.Run("uploads first link found after reset", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "first").Return(nil, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "second").Return(nil, nil)
+		s.mockUploader.EXPECT().Upload(ctx, gomock.Any()).DoAndReturn(doUpload).Times(2)
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile).Times(2)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+		)
+
+		_, err := processor.Process(ctx, "first")
+		s.Require().NoError(err)
+		processor.Reset()
+		second, err := processor.Process(ctx, "second")
+
+		s.Require().NoError(err)
+		s.Empty(second.LinkTarget)
+	})
+	s.Run("uploads link to file rewritten since first link", func() {
+		s.Require().NoError(os.WriteFile(filepath.Join(root, "old"), body, 0644))
+		s.Require().NoError(os.Link(filepath.Join(root, "old"), filepath.Join(root, "new")))
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "old").Return(nil, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "new").Return(nil, nil)
+		s.mockUploader.EXPECT().Upload(ctx, gomock.Any()).DoAndReturn(doUpload).Times(2)
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile).Times(2)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+		)
+
+		_, err := processor.Process(ctx, "old")
+		s.Require().NoError(err)
+		s.Require().NoError(os.WriteFile(filepath.Join(root, "new"), append(body, 4), 0644))
+		second, err := processor.Process(ctx, "new")
+
+		s.Require().NoError(err)
+		s.Empty(second.LinkTarget)
+	})
+	s.Run("does not count calls waiting for first link as active", func() {
+		activity := processor.NewActivity()
+		release := make(chan struct{})
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "first").Return(nil, nil)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "second").Return(nil, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				<-release
+				return doUpload(ctx, file)
+			})
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile).Times(2)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithActivity(activity),
+		)
+
+		errs := make(chan error, 2)
+		process := func(path string) {
+			_, err := processor.Process(ctx, path)
+			errs <- err
+		}
+		go process("first")
+		s.Eventually(func() bool { return activity.Active() == 1 }, time.Second, time.Millisecond)
+		go process("second")
+		time.Sleep(10 * time.Millisecond)
+		s.Equal(1, activity.Active())
+		close(release)
+
+		s.Require().NoError(<-errs)
+		s.Require().NoError(<-errs)
+		s.Equal(0, activity.Active())
+	})
+	s.Run("uploads file with single link", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, "single").Return(nil, nil)
+		s.mockUploader.EXPECT().Upload(ctx, gomock.Any()).DoAndReturn(doUpload)
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+		)
+
+		file, err := processor.Process(ctx, "single")
+
+		s.Require().NoError(err)
+		s.Empty(file.LinkTarget)
+	})
+}
@@ -6,9 +6,12 @@ package mocks
 
 import (
 	context "context"
+	fs "io/fs"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	metadata "github.com/mspraggs/hoard/internal/metadata"
 	processor "github.com/mspraggs/hoard/internal/processor"
 )
 
@@ -36,17 +39,160 @@ func (m *MockKeyGenerator) EXPECT() *MockKeyGeneratorMockRecorder {
 }
 
 // GenerateKey mocks base method.
-func (m *MockKeyGenerator) GenerateKey() string {
+func (m *MockKeyGenerator) GenerateKey(path string) string {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenerateKey")
+	ret := m.ctrl.Call(m, "GenerateKey", path)
 	ret0, _ := ret[0].(string)
 	return ret0
 }
 
 // GenerateKey indicates an expected call of GenerateKey.
-func (mr *MockKeyGeneratorMockRecorder) GenerateKey() *gomock.Call {
+func (mr *MockKeyGeneratorMockRecorder) GenerateKey(path interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateKey", reflect.TypeOf((*MockKeyGenerator)(nil).GenerateKey))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateKey", reflect.TypeOf((*MockKeyGenerator)(nil).GenerateKey), path)
+}
+
+// Layout mocks base method.
+func (m *MockKeyGenerator) Layout() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Layout")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Layout indicates an expected call of Layout.
+func (mr *MockKeyGeneratorMockRecorder) Layout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Layout", reflect.TypeOf((*MockKeyGenerator)(nil).Layout))
+}
+
+// MockCTimeGetter is a mock of CTimeGetter interface.
+type MockCTimeGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockCTimeGetterMockRecorder
+}
+
+// MockCTimeGetterMockRecorder is the mock recorder for MockCTimeGetter.
+type MockCTimeGetterMockRecorder struct {
+	mock *MockCTimeGetter
+}
+
+// NewMockCTimeGetter creates a new mock instance.
+func NewMockCTimeGetter(ctrl *gomock.Controller) *MockCTimeGetter {
+	mock := &MockCTimeGetter{ctrl: ctrl}
+	mock.recorder = &MockCTimeGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCTimeGetter) EXPECT() *MockCTimeGetterMockRecorder {
+	return m.recorder
+}
+
+// GetCTime mocks base method.
+func (m *MockCTimeGetter) GetCTime(fi fs.File) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCTime", fi)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCTime indicates an expected call of GetCTime.
+func (mr *MockCTimeGetterMockRecorder) GetCTime(fi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCTime", reflect.TypeOf((*MockCTimeGetter)(nil).GetCTime), fi)
+}
+
+// MockMetadataGetter is a mock of MetadataGetter interface.
+type MockMetadataGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetadataGetterMockRecorder
+}
+
+// MockMetadataGetterMockRecorder is the mock recorder for MockMetadataGetter.
+type MockMetadataGetterMockRecorder struct {
+	mock *MockMetadataGetter
+}
+
+// NewMockMetadataGetter creates a new mock instance.
+func NewMockMetadataGetter(ctrl *gomock.Controller) *MockMetadataGetter {
+	mock := &MockMetadataGetter{ctrl: ctrl}
+	mock.recorder = &MockMetadataGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetadataGetter) EXPECT() *MockMetadataGetterMockRecorder {
+	return m.recorder
+}
+
+// GetMetadata mocks base method.
+func (m *MockMetadataGetter) GetMetadata(path string) (*metadata.Metadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetadata", path)
+	ret0, _ := ret[0].(*metadata.Metadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMetadata indicates an expected call of GetMetadata.
+func (mr *MockMetadataGetterMockRecorder) GetMetadata(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadata", reflect.TypeOf((*MockMetadataGetter)(nil).GetMetadata), path)
+}
+
+// MockEntryReader is a mock of EntryReader interface.
+type MockEntryReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockEntryReaderMockRecorder
+}
+
+// MockEntryReaderMockRecorder is the mock recorder for MockEntryReader.
+type MockEntryReaderMockRecorder struct {
+	mock *MockEntryReader
+}
+
+// NewMockEntryReader creates a new mock instance.
+func NewMockEntryReader(ctrl *gomock.Controller) *MockEntryReader {
+	mock := &MockEntryReader{ctrl: ctrl}
+	mock.recorder = &MockEntryReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEntryReader) EXPECT() *MockEntryReaderMockRecorder {
+	return m.recorder
+}
+
+// ReadLink mocks base method.
+func (m *MockEntryReader) ReadLink(path string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadLink", path)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadLink indicates an expected call of ReadLink.
+func (mr *MockEntryReaderMockRecorder) ReadLink(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLink", reflect.TypeOf((*MockEntryReader)(nil).ReadLink), path)
+}
+
+// Stat mocks base method.
+func (m *MockEntryReader) Stat(path string) (fs.FileInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stat", path)
+	ret0, _ := ret[0].(fs.FileInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stat indicates an expected call of Stat.
+func (mr *MockEntryReaderMockRecorder) Stat(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stat", reflect.TypeOf((*MockEntryReader)(nil).Stat), path)
 }
 
 // MockRegistry is a mock of Registry interface.
@@ -102,6 +248,21 @@ func (mr *MockRegistryMockRecorder) FetchLatest(ctx, path interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatest", reflect.TypeOf((*MockRegistry)(nil).FetchLatest), ctx, path)
 }
 
+// FetchLatestBySize mocks base method.
+func (m *MockRegistry) FetchLatestBySize(ctx context.Context, bucket string, size int64) ([]*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLatestBySize", ctx, bucket, size)
+	ret0, _ := ret[0].([]*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLatestBySize indicates an expected call of FetchLatestBySize.
+func (mr *MockRegistryMockRecorder) FetchLatestBySize(ctx, bucket, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLatestBySize", reflect.TypeOf((*MockRegistry)(nil).FetchLatestBySize), ctx, bucket, size)
+}
+
 // MockUploader is a mock of Uploader interface.
 type MockUploader struct {
 	ctrl     *gomock.Controller
@@ -125,6 +286,35 @@ func (m *MockUploader) EXPECT() *MockUploaderMockRecorder {
 	return m.recorder
 }
 
+// Copy mocks base method.
+func (m *MockUploader) Copy(ctx context.Context, src, dst *processor.File) (*processor.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Copy", ctx, src, dst)
+	ret0, _ := ret[0].(*processor.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Copy indicates an expected call of Copy.
+func (mr *MockUploaderMockRecorder) Copy(ctx, src, dst interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Copy", reflect.TypeOf((*MockUploader)(nil).Copy), ctx, src, dst)
+}
+
+// Delete mocks base method.
+func (m *MockUploader) Delete(ctx context.Context, file *processor.File) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUploaderMockRecorder) Delete(ctx, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUploader)(nil).Delete), ctx, file)
+}
+
 // Upload mocks base method.
 func (m *MockUploader) Upload(ctx context.Context, file *processor.File) (*processor.File, error) {
 	m.ctrl.T.Helper()
@@ -139,3 +329,74 @@ func (mr *MockUploaderMockRecorder) Upload(ctx, file interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockUploader)(nil).Upload), ctx, file)
 }
+
+// MockReporter is a mock of Reporter interface.
+type MockReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockReporterMockRecorder
+}
+
+// MockReporterMockRecorder is the mock recorder for MockReporter.
+type MockReporterMockRecorder struct {
+	mock *MockReporter
+}
+
+// NewMockReporter creates a new mock instance.
+func NewMockReporter(ctrl *gomock.Controller) *MockReporter {
+	mock := &MockReporter{ctrl: ctrl}
+	mock.recorder = &MockReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReporter) EXPECT() *MockReporterMockRecorder {
+	return m.recorder
+}
+
+// BytesHashed mocks base method.
+func (m *MockReporter) BytesHashed(ctx context.Context, n int64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "BytesHashed", ctx, n)
+}
+
+// BytesHashed indicates an expected call of BytesHashed.
+func (mr *MockReporterMockRecorder) BytesHashed(ctx, n interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BytesHashed", reflect.TypeOf((*MockReporter)(nil).BytesHashed), ctx, n)
+}
+
+// FileBusy mocks base method.
+func (m *MockReporter) FileBusy(ctx context.Context, path string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileBusy", ctx, path)
+}
+
+// FileBusy indicates an expected call of FileBusy.
+func (mr *MockReporterMockRecorder) FileBusy(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileBusy", reflect.TypeOf((*MockReporter)(nil).FileBusy), ctx, path)
+}
+
+// FileSkipped mocks base method.
+func (m *MockReporter) FileSkipped(ctx context.Context, path string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileSkipped", ctx, path)
+}
+
+// FileSkipped indicates an expected call of FileSkipped.
+func (mr *MockReporterMockRecorder) FileSkipped(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileSkipped", reflect.TypeOf((*MockReporter)(nil).FileSkipped), ctx, path)
+}
+
+// FileUploaded mocks base method.
+func (m *MockReporter) FileUploaded(ctx context.Context, path string, changed bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileUploaded", ctx, path, changed)
+}
+
+// FileUploaded indicates an expected call of FileUploaded.
+func (mr *MockReporterMockRecorder) FileUploaded(ctx, path, changed interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileUploaded", reflect.TypeOf((*MockReporter)(nil).FileUploaded), ctx, path, changed)
+}
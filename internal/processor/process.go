@@ -1,21 +1,97 @@
 package processor
 
 import (
+	"bytes"
 	"context"
-	"hash/crc32"
+	"fmt"
+	"hash"
+	"hash/fnv"
 	"io"
-	"time"
+	"io/fs"
+
+	"github.com/google/uuid"
+
+	"github.com/mspraggs/hoard/internal/checksum"
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+)
+
+const versionKeySeparator = "~"
+
+type change int
+
+const (
+	changeNone change = iota
+	changeUnknown
+	changeDetected
 )
 
 // Process creates a file from the provided path and uploads it to the store.
+// Whether a previously uploaded file has changed is decided according to the
+// processor's change detection policy. The checksum of a new file is computed
+// as it is uploaded. Where the policy requires the checksum of a file to be
+// compared with that of its previous version, it is computed before the file
+// is uploaded, unless single reads are enabled, in which case it is computed
+// during upload and the uploaded version is deleted if the file turns out to
+// be unchanged. Files that change while they are being uploaded are uploaded
+// again and reported as busy if they do not settle. If rename detection is
+// enabled, a new file may instead be registered as a rename of a file that no
+// longer exists. If irregular files are enabled, entries other than regular
+// files are registered without being uploaded. A file with several hard links
+// is only uploaded for the first link found, and its other links are
+// registered as references to the same object. Calls are counted by the
+// processor's Activity, if it has one, except while they wait for the first
+// link to a file to be processed.
 func (p *Processor) Process(ctx context.Context, path string) (*File, error) {
-	ctime, err := p.getCTime(path)
+	p.activity.add(1)
+	defer p.activity.add(-1)
+
+	if p.entries != nil {
+		info, err := p.entries.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return p.processEntry(ctx, path, info)
+		}
+	}
+
+	file, info, err := p.stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	link, first := p.links.claim(info)
+	if link == nil {
+		return p.processFile(ctx, file)
+	}
+	if first {
+		file, err := p.processFile(ctx, file)
+		link.finish(file)
+		return file, err
+	}
+
+	p.activity.add(-1)
+	target, err := link.wait(ctx)
+	p.activity.add(1)
 	if err != nil {
 		return nil, err
 	}
+	if target == nil {
+		return p.processFile(ctx, file)
+	}
+	return p.registerLink(ctx, file, target)
+}
+
+// processFile uploads and registers the provided regular file if it has changed
+// since its previous version was registered.
+func (p *Processor) processFile(ctx context.Context, file *File) (*File, error) {
+	path := file.LocalPath
+	file.ChecksumAlgorithm = string(p.csAlg)
 	p.log.Infow(
-		"Fetched ctime for path",
-		"ctime", ctime,
+		"Fetched attributes for path",
+		"ctime", file.CTime,
+		"mtime", file.MTime,
+		"size", file.Size,
 		"path", path,
 	)
 
@@ -23,103 +99,465 @@ func (p *Processor) Process(ctx context.Context, path string) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
+	if prevFile != nil && prevFile.Type != FileTypeRegular {
+		p.log.Infow(
+			"Previous version of path was not a regular file",
+			"path", path,
+			"type", prevFile.Type,
+		)
+		prevFile = nil
+	}
 
-	file := &File{
-		Key:       p.keyGen.GenerateKey(),
-		LocalPath: path,
-		CTime:     ctime,
+	if prevFile == nil && p.detectRenames {
+		renamed, err := p.detectRename(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		if renamed != nil {
+			return renamed, nil
+		}
 	}
 
+	compareAfterUpload := false
 	if prevFile != nil {
 		p.log.Infow(
 			"Found previous file version",
 			"key", prevFile.Key,
 			"checksum", prevFile.Checksum,
 			"ctime", prevFile.CTime,
+			"mtime", prevFile.MTime,
+			"size", prevFile.Size,
 		)
-		if prevFile.CTime.Equal(file.CTime) {
-			p.log.Infow(
-				"Skipping previously uploaded file",
-				"path", prevFile.LocalPath,
-				"version", prevFile.Version,
-			)
-			return prevFile, nil
+
+		switch p.detectChange(file, prevFile) {
+		case changeNone:
+			return p.unchanged(ctx, file, prevFile)
+		case changeUnknown:
+			if p.singleRead && prevFile.ChecksumAlgorithm == file.ChecksumAlgorithm {
+				compareAfterUpload = true
+				break
+			}
+			unchanged, err := p.attachChecksum(ctx, file, prevFile)
+			if err != nil {
+				return nil, err
+			}
+			if unchanged {
+				return p.unchanged(ctx, file, prevFile)
+			}
+			if p.corrupt(file, prevFile) {
+				return nil, p.refuseCorrupt(file, prevFile)
+			}
 		}
+	}
+	p.attachKey(file, prevFile)
 
-		if err := p.attachChecksum(file); err != nil {
-			return nil, err
+	file, h, err := p.upload(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Inconsistent {
+		p.reporter.FileBusy(ctx, path)
+		if p.inconsistent == InconsistentPolicySkip {
+			return p.skipInconsistent(ctx, file, prevFile)
 		}
+	}
+
+	if h != nil {
+		file.Checksum = h.Sum(nil)
+		file.Hash = nil
+		p.reporter.BytesHashed(ctx, h.n)
+		p.log.Infow(
+			"Computed checksum for path during upload",
+			"path", file.LocalPath,
+			"checksum", file.Checksum,
+		)
 
-		if prevFile.Checksum == file.Checksum {
-			p.log.Infow(
-				"Skipping previously uploaded file",
-				"path", prevFile.LocalPath,
-				"version", prevFile.Version,
-			)
-			return prevFile, nil
+		if compareAfterUpload && !file.Inconsistent {
+			if bytes.Equal(prevFile.Checksum, file.Checksum) {
+				return p.discardUpload(ctx, file, prevFile)
+			}
+			if p.corrupt(file, prevFile) {
+				p.deleteVersion(ctx, file)
+				return nil, p.refuseCorrupt(file, prevFile)
+			}
 		}
-		file.Key = prevFile.Key
-	} else if err := p.attachChecksum(file); err != nil {
-		return nil, err
 	}
 
-	file, err = p.uploader.Upload(ctx, file)
+	file, err = p.registry.Create(ctx, file)
 	if err != nil {
 		return nil, err
 	}
 	p.log.Infow(
-		"Stored file in storage backend",
+		"Stored file in file registry",
+		"path", file.LocalPath,
+	)
+	p.reporter.FileUploaded(ctx, path, prevFile != nil)
+
+	return file, nil
+}
+
+// upload uploads the file and then checks whether its size, ctime or mtime
+// changed while it was being read. A file that changed is uploaded again, up to
+// the maximum number of attempts, after which the last uploaded version is
+// marked as inconsistent. Superseded versions are deleted where possible. The
+// hash used to compute the checksum during upload is returned, if any.
+func (p *Processor) upload(ctx context.Context, file *File) (*File, *countingHash, error) {
+	var h *countingHash
+	for attempt := 1; ; attempt++ {
+		if file.Checksum == nil && h == nil {
+			var err error
+			if h, err = newCountingHash(p.csAlg); err != nil {
+				return nil, nil, err
+			}
+			file.Hash = h
+		} else if h != nil {
+			// Each attempt reads the file afresh, so the hash must only hold
+			// the contents read during the last of them.
+			h.Reset()
+		}
+
+		uploaded, err := p.uploader.Upload(ctx, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.log.Infow(
+			"Stored file in storage backend",
+			"path", uploaded.LocalPath,
+			"etag", uploaded.ETag,
+			"version", uploaded.Version,
+		)
+
+		current, err := p.statFile(file.LocalPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if current.Size == file.Size && current.CTime.Equal(file.CTime) &&
+			current.MTime.Equal(file.MTime) {
+			return uploaded, h, nil
+		}
+
+		p.log.Warnw(
+			"File changed while it was being uploaded",
+			"path", file.LocalPath,
+			"attempt", attempt,
+		)
+		if attempt >= p.maxAttempts {
+			uploaded.Inconsistent = true
+			return uploaded, h, nil
+		}
+		p.deleteVersion(ctx, uploaded)
+
+		file.CTime = current.CTime
+		file.MTime = current.MTime
+		file.Size = current.Size
+		// A checksum computed before the upload no longer reflects the
+		// contents of the file, so compute it during the next upload instead.
+		file.Checksum = nil
+	}
+}
+
+// skipInconsistent discards the last uploaded version of a file that kept
+// changing while it was being uploaded, leaving the previous version as the
+// latest registered version. A file with no previous version has nothing left
+// to back it up, so an error wrapping ErrInconsistentFile is returned for it
+// instead, so that it is reported as failed.
+func (p *Processor) skipInconsistent(ctx context.Context, file, prevFile *File) (*File, error) {
+	p.deleteVersion(ctx, file)
+	p.log.Warnw(
+		"Skipping file that changed during every upload attempt",
+		"path", file.LocalPath,
+	)
+	if prevFile == nil {
+		return nil, fmt.Errorf("%w: %s", hoarderrors.ErrInconsistentFile, file.LocalPath)
+	}
+	p.reporter.FileSkipped(ctx, file.LocalPath)
+
+	return prevFile, nil
+}
+
+func (p *Processor) deleteVersion(ctx context.Context, file *File) {
+	if err := p.uploader.Delete(ctx, file); err != nil {
+		p.log.Warnw(
+			"Unable to delete superseded file version",
+			"path", file.LocalPath,
+			"version", file.Version,
+			"error", err,
+		)
+	}
+}
+
+func (p *Processor) skip(ctx context.Context, prevFile *File) *File {
+	p.log.Infow(
+		"Skipping previously uploaded file",
+		"path", prevFile.LocalPath,
+		"version", prevFile.Version,
+	)
+	p.reporter.FileSkipped(ctx, prevFile.LocalPath)
+
+	return prevFile
+}
+
+// discardUpload deletes the newly uploaded version of a file whose contents
+// turned out to match the previous version. If the new version cannot be
+// deleted, for example because it is locked, it is registered as normal.
+func (p *Processor) discardUpload(ctx context.Context, file, prevFile *File) (*File, error) {
+	if err := p.uploader.Delete(ctx, file); err != nil {
+		p.log.Warnw(
+			"Unable to delete redundant file version, registering it instead",
+			"path", file.LocalPath,
+			"version", file.Version,
+			"error", err,
+		)
+		file, err = p.registry.Create(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		p.reporter.FileUploaded(ctx, file.LocalPath, true)
+		return file, nil
+	}
+
+	p.log.Infow(
+		"Deleted redundant upload of unchanged file",
 		"path", file.LocalPath,
-		"etag", file.ETag,
 		"version", file.Version,
 	)
 
-	file, err = p.registry.Create(ctx, file)
-	if err != nil {
-		return nil, err
+	return p.unchanged(ctx, file, prevFile)
+}
+
+// corrupt reports whether a file whose checksum changed since its previous
+// version was registered kept the same ctime, which suggests that its contents
+// were corrupted on disk rather than modified. Only the periodic checksum
+// comparisons made to detect bit rot are checked, since the other policies
+// that compare checksums regardless of ctime are used where ctime cannot be
+// relied upon.
+func (p *Processor) corrupt(file, prevFile *File) bool {
+	return p.detection == ChangeDetectionChecksumEveryNRuns &&
+		!file.CTime.IsZero() && prevFile.CTime.Equal(file.CTime)
+}
+
+// refuseCorrupt returns an error for a file that appears to be corrupt, so
+// that it is reported as failed and the previous version, which is likely to
+// hold its intended contents, remains the latest registered version.
+func (p *Processor) refuseCorrupt(file, prevFile *File) error {
+	p.log.Warnw(
+		"Checksum changed without a change in ctime, file may be corrupt",
+		"path", file.LocalPath,
+		"version", prevFile.Version,
+	)
+	return fmt.Errorf("%w: %s", hoarderrors.ErrCorruptFile, file.LocalPath)
+}
+
+// unchanged skips a file whose contents have not changed since the previous
+// version was uploaded. If its metadata has changed, a new version is
+// registered that refers to the previously uploaded object.
+func (p *Processor) unchanged(ctx context.Context, file, prevFile *File) (*File, error) {
+	if p.mdGetter == nil || file.Metadata.Equal(prevFile.Metadata) {
+		return p.skip(ctx, prevFile), nil
 	}
+
 	p.log.Infow(
-		"Stored file in file registry",
+		"Registering metadata change of unchanged file",
 		"path", file.LocalPath,
+		"version", prevFile.Version,
 	)
 
-	return file, nil
+	updated := *prevFile
+	updated.CTime = file.CTime
+	updated.MTime = file.MTime
+	updated.Size = file.Size
+	updated.Metadata = file.Metadata
+	updated.Inconsistent = false
+
+	registered, err := p.registry.Create(ctx, &updated)
+	if err != nil {
+		return nil, err
+	}
+	p.reporter.FileSkipped(ctx, file.LocalPath)
+
+	return registered, nil
 }
 
-func (p *Processor) getCTime(path string) (time.Time, error) {
+// detectChange decides whether the file has changed since the previous version
+// was uploaded, or whether its checksum must be compared to decide.
+func (p *Processor) detectChange(file, prevFile *File) change {
+	switch p.detection {
+	case ChangeDetectionCTime:
+		if prevFile.CTime.Equal(file.CTime) {
+			return changeNone
+		}
+		return changeDetected
+	case ChangeDetectionMTimeSize:
+		if prevFile.MTime.Equal(file.MTime) && prevFile.Size == file.Size {
+			return changeNone
+		}
+		return changeDetected
+	case ChangeDetectionAlwaysChecksum:
+		return changeUnknown
+	case ChangeDetectionChecksumEveryNRuns:
+		if p.checksumDue(file.LocalPath) {
+			return changeUnknown
+		}
+	}
+
+	if prevFile.CTime.Equal(file.CTime) {
+		return changeNone
+	}
+	return changeUnknown
+}
+
+// checksumDue reports whether the checksum of the file with the provided path
+// is due to be compared in the current run.
+func (p *Processor) checksumDue(path string) bool {
+	if p.runsPerSum <= 0 {
+		return false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(path))
+
+	return (uint64(p.run)+h.Sum64())%uint64(p.runsPerSum) == 0
+}
+
+func (p *Processor) statFile(path string) (*File, error) {
+	file, _, err := p.stat(path)
+	return file, err
+}
+
+func (p *Processor) stat(path string) (*File, fs.FileInfo, error) {
 	f, err := p.fs.Open(path)
 	if err != nil {
-		return time.Time{}, err
+		return nil, nil, err
 	}
 	defer f.Close()
 
-	return p.ctg.GetCTime(f)
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctime, err := p.ctg.GetCTime(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := &File{
+		LocalPath: path,
+		CTime:     ctime,
+		MTime:     mtimeOf(info),
+		Size:      info.Size(),
+	}
+	if p.mdGetter != nil {
+		if file.Metadata, err = p.mdGetter.GetMetadata(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return file, info, nil
 }
 
-func (p *Processor) attachChecksum(file *File) error {
-	checksum, err := p.computeChecksum(file.LocalPath)
+// attachKey reuses the key of the previous version of a file, so that new
+// versions of a file accumulate under the same key, unless there is no previous
+// version or the previous version was stored in a pack or shared with another
+// hard link. If unique keys are enabled, a version suffix is added to every new
+// key instead.
+func (p *Processor) attachKey(file, prevFile *File) {
+	if prevFile != nil && prevFile.PackID == "" && prevFile.LinkTarget == "" && !p.uniqueKeys {
+		file.Key = prevFile.Key
+		file.KeyLayout = prevFile.KeyLayout
+		return
+	}
+
+	file.Key = p.keyGen.GenerateKey(file.LocalPath)
+	file.KeyLayout = p.keyGen.Layout()
+	if p.uniqueKeys {
+		file.Key += versionKeySeparator + uuid.NewString()
+	}
+}
+
+// attachChecksum computes the checksum of the file and reports whether it
+// matches the checksum of the previous version. If the previous version's
+// checksum was computed using a different algorithm, both checksums are
+// computed from a single read of the file.
+func (p *Processor) attachChecksum(ctx context.Context, file, prevFile *File) (bool, error) {
+	algs := []checksum.Algorithm{p.csAlg}
+	if prevFile.ChecksumAlgorithm != file.ChecksumAlgorithm {
+		algs = append(algs, checksum.Algorithm(prevFile.ChecksumAlgorithm))
+	}
+
+	checksums, err := p.computeChecksums(ctx, file.LocalPath, algs...)
 	if err != nil {
-		return err
+		return false, err
 	}
 	p.log.Infow(
 		"Computed checksum for path",
 		"path", file.LocalPath,
-		"checksum", checksum,
+		"checksum", checksums[0],
 	)
-	file.Checksum = checksum
+	file.Checksum = checksums[0]
 
-	return nil
+	return bytes.Equal(prevFile.Checksum, checksums[len(checksums)-1]), nil
 }
 
-func (p *Processor) computeChecksum(path string) (Checksum, error) {
+func (p *Processor) computeChecksums(
+	ctx context.Context,
+	path string,
+	algs ...checksum.Algorithm,
+) ([]Checksum, error) {
+
+	hashes := make([]hash.Hash, len(algs))
+	writers := make([]io.Writer, len(algs))
+	for i, alg := range algs {
+		h, err := alg.New()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = h
+		writers[i] = h
+	}
+
 	f, err := p.fs.Open(path)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer f.Close()
 
-	h := crc32.NewIEEE()
-	io.Copy(h, f)
+	n, err := io.Copy(io.MultiWriter(writers...), f)
+	if err != nil {
+		return nil, err
+	}
+	p.reporter.BytesHashed(ctx, n)
+
+	checksums := make([]Checksum, len(hashes))
+	for i, h := range hashes {
+		checksums[i] = h.Sum(nil)
+	}
+
+	return checksums, nil
+}
+
+// countingHash wraps a hash to count the number of bytes written to it.
+type countingHash struct {
+	hash.Hash
+	n int64
+}
+
+func newCountingHash(alg checksum.Algorithm) (*countingHash, error) {
+	h, err := alg.New()
+	if err != nil {
+		return nil, err
+	}
+	return &countingHash{Hash: h}, nil
+}
+
+func (h *countingHash) Write(bs []byte) (int, error) {
+	n, err := h.Hash.Write(bs)
+	h.n += int64(n)
+	return n, err
+}
 
-	return Checksum(h.Sum32()), nil
+func (h *countingHash) Reset() {
+	h.Hash.Reset()
+	h.n = 0
 }
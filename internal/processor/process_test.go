@@ -2,22 +2,36 @@ package processor_test
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/psanford/memfs"
 
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+	"github.com/mspraggs/hoard/internal/metadata"
 	"github.com/mspraggs/hoard/internal/processor"
 )
 
+const (
+	fakeKeyLayout  = "fake"
+	crc32Algorithm = "CRC32"
+)
+
 type fakeKeyGenerator func() string
 
-func (fn fakeKeyGenerator) GenerateKey() string {
+func (fn fakeKeyGenerator) GenerateKey(path string) string {
 	return fn()
 }
 
+func (fn fakeKeyGenerator) Layout() string {
+	return fakeKeyLayout
+}
+
 type fakeCTimeGetter func() (time.Time, error)
 
 func (fn fakeCTimeGetter) GetCTime(fi fs.File) (time.Time, error) {
@@ -30,35 +44,50 @@ func (s *ProcessorTestSuite) TestProcess() {
 	ctime := time.Unix(123, 456).UTC()
 	version := "123"
 	path := "path/to/file"
-	checksum := processor.Checksum(1438416925)
+	checksum := processor.Checksum{0x55, 0xbc, 0x80, 0x1d}
 	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
 
 	fs := memfs.New()
 	fs.MkdirAll("path/to", os.FileMode(0))
 	fs.WriteFile(path, body, os.FileMode(0))
+	mtime := s.modTime(fs, path)
+	size := int64(len(body))
 
 	keyGen := fakeKeyGenerator(func() string { return key })
 	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
 
 	prevFile := &processor.File{
 		Key:       key,
+		KeyLayout: fakeKeyLayout,
 		LocalPath: path,
-		Checksum:  7,
+		Checksum:  processor.Checksum{0, 0, 0, 7},
 		CTime:     time.Unix(12, 345).UTC(),
 		Version:   "456",
+
+		ChecksumAlgorithm: crc32Algorithm,
 	}
 	currentFile := &processor.File{
 		Key:       key,
+		KeyLayout: fakeKeyLayout,
 		LocalPath: path,
 		Checksum:  checksum,
 		CTime:     ctime,
+		MTime:     mtime,
+		Size:      size,
+
+		ChecksumAlgorithm: crc32Algorithm,
 	}
 	uploadedFile := &processor.File{
 		Key:       key,
+		KeyLayout: fakeKeyLayout,
 		LocalPath: path,
 		Checksum:  checksum,
 		CTime:     ctime,
+		MTime:     mtime,
+		Size:      size,
 		Version:   version,
+
+		ChecksumAlgorithm: crc32Algorithm,
 	}
 
 	s.Run("creates and uploads file", func() {
@@ -79,9 +108,112 @@ func (s *ProcessorTestSuite) TestProcess() {
 			s.Require().NoError(err)
 			s.Equal(uploadedFile, file)
 		})
+		s.Run("where previous file version was packed", func() {
+			prevFile := &processor.File{
+				Key:        "some-pack-key",
+				KeyLayout:  fakeKeyLayout,
+				LocalPath:  path,
+				Checksum:   processor.Checksum{0, 0, 0, 7},
+				CTime:      time.Unix(12, 345).UTC(),
+				Version:    "456",
+				PackID:     "some-pack-id",
+				PackOffset: 12,
+				PackLength: 34,
+
+				ChecksumAlgorithm: crc32Algorithm,
+			}
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+			s.mockUploader.EXPECT().Upload(ctx, currentFile).Return(uploadedFile, nil)
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
+		s.Run("where unique keys are enabled", func() {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+					s.Regexp("^foo~[0-9a-f-]{36}$", file.Key)
+					return uploadedFile, nil
+				})
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+			keyGen := fakeKeyGenerator(func() string { return "foo" })
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithUniqueKeys(),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
+		s.Run("where checksum computed during upload", func() {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(s.makeDoUpload(body, uploadedFile))
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithSingleRead(),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
+		s.Run("where redundant upload cannot be deleted", func() {
+			prevFile := &processor.File{
+				Key:       key,
+				LocalPath: path,
+				CTime:     time.Unix(12, 345).UTC(),
+				Checksum:  checksum,
+				Version:   "456",
+
+				ChecksumAlgorithm: crc32Algorithm,
+			}
+
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(s.makeDoUpload(body, uploadedFile))
+			s.mockUploader.EXPECT().Delete(ctx, uploadedFile).Return(errors.New("locked"))
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithSingleRead(),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
 		s.Run("where file never uploaded", func() {
 			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
-			s.mockUploader.EXPECT().Upload(ctx, currentFile).Return(uploadedFile, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(s.makeDoUpload(body, uploadedFile))
 			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
 
 			processor := processor.New(
@@ -127,6 +259,8 @@ func (s *ProcessorTestSuite) TestProcess() {
 				CTime:     time.Unix(12, 345).UTC(),
 				Checksum:  checksum,
 				Version:   "456",
+
+				ChecksumAlgorithm: crc32Algorithm,
 			}
 
 			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
@@ -140,6 +274,61 @@ func (s *ProcessorTestSuite) TestProcess() {
 
 			file, err := processor.Process(ctx, path)
 
+			s.Require().NoError(err)
+			s.Equal(prevFile, file)
+		})
+		s.Run("for matching checksum using previous algorithm", func() {
+			sum := sha256.Sum256(body)
+			prevFile := &processor.File{
+				Key:       key,
+				LocalPath: path,
+				CTime:     time.Unix(12, 345).UTC(),
+				Checksum:  sum[:],
+				Version:   "456",
+
+				ChecksumAlgorithm: "SHA256",
+			}
+
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithSingleRead(),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(prevFile, file)
+		})
+		s.Run("for matching checksum computed during upload", func() {
+			prevFile := &processor.File{
+				Key:       key,
+				LocalPath: path,
+				CTime:     time.Unix(12, 345).UTC(),
+				Checksum:  checksum,
+				Version:   "456",
+
+				ChecksumAlgorithm: crc32Algorithm,
+			}
+
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(s.makeDoUpload(body, uploadedFile))
+			s.mockUploader.EXPECT().Delete(ctx, uploadedFile).Return(nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithSingleRead(),
+			)
+
+			file, err := processor.Process(ctx, path)
+
 			s.Require().NoError(err)
 			s.Equal(prevFile, file)
 		})
@@ -222,3 +411,579 @@ func (s *ProcessorTestSuite) TestProcess() {
 		})
 	})
 }
+
+func (s *ProcessorTestSuite) modTime(fs *memfs.FS, path string) time.Time {
+	f, err := fs.Open(path)
+	s.Require().NoError(err)
+	defer f.Close()
+
+	info, err := f.Stat()
+	s.Require().NoError(err)
+
+	return info.ModTime().UTC().Truncate(time.Microsecond)
+}
+
+// makeDoUpload returns a function that writes the provided body to the hash of
+// the file being uploaded, as an uploader would, and returns a copy of the
+// provided uploaded file.
+func (s *ProcessorTestSuite) makeDoUpload(
+	body []byte,
+	uploadedFile *processor.File,
+) func(context.Context, *processor.File) (*processor.File, error) {
+
+	return func(ctx context.Context, file *processor.File) (*processor.File, error) {
+		s.Require().NotNil(file.Hash)
+		file.Hash.Write(body)
+
+		output := *uploadedFile
+		output.Hash = file.Hash
+		return &output, nil
+	}
+}
+
+func (s *ProcessorTestSuite) TestProcessReportsProgress() {
+	body := []byte{1, 2, 3}
+	path := "path/to/file"
+	ctime := time.Unix(123, 456).UTC()
+	checksum := processor.Checksum{0x55, 0xbc, 0x80, 0x1d}
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	fs := memfs.New()
+	fs.MkdirAll("path/to", os.FileMode(0))
+	fs.WriteFile(path, body, os.FileMode(0))
+
+	keyGen := fakeKeyGenerator(func() string { return "key" })
+	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
+
+	s.Run("for uploaded file", func() {
+		uploadedFile := &processor.File{Key: "key", LocalPath: path}
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(s.makeDoUpload(body, uploadedFile))
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).Return(uploadedFile, nil)
+		gomock.InOrder(
+			s.mockReporter.EXPECT().BytesHashed(ctx, int64(len(body))),
+			s.mockReporter.EXPECT().FileUploaded(ctx, path, false),
+		)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithReporter(s.mockReporter),
+		)
+
+		_, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+	})
+	s.Run("for skipped file", func() {
+		prevFile := &processor.File{
+			Key:       "key",
+			LocalPath: path,
+			CTime:     time.Unix(12, 345).UTC(),
+			Checksum:  checksum,
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+		gomock.InOrder(
+			s.mockReporter.EXPECT().BytesHashed(ctx, int64(len(body))),
+			s.mockReporter.EXPECT().FileSkipped(ctx, path),
+		)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithReporter(s.mockReporter),
+		)
+
+		_, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+	})
+}
+
+func (s *ProcessorTestSuite) TestProcessChangeDetection() {
+	body := []byte{1, 2, 3}
+	path := "path/to/file"
+	ctime := time.Unix(123, 456).UTC()
+	checksum := processor.Checksum{0x55, 0xbc, 0x80, 0x1d}
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	fs := memfs.New()
+	fs.MkdirAll("path/to", os.FileMode(0))
+	fs.WriteFile(path, body, os.FileMode(0))
+	mtime := s.modTime(fs, path)
+
+	keyGen := fakeKeyGenerator(func() string { return "key" })
+	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
+	uploadedFile := &processor.File{Key: "key", LocalPath: path}
+	doUpload := func(ctx context.Context, file *processor.File) (*processor.File, error) {
+		if file.Hash != nil {
+			file.Hash.Write(body)
+		}
+		output := *uploadedFile
+		output.Checksum = file.Checksum
+		output.Hash = file.Hash
+		return &output, nil
+	}
+
+	newPrevFile := func(ctime, mtime time.Time, size int64, checksum processor.Checksum) *processor.File {
+		return &processor.File{
+			Key:       "key",
+			LocalPath: path,
+			Checksum:  checksum,
+			CTime:     ctime,
+			MTime:     mtime,
+			Size:      size,
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+	}
+	otherTime := time.Unix(12, 345).UTC()
+	otherChecksum := processor.Checksum{0, 0, 0, 7}
+
+	testCases := []struct {
+		name         string
+		opt          processor.Option
+		prevFile     *processor.File
+		expectUpload bool
+		expectedErr  error
+	}{
+		{
+			name:     "ctime skips unchanged ctime",
+			opt:      processor.WithChangeDetection(processor.ChangeDetectionCTime),
+			prevFile: newPrevFile(ctime, otherTime, 0, otherChecksum),
+		},
+		{
+			name:         "ctime uploads changed ctime without comparing checksum",
+			opt:          processor.WithChangeDetection(processor.ChangeDetectionCTime),
+			prevFile:     newPrevFile(otherTime, mtime, 3, checksum),
+			expectUpload: true,
+		},
+		{
+			name:     "mtime and size skips unchanged mtime and size",
+			opt:      processor.WithChangeDetection(processor.ChangeDetectionMTimeSize),
+			prevFile: newPrevFile(otherTime, mtime, 3, otherChecksum),
+		},
+		{
+			name:         "mtime and size uploads changed size",
+			opt:          processor.WithChangeDetection(processor.ChangeDetectionMTimeSize),
+			prevFile:     newPrevFile(ctime, mtime, 4, checksum),
+			expectUpload: true,
+		},
+		{
+			name:     "always checksum skips matching checksum",
+			opt:      processor.WithChangeDetection(processor.ChangeDetectionAlwaysChecksum),
+			prevFile: newPrevFile(otherTime, otherTime, 0, checksum),
+		},
+		{
+			name:         "always checksum uploads changed checksum with changed ctime",
+			opt:          processor.WithChangeDetection(processor.ChangeDetectionAlwaysChecksum),
+			prevFile:     newPrevFile(otherTime, mtime, 3, otherChecksum),
+			expectUpload: true,
+		},
+		{
+			name:         "always checksum uploads changed checksum with unchanged ctime",
+			opt:          processor.WithChangeDetection(processor.ChangeDetectionAlwaysChecksum),
+			prevFile:     newPrevFile(ctime, mtime, 3, otherChecksum),
+			expectUpload: true,
+		},
+		{
+			name:        "checksum every run refuses changed checksum with unchanged ctime",
+			opt:         processor.WithChecksumEveryNRuns(5, 1),
+			prevFile:    newPrevFile(ctime, mtime, 3, otherChecksum),
+			expectedErr: hoarderrors.ErrCorruptFile,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(tc.prevFile, nil)
+			if tc.expectUpload {
+				s.mockUploader.EXPECT().
+					Upload(ctx, gomock.Any()).
+					DoAndReturn(doUpload)
+				s.mockRegistry.EXPECT().
+					Create(ctx, gomock.Any()).
+					DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+						return file, nil
+					})
+			}
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				tc.opt,
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			if tc.expectedErr != nil {
+				s.ErrorIs(err, tc.expectedErr)
+				s.Nil(file)
+				return
+			}
+			s.Require().NoError(err)
+			if tc.expectUpload {
+				s.Equal(uploadedFile.Key, file.Key)
+				s.Equal(checksum, file.Checksum)
+			} else {
+				s.Equal(tc.prevFile, file)
+			}
+		})
+	}
+
+	s.Run("checksum every n runs staggers checks across runs", func() {
+		prevFile := newPrevFile(ctime, mtime, 3, otherChecksum)
+		numRuns := int64(4)
+
+		checks := 0
+		for run := int64(0); run < numRuns; run++ {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithChecksumEveryNRuns(run, numRuns),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			if errors.Is(err, hoarderrors.ErrCorruptFile) {
+				checks++
+				continue
+			}
+			s.Require().NoError(err)
+			s.Equal(prevFile, file)
+		}
+
+		s.Equal(1, checks)
+	})
+	s.Run("checksum every n runs advances with next run", func() {
+		prevFile := newPrevFile(ctime, mtime, 3, otherChecksum)
+		numRuns := int64(4)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithChecksumEveryNRuns(0, numRuns),
+		)
+
+		checks := 0
+		for run := int64(0); run < numRuns; run++ {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+
+			file, err := processor.Process(ctx, path)
+			processor.NextRun()
+
+			if errors.Is(err, hoarderrors.ErrCorruptFile) {
+				checks++
+				continue
+			}
+			s.Require().NoError(err)
+			s.Equal(prevFile, file)
+		}
+
+		s.Equal(1, checks)
+	})
+	s.Run("single read deletes upload of changed checksum with unchanged ctime", func() {
+		prevFile := newPrevFile(ctime, mtime, 3, otherChecksum)
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				file.Hash.Write(body)
+				return file, nil
+			})
+		s.mockUploader.EXPECT().Delete(ctx, gomock.Any()).Return(nil)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithChecksumEveryNRuns(5, 1),
+			processor.WithSingleRead(),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.ErrorIs(err, hoarderrors.ErrCorruptFile)
+		s.Nil(file)
+	})
+}
+
+func (s *ProcessorTestSuite) TestProcessFileChangingDuringUpload() {
+	body := []byte{1, 2, 3}
+	path := "path/to/file"
+	ctime := time.Unix(123, 456).UTC()
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	keyGen := fakeKeyGenerator(func() string { return "key" })
+	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
+
+	newFS := func() *memfs.FS {
+		fs := memfs.New()
+		fs.MkdirAll("path/to", os.FileMode(0))
+		fs.WriteFile(path, body, os.FileMode(0))
+		return fs
+	}
+	// makeDoUpload returns an upload function that appends to the file while
+	// it is being uploaded for the provided number of calls.
+	makeDoUpload := func(fs *memfs.FS, changes int) func(context.Context, *processor.File) (*processor.File, error) {
+		contents := body
+		version := 0
+		return func(ctx context.Context, file *processor.File) (*processor.File, error) {
+			if file.Hash != nil {
+				file.Hash.Write(contents)
+			}
+			version++
+			if version <= changes {
+				contents = append(contents, byte(version))
+				fs.WriteFile(path, contents, os.FileMode(0))
+			}
+			output := *file
+			output.Version = fmt.Sprint(version)
+			return &output, nil
+		}
+	}
+
+	s.Run("uploads file again once it settles", func() {
+		fs := newFS()
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(makeDoUpload(fs, 1)).
+			Times(2)
+		s.mockUploader.EXPECT().
+			Delete(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) error {
+				s.Equal("1", file.Version)
+				return nil
+			})
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				return file, nil
+			})
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal("2", file.Version)
+		s.Equal(int64(len(body)+1), file.Size)
+		s.False(file.Inconsistent)
+	})
+	s.Run("marks file as inconsistent if it does not settle", func() {
+		fs := newFS()
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(makeDoUpload(fs, 2)).
+			Times(2)
+		s.mockUploader.EXPECT().Delete(ctx, gomock.Any()).Return(nil)
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				return file, nil
+			})
+		s.mockReporter.EXPECT().FileBusy(ctx, path)
+		s.mockReporter.EXPECT().BytesHashed(ctx, gomock.Any())
+		s.mockReporter.EXPECT().FileUploaded(ctx, path, false)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithReporter(s.mockReporter),
+			processor.WithMaxAttempts(2),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal("2", file.Version)
+		s.True(file.Inconsistent)
+	})
+	s.Run("skips file that does not settle if configured to", func() {
+		fs := newFS()
+		prevFile := &processor.File{
+			Key:       "key",
+			LocalPath: path,
+			CTime:     time.Unix(12, 345).UTC(),
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(makeDoUpload(fs, 2)).
+			Times(2)
+		s.mockUploader.EXPECT().Delete(ctx, gomock.Any()).Return(nil).Times(2)
+		s.mockReporter.EXPECT().BytesHashed(ctx, gomock.Any()).AnyTimes()
+		s.mockReporter.EXPECT().FileBusy(ctx, path)
+		s.mockReporter.EXPECT().FileSkipped(ctx, path)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithReporter(s.mockReporter),
+			processor.WithMaxAttempts(2),
+			processor.WithInconsistentPolicy(processor.InconsistentPolicySkip),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal(prevFile, file)
+	})
+	s.Run("fails file that does not settle without previous version", func() {
+		fs := newFS()
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(makeDoUpload(fs, 2)).
+			Times(2)
+		s.mockUploader.EXPECT().Delete(ctx, gomock.Any()).Return(nil).Times(2)
+		s.mockReporter.EXPECT().BytesHashed(ctx, gomock.Any()).AnyTimes()
+		s.mockReporter.EXPECT().FileBusy(ctx, path)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithReporter(s.mockReporter),
+			processor.WithMaxAttempts(2),
+			processor.WithInconsistentPolicy(processor.InconsistentPolicySkip),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.ErrorIs(err, hoarderrors.ErrInconsistentFile)
+		s.Nil(file)
+	})
+}
+
+type fakeMetadataGetter func() (*metadata.Metadata, error)
+
+func (fn fakeMetadataGetter) GetMetadata(path string) (*metadata.Metadata, error) {
+	return fn()
+}
+
+func (s *ProcessorTestSuite) TestProcessRecordsMetadata() {
+	body := []byte{1, 2, 3}
+	path := "path/to/file"
+	ctime := time.Unix(123, 456).UTC()
+	checksum := processor.Checksum{0x55, 0xbc, 0x80, 0x1d}
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	fs := memfs.New()
+	fs.MkdirAll("path/to", os.FileMode(0))
+	fs.WriteFile(path, body, os.FileMode(0))
+	mtime := s.modTime(fs, path)
+
+	md := &metadata.Metadata{Mode: 0644, UID: 1000, GID: 1000}
+	keyGen := fakeKeyGenerator(func() string { return "key" })
+	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
+	mdGetter := fakeMetadataGetter(func() (*metadata.Metadata, error) { return md, nil })
+	returnFile := func(ctx context.Context, file *processor.File) (*processor.File, error) {
+		return file, nil
+	}
+
+	s.Run("attaches metadata to uploaded file", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockUploader.EXPECT().
+			Upload(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+				file.Hash.Write(body)
+				return file, nil
+			})
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithMetadataGetter(mdGetter),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal(md, file.Metadata)
+	})
+	s.Run("skips file with unchanged metadata", func() {
+		prevFile := &processor.File{
+			Key:       "key",
+			LocalPath: path,
+			CTime:     ctime,
+			Metadata:  &metadata.Metadata{Mode: 0644, UID: 1000, GID: 1000},
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithMetadataGetter(mdGetter),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal(prevFile, file)
+	})
+	s.Run("registers metadata change without uploading", func() {
+		prevFile := &processor.File{
+			Key:       "key",
+			LocalPath: path,
+			Checksum:  checksum,
+			CTime:     time.Unix(12, 345).UTC(),
+			Version:   "some-version",
+			Metadata:  &metadata.Metadata{Mode: 0600, UID: 1000, GID: 1000},
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+		expectedFile := &processor.File{
+			Key:       "key",
+			LocalPath: path,
+			Checksum:  checksum,
+			CTime:     ctime,
+			MTime:     mtime,
+			Size:      int64(len(body)),
+			Version:   "some-version",
+			Metadata:  md,
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(prevFile, nil)
+		s.mockRegistry.EXPECT().Create(ctx, expectedFile).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithMetadataGetter(mdGetter),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal(expectedFile, file)
+	})
+}
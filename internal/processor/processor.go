@@ -2,6 +2,8 @@ package processor
 
 import (
 	"context"
+	"encoding/hex"
+	"hash"
 	"io/fs"
 	"syscall"
 	"time"
@@ -9,28 +11,134 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/google/uuid"
+	"github.com/mspraggs/hoard/internal/checksum"
+	"github.com/mspraggs/hoard/internal/metadata"
 	"github.com/mspraggs/hoard/internal/util"
 )
 
 //go:generate mockgen -destination=./mocks/processor.go -package=mocks -source=$GOFILE
 
-// Checksum defines a CRC32 checksum as an unsigned 32-bit integer.
-type Checksum uint32
+// Checksum defines the checksum of a file's contents as a byte slice.
+type Checksum []byte
 
-// File encapsulates all information associated with a file.
+// String returns the hexadecimal representation of the checksum.
+func (c Checksum) String() string {
+	return hex.EncodeToString(c)
+}
+
+// FileType denotes the type of a directory entry. Entries other than regular
+// files are recorded without an object body.
+type FileType string
+
+const (
+	// FileTypeRegular denotes a regular file. It is the zero value, so that
+	// files registered before other types were recorded are regular files.
+	FileTypeRegular FileType = ""
+	// FileTypeSymlink denotes a symbolic link, recorded with its target.
+	FileTypeSymlink FileType = "symlink"
+	// FileTypeDirectory denotes a directory.
+	FileTypeDirectory FileType = "directory"
+	// FileTypeFIFO denotes a named pipe.
+	FileTypeFIFO FileType = "fifo"
+	// FileTypeCharDevice denotes a character device node, recorded with its
+	// major and minor device numbers.
+	FileTypeCharDevice FileType = "char_device"
+	// FileTypeBlockDevice denotes a block device node, recorded with its major
+	// and minor device numbers.
+	FileTypeBlockDevice FileType = "block_device"
+)
+
+// Extent describes a region of a sparse file that holds data. The regions of a
+// sparse file outside its extents are holes, which read as zeros.
+type Extent struct {
+	Offset int64
+	Length int64
+}
+
+// File encapsulates all information associated with a file. If Hash is set,
+// the uploader writes the contents of the file to it as they are uploaded, so
+// that the checksum can be computed without reading the file a second time.
+// Inconsistent is set if the file kept changing while it was being uploaded,
+// in which case the stored object may not match the recorded checksum.
+// LinkTarget holds the target of a symbolic link, or for a regular file, the
+// path of another hard link to the same file whose object it shares. If
+// Extents is non-nil, the file is sparse and its object holds only the
+// contents of its extents, in order.
 type File struct {
-	Key       string
-	LocalPath string
-	Checksum  Checksum
-	CTime     time.Time
-	Bucket    string
-	ETag      string
-	Version   string
+	Type              FileType
+	Key               string
+	KeyLayout         string
+	LocalPath         string
+	Checksum          Checksum
+	ChecksumAlgorithm string
+	Hash              hash.Hash
+	CTime             time.Time
+	MTime             time.Time
+	Size              int64
+	Bucket            string
+	StorageClass      string
+	ETag              string
+	Version           string
+	PackID            string
+	PackOffset        int64
+	PackLength        int64
+	RetainUntil       time.Time
+	LegalHold         bool
+	Inconsistent      bool
+	Metadata          *metadata.Metadata
+	LinkTarget        string
+	DeviceMajor       uint32
+	DeviceMinor       uint32
+	Extents           []Extent
 }
 
-// KeyGenerator defines the interface required to generate a random key.
+// ChangeDetection denotes the policy used to decide whether a file has changed
+// since its previous version was uploaded.
+type ChangeDetection string
+
+const (
+	// ChangeDetectionCTime treats a file as changed whenever its ctime
+	// changes.
+	ChangeDetectionCTime ChangeDetection = "ctime"
+	// ChangeDetectionMTimeSize treats a file as changed whenever its mtime or
+	// size changes, without computing a checksum beforehand.
+	ChangeDetectionMTimeSize ChangeDetection = "mtime_size"
+	// ChangeDetectionCTimeThenChecksum treats a file as unchanged if its ctime
+	// is unchanged, and otherwise compares its checksum.
+	ChangeDetectionCTimeThenChecksum ChangeDetection = "ctime_then_checksum"
+	// ChangeDetectionAlwaysChecksum compares the checksum of every file,
+	// regardless of its ctime, for filesystems on which ctime is unreliable.
+	ChangeDetectionAlwaysChecksum ChangeDetection = "always_checksum"
+	// ChangeDetectionChecksumEveryNRuns behaves as
+	// ChangeDetectionCTimeThenChecksum, except that the checksum of each file
+	// is compared once every N runs regardless of its ctime, in order to
+	// detect bit rot.
+	ChangeDetectionChecksumEveryNRuns ChangeDetection = "checksum_every_n_runs"
+)
+
+// InconsistentPolicy denotes what a Processor does with a file that is still
+// changing after the maximum number of upload attempts.
+type InconsistentPolicy string
+
+const (
+	// InconsistentPolicyMark registers the last uploaded version of the file,
+	// marking it as inconsistent.
+	InconsistentPolicyMark InconsistentPolicy = "mark"
+	// InconsistentPolicySkip deletes the last uploaded version of the file and
+	// leaves the registry unchanged.
+	InconsistentPolicySkip InconsistentPolicy = "skip"
+)
+
+const (
+	defaultMaxAttempts = 3
+	uuidKeyLayout      = "uuid"
+)
+
+// KeyGenerator defines the interface required to generate a key for a file
+// with a given path, along with the name of the layout the keys follow.
 type KeyGenerator interface {
-	GenerateKey() string
+	GenerateKey(path string) string
+	Layout() string
 }
 
 // CTimeGetter defines the interface required to get the change time from a
@@ -39,16 +147,45 @@ type CTimeGetter interface {
 	GetCTime(fi fs.File) (time.Time, error)
 }
 
+// MetadataGetter defines the interface required to read the POSIX metadata of
+// the file with a given path.
+type MetadataGetter interface {
+	GetMetadata(path string) (*metadata.Metadata, error)
+}
+
+// EntryReader defines the interface required to inspect the directory entry
+// with a given path without opening it, and to read the target of a symbolic
+// link.
+type EntryReader interface {
+	Stat(path string) (fs.FileInfo, error)
+	ReadLink(path string) (string, error)
+}
+
 // Registry specifies the interface required to register and update the
 // registry of uploaded files.
 type Registry interface {
 	Create(ctx context.Context, file *File) (*File, error)
 	FetchLatest(ctx context.Context, path string) (*File, error)
+	FetchLatestBySize(ctx context.Context, bucket string, size int64) ([]*File, error)
 }
 
-// Uploader specifies the interface required to upload files.
+// Uploader specifies the interface required to upload files, to delete an
+// uploaded version of a file that turns out to be redundant and to copy an
+// uploaded file to a new key.
 type Uploader interface {
 	Upload(ctx context.Context, file *File) (*File, error)
+	Delete(ctx context.Context, file *File) error
+	Copy(ctx context.Context, src, dst *File) (*File, error)
+}
+
+// Reporter specifies the interface required to report the progress of
+// processing files. Uploaded files are reported as changed if a previous version
+// of them was registered.
+type Reporter interface {
+	FileSkipped(ctx context.Context, path string)
+	FileUploaded(ctx context.Context, path string, changed bool)
+	BytesHashed(ctx context.Context, n int64)
+	FileBusy(ctx context.Context, path string)
 }
 
 // Option is the type used to implement the functional options pattern for the
@@ -58,12 +195,32 @@ type Option func(*Processor)
 // Processor encapsulates the logic required to create a file and store it in
 // the file store.
 type Processor struct {
-	log      *zap.SugaredLogger
-	fs       fs.FS
-	keyGen   KeyGenerator
-	ctg      CTimeGetter
-	registry Registry
-	uploader Uploader
+	log        *zap.SugaredLogger
+	fs         fs.FS
+	keyGen     KeyGenerator
+	ctg        CTimeGetter
+	mdGetter   MetadataGetter
+	entries    EntryReader
+	registry   Registry
+	uploader   Uploader
+	reporter   Reporter
+	csAlg      checksum.Algorithm
+	detection  ChangeDetection
+	run        int64
+	runsPerSum int64
+	uniqueKeys bool
+	singleRead bool
+
+	detectRenames  bool
+	renameBucket   string
+	entryBucket    string
+	entryKeyPrefix string
+
+	maxAttempts  int
+	inconsistent InconsistentPolicy
+
+	links    *linkTracker
+	activity *Activity
 }
 
 // New instantiates a new Processor instance with provided file store and
@@ -71,12 +228,20 @@ type Processor struct {
 func New(fs fs.FS, uploader Uploader, registry Registry, opts ...Option) *Processor {
 	log := util.MustNewLogger()
 	p := &Processor{
-		log:      log,
-		fs:       fs,
-		keyGen:   keyGen{},
-		ctg:      ctimeGetter{},
-		registry: registry,
-		uploader: uploader,
+		log:       log,
+		fs:        fs,
+		keyGen:    keyGen{},
+		ctg:       ctimeGetter{},
+		registry:  registry,
+		uploader:  uploader,
+		reporter:  nopReporter{},
+		csAlg:     checksum.AlgorithmCRC32,
+		detection: ChangeDetectionCTimeThenChecksum,
+
+		maxAttempts:  defaultMaxAttempts,
+		inconsistent: InconsistentPolicyMark,
+
+		links: newLinkTracker(),
 	}
 
 	for _, opt := range opts {
@@ -86,6 +251,22 @@ func New(fs fs.FS, uploader Uploader, registry Registry, opts ...Option) *Proces
 	return p
 }
 
+// Reset forgets the hard links found by previous calls to Process, so that
+// each pass over a directory uploads the first link it finds to a file rather
+// than referring to the version registered by an earlier pass. Reset must not
+// be called concurrently with Process.
+func (p *Processor) Reset() {
+	p.links.reset()
+}
+
+// NextRun advances the run against which checksums are scheduled, so that a
+// Processor used across several passes over a directory compares the checksums
+// of the next share of its files. NextRun must not be called concurrently with
+// Process.
+func (p *Processor) NextRun() {
+	p.run++
+}
+
 // WithKeyGenerator returns an option for setting the way in which a Processor
 // generates a key for a new file.
 func WithKeyGenerator(kg KeyGenerator) Option {
@@ -102,14 +283,147 @@ func WithCTimeGetter(ctg CTimeGetter) Option {
 	}
 }
 
+// WithMetadataGetter returns an option that causes a Processor to record the
+// POSIX metadata of every file. A new version is registered whenever the
+// metadata of a file changes, even if its contents do not.
+func WithMetadataGetter(mdGetter MetadataGetter) Option {
+	return func(p *Processor) {
+		p.mdGetter = mdGetter
+	}
+}
+
+// WithIrregularFiles returns an option that causes a Processor to record
+// symbolic links, directories, named pipes and device nodes in the provided
+// bucket, without uploading an object body. Each path is inspected with the
+// provided reader before it is opened.
+func WithIrregularFiles(entries EntryReader, bucket string) Option {
+	return func(p *Processor) {
+		p.entries = entries
+		p.entryBucket = bucket
+	}
+}
+
+// WithEntryKeyPrefix returns an option that causes a Processor to record the
+// provided key prefix as the key of each entry it records without an object
+// body, so that entries identify the directory they belong to in the same way
+// as the keys of uploaded objects.
+func WithEntryKeyPrefix(prefix string) Option {
+	return func(p *Processor) {
+		p.entryKeyPrefix = prefix
+	}
+}
+
+// WithActivity returns an option that causes a Processor to count the calls to
+// Process under way in the provided Activity.
+func WithActivity(activity *Activity) Option {
+	return func(p *Processor) {
+		p.activity = activity
+	}
+}
+
+// WithUniqueKeys returns an option that causes a Processor to store every
+// version of a file under a new, unique key rather than reusing the key of the
+// previous version. This is required when the storage bucket does not retain
+// previous versions of an object.
+func WithUniqueKeys() Option {
+	return func(p *Processor) {
+		p.uniqueKeys = true
+	}
+}
+
+// WithChecksumAlgorithm returns an option for setting the algorithm a
+// Processor uses to compute the checksums that detect changes to files.
+func WithChecksumAlgorithm(alg checksum.Algorithm) Option {
+	return func(p *Processor) {
+		p.csAlg = alg
+	}
+}
+
+// WithChangeDetection returns an option for setting the policy a Processor
+// uses to decide whether a file has changed.
+func WithChangeDetection(detection ChangeDetection) Option {
+	return func(p *Processor) {
+		p.detection = detection
+	}
+}
+
+// WithChecksumEveryNRuns returns an option that causes a Processor to compare
+// the checksum of each file once every n runs, regardless of its ctime. The
+// runs in which each file is checked are staggered by path, so that roughly
+// one nth of the files are checked in the provided run.
+func WithChecksumEveryNRuns(run, n int64) Option {
+	return func(p *Processor) {
+		p.detection = ChangeDetectionChecksumEveryNRuns
+		p.run = run
+		p.runsPerSum = n
+	}
+}
+
+// WithSingleRead returns an option that causes a Processor to read a changed
+// file only once, computing its checksum as it is uploaded rather than before.
+// If the file turns out to be unchanged, the uploaded version is deleted.
+func WithSingleRead() Option {
+	return func(p *Processor) {
+		p.singleRead = true
+	}
+}
+
+// WithMaxAttempts returns an option for setting the number of times a Processor
+// attempts to upload a file that changes while it is being uploaded.
+func WithMaxAttempts(n int) Option {
+	return func(p *Processor) {
+		p.maxAttempts = n
+	}
+}
+
+// WithInconsistentPolicy returns an option for setting what a Processor does
+// with a file that is still changing after the maximum number of attempts.
+func WithInconsistentPolicy(policy InconsistentPolicy) Option {
+	return func(p *Processor) {
+		p.inconsistent = policy
+	}
+}
+
+// WithRenameDetection returns an option that causes a Processor to look for a
+// file in the provided bucket that has the same size and checksum as a new
+// file, but whose path no longer exists. If one is found, the new file is
+// registered as a rename of it rather than being uploaded again.
+func WithRenameDetection(bucket string) Option {
+	return func(p *Processor) {
+		p.detectRenames = true
+		p.renameBucket = bucket
+	}
+}
+
+// WithReporter returns an option for setting the reporter a Processor notifies
+// as it processes files.
+func WithReporter(reporter Reporter) Option {
+	return func(p *Processor) {
+		p.reporter = reporter
+	}
+}
+
+type nopReporter struct{}
+
+func (r nopReporter) FileSkipped(ctx context.Context, path string)                {}
+func (r nopReporter) FileUploaded(ctx context.Context, path string, changed bool) {}
+func (r nopReporter) BytesHashed(ctx context.Context, n int64)                    {}
+func (r nopReporter) FileBusy(ctx context.Context, path string)                   {}
+
 type keyGen struct{}
 
 // GenerateKey returns a random UUID in accordance with the KeyGenerator
 // interface.
-func (g keyGen) GenerateKey() string {
+func (g keyGen) GenerateKey(path string) string {
 	return uuid.NewString()
 }
 
+// Layout returns the name of the random UUID key layout in accordance with the
+// KeyGenerator interface.
+func (g keyGen) Layout() string {
+	return uuidKeyLayout
+}
+
 type ctimeGetter struct{}
 
 // GetCTime extracts the ctime from the provided file object in accordance with
@@ -119,6 +433,22 @@ func (ctg ctimeGetter) GetCTime(f fs.File) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
-	ctime := fi.Sys().(*syscall.Stat_t).Ctim
-	return time.Unix(int64(ctime.Sec), int64(ctime.Nsec)).UTC().Truncate(time.Microsecond), nil
+	return ctimeOf(fi), nil
+}
+
+// mtimeOf returns the mtime of the file described by the provided FileInfo
+// object, truncated to the precision with which times are registered.
+func mtimeOf(fi fs.FileInfo) time.Time {
+	return fi.ModTime().UTC().Truncate(time.Microsecond)
+}
+
+// ctimeOf extracts the ctime from the provided FileInfo object. The zero time
+// is returned if the FileInfo object does not hold the underlying stat data.
+func ctimeOf(fi fs.FileInfo) time.Time {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	ctime := stat.Ctim
+	return time.Unix(int64(ctime.Sec), int64(ctime.Nsec)).UTC().Truncate(time.Microsecond)
 }
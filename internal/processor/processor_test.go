@@ -16,6 +16,7 @@ type ProcessorTestSuite struct {
 	controller   *gomock.Controller
 	mockRegistry *mocks.MockRegistry
 	mockUploader *mocks.MockUploader
+	mockReporter *mocks.MockReporter
 }
 
 func TestHandlerTestSuite(t *testing.T) {
@@ -26,4 +27,5 @@ func (s *ProcessorTestSuite) SetupTest() {
 	s.controller = gomock.NewController(s.T())
 	s.mockRegistry = mocks.NewMockRegistry(s.controller)
 	s.mockUploader = mocks.NewMockUploader(s.controller)
+	s.mockReporter = mocks.NewMockReporter(s.controller)
 }
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/mspraggs/hoard/internal/checksum"
+)
+
+// detectRename looks for a previously registered file with the same size and
+// checksum as the provided new file, but whose path no longer exists. If one
+// is found, the new file is registered as a rename of it and returned. The
+// checksum of the new file is only computed if there are candidates to compare
+// against, in which case it is attached to the file for use during upload.
+func (p *Processor) detectRename(ctx context.Context, file *File) (*File, error) {
+	if file.Size == 0 {
+		return nil, nil
+	}
+
+	candidates, err := p.registry.FetchLatestBySize(ctx, p.renameBucket, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	algs := []checksum.Algorithm{p.csAlg}
+	algIndices := map[string]int{file.ChecksumAlgorithm: 0}
+	var missing []*File
+	for _, candidate := range candidates {
+		if candidate.LocalPath == file.LocalPath || candidate.Inconsistent {
+			continue
+		}
+		if _, err := fs.Stat(p.fs, candidate.LocalPath); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if _, ok := algIndices[candidate.ChecksumAlgorithm]; !ok {
+			algIndices[candidate.ChecksumAlgorithm] = len(algs)
+			algs = append(algs, checksum.Algorithm(candidate.ChecksumAlgorithm))
+		}
+		missing = append(missing, candidate)
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	checksums, err := p.computeChecksums(ctx, file.LocalPath, algs...)
+	if err != nil {
+		return nil, err
+	}
+	file.Checksum = checksums[0]
+
+	for _, candidate := range missing {
+		sum := checksums[algIndices[candidate.ChecksumAlgorithm]]
+		if bytes.Equal(candidate.Checksum, sum) {
+			return p.registerRename(ctx, file, candidate)
+		}
+	}
+
+	return nil, nil
+}
+
+// registerRename registers the new file as holding the same contents as the
+// provided file whose path no longer exists. The existing object is reused if
+// its key does not depend on its path, and is otherwise copied to a new key,
+// along with the extents of a sparse file.
+// If the object cannot be copied, nil is returned so that the file is uploaded
+// instead.
+func (p *Processor) registerRename(ctx context.Context, file, src *File) (*File, error) {
+	p.log.Infow(
+		"Detected renamed file",
+		"path", file.LocalPath,
+		"previous_path", src.LocalPath,
+	)
+
+	renamed := *file
+	if src.PackID != "" || src.KeyLayout == uuidKeyLayout {
+		renamed.Key = src.Key
+		renamed.KeyLayout = src.KeyLayout
+		renamed.Bucket = src.Bucket
+		renamed.StorageClass = src.StorageClass
+		renamed.ETag = src.ETag
+		renamed.Version = src.Version
+		renamed.PackID = src.PackID
+		renamed.PackOffset = src.PackOffset
+		renamed.PackLength = src.PackLength
+		renamed.Extents = src.Extents
+		renamed.RetainUntil = src.RetainUntil
+		renamed.LegalHold = src.LegalHold
+	} else {
+		p.attachKey(&renamed, nil)
+		renamed.Extents = src.Extents
+		copied, err := p.uploader.Copy(ctx, src, &renamed)
+		if err != nil {
+			p.log.Warnw(
+				"Unable to copy renamed file, uploading it instead",
+				"path", file.LocalPath,
+				"previous_path", src.LocalPath,
+				"error", err,
+			)
+			return nil, nil
+		}
+		renamed = *copied
+	}
+
+	registered, err := p.registry.Create(ctx, &renamed)
+	if err != nil {
+		return nil, err
+	}
+	p.reporter.FileSkipped(ctx, file.LocalPath)
+
+	return registered, nil
+}
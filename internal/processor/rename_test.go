@@ -0,0 +1,265 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/psanford/memfs"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+func (s *ProcessorTestSuite) TestProcessDetectsRenames() {
+	body := []byte{1, 2, 3}
+	path := "path/to/new"
+	oldPath := "path/to/old"
+	bucket := "some-bucket"
+	ctime := time.Unix(123, 456).UTC()
+	checksum := processor.Checksum{0x55, 0xbc, 0x80, 0x1d}
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	fs := memfs.New()
+	fs.MkdirAll("path/to", os.FileMode(0))
+	fs.WriteFile(path, body, os.FileMode(0))
+	fs.WriteFile("path/to/other", body, os.FileMode(0))
+	mtime := s.modTime(fs, path)
+	size := int64(len(body))
+
+	keyGen := fakeKeyGenerator(func() string { return "new-key" })
+	ctimeGetter := fakeCTimeGetter(func() (time.Time, error) { return ctime, nil })
+
+	oldFile := &processor.File{
+		Key:          "old-key",
+		KeyLayout:    "uuid",
+		LocalPath:    oldPath,
+		Checksum:     checksum,
+		Size:         size,
+		Bucket:       bucket,
+		StorageClass: "STANDARD",
+		ETag:         "old-etag",
+		Version:      "old-version",
+
+		ChecksumAlgorithm: crc32Algorithm,
+	}
+	pathKeyedFile := *oldFile
+	pathKeyedFile.KeyLayout = fakeKeyLayout
+	existingFile := &processor.File{
+		Key:       "other-key",
+		LocalPath: "path/to/other",
+		Checksum:  checksum,
+		Size:      size,
+		Bucket:    bucket,
+
+		ChecksumAlgorithm: crc32Algorithm,
+	}
+	returnFile := func(ctx context.Context, file *processor.File) (*processor.File, error) {
+		return file, nil
+	}
+
+	s.Run("reuses object with path independent key", func() {
+		expectedFile := &processor.File{
+			Key:          "old-key",
+			KeyLayout:    "uuid",
+			LocalPath:    path,
+			Checksum:     checksum,
+			CTime:        ctime,
+			MTime:        mtime,
+			Size:         size,
+			Bucket:       bucket,
+			StorageClass: "STANDARD",
+			ETag:         "old-etag",
+			Version:      "old-version",
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockRegistry.EXPECT().
+			FetchLatestBySize(ctx, bucket, size).
+			Return([]*processor.File{existingFile, oldFile}, nil)
+		s.mockRegistry.EXPECT().Create(ctx, expectedFile).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithRenameDetection(bucket),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal(expectedFile, file)
+	})
+	s.Run("reuses object with path independent key under other layout", func() {
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockRegistry.EXPECT().
+			FetchLatestBySize(ctx, bucket, size).
+			Return([]*processor.File{oldFile}, nil)
+		s.mockRegistry.EXPECT().
+			Create(ctx, gomock.Any()).
+			DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithRenameDetection(bucket),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal("old-key", file.Key)
+		s.Equal("uuid", file.KeyLayout)
+	})
+	s.Run("copies object with path dependent key", func() {
+		copiedFile := &processor.File{
+			Key:       "new-key",
+			KeyLayout: fakeKeyLayout,
+			LocalPath: path,
+			Checksum:  checksum,
+			CTime:     ctime,
+			MTime:     mtime,
+			Size:      size,
+			Bucket:    bucket,
+			Version:   "new-version",
+
+			ChecksumAlgorithm: crc32Algorithm,
+		}
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockRegistry.EXPECT().
+			FetchLatestBySize(ctx, bucket, size).
+			Return([]*processor.File{&pathKeyedFile}, nil)
+		s.mockUploader.EXPECT().
+			Copy(ctx, &pathKeyedFile, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, src, dst *processor.File) (*processor.File, error) {
+				s.Equal("new-key", dst.Key)
+				return copiedFile, nil
+			})
+		s.mockRegistry.EXPECT().Create(ctx, copiedFile).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithRenameDetection(bucket),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal(copiedFile, file)
+	})
+	s.Run("copies extents of sparse file with path dependent key", func() {
+		extents := []processor.Extent{{Offset: 1, Length: 2}}
+		sparseFile := pathKeyedFile
+		sparseFile.Extents = extents
+
+		s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+		s.mockRegistry.EXPECT().
+			FetchLatestBySize(ctx, bucket, size).
+			Return([]*processor.File{&sparseFile}, nil)
+		s.mockUploader.EXPECT().
+			Copy(ctx, &sparseFile, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, src, dst *processor.File) (*processor.File, error) {
+				s.Equal(extents, dst.Extents)
+				s.Equal(checksum, dst.Checksum)
+				return dst, nil
+			})
+		s.mockRegistry.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(returnFile)
+
+		processor := processor.New(
+			fs, s.mockUploader, s.mockRegistry,
+			processor.WithKeyGenerator(keyGen),
+			processor.WithCTimeGetter(ctimeGetter),
+			processor.WithRenameDetection(bucket),
+		)
+
+		file, err := processor.Process(ctx, path)
+
+		s.Require().NoError(err)
+		s.Equal("new-key", file.Key)
+		s.Equal(extents, file.Extents)
+	})
+	s.Run("uploads file", func() {
+		uploadedFile := &processor.File{Key: "new-key", LocalPath: path, Checksum: checksum}
+
+		s.Run("where no candidate path has disappeared", func() {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+			s.mockRegistry.EXPECT().
+				FetchLatestBySize(ctx, bucket, size).
+				Return([]*processor.File{existingFile}, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(s.makeDoUpload(body, uploadedFile))
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithRenameDetection(bucket),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
+		s.Run("where checksums differ", func() {
+			otherFile := *oldFile
+			otherFile.Checksum = processor.Checksum{0, 0, 0, 7}
+
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+			s.mockRegistry.EXPECT().
+				FetchLatestBySize(ctx, bucket, size).
+				Return([]*processor.File{&otherFile}, nil)
+			s.mockUploader.EXPECT().
+				Upload(ctx, gomock.Any()).
+				DoAndReturn(func(ctx context.Context, file *processor.File) (*processor.File, error) {
+					s.Equal(checksum, file.Checksum)
+					return uploadedFile, nil
+				})
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithRenameDetection(bucket),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
+		s.Run("where copy fails", func() {
+			s.mockRegistry.EXPECT().FetchLatest(ctx, path).Return(nil, nil)
+			s.mockRegistry.EXPECT().
+				FetchLatestBySize(ctx, bucket, size).
+				Return([]*processor.File{&pathKeyedFile}, nil)
+			s.mockUploader.EXPECT().
+				Copy(ctx, &pathKeyedFile, gomock.Any()).
+				Return(nil, errors.New("oh no"))
+			s.mockUploader.EXPECT().Upload(ctx, gomock.Any()).Return(uploadedFile, nil)
+			s.mockRegistry.EXPECT().Create(ctx, uploadedFile).Return(uploadedFile, nil)
+
+			processor := processor.New(
+				fs, s.mockUploader, s.mockRegistry,
+				processor.WithKeyGenerator(keyGen),
+				processor.WithCTimeGetter(ctimeGetter),
+				processor.WithRenameDetection(bucket),
+			)
+
+			file, err := processor.Process(ctx, path)
+
+			s.Require().NoError(err)
+			s.Equal(uploadedFile, file)
+		})
+	})
+}
@@ -0,0 +1,208 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Display defines the interface required to present snapshots of a tracker's
+// progress.
+type Display interface {
+	Update(s Snapshot)
+	Finish(s Snapshot)
+}
+
+// Run passes a snapshot of the tracker to the provided display at the provided
+// interval until the context is done, at which point a final summary is
+// passed to the display.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration, display Display) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			display.Update(t.Snapshot())
+		case <-ctx.Done():
+			display.Finish(t.Snapshot())
+			return
+		}
+	}
+}
+
+// IsTerminal reports whether the provided file refers to an interactive
+// terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// TerminalDisplay renders progress to an interactive terminal, redrawing the
+// totals and a line per worker in place on every update.
+type TerminalDisplay struct {
+	w     io.Writer
+	lines int
+}
+
+// NewTerminalDisplay instantiates a new TerminalDisplay writing to the provided
+// writer.
+func NewTerminalDisplay(w io.Writer) *TerminalDisplay {
+	return &TerminalDisplay{w: w}
+}
+
+// Update redraws the display using the provided snapshot.
+func (d *TerminalDisplay) Update(s Snapshot) {
+	if d.lines > 0 {
+		fmt.Fprintf(d.w, "\x1b[%dA", d.lines)
+	}
+
+	lines := []string{formatTotals(s)}
+	for _, w := range s.Workers {
+		lines = append(lines, formatWorker(w))
+	}
+	// Clear any lines left over from a previous update with more workers.
+	for i := len(lines); i < d.lines; i++ {
+		lines = append(lines, "")
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(d.w, "\x1b[2K%s\n", line)
+	}
+	d.lines = len(lines)
+}
+
+// Finish redraws the display a final time and prints a summary line, followed
+// by any files that changed while they were being uploaded.
+func (d *TerminalDisplay) Finish(s Snapshot) {
+	d.Update(s)
+	fmt.Fprintf(d.w, "Finished in %s\n", s.Elapsed.Round(time.Second))
+	if s.ExcludedFiles > 0 || s.ExcludedDirs > 0 {
+		fmt.Fprintf(
+			d.w, "Excluded %d files and %d directories\n", s.ExcludedFiles, s.ExcludedDirs,
+		)
+	}
+	if len(s.BusyFiles) > 0 {
+		fmt.Fprintf(d.w, "Files that changed during upload:\n")
+		for _, path := range s.BusyFiles {
+			fmt.Fprintf(d.w, "  %s\n", path)
+		}
+	}
+	if len(s.Hooks) > 0 {
+		fmt.Fprintf(d.w, "Hooks:\n")
+		for _, h := range s.Hooks {
+			fmt.Fprintf(d.w, "  %s\n", formatHook(h))
+		}
+	}
+}
+
+// LogDisplay reports progress as periodic summary log lines, for use when
+// output is not an interactive terminal.
+type LogDisplay struct {
+	log *zap.SugaredLogger
+}
+
+// NewLogDisplay instantiates a new LogDisplay writing to the provided logger.
+func NewLogDisplay(log *zap.SugaredLogger) *LogDisplay {
+	return &LogDisplay{log: log}
+}
+
+// Update logs a summary of the provided snapshot.
+func (d *LogDisplay) Update(s Snapshot) {
+	d.log.Infow("Backup progress", snapshotFields(s)...)
+}
+
+// Finish logs a final summary of the provided snapshot, along with any files
+// that changed while they were being uploaded.
+func (d *LogDisplay) Finish(s Snapshot) {
+	fields := append(
+		snapshotFields(s), "files_excluded", s.ExcludedFiles, "dirs_excluded", s.ExcludedDirs,
+	)
+	d.log.Infow("Backup finished", fields...)
+	if len(s.BusyFiles) > 0 {
+		d.log.Warnw("Files changed during upload", "paths", s.BusyFiles)
+	}
+	for _, h := range s.Hooks {
+		if h.Err != nil {
+			d.log.Warnw("Hook failed", "hook", h.Name, "duration", h.Duration, "error", h.Err)
+		} else {
+			d.log.Infow("Hook succeeded", "hook", h.Name, "duration", h.Duration)
+		}
+	}
+}
+
+func snapshotFields(s Snapshot) []interface{} {
+	return []interface{}{
+		"files_scanned", s.FilesScanned,
+		"files_skipped", s.FilesSkipped,
+		"files_uploaded", s.FilesUploaded,
+		"files_failed", s.FilesFailed,
+		"bytes_hashed", s.BytesHashed,
+		"bytes_uploaded", s.BytesUploaded,
+		"throughput", s.Throughput,
+		"eta", s.ETA,
+		"elapsed_time", s.Elapsed,
+	}
+}
+
+func formatTotals(s Snapshot) string {
+	return fmt.Sprintf(
+		"%s | %s/s | ETA %s",
+		formatCounters(s.Counters),
+		formatBytes(int64(s.Throughput)),
+		formatETA(s),
+	)
+}
+
+func formatWorker(w WorkerStatus) string {
+	return fmt.Sprintf("  worker %d: %s | %s", w.ID, formatCounters(w.Counters), w.Current)
+}
+
+func formatCounters(c Counters) string {
+	return fmt.Sprintf(
+		"%d scanned, %d skipped, %d uploaded, %d failed | %s hashed, %s uploaded",
+		c.FilesScanned,
+		c.FilesSkipped,
+		c.FilesUploaded,
+		c.FilesFailed,
+		formatBytes(c.BytesHashed),
+		formatBytes(c.BytesUploaded),
+	)
+}
+
+func formatHook(h HookStatus) string {
+	outcome := "ok"
+	if h.Err != nil {
+		outcome = "failed: " + h.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s (%s)", h.Name, outcome, h.Duration.Round(time.Millisecond))
+}
+
+func formatETA(s Snapshot) string {
+	if s.ETA == 0 {
+		return "--"
+	}
+	return s.ETA.Round(time.Second).String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
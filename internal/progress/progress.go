@@ -0,0 +1,432 @@
+package progress
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+type workerKey struct{}
+
+// WithWorker returns a copy of the provided context identifying the worker
+// processing files within it, so that events can be attributed per worker.
+func WithWorker(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, workerKey{}, id)
+}
+
+// WorkerFromContext returns the ID of the worker associated with the provided
+// context, if any.
+func WorkerFromContext(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(workerKey{}).(int)
+	return id, ok
+}
+
+// Clock defines the interface required to fetch the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Counters contains the running totals of events received by a Tracker. Changed
+// files are those uploaded files with a previously registered version, while
+// unverified uploads are those for which the storage backend returned no
+// checksum.
+type Counters struct {
+	FilesScanned      int64
+	FilesSkipped      int64
+	FilesUploaded     int64
+	FilesChanged      int64
+	FilesFailed       int64
+	UploadsUnverified int64
+	BytesHashed       int64
+	BytesUploaded     int64
+}
+
+// WorkerStatus contains the running totals of events attributed to a single
+// worker, along with the path of the file it is currently processing.
+type WorkerStatus struct {
+	Counters
+	ID      int
+	Current string
+}
+
+// FileFailure contains the path of a file that could not be processed, along
+// with the reason why.
+type FileFailure struct {
+	Path string
+	Err  error
+}
+
+// HookStatus contains the outcome of a hook run during the backup.
+type HookStatus struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// Snapshot captures the state of a Tracker at a particular point in time.
+type Snapshot struct {
+	Counters
+	Elapsed       time.Duration
+	TotalFiles    int64
+	TotalBytes    int64
+	DoneBytes     int64
+	Throughput    float64
+	ETA           time.Duration
+	ExcludedFiles int64
+	ExcludedDirs  int64
+	Workers       []WorkerStatus
+	BusyFiles     []string
+	Failures      []FileFailure
+	Hooks         []HookStatus
+}
+
+// Option is the type used to implement the functional options pattern for the
+// Tracker type.
+type Option func(*Tracker)
+
+// Tracker accumulates progress events emitted during a backup, both in total
+// and per worker, and derives throughput and estimated time remaining from
+// them. It is safe for concurrent use.
+type Tracker struct {
+	mu            sync.Mutex
+	clock         Clock
+	parent        *Tracker
+	prefix        string
+	start         time.Time
+	total         Counters
+	totalFiles    int64
+	totalBytes    int64
+	doneBytes     int64
+	excludedFiles int64
+	excludedDirs  int64
+	sizes         map[string]int64
+	workers       map[int]*WorkerStatus
+	lastTime      time.Time
+	lastBytes     int64
+	maxEntries    int
+	busy          []string
+	failures      []FileFailure
+	hooks         []HookStatus
+}
+
+// New instantiates a new Tracker, with the start of the tracked operation taken
+// as the current time.
+func New(opts ...Option) *Tracker {
+	t := &Tracker{
+		clock:   &util.Clock{},
+		sizes:   make(map[string]int64),
+		workers: make(map[int]*WorkerStatus),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	t.start = t.clock.Now()
+	t.lastTime = t.start
+
+	return t
+}
+
+// WithClock returns an option that sets the clock used to measure elapsed time.
+func WithClock(clock Clock) Option {
+	return func(t *Tracker) {
+		t.clock = clock
+	}
+}
+
+// WithMaxEntries returns an option that limits the number of busy files,
+// failures and hooks a Tracker keeps, discarding the oldest of each once the
+// limit is reached, so that a long-lived tracker does not grow without bound.
+// The counters are unaffected.
+func WithMaxEntries(n int) Option {
+	return func(t *Tracker) {
+		t.maxEntries = n
+	}
+}
+
+// WithParent returns an option that forwards every event recorded by a Tracker
+// to the provided parent, with paths prefixed by the provided directory. This
+// allows the progress of several directories processed at once to be tracked
+// both separately and in total.
+func WithParent(parent *Tracker, prefix string) Option {
+	return func(t *Tracker) {
+		t.parent = parent
+		t.prefix = prefix
+	}
+}
+
+// FileScanned records that a file has been found and queued for processing,
+// adding it to the number and total size of the files to be processed.
+func (t *Tracker) FileScanned(ctx context.Context, path string, size int64) {
+	if t.parent != nil {
+		t.parent.FileScanned(ctx, t.parentPath(path), size)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.FilesScanned++
+	t.totalFiles++
+	t.totalBytes += size
+	t.sizes[path] = size
+}
+
+// PathExcluded records that a file or directory was excluded from the backup.
+// Excluded directories are counted once, regardless of their contents.
+func (t *Tracker) PathExcluded(ctx context.Context, path string, isDir bool) {
+	if t.parent != nil {
+		t.parent.PathExcluded(ctx, t.parentPath(path), isDir)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if isDir {
+		t.excludedDirs++
+	} else {
+		t.excludedFiles++
+	}
+}
+
+// FileStarted records that a worker has started processing a file. Files are
+// counted as scanned by the worker that processes them.
+func (t *Tracker) FileStarted(ctx context.Context, path string) {
+	if t.parent != nil {
+		t.parent.FileStarted(ctx, t.parentPath(path))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if w := t.worker(ctx); w != nil {
+		w.FilesScanned++
+		w.Current = path
+	}
+}
+
+// FileSkipped records that a file was not uploaded, either because it has not
+// changed since it was last uploaded or because it was excluded.
+func (t *Tracker) FileSkipped(ctx context.Context, path string) {
+	if t.parent != nil {
+		t.parent.FileSkipped(ctx, t.parentPath(path))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.FilesSkipped++
+	t.finish(path)
+	if w := t.worker(ctx); w != nil {
+		w.FilesSkipped++
+	}
+}
+
+// FileUploaded records that a file was uploaded and registered, either for the
+// first time or as a change to a previous version.
+func (t *Tracker) FileUploaded(ctx context.Context, path string, changed bool) {
+	if t.parent != nil {
+		t.parent.FileUploaded(ctx, t.parentPath(path), changed)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.FilesUploaded++
+	if changed {
+		t.total.FilesChanged++
+	}
+	t.finish(path)
+	if w := t.worker(ctx); w != nil {
+		w.FilesUploaded++
+		if changed {
+			w.FilesChanged++
+		}
+	}
+}
+
+// FileFailed records that processing a file failed, along with the error that
+// caused it.
+func (t *Tracker) FileFailed(ctx context.Context, path string, err error) {
+	if t.parent != nil {
+		t.parent.FileFailed(ctx, t.parentPath(path), err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.FilesFailed++
+	if t.full(len(t.failures)) {
+		t.failures = t.failures[1:]
+	}
+	t.failures = append(t.failures, FileFailure{Path: path, Err: err})
+	t.finish(path)
+	if w := t.worker(ctx); w != nil {
+		w.FilesFailed++
+	}
+}
+
+// FileBusy records that a file kept changing while it was being uploaded.
+func (t *Tracker) FileBusy(ctx context.Context, path string) {
+	if t.parent != nil {
+		t.parent.FileBusy(ctx, t.parentPath(path))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.full(len(t.busy)) {
+		t.busy = t.busy[1:]
+	}
+	t.busy = append(t.busy, path)
+}
+
+// HookFinished records the outcome of a hook.
+func (t *Tracker) HookFinished(ctx context.Context, name string, duration time.Duration, err error) {
+	if t.parent != nil {
+		t.parent.HookFinished(ctx, name, duration, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.full(len(t.hooks)) {
+		t.hooks = t.hooks[1:]
+	}
+	t.hooks = append(t.hooks, HookStatus{Name: name, Duration: duration, Err: err})
+}
+
+// UploadUnverified records that an upload could not be verified because the
+// storage backend returned no checksum for it.
+func (t *Tracker) UploadUnverified(ctx context.Context) {
+	if t.parent != nil {
+		t.parent.UploadUnverified(ctx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.UploadsUnverified++
+	if w := t.worker(ctx); w != nil {
+		w.UploadsUnverified++
+	}
+}
+
+// BytesHashed records that the provided number of bytes were read in order to
+// compute a checksum.
+func (t *Tracker) BytesHashed(ctx context.Context, n int64) {
+	if t.parent != nil {
+		t.parent.BytesHashed(ctx, n)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.BytesHashed += n
+	if w := t.worker(ctx); w != nil {
+		w.BytesHashed += n
+	}
+}
+
+// BytesUploaded records that the provided number of bytes were sent to the
+// storage backend.
+func (t *Tracker) BytesUploaded(ctx context.Context, n int64) {
+	if t.parent != nil {
+		t.parent.BytesUploaded(ctx, n)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.BytesUploaded += n
+	if w := t.worker(ctx); w != nil {
+		w.BytesUploaded += n
+	}
+}
+
+// Snapshot returns the current state of the tracker. The throughput is the
+// rate at which bytes were uploaded since the previous snapshot, while the
+// estimated time remaining is derived from the average rate at which scanned
+// bytes have been processed since the tracker was created.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+
+	snapshot := Snapshot{
+		Counters:      t.total,
+		Elapsed:       now.Sub(t.start),
+		TotalFiles:    t.totalFiles,
+		TotalBytes:    t.totalBytes,
+		DoneBytes:     t.doneBytes,
+		ExcludedFiles: t.excludedFiles,
+		ExcludedDirs:  t.excludedDirs,
+	}
+
+	if interval := now.Sub(t.lastTime).Seconds(); interval > 0 {
+		snapshot.Throughput = float64(t.total.BytesUploaded-t.lastBytes) / interval
+	}
+	t.lastTime = now
+	t.lastBytes = t.total.BytesUploaded
+
+	if elapsed := snapshot.Elapsed.Seconds(); elapsed > 0 && t.doneBytes > 0 {
+		rate := float64(t.doneBytes) / elapsed
+		remaining := float64(t.totalBytes - t.doneBytes)
+		if remaining > 0 {
+			snapshot.ETA = time.Duration(remaining / rate * float64(time.Second))
+		}
+	}
+
+	snapshot.Workers = make([]WorkerStatus, 0, len(t.workers))
+	for _, w := range t.workers {
+		snapshot.Workers = append(snapshot.Workers, *w)
+	}
+	sort.Slice(snapshot.Workers, func(i, j int) bool {
+		return snapshot.Workers[i].ID < snapshot.Workers[j].ID
+	})
+
+	if len(t.busy) > 0 {
+		snapshot.BusyFiles = append([]string(nil), t.busy...)
+		sort.Strings(snapshot.BusyFiles)
+	}
+	if len(t.failures) > 0 {
+		snapshot.Failures = append([]FileFailure(nil), t.failures...)
+	}
+	if len(t.hooks) > 0 {
+		snapshot.Hooks = append([]HookStatus(nil), t.hooks...)
+	}
+
+	return snapshot
+}
+
+func (t *Tracker) parentPath(path string) string {
+	return filepath.Join(t.prefix, path)
+}
+
+func (t *Tracker) full(n int) bool {
+	return t.maxEntries > 0 && n >= t.maxEntries
+}
+
+func (t *Tracker) finish(path string) {
+	t.doneBytes += t.sizes[path]
+	delete(t.sizes, path)
+}
+
+func (t *Tracker) worker(ctx context.Context) *WorkerStatus {
+	id, ok := WorkerFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	w, ok := t.workers[id]
+	if !ok {
+		w = &WorkerStatus{ID: id}
+		t.workers[id] = w
+	}
+
+	return w
+}
@@ -0,0 +1,281 @@
+package progress_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/progress"
+)
+
+type TrackerTestSuite struct {
+	suite.Suite
+}
+
+func TestTrackerTestSuite(t *testing.T) {
+	suite.Run(t, new(TrackerTestSuite))
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (s *TrackerTestSuite) TestSnapshot() {
+	start := time.Date(2022, 6, 5, 1, 2, 3, 0, time.UTC)
+
+	s.Run("accumulates totals and per worker counters", func() {
+		ctx := context.Background()
+		worker0 := progress.WithWorker(ctx, 0)
+		worker1 := progress.WithWorker(ctx, 1)
+
+		clock := &fakeClock{start}
+		tracker := progress.New(progress.WithClock(clock))
+
+		tracker.FileScanned(ctx, "foo", 100)
+		tracker.FileScanned(ctx, "bar", 100)
+		tracker.FileScanned(ctx, "baz", 200)
+		tracker.FileStarted(worker0, "foo")
+		tracker.BytesHashed(worker0, 100)
+		tracker.BytesUploaded(worker0, 100)
+		tracker.FileUploaded(worker0, "foo", true)
+		tracker.FileStarted(worker1, "bar")
+		tracker.FileSkipped(worker1, "bar")
+		tracker.FileStarted(worker0, "baz")
+
+		clock.now = start.Add(10 * time.Second)
+		snapshot := tracker.Snapshot()
+
+		s.Equal(progress.Counters{
+			FilesScanned:  3,
+			FilesSkipped:  1,
+			FilesUploaded: 1,
+			FilesChanged:  1,
+			BytesHashed:   100,
+			BytesUploaded: 100,
+		}, snapshot.Counters)
+		s.Equal(10*time.Second, snapshot.Elapsed)
+		s.Equal(int64(200), snapshot.DoneBytes)
+		s.Equal(10.0, snapshot.Throughput)
+		s.Equal(10*time.Second, snapshot.ETA)
+		s.Equal([]progress.WorkerStatus{
+			{
+				ID:      0,
+				Current: "baz",
+				Counters: progress.Counters{
+					FilesScanned:  2,
+					FilesUploaded: 1,
+					FilesChanged:  1,
+					BytesHashed:   100,
+					BytesUploaded: 100,
+				},
+			},
+			{
+				ID:       1,
+				Current:  "bar",
+				Counters: progress.Counters{FilesScanned: 1, FilesSkipped: 1},
+			},
+		}, snapshot.Workers)
+	})
+	s.Run("measures throughput since previous snapshot", func() {
+		ctx := context.Background()
+
+		clock := &fakeClock{start}
+		tracker := progress.New(progress.WithClock(clock))
+
+		tracker.BytesUploaded(ctx, 100)
+		clock.now = start.Add(time.Second)
+		tracker.Snapshot()
+
+		tracker.BytesUploaded(ctx, 20)
+		clock.now = start.Add(3 * time.Second)
+		snapshot := tracker.Snapshot()
+
+		s.Equal(10.0, snapshot.Throughput)
+	})
+	s.Run("counts failed files as done", func() {
+		ctx := context.Background()
+
+		clock := &fakeClock{start}
+		tracker := progress.New(progress.WithClock(clock))
+
+		tracker.FileScanned(ctx, "foo", 100)
+		tracker.FileFailed(ctx, "foo", errors.New("oh no"))
+
+		snapshot := tracker.Snapshot()
+
+		s.Equal(int64(1), snapshot.FilesFailed)
+		s.Equal([]progress.FileFailure{{Path: "foo", Err: errors.New("oh no")}}, snapshot.Failures)
+		s.Equal(int64(100), snapshot.DoneBytes)
+		s.Equal(time.Duration(0), snapshot.ETA)
+	})
+	s.Run("counts unverified uploads", func() {
+		ctx := context.Background()
+		worker := progress.WithWorker(ctx, 0)
+
+		tracker := progress.New(progress.WithClock(&fakeClock{start}))
+
+		tracker.UploadUnverified(worker)
+		tracker.UploadUnverified(ctx)
+
+		snapshot := tracker.Snapshot()
+
+		s.Equal(int64(2), snapshot.UploadsUnverified)
+		s.Equal(int64(1), snapshot.Workers[0].UploadsUnverified)
+	})
+	s.Run("lists busy files", func() {
+		ctx := context.Background()
+
+		tracker := progress.New(progress.WithClock(&fakeClock{start}))
+
+		tracker.FileBusy(ctx, "foo")
+		tracker.FileBusy(ctx, "bar")
+
+		snapshot := tracker.Snapshot()
+
+		s.Equal([]string{"bar", "foo"}, snapshot.BusyFiles)
+	})
+	s.Run("keeps most recent entries up to limit", func() {
+		ctx := context.Background()
+
+		tracker := progress.New(progress.WithClock(&fakeClock{start}), progress.WithMaxEntries(2))
+
+		for _, path := range []string{"foo", "bar", "baz"} {
+			tracker.FileScanned(ctx, path, 0)
+			tracker.FileFailed(ctx, path, errors.New("oh no"))
+			tracker.FileBusy(ctx, path)
+			tracker.HookFinished(ctx, path, time.Second, nil)
+		}
+
+		snapshot := tracker.Snapshot()
+
+		s.Equal(int64(3), snapshot.FilesFailed)
+		s.Equal(
+			[]progress.FileFailure{
+				{Path: "bar", Err: errors.New("oh no")},
+				{Path: "baz", Err: errors.New("oh no")},
+			},
+			snapshot.Failures,
+		)
+		s.Equal([]string{"bar", "baz"}, snapshot.BusyFiles)
+		s.Equal(
+			[]progress.HookStatus{
+				{Name: "bar", Duration: time.Second},
+				{Name: "baz", Duration: time.Second},
+			},
+			snapshot.Hooks,
+		)
+	})
+	s.Run("counts excluded paths", func() {
+		ctx := context.Background()
+
+		tracker := progress.New(progress.WithClock(&fakeClock{start}))
+
+		tracker.PathExcluded(ctx, "foo.tmp", false)
+		tracker.PathExcluded(ctx, "bar.tmp", false)
+		tracker.PathExcluded(ctx, "cache", true)
+
+		snapshot := tracker.Snapshot()
+
+		s.Equal(int64(2), snapshot.ExcludedFiles)
+		s.Equal(int64(1), snapshot.ExcludedDirs)
+	})
+	s.Run("forwards events to parent", func() {
+		ctx := context.Background()
+		expectedErr := errors.New("oh no")
+
+		parent := progress.New(progress.WithClock(&fakeClock{start}))
+		first := progress.New(
+			progress.WithClock(&fakeClock{start}),
+			progress.WithParent(parent, "/first"),
+		)
+		second := progress.New(
+			progress.WithClock(&fakeClock{start}),
+			progress.WithParent(parent, "/second"),
+		)
+
+		first.FileScanned(ctx, "foo", 100)
+		second.FileScanned(ctx, "foo", 200)
+		first.FileUploaded(ctx, "foo", false)
+		second.FileFailed(ctx, "foo", expectedErr)
+
+		firstSnapshot := first.Snapshot()
+		secondSnapshot := second.Snapshot()
+		parentSnapshot := parent.Snapshot()
+
+		s.Equal(int64(1), firstSnapshot.FilesUploaded)
+		s.Equal(int64(0), firstSnapshot.FilesFailed)
+		s.Equal(int64(1), secondSnapshot.FilesFailed)
+		s.Equal(int64(300), parentSnapshot.TotalBytes)
+		s.Equal(int64(300), parentSnapshot.DoneBytes)
+		s.Equal(int64(1), parentSnapshot.FilesUploaded)
+		s.Equal(
+			[]progress.FileFailure{{Path: "/second/foo", Err: expectedErr}},
+			parentSnapshot.Failures,
+		)
+	})
+}
+
+func (s *TrackerTestSuite) TestTerminalDisplay() {
+	snapshot := progress.Snapshot{
+		Counters: progress.Counters{FilesScanned: 2, BytesUploaded: 2048},
+		Workers:  []progress.WorkerStatus{{ID: 0, Current: "foo"}},
+	}
+
+	s.Run("redraws lines in place", func() {
+		buf := &bytes.Buffer{}
+		display := progress.NewTerminalDisplay(buf)
+
+		display.Update(snapshot)
+		first := buf.String()
+		buf.Reset()
+		display.Update(snapshot)
+
+		s.Contains(first, "2 scanned")
+		s.Contains(first, "2.0 KiB uploaded")
+		s.Contains(first, "worker 0")
+		s.Contains(first, "foo")
+		s.Equal("\x1b[2A"+first, buf.String())
+	})
+	s.Run("lists busy files in summary", func() {
+		buf := &bytes.Buffer{}
+		display := progress.NewTerminalDisplay(buf)
+
+		snapshot := snapshot
+		snapshot.BusyFiles = []string{"bar.db"}
+		display.Finish(snapshot)
+
+		s.Contains(buf.String(), "Files that changed during upload:\n  bar.db\n")
+	})
+	s.Run("lists hook outcomes in summary", func() {
+		buf := &bytes.Buffer{}
+		display := progress.NewTerminalDisplay(buf)
+
+		snapshot := snapshot
+		snapshot.Hooks = []progress.HookStatus{
+			{Name: "pre", Duration: time.Second},
+			{Name: "post", Duration: 2 * time.Second, Err: errors.New("oh no")},
+		}
+		display.Finish(snapshot)
+
+		s.Contains(buf.String(), "Hooks:\n  pre: ok (1s)\n  post: failed: oh no (2s)\n")
+	})
+	s.Run("counts excluded paths in summary", func() {
+		buf := &bytes.Buffer{}
+		display := progress.NewTerminalDisplay(buf)
+
+		snapshot := snapshot
+		snapshot.ExcludedFiles = 3
+		snapshot.ExcludedDirs = 1
+		display.Finish(snapshot)
+
+		s.Contains(buf.String(), "Excluded 3 files and 1 directories\n")
+	})
+}
@@ -0,0 +1,253 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/mspraggs/hoard/internal/progress"
+)
+
+// Status denotes the overall outcome of a backup run.
+type Status string
+
+const (
+	// StatusSuccess denotes a run in which every directory and file was
+	// processed without error.
+	StatusSuccess Status = "success"
+	// StatusPartialFailure denotes a run in which some, but not all, of the
+	// directories or files could not be processed.
+	StatusPartialFailure Status = "partial_failure"
+	// StatusFailure denotes a run in which nothing could be processed.
+	StatusFailure Status = "failure"
+	// StatusInterrupted denotes a run that was stopped before it finished.
+	StatusInterrupted Status = "interrupted"
+)
+
+// Failure contains the path of a file that could not be processed, along with
+// the reason why.
+type Failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Hook contains the outcome of a hook run during the backup. The exit code is -1
+// if the hook's command did not exit by itself, for example because it could
+// not be started or was killed after timing out.
+type Hook struct {
+	Name            string  `json:"name"`
+	ExitCode        int     `json:"exit_code"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// NewHooks builds the outcomes of the provided hooks, as recorded by the
+// progress tracker.
+func NewHooks(statuses []progress.HookStatus) []Hook {
+	var hooks []Hook
+	for _, status := range statuses {
+		h := Hook{Name: status.Name, DurationSeconds: status.Duration.Seconds()}
+		if status.Err != nil {
+			h.ExitCode = -1
+			h.Error = status.Err.Error()
+			var exitErr *exec.ExitError
+			if errors.As(status.Err, &exitErr) {
+				h.ExitCode = exitErr.ExitCode()
+			}
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+// Directory summarises the outcome of backing up a single directory. Skipped
+// files are those excluded from the backup, while unchanged files were
+// processed but did not need uploading. Unverified uploads are those for which
+// the storage backend returned no checksum to compare, while busy files are
+// those that kept changing while they were being uploaded. A directory that
+// could not be backed up at all carries the error that prevented it.
+type Directory struct {
+	Path          string    `json:"path"`
+	Bucket        string    `json:"bucket"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	New           int64     `json:"new"`
+	Changed       int64     `json:"changed"`
+	Unchanged     int64     `json:"unchanged"`
+	Skipped       int64     `json:"skipped"`
+	Failed        int64     `json:"failed"`
+	Unverified    int64     `json:"unverified"`
+	BytesHashed   int64     `json:"bytes_hashed"`
+	BytesUploaded int64     `json:"bytes_uploaded"`
+	Failures      []Failure `json:"failures,omitempty"`
+	BusyFiles     []string  `json:"busy_files,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// NewDirectory builds the summary of a directory from snapshots of the
+// progress tracker taken immediately before and after it was backed up.
+func NewDirectory(path, bucket string, before, after progress.Snapshot) *Directory {
+	uploaded := after.FilesUploaded - before.FilesUploaded
+	changed := after.FilesChanged - before.FilesChanged
+
+	d := &Directory{
+		Path:          path,
+		Bucket:        bucket,
+		New:           uploaded - changed,
+		Changed:       changed,
+		Unchanged:     after.FilesSkipped - before.FilesSkipped,
+		Skipped:       after.ExcludedFiles - before.ExcludedFiles,
+		Failed:        after.FilesFailed - before.FilesFailed,
+		Unverified:    after.UploadsUnverified - before.UploadsUnverified,
+		BytesHashed:   after.BytesHashed - before.BytesHashed,
+		BytesUploaded: after.BytesUploaded - before.BytesUploaded,
+	}
+	for _, f := range after.Failures[len(before.Failures):] {
+		d.Failures = append(d.Failures, Failure{Path: f.Path, Error: f.Err.Error()})
+	}
+	// Busy files are listed in order of path rather than as they were found,
+	// so those found before are removed one at a time.
+	found := make(map[string]int)
+	for _, path := range before.BusyFiles {
+		found[path]++
+	}
+	for _, path := range after.BusyFiles {
+		if found[path] > 0 {
+			found[path]--
+			continue
+		}
+		d.BusyFiles = append(d.BusyFiles, path)
+	}
+
+	return d
+}
+
+// Duration returns how long it took to back up the directory.
+func (d *Directory) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Report summarises the outcome of a backup run.
+type Report struct {
+	Run         int64        `json:"run"`
+	Status      Status       `json:"status"`
+	Start       time.Time    `json:"start"`
+	End         time.Time    `json:"end"`
+	Error       string       `json:"error,omitempty"`
+	Interrupted bool         `json:"-"`
+	Hooks       []Hook       `json:"hooks,omitempty"`
+	Directories []*Directory `json:"directories"`
+}
+
+// Finish records the end of the run and determines its overall status. A run
+// that was interrupted is reported as such, regardless of what it achieved
+// before it was stopped. Otherwise, a run fails outright if it was aborted, or
+// if no directory or file was processed successfully, and fails partially if
+// anything else went wrong.
+func (r *Report) Finish(end time.Time) {
+	r.End = end
+
+	var failed, succeeded bool
+	for _, d := range r.Directories {
+		if d.Error != "" || d.Failed > 0 {
+			failed = true
+		}
+		if d.Error == "" && (d.Failed == 0 || d.New+d.Changed+d.Unchanged > 0) {
+			succeeded = true
+		}
+	}
+
+	switch {
+	case r.Interrupted:
+		r.Status = StatusInterrupted
+	case r.Error != "" || (failed && !succeeded):
+		r.Status = StatusFailure
+	case failed:
+		r.Status = StatusPartialFailure
+	default:
+		r.Status = StatusSuccess
+	}
+}
+
+// WriteJSON writes the report to the provided writer as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteSummary writes a human-readable summary of the report to the provided
+// writer, listing the outcome of every hook, every file that could not be
+// processed and every file that changed while it was being uploaded.
+func (r *Report) WriteSummary(w io.Writer) error {
+	_, err := fmt.Fprintf(
+		w, "Run %d finished with status %s in %s\n",
+		r.Run, r.Status, r.End.Sub(r.Start).Round(time.Second),
+	)
+	if err != nil {
+		return err
+	}
+	if r.Error != "" {
+		if _, err := fmt.Fprintf(w, "  error: %s\n", r.Error); err != nil {
+			return err
+		}
+	}
+	for _, h := range r.Hooks {
+		_, err := fmt.Fprintf(
+			w, "  hook %s exited with status %d in %s",
+			h.Name, h.ExitCode,
+			time.Duration(h.DurationSeconds*float64(time.Second)).Round(time.Millisecond),
+		)
+		if err != nil {
+			return err
+		}
+		if h.Error != "" {
+			if _, err := fmt.Fprintf(w, ": %s", h.Error); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range r.Directories {
+		_, err := fmt.Fprintf(
+			w, "%s: %d new, %d changed, %d unchanged, %d skipped, %d failed | "+
+				"%d bytes uploaded in %s\n",
+			d.Path, d.New, d.Changed, d.Unchanged, d.Skipped, d.Failed,
+			d.BytesUploaded, d.Duration().Round(time.Second),
+		)
+		if err != nil {
+			return err
+		}
+		if d.Error != "" {
+			if _, err := fmt.Fprintf(w, "  error: %s\n", d.Error); err != nil {
+				return err
+			}
+		}
+		if d.Unverified > 0 {
+			_, err := fmt.Fprintf(
+				w, "  %d uploads unverified: no checksum returned by storage\n", d.Unverified,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		for _, f := range d.Failures {
+			if _, err := fmt.Fprintf(w, "  failed %s: %s\n", f.Path, f.Error); err != nil {
+				return err
+			}
+		}
+		for _, path := range d.BusyFiles {
+			if _, err := fmt.Fprintf(w, "  busy %s: changed during upload\n", path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
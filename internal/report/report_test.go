@@ -0,0 +1,230 @@
+package report_test
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/progress"
+	"github.com/mspraggs/hoard/internal/report"
+)
+
+type ReportTestSuite struct {
+	suite.Suite
+}
+
+func TestReportTestSuite(t *testing.T) {
+	suite.Run(t, new(ReportTestSuite))
+}
+
+func (s *ReportTestSuite) TestNewDirectory() {
+	before := progress.Snapshot{
+		Counters: progress.Counters{
+			FilesSkipped:  1,
+			FilesUploaded: 2,
+			FilesChanged:  1,
+			FilesFailed:   1,
+			BytesUploaded: 100,
+		},
+		ExcludedFiles: 1,
+		BusyFiles:     []string{"old"},
+		Failures:      []progress.FileFailure{{Path: "old", Err: errors.New("old error")}},
+	}
+	after := progress.Snapshot{
+		Counters: progress.Counters{
+			FilesSkipped:      4,
+			FilesUploaded:     5,
+			FilesChanged:      2,
+			FilesFailed:       2,
+			UploadsUnverified: 1,
+			BytesHashed:       50,
+			BytesUploaded:     300,
+		},
+		ExcludedFiles: 3,
+		BusyFiles:     []string{"bar", "old", "old"},
+		Failures: []progress.FileFailure{
+			{Path: "old", Err: errors.New("old error")},
+			{Path: "foo", Err: errors.New("oh no")},
+		},
+	}
+
+	dir := report.NewDirectory("/some/path", "bucket", before, after)
+
+	s.Equal(&report.Directory{
+		Path:          "/some/path",
+		Bucket:        "bucket",
+		New:           2,
+		Changed:       1,
+		Unchanged:     3,
+		Skipped:       2,
+		Failed:        1,
+		Unverified:    1,
+		BytesHashed:   50,
+		BytesUploaded: 200,
+		Failures:      []report.Failure{{Path: "foo", Error: "oh no"}},
+		BusyFiles:     []string{"bar", "old"},
+	}, dir)
+}
+
+func (s *ReportTestSuite) TestNewHooks() {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	s.Require().Error(exitErr)
+
+	hooks := report.NewHooks([]progress.HookStatus{
+		{Name: "ok", Duration: 1500 * time.Millisecond},
+		{Name: "exited", Duration: time.Second, Err: exitErr},
+		{Name: "timed out", Duration: 2 * time.Second, Err: errors.New("timed out after 2s")},
+	})
+
+	s.Equal([]report.Hook{
+		{Name: "ok", ExitCode: 0, DurationSeconds: 1.5},
+		{Name: "exited", ExitCode: 3, DurationSeconds: 1, Error: "exit status 3"},
+		{Name: "timed out", ExitCode: -1, DurationSeconds: 2, Error: "timed out after 2s"},
+	}, hooks)
+}
+
+func (s *ReportTestSuite) TestFinish() {
+	end := time.Unix(10, 0)
+
+	cases := []struct {
+		name     string
+		report   report.Report
+		expected report.Status
+	}{
+		{
+			name: "succeeds without failures",
+			report: report.Report{
+				Directories: []*report.Directory{{New: 1}, {Unchanged: 1}},
+			},
+			expected: report.StatusSuccess,
+		},
+		{
+			name: "partially fails with failed files",
+			report: report.Report{
+				Directories: []*report.Directory{{New: 1, Failed: 1}},
+			},
+			expected: report.StatusPartialFailure,
+		},
+		{
+			name: "partially fails with failed directory",
+			report: report.Report{
+				Directories: []*report.Directory{{New: 1}, {Error: "oh no"}},
+			},
+			expected: report.StatusPartialFailure,
+		},
+		{
+			name: "fails when every file fails",
+			report: report.Report{
+				Directories: []*report.Directory{{Failed: 2}, {Error: "oh no"}},
+			},
+			expected: report.StatusFailure,
+		},
+		{
+			name:     "fails when run is aborted",
+			report:   report.Report{Error: "oh no"},
+			expected: report.StatusFailure,
+		},
+		{
+			name: "reports interruption",
+			report: report.Report{
+				Interrupted: true,
+				Directories: []*report.Directory{{New: 1, Failed: 1}},
+			},
+			expected: report.StatusInterrupted,
+		},
+	}
+
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			rep := c.report
+
+			rep.Finish(end)
+
+			s.Equal(c.expected, rep.Status)
+			s.Equal(end, rep.End)
+		})
+	}
+}
+
+func (s *ReportTestSuite) TestWrite() {
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	rep := &report.Report{
+		Run:    3,
+		Status: report.StatusPartialFailure,
+		Start:  start,
+		End:    start.Add(time.Minute),
+		Hooks: []report.Hook{
+			{Name: "pre", DurationSeconds: 1.5},
+			{Name: "post", ExitCode: 1, DurationSeconds: 2, Error: "exit status 1"},
+		},
+		Directories: []*report.Directory{
+			{
+				Path:          "/some/path",
+				Start:         start,
+				End:           start.Add(30 * time.Second),
+				New:           1,
+				Failed:        1,
+				Unverified:    2,
+				BytesUploaded: 1024,
+				Failures:      []report.Failure{{Path: "foo", Error: "oh no"}},
+				BusyFiles:     []string{"bar"},
+			},
+		},
+	}
+
+	s.Run("writes summary", func() {
+		buf := &bytes.Buffer{}
+
+		err := rep.WriteSummary(buf)
+
+		s.Require().NoError(err)
+		s.Equal(
+			"Run 3 finished with status partial_failure in 1m0s\n"+
+				"  hook pre exited with status 0 in 1.5s\n"+
+				"  hook post exited with status 1 in 2s: exit status 1\n"+
+				"/some/path: 1 new, 0 changed, 0 unchanged, 0 skipped, 1 failed | "+
+				"1024 bytes uploaded in 30s\n"+
+				"  2 uploads unverified: no checksum returned by storage\n"+
+				"  failed foo: oh no\n"+
+				"  busy bar: changed during upload\n",
+			buf.String(),
+		)
+	})
+	s.Run("writes JSON", func() {
+		buf := &bytes.Buffer{}
+
+		err := rep.WriteJSON(buf)
+
+		s.Require().NoError(err)
+		s.JSONEq(`{
+			"run": 3,
+			"status": "partial_failure",
+			"start": "2022-06-01T00:00:00Z",
+			"end": "2022-06-01T00:01:00Z",
+			"hooks": [
+				{"name": "pre", "exit_code": 0, "duration_seconds": 1.5},
+				{"name": "post", "exit_code": 1, "duration_seconds": 2, "error": "exit status 1"}
+			],
+			"directories": [{
+				"path": "/some/path",
+				"bucket": "",
+				"start": "2022-06-01T00:00:00Z",
+				"end": "2022-06-01T00:00:30Z",
+				"new": 1,
+				"changed": 0,
+				"unchanged": 0,
+				"skipped": 0,
+				"failed": 1,
+				"unverified": 2,
+				"bytes_hashed": 0,
+				"bytes_uploaded": 1024,
+				"failures": [{"path": "foo", "error": "oh no"}],
+				"busy_files": ["bar"]
+			}]
+		}`, buf.String())
+	})
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// maxCopySize is the size of the largest object the storage backend can copy
+// in a single request.
+const maxCopySize = 5 * 1024 * 1024 * 1024
+
+// Copy copies the object holding the source file to the key of the destination
+// file within the store's bucket, without uploading the contents of the file
+// again. The storage class and object lock settings are chosen for the
+// destination file as though it were being uploaded, except that the copy is
+// retained for at least as long as the source, and kept under any legal hold
+// placed on it, if object locking is enabled. The extents of a sparse source
+// are carried over to the destination, as is the checksum of its contents if
+// the destination has none of its own.
+func (s *Store) Copy(ctx context.Context, src, dst *processor.File) (*processor.File, error) {
+	info, err := fs.Stat(s.fs, dst.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxCopySize {
+		return nil, fmt.Errorf("unable to copy object larger than %d bytes", maxCopySize)
+	}
+
+	sc, _ := s.selectStorage(dst.LocalPath, info)
+
+	source := (&url.URL{Path: src.Bucket + "/" + src.Key}).EscapedPath()
+	if src.Version != "" {
+		source += "?versionId=" + url.QueryEscape(src.Version)
+	}
+
+	storeFile := &File{Key: dst.Key, Bucket: s.bucket, StorageClass: sc}
+	s.applyObjectLock(storeFile)
+	if s.lock != nil {
+		if storeFile.ObjectLockMode != "" && src.RetainUntil.After(storeFile.RetainUntil) {
+			storeFile.RetainUntil = src.RetainUntil
+		}
+		storeFile.LegalHold = storeFile.LegalHold || src.LegalHold
+	}
+
+	output, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:       &s.bucket,
+		Key:          &dst.Key,
+		CopySource:   &source,
+		StorageClass: sc,
+
+		ChecksumAlgorithm: s.csAlg,
+
+		ObjectLockMode:            storeFile.ObjectLockMode,
+		ObjectLockRetainUntilDate: storeFile.retainUntilInput(),
+		ObjectLockLegalHoldStatus: storeFile.legalHoldInput(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy object: %w", err)
+	}
+
+	dst.Bucket = s.bucket
+	dst.StorageClass = string(sc)
+	dst.Version = aws.ToString(output.VersionId)
+	if output.CopyObjectResult != nil {
+		dst.ETag = aws.ToString(output.CopyObjectResult.ETag)
+	}
+	dst.RetainUntil = storeFile.RetainUntil
+	dst.LegalHold = storeFile.LegalHold
+	dst.Extents = src.Extents
+	if dst.Checksum == nil {
+		dst.Checksum = src.Checksum
+		dst.ChecksumAlgorithm = src.ChecksumAlgorithm
+	}
+
+	return dst, nil
+}
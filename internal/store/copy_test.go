@@ -0,0 +1,155 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+func (s *StoreTestSuite) TestCopy() {
+	bucket := "some-bucket"
+	path := "new/path"
+	eTag := "some-etag"
+	version := "new-version"
+
+	fs, err := newMemFS(map[string][]byte{path: {0, 1, 2, 3}})
+	s.Require().NoError(err)
+
+	src := &processor.File{
+		Key:       "old/some key",
+		LocalPath: "old/path",
+		Bucket:    "old-bucket",
+		Version:   "old version",
+	}
+
+	s.Run("copies object to new key", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		dst := &processor.File{Key: "new-key", LocalPath: path}
+		expectedFile := &processor.File{
+			Key:          "new-key",
+			LocalPath:    path,
+			Bucket:       bucket,
+			StorageClass: string(types.StorageClassStandardIa),
+			ETag:         eTag,
+			Version:      version,
+		}
+
+		s.mockClient.EXPECT().
+			CopyObject(ctx, gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.CopyObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.CopyObjectOutput, error) {
+				s.Equal(bucket, *input.Bucket)
+				s.Equal("new-key", *input.Key)
+				s.Equal("old-bucket/old/some%20key?versionId=old+version", *input.CopySource)
+				s.Equal(types.StorageClassStandardIa, input.StorageClass)
+				return &s3.CopyObjectOutput{
+					CopyObjectResult: &types.CopyObjectResult{ETag: &eTag},
+					VersionId:        &version,
+				}, nil
+			})
+
+		store := store.New(
+			s.mockClient, fs, bucket,
+			store.WithStorageClass(types.StorageClassStandardIa),
+		)
+
+		file, err := store.Copy(ctx, src, dst)
+
+		s.Require().NoError(err)
+		s.Equal(expectedFile, file)
+	})
+	s.Run("carries extents and checksum of source", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		sparse := *src
+		sparse.Extents = []processor.Extent{{Offset: 2, Length: 2}}
+		sparse.Checksum = processor.Checksum{1, 2, 3, 4}
+		sparse.ChecksumAlgorithm = "CRC32"
+		dst := &processor.File{Key: "new-key", LocalPath: path}
+
+		s.mockClient.EXPECT().
+			CopyObject(ctx, gomock.Any()).
+			Return(&s3.CopyObjectOutput{VersionId: &version}, nil)
+
+		store := store.New(s.mockClient, fs, bucket)
+
+		file, err := store.Copy(ctx, &sparse, dst)
+
+		s.Require().NoError(err)
+		s.Equal(sparse.Extents, file.Extents)
+		s.Equal(sparse.Checksum, file.Checksum)
+		s.Equal(sparse.ChecksumAlgorithm, file.ChecksumAlgorithm)
+	})
+	s.Run("retains copy for at least as long as source", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		now := time.Unix(1000, 0)
+		retainUntil := now.Add(48 * time.Hour).UTC()
+		locked := *src
+		locked.RetainUntil = retainUntil
+		locked.LegalHold = true
+		dst := &processor.File{Key: "new-key", LocalPath: path}
+
+		s.mockClient.EXPECT().
+			CopyObject(ctx, gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.CopyObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.CopyObjectOutput, error) {
+				s.Equal(types.ObjectLockModeGovernance, input.ObjectLockMode)
+				s.Equal(&retainUntil, input.ObjectLockRetainUntilDate)
+				s.Equal(types.ObjectLockLegalHoldStatusOn, input.ObjectLockLegalHoldStatus)
+				return &s3.CopyObjectOutput{VersionId: &version}, nil
+			})
+
+		store := store.New(
+			s.mockClient, fs, bucket,
+			store.WithObjectLock(store.ObjectLock{
+				Mode:      types.ObjectLockModeGovernance,
+				Retention: 24 * time.Hour,
+			}),
+			store.WithClock(fakeClock(func() time.Time { return now })),
+		)
+
+		file, err := store.Copy(ctx, &locked, dst)
+
+		s.Require().NoError(err)
+		s.Equal(retainUntil, file.RetainUntil)
+		s.True(file.LegalHold)
+	})
+	s.Run("handles error from client", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		expectedErr := errors.New("oh no")
+		dst := &processor.File{Key: "new-key", LocalPath: path}
+
+		s.mockClient.EXPECT().CopyObject(ctx, gomock.Any()).Return(nil, expectedErr)
+
+		store := store.New(s.mockClient, fs, bucket)
+
+		file, err := store.Copy(ctx, src, dst)
+
+		s.ErrorIs(err, expectedErr)
+		s.Nil(file)
+	})
+	s.Run("refuses to copy packed file", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		dst := &processor.File{Key: "new-key", LocalPath: path}
+		packed := &processor.File{Key: "pack-key", PackID: "some-pack"}
+
+		packer := store.NewPacker(store.New(s.mockClient, fs, bucket), nil)
+
+		file, err := packer.Copy(ctx, packed, dst)
+
+		s.Error(err)
+		s.Nil(file)
+	})
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/mspraggs/hoard/internal/checksum"
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// Download writes the stored contents of the provided file to the provided
+// writer. The recorded object version is fetched, so the contents are those of
+// the file when it was uploaded. Files stored in a pack are read from their
+// range within the pack object. The extents of a sparse file are written at
+// their offsets, which requires the writer to be a SparseWriter, and the file
+// is then extended to its full size. If the file has a recorded checksum, the
+// downloaded contents are checked against it, and an error wrapping
+// ErrChecksumMismatch is returned if they differ.
+func (s *Store) Download(ctx context.Context, file *processor.File, w io.Writer) error {
+	var h hash.Hash
+	if len(file.Checksum) > 0 {
+		var err error
+		if h, err = checksum.Algorithm(file.ChecksumAlgorithm).New(); err != nil {
+			return err
+		}
+	}
+
+	var sparse SparseWriter
+	var sw *sparseWriter
+	switch {
+	case file.Extents != nil:
+		var ok bool
+		if sparse, ok = w.(SparseWriter); !ok {
+			return fmt.Errorf("unable to restore sparse file %q to %T", file.LocalPath, w)
+		}
+		sw = &sparseWriter{w: sparse, extents: file.Extents, hash: h}
+		w = sw
+	case h != nil:
+		w = io.MultiWriter(w, h)
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: &file.Bucket,
+		Key:    &file.Key,
+	}
+	if file.Version != "" {
+		input.VersionId = &file.Version
+	}
+	if file.PackID != "" {
+		if file.PackLength == 0 {
+			return verifyDownload(file, h)
+		}
+		byteRange := fmt.Sprintf(
+			"bytes=%d-%d", file.PackOffset, file.PackOffset+file.PackLength-1,
+		)
+		input.Range = &byteRange
+	}
+
+	output, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("unable to download object: %w", err)
+	}
+	defer output.Body.Close()
+
+	if _, err := io.Copy(w, output.Body); err != nil {
+		return fmt.Errorf("unable to read object: %w", err)
+	}
+
+	if sparse != nil {
+		sw.hashZeros(file.Size)
+		if err := sparse.Truncate(file.Size); err != nil {
+			return err
+		}
+	}
+
+	return verifyDownload(file, h)
+}
+
+// verifyDownload compares the checksum of the downloaded contents of a file,
+// written to the provided hash, with the checksum recorded for the file.
+func verifyDownload(file *processor.File, h hash.Hash) error {
+	if h == nil {
+		return nil
+	}
+	if sum := h.Sum(nil); !bytes.Equal(file.Checksum, sum) {
+		return fmt.Errorf(
+			"%w: downloaded contents of %s differ from recorded checksum",
+			hoarderrors.ErrChecksumMismatch, file.LocalPath,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+func (s *StoreTestSuite) TestDownload() {
+	bucket := "some-bucket"
+	body := []byte{0, 1, 2, 3}
+
+	s.Run("downloads object version", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		file := &processor.File{Key: "some-key", Bucket: bucket, Version: "some-version"}
+
+		s.mockClient.EXPECT().
+			GetObject(ctx, gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.GetObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.GetObjectOutput, error) {
+				s.Equal(bucket, *input.Bucket)
+				s.Equal("some-key", *input.Key)
+				s.Equal("some-version", *input.VersionId)
+				s.Nil(input.Range)
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+			})
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		buf := &bytes.Buffer{}
+		err := store.Download(ctx, file, buf)
+
+		s.Require().NoError(err)
+		s.Equal(body, buf.Bytes())
+	})
+	s.Run("downloads range of pack", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		file := &processor.File{
+			Key:        "pack-key",
+			Bucket:     bucket,
+			PackID:     "some-pack",
+			PackOffset: 10,
+			PackLength: 4,
+		}
+
+		s.mockClient.EXPECT().
+			GetObject(ctx, gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.GetObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.GetObjectOutput, error) {
+				s.Equal("pack-key", *input.Key)
+				s.Nil(input.VersionId)
+				s.Equal("bytes=10-13", *input.Range)
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+			})
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		buf := &bytes.Buffer{}
+		err := store.Download(ctx, file, buf)
+
+		s.Require().NoError(err)
+		s.Equal(body, buf.Bytes())
+	})
+	s.Run("verifies downloaded contents against recorded checksum", func() {
+		sum := make(processor.Checksum, 4)
+		binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(body))
+
+		testCases := []struct {
+			name        string
+			body        []byte
+			expectedErr error
+		}{
+			{"with matching contents", body, nil},
+			{"with corrupt contents", []byte{0, 1, 2, 4}, hoarderrors.ErrChecksumMismatch},
+		}
+
+		for _, tc := range testCases {
+			s.Run(tc.name, func() {
+				ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+				file := &processor.File{
+					Key:      "some-key",
+					Bucket:   bucket,
+					Checksum: sum,
+
+					ChecksumAlgorithm: "CRC32",
+				}
+
+				s.mockClient.EXPECT().
+					GetObject(ctx, gomock.Any()).
+					Return(&s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(tc.body))}, nil)
+
+				store := store.New(s.mockClient, nil, bucket)
+
+				err := store.Download(ctx, file, &bytes.Buffer{})
+
+				if tc.expectedErr != nil {
+					s.ErrorIs(err, tc.expectedErr)
+				} else {
+					s.NoError(err)
+				}
+			})
+		}
+	})
+	s.Run("handles error from client", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		expectedErr := errors.New("oh no")
+		file := &processor.File{Key: "some-key", Bucket: bucket}
+
+		s.mockClient.EXPECT().GetObject(ctx, gomock.Any()).Return(nil, expectedErr)
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		err := store.Download(ctx, file, &bytes.Buffer{})
+
+		s.ErrorIs(err, expectedErr)
+	})
+}
@@ -0,0 +1,403 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+const (
+	defaultPackThreshold  = 1024 * 1024
+	defaultPackTargetSize = 64 * 1024 * 1024
+	defaultPackMaxDelay   = 5 * time.Second
+
+	packKeyPrefix = "packs/"
+
+	// MetadataPackIndexOffset is the object metadata key holding the byte
+	// offset of the index within a pack object.
+	MetadataPackIndexOffset = "hoard-index-offset"
+	// MetadataPackIndexLength is the object metadata key holding the length in
+	// bytes of the index within a pack object.
+	MetadataPackIndexLength = "hoard-index-length"
+)
+
+// PackIDGenerator defines the interface required to generate a unique ID for a
+// pack object.
+type PackIDGenerator interface {
+	GenerateID() string
+}
+
+// PackEntry describes the location of a single file within a pack object.
+type PackEntry struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// Activity is the interface required to find out how many callers are able to
+// add files to a pack, and to be told when that number falls.
+type Activity interface {
+	Active() int
+	Watch(fn func(active int))
+}
+
+// PackerOption defines the interface for configuring options on a Packer
+// instance.
+type PackerOption func(*Packer)
+
+// Packer encapsulates the logic required to bundle small files into larger
+// pack objects before storing them in a storage bucket. Each pack object
+// consists of the contents of its files laid end-to-end, followed by a JSON
+// index describing the offset and length of each file. Files at or above the
+// packing threshold are passed straight through to the underlying store. A pack
+// holds files that may match different storage rules, so pack objects ignore
+// the store's rules and always use its default storage class and chunk size.
+type Packer struct {
+	store      *Store
+	idGen      PackIDGenerator
+	threshold  int64
+	targetSize int64
+	maxDelay   time.Duration
+	activity   Activity
+	keyPrefix  string
+	log        *zap.SugaredLogger
+	mu         sync.Mutex
+	current    *pack
+}
+
+type pack struct {
+	id          string
+	key         string
+	buf         bytes.Buffer
+	entries     []PackEntry
+	timer       *time.Timer
+	once        sync.Once
+	done        chan struct{}
+	eTag        string
+	version     string
+	retainUntil time.Time
+	legalHold   bool
+	err         error
+}
+
+// NewPacker instantiates a new Packer that uploads pack objects, and any files
+// too large to pack, using the provided store.
+func NewPacker(store *Store, idGen PackIDGenerator, opts ...PackerOption) *Packer {
+	p := &Packer{
+		store:      store,
+		idGen:      idGen,
+		threshold:  defaultPackThreshold,
+		targetSize: defaultPackTargetSize,
+		maxDelay:   defaultPackMaxDelay,
+		log:        util.MustNewLogger(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.activity != nil {
+		p.activity.Watch(p.flushIfWaiting)
+	}
+
+	return p
+}
+
+// WithPackThreshold returns a PackerOption that sets the size below which a
+// file is added to a pack rather than uploaded as its own object.
+func WithPackThreshold(threshold int64) PackerOption {
+	return func(p *Packer) {
+		p.threshold = threshold
+	}
+}
+
+// WithPackTargetSize returns a PackerOption that sets the size at which a pack
+// is considered full and is uploaded.
+func WithPackTargetSize(size int64) PackerOption {
+	return func(p *Packer) {
+		p.targetSize = size
+	}
+}
+
+// WithPackMaxDelay returns a PackerOption that sets the maximum time a pack
+// may remain open after its first file is added before it is uploaded,
+// regardless of its size.
+func WithPackMaxDelay(delay time.Duration) PackerOption {
+	return func(p *Packer) {
+		p.maxDelay = delay
+	}
+}
+
+// WithPackActivity returns a PackerOption that causes a pack to be uploaded as
+// soon as every active caller of the provided Activity is waiting for it. Each
+// caller blocks until its pack is uploaded, so once every caller still able to
+// add a file is waiting, the pack is uploaded rather than left open for the
+// maximum delay.
+func WithPackActivity(activity Activity) PackerOption {
+	return func(p *Packer) {
+		p.activity = activity
+	}
+}
+
+// WithPackKeyPrefix returns a PackerOption that sets a prefix prepended to the
+// key of every pack object.
+func WithPackKeyPrefix(prefix string) PackerOption {
+	return func(p *Packer) {
+		p.keyPrefix = prefix
+	}
+}
+
+// Upload stores the contents of the provided file in the storage backend. Small
+// files are appended to the currently open pack, and the call blocks until
+// that pack has been uploaded, which happens once it reaches the target size,
+// once every active caller is waiting for it or once the maximum delay has
+// passed, whichever comes first. The returned file records the ID of the pack
+// along with the offset and length of the file's contents within it.
+func (p *Packer) Upload(ctx context.Context, file *processor.File) (*processor.File, error) {
+	f, err := p.store.fs.Open(file.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.Size() >= p.threshold {
+		f.Close()
+		return p.store.Upload(ctx, file)
+	}
+
+	body, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if file.Hash != nil {
+		file.Hash.Reset()
+		file.Hash.Write(body)
+	}
+	contents, err := contentHash(file)
+	if err != nil {
+		return nil, err
+	}
+	if contents != nil {
+		contents.Write(body)
+		if err := checkContents(file, contents.Sum(nil)); err != nil {
+			return nil, err
+		}
+	}
+
+	pk, entry := p.add(file.LocalPath, body)
+
+	select {
+	case <-pk.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if pk.err != nil {
+		return nil, pk.err
+	}
+
+	file.Key = pk.key
+	file.Bucket = p.store.bucket
+	file.ETag = pk.eTag
+	file.Version = pk.version
+	file.StorageClass = string(p.store.sc)
+	file.RetainUntil = pk.retainUntil
+	file.LegalHold = pk.legalHold
+	file.PackID = pk.id
+	file.PackOffset = entry.Offset
+	file.PackLength = entry.Length
+
+	return file, nil
+}
+
+// Delete removes the uploaded version of the provided file from the storage
+// backend. Files stored in a pack share their object with other files, so they
+// cannot be deleted and are left in place.
+func (p *Packer) Delete(ctx context.Context, file *processor.File) error {
+	if file.PackID != "" {
+		return errors.New("unable to delete file stored in pack")
+	}
+	return p.store.Delete(ctx, file)
+}
+
+func (p *Packer) add(path string, body []byte) (*pack, PackEntry) {
+	p.mu.Lock()
+
+	pk := p.current
+	if pk == nil {
+		pk = p.newPack()
+		p.current = pk
+	}
+
+	entry := PackEntry{
+		Path:   path,
+		Offset: int64(pk.buf.Len()),
+		Length: int64(len(body)),
+	}
+	pk.buf.Write(body)
+	pk.entries = append(pk.entries, entry)
+
+	full := int64(pk.buf.Len()) >= p.targetSize ||
+		(p.activity != nil && len(pk.entries) >= p.activity.Active())
+	if full {
+		p.current = nil
+	}
+
+	p.mu.Unlock()
+
+	if full {
+		p.flush(pk)
+	}
+
+	return pk, entry
+}
+
+// flushIfWaiting uploads the open pack if it holds a file from each of the
+// provided number of active callers, all of which are therefore waiting for it.
+func (p *Packer) flushIfWaiting(active int) {
+	p.mu.Lock()
+	pk := p.current
+	if pk == nil || len(pk.entries) < active {
+		p.mu.Unlock()
+		return
+	}
+	p.current = nil
+	p.mu.Unlock()
+
+	go p.flush(pk)
+}
+
+func (p *Packer) newPack() *pack {
+	id := p.idGen.GenerateID()
+	pk := &pack{
+		id:   id,
+		key:  p.keyPrefix + packKeyPrefix + id,
+		done: make(chan struct{}),
+	}
+	pk.timer = time.AfterFunc(p.maxDelay, func() {
+		p.seal(pk)
+		p.flush(pk)
+	})
+
+	return pk
+}
+
+func (p *Packer) seal(pk *pack) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current == pk {
+		p.current = nil
+	}
+}
+
+func (p *Packer) flush(pk *pack) {
+	pk.once.Do(func() {
+		defer close(pk.done)
+
+		pk.timer.Stop()
+		pk.eTag, pk.version, pk.err = p.upload(pk)
+		pk.buf = bytes.Buffer{}
+	})
+}
+
+func (p *Packer) upload(pk *pack) (string, string, error) {
+	index, err := json.Marshal(pk.entries)
+	if err != nil {
+		return "", "", err
+	}
+
+	indexOffset := pk.buf.Len()
+	pk.buf.Write(index)
+	body := pk.buf.Bytes()
+
+	p.log.Infow(
+		"Uploading pack",
+		"pack_id", pk.id,
+		"num_files", len(pk.entries),
+		"size", pk.buf.Len(),
+	)
+
+	for attempt := 1; ; attempt++ {
+		file := &File{
+			Key:               pk.key,
+			Bucket:            p.store.bucket,
+			ChecksumAlgorithm: p.store.csAlg,
+			StorageClass:      p.store.sc,
+			ChunkSize:         p.store.chunksize,
+			Metadata: map[string]string{
+				MetadataPackIndexOffset: strconv.Itoa(indexOffset),
+				MetadataPackIndexLength: strconv.Itoa(len(index)),
+			},
+			File: newBufferFile(pk.key, body),
+		}
+		p.store.applyObjectLock(file)
+		pk.retainUntil = file.RetainUntil
+		pk.legalHold = file.LegalHold
+
+		// The pack is shared by several callers, so a single caller's context
+		// must not be able to abort its upload.
+		eTag, version, err := p.store.upload(context.Background(), file)
+		if err == nil || !p.store.shouldRetry(err, pk.key, attempt) {
+			return eTag, version, err
+		}
+	}
+}
+
+type bufferFile struct {
+	*bytes.Reader
+	name string
+	size int64
+}
+
+func newBufferFile(name string, body []byte) *bufferFile {
+	return &bufferFile{
+		Reader: bytes.NewReader(body),
+		name:   name,
+		size:   int64(len(body)),
+	}
+}
+
+func (f *bufferFile) Stat() (fs.FileInfo, error) {
+	return bufferFileInfo{f.name, f.size}, nil
+}
+
+func (f *bufferFile) Close() error {
+	return nil
+}
+
+type bufferFileInfo struct {
+	name string
+	size int64
+}
+
+func (i bufferFileInfo) Name() string       { return i.name }
+func (i bufferFileInfo) Size() int64        { return i.size }
+func (i bufferFileInfo) Mode() fs.FileMode  { return 0444 }
+func (i bufferFileInfo) ModTime() time.Time { return time.Time{} }
+func (i bufferFileInfo) IsDir() bool        { return false }
+func (i bufferFileInfo) Sys() interface{}   { return nil }
+
+// Copy copies the object holding the source file to the key of the destination
+// file. Files stored in packs cannot be copied.
+func (p *Packer) Copy(ctx context.Context, src, dst *processor.File) (*processor.File, error) {
+	if src.PackID != "" {
+		return nil, errors.New("unable to copy file stored in pack")
+	}
+	return p.store.Copy(ctx, src, dst)
+}
@@ -0,0 +1,439 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+type fakePackIDGenerator func() string
+
+type fakeActivity struct {
+	mu      sync.Mutex
+	active  int
+	watcher func(active int)
+}
+
+func (a *fakeActivity) Active() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.active
+}
+
+func (a *fakeActivity) Watch(fn func(active int)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.watcher = fn
+}
+
+func (a *fakeActivity) set(active int) {
+	a.mu.Lock()
+	a.active = active
+	fn := a.watcher
+	a.mu.Unlock()
+	fn(active)
+}
+
+func (fn fakePackIDGenerator) GenerateID() string {
+	return fn()
+}
+
+func (s *StoreTestSuite) TestPackerUpload() {
+	bucket := "some-bucket"
+	packID := "some-pack-id"
+	eTag := "some-etag"
+	version := "some-version"
+
+	bodies := map[string][]byte{
+		"small/one": {0, 1, 2},
+		"small/two": {3, 4, 5, 6},
+		"large":     {7, 8, 9, 10, 11, 12, 13, 14},
+	}
+
+	fs, err := newMemFS(bodies)
+	s.Require().NoError(err)
+
+	idGen := fakePackIDGenerator(func() string { return packID })
+
+	s.Run("uploads small files in a single pack", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		var body []byte
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.PutObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.PutObjectOutput, error) {
+				s.Equal("packs/"+packID, *input.Key)
+				s.Equal(bucket, *input.Bucket)
+				s.Equal(types.ChecksumAlgorithmCrc32, input.ChecksumAlgorithm)
+				s.Equal(types.StorageClassStandard, input.StorageClass)
+
+				var err error
+				body, err = io.ReadAll(input.Body)
+				s.Require().NoError(err)
+				s.assertPackIndex(body, input.Metadata)
+
+				return &s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil
+			})
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackTargetSize(7),
+			store.WithPackMaxDelay(time.Minute),
+		)
+
+		outputFiles := s.uploadConcurrently(ctx, packer, "small/one", "small/two")
+
+		for path, file := range outputFiles {
+			s.Equal("packs/"+packID, file.Key)
+			s.Equal(bucket, file.Bucket)
+			s.Equal(eTag, file.ETag)
+			s.Equal(version, file.Version)
+			s.Equal(packID, file.PackID)
+			s.Equal(
+				bodies[path],
+				body[file.PackOffset:file.PackOffset+file.PackLength],
+			)
+		}
+	})
+
+	s.Run("uploads pack with key prefix", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.PutObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.PutObjectOutput, error) {
+				s.Equal("some-prefix/packs/"+packID, *input.Key)
+				return &s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil
+			})
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackMaxDelay(time.Millisecond),
+			store.WithPackKeyPrefix("some-prefix/"),
+		)
+
+		file, err := packer.Upload(ctx, &processor.File{LocalPath: "small/one"})
+
+		s.Require().NoError(err)
+		s.Equal("some-prefix/packs/"+packID, file.Key)
+	})
+
+	s.Run("uploads pack after max delay", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			Return(&s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackTargetSize(1024),
+			store.WithPackMaxDelay(time.Millisecond),
+		)
+
+		file, err := packer.Upload(ctx, &processor.File{LocalPath: "small/one"})
+
+		s.Require().NoError(err)
+		s.Equal(packID, file.PackID)
+		s.Equal(int64(0), file.PackOffset)
+		s.Equal(int64(3), file.PackLength)
+	})
+
+	s.Run("uploads pack once every active caller is waiting", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		activity := &fakeActivity{active: 2}
+
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			Return(&s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackTargetSize(1024),
+			store.WithPackMaxDelay(time.Hour),
+			store.WithPackActivity(activity),
+		)
+
+		outputFiles := s.uploadConcurrently(ctx, packer, "small/one", "small/two")
+
+		s.Len(outputFiles, 2)
+		for _, file := range outputFiles {
+			s.Equal(packID, file.PackID)
+		}
+	})
+	s.Run("uploads pack once other callers stop being active", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		activity := &fakeActivity{active: 3}
+
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			Return(&s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackTargetSize(1024),
+			store.WithPackMaxDelay(time.Hour),
+			store.WithPackActivity(activity),
+		)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			file, err := packer.Upload(ctx, &processor.File{LocalPath: "small/one"})
+			s.NoError(err)
+			s.Equal(packID, file.PackID)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		activity.set(1)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			s.Fail("pack was not uploaded once other callers stopped")
+		}
+	})
+
+	s.Run("ignores storage rules", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.PutObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.PutObjectOutput, error) {
+				s.Equal(types.StorageClassStandard, input.StorageClass)
+				return &s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil
+			})
+
+		rule := store.StorageRule{StorageClass: types.StorageClassDeepArchive}
+		packer := store.NewPacker(
+			store.New(
+				s.mockClient, fs, bucket,
+				store.WithChunkSize(1024),
+				store.WithStorageRules(rule),
+			),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackMaxDelay(time.Millisecond),
+		)
+
+		file, err := packer.Upload(ctx, &processor.File{LocalPath: "small/one"})
+
+		s.Require().NoError(err)
+		s.Equal(string(types.StorageClassStandard), file.StorageClass)
+	})
+
+	s.Run("writes packed file contents to hash", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		h := crc32.NewIEEE()
+		h.Write([]byte("stale"))
+
+		s.mockClient.EXPECT().
+			PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(s.makeDoPutObject(&s3.PutObjectOutput{ETag: &eTag}))
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+			store.WithPackMaxDelay(time.Millisecond),
+		)
+
+		_, err := packer.Upload(ctx, &processor.File{LocalPath: "small/one", Hash: h})
+
+		s.Require().NoError(err)
+		s.Equal(crc32.ChecksumIEEE(bodies["small/one"]), h.Sum32())
+	})
+
+	s.Run("refuses to delete packed file", func() {
+		ctx := context.Background()
+
+		packer := store.NewPacker(store.New(s.mockClient, fs, bucket), idGen)
+
+		err := packer.Delete(ctx, &processor.File{Key: "packs/" + packID, PackID: packID})
+
+		s.Error(err)
+	})
+
+	s.Run("uploads large file directly", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		key := "some-key"
+
+		expectedOutputFile := &processor.File{
+			Key:          key,
+			LocalPath:    "large",
+			Bucket:       bucket,
+			StorageClass: string(types.StorageClassStandard),
+			ETag:         eTag,
+			Version:      version,
+		}
+
+		file, err := fs.Open("large")
+		s.Require().NoError(err)
+		defer file.Close()
+
+		putObjectInput := newTestPutObjectInput(
+			expectedOutputFile,
+			bucket,
+			types.ChecksumAlgorithmCrc32,
+			file,
+		)
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, newPutObjectInputMatcher(putObjectInput)).
+			Return(&s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		packer := store.NewPacker(
+			store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+			idGen,
+			store.WithPackThreshold(5),
+		)
+
+		outputFile, err := packer.Upload(ctx, &processor.File{Key: key, LocalPath: "large"})
+
+		s.Require().NoError(err)
+		s.Equal(expectedOutputFile, outputFile)
+	})
+
+	s.Run("handles error", func() {
+		expectedErr := errors.New("oh no")
+
+		s.Run("from client for all files in pack", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				PutObject(gomock.Any(), gomock.Any()).
+				Return(nil, expectedErr)
+
+			packer := store.NewPacker(
+				store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+				idGen,
+				store.WithPackThreshold(5),
+				store.WithPackTargetSize(7),
+				store.WithPackMaxDelay(time.Minute),
+			)
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i, path := range []string{"small/one", "small/two"} {
+				wg.Add(1)
+				go func(i int, path string) {
+					defer wg.Done()
+					_, errs[i] = packer.Upload(ctx, &processor.File{LocalPath: path})
+				}(i, path)
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				s.ErrorIs(err, expectedErr)
+			}
+		})
+		s.Run("when context canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			s.mockClient.EXPECT().
+				PutObject(gomock.Any(), gomock.Any()).
+				Return(&s3.PutObjectOutput{ETag: &eTag}, nil)
+
+			packer := store.NewPacker(
+				store.New(s.mockClient, fs, bucket, store.WithChunkSize(1024)),
+				idGen,
+				store.WithPackThreshold(5),
+				store.WithPackMaxDelay(time.Millisecond),
+			)
+
+			file, err := packer.Upload(ctx, &processor.File{LocalPath: "small/one"})
+
+			s.Nil(file)
+			s.ErrorIs(err, context.Canceled)
+
+			time.Sleep(10 * time.Millisecond)
+		})
+		s.Run("when opening file", func() {
+			ctx := context.Background()
+
+			packer := store.NewPacker(store.New(nil, fs, bucket), idGen)
+
+			file, err := packer.Upload(ctx, &processor.File{LocalPath: "doesnt/exist"})
+
+			s.Nil(file)
+			s.Error(err)
+		})
+	})
+}
+
+func (s *StoreTestSuite) uploadConcurrently(
+	ctx context.Context,
+	packer *store.Packer,
+	paths ...string,
+) map[string]*processor.File {
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	files := make(map[string]*processor.File, len(paths))
+
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			file, err := packer.Upload(ctx, &processor.File{LocalPath: path})
+			s.Require().NoError(err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			files[path] = file
+		}(path)
+	}
+	wg.Wait()
+
+	return files
+}
+
+func (s *StoreTestSuite) assertPackIndex(body []byte, metadata map[string]string) {
+	offset, err := strconv.Atoi(metadata[store.MetadataPackIndexOffset])
+	s.Require().NoError(err)
+	length, err := strconv.Atoi(metadata[store.MetadataPackIndexLength])
+	s.Require().NoError(err)
+	s.Require().Equal(len(body), offset+length)
+
+	var entries []store.PackEntry
+	err = json.Unmarshal(body[offset:], &entries)
+	s.Require().NoError(err)
+
+	for _, entry := range entries {
+		s.LessOrEqual(entry.Offset+entry.Length, int64(offset))
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/mspraggs/hoard/internal/errors"
+)
+
+const preflightKeyPrefix = ".hoard-preflight/"
+
+// Preflight checks that the store's bucket is usable before any files are
+// uploaded to it, without modifying the bucket. It confirms that the bucket
+// exists and can be accessed, and then that versioning is enabled on it. If
+// the bucket can be accessed but versioning is disabled, the returned error
+// wraps ErrVersioningDisabled.
+func (s *Store) Preflight(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &s.bucket})
+	if err != nil {
+		return fmt.Errorf("unable to access bucket %q: %w", s.bucket, err)
+	}
+
+	output, err := s.client.GetBucketVersioning(
+		ctx, &s3.GetBucketVersioningInput{Bucket: &s.bucket},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to get versioning status of bucket %q: %w", s.bucket, err)
+	}
+	if output.Status != types.BucketVersioningStatusEnabled {
+		return fmt.Errorf("bucket %q: %w", s.bucket, errors.ErrVersioningDisabled)
+	}
+
+	return nil
+}
+
+// CheckWrites checks that the store's bucket accepts writes using the
+// configured checksum algorithm, by writing a zero-length marker object and then
+// deleting it. The marker is written with the STANDARD storage class, so that
+// no minimum storage duration is charged for it. If the marker is written but
+// cannot be deleted, for example because the bucket applies a default object
+// lock retention period, the returned error wraps ErrProbeNotDeleted.
+func (s *Store) CheckWrites(ctx context.Context, probeID string) error {
+	key := preflightKeyPrefix + probeID
+	versionID, err := s.writeProbe(ctx, key, types.StorageClassStandard)
+	if err != nil {
+		return err
+	}
+	if err := s.deleteProbe(ctx, key, versionID); err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrProbeNotDeleted, err)
+	}
+
+	return nil
+}
+
+// ProbeWrites checks that a probe object can be written to the store's bucket
+// using each configured storage class and the configured checksum algorithm,
+// deleting each probe object once written. Unlike Preflight, this modifies the
+// bucket. Probes cannot be deleted from buckets that apply a default object
+// lock retention period, and incur the minimum storage duration charge of
+// archival storage classes, so this check should only be run on request.
+func (s *Store) ProbeWrites(ctx context.Context, probeID string) error {
+	for _, sc := range s.storageClasses() {
+		if err := s.probe(ctx, probeID, sc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) probe(ctx context.Context, probeID string, sc StorageClass) error {
+	key := preflightKeyPrefix + probeID + "-" + string(sc)
+
+	versionID, err := s.writeProbe(ctx, key, sc)
+	if err != nil {
+		return err
+	}
+
+	return s.deleteProbe(ctx, key, versionID)
+}
+
+func (s *Store) writeProbe(ctx context.Context, key string, sc StorageClass) (*string, error) {
+	output, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:            &s.bucket,
+		Key:               &key,
+		ChecksumAlgorithm: s.csAlg,
+		StorageClass:      sc,
+		Body:              bytes.NewReader([]byte{}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"unable to write to bucket %q with storage class %q and checksum algorithm %q: %w",
+			s.bucket, sc, s.csAlg, err,
+		)
+	}
+
+	return output.VersionId, nil
+}
+
+func (s *Store) deleteProbe(ctx context.Context, key string, versionID *string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    &s.bucket,
+		Key:       &key,
+		VersionId: versionID,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete preflight object from bucket %q: %w", s.bucket, err)
+	}
+
+	return nil
+}
+
+func (s *Store) storageClasses() []StorageClass {
+	classes := []StorageClass{s.sc}
+	seen := map[StorageClass]bool{s.sc: true}
+
+	for _, rule := range s.rules {
+		if rule.StorageClass == "" || seen[rule.StorageClass] {
+			continue
+		}
+		seen[rule.StorageClass] = true
+		classes = append(classes, rule.StorageClass)
+	}
+
+	return classes
+}
@@ -0,0 +1,248 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/golang/mock/gomock"
+
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+func (s *StoreTestSuite) TestPreflight() {
+	bucket := "some-bucket"
+
+	s.Run("passes for versioned bucket without writing to it", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket}).
+			Return(&s3.HeadBucketOutput{}, nil)
+		s.mockClient.EXPECT().
+			GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: &bucket}).
+			Return(&s3.GetBucketVersioningOutput{
+				Status: types.BucketVersioningStatusEnabled,
+			}, nil)
+
+		store := store.New(
+			s.mockClient, nil, bucket,
+			store.WithStorageRules(
+				store.StorageRule{StorageClass: types.StorageClassGlacier},
+			),
+		)
+
+		err := store.Preflight(ctx)
+
+		s.NoError(err)
+	})
+
+	s.Run("handles error", func() {
+		expectedErr := errors.New("oh no")
+
+		s.Run("from head bucket", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				HeadBucket(ctx, gomock.Any()).
+				Return(nil, expectedErr)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.Preflight(ctx)
+
+			s.ErrorIs(err, expectedErr)
+		})
+		s.Run("from versioning status", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				HeadBucket(ctx, gomock.Any()).
+				Return(&s3.HeadBucketOutput{}, nil)
+			s.mockClient.EXPECT().
+				GetBucketVersioning(ctx, gomock.Any()).
+				Return(nil, expectedErr)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.Preflight(ctx)
+
+			s.ErrorIs(err, expectedErr)
+		})
+		s.Run("when versioning disabled", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				HeadBucket(ctx, gomock.Any()).
+				Return(&s3.HeadBucketOutput{}, nil)
+			s.mockClient.EXPECT().
+				GetBucketVersioning(ctx, gomock.Any()).
+				Return(&s3.GetBucketVersioningOutput{
+					Status: types.BucketVersioningStatusSuspended,
+				}, nil)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.Preflight(ctx)
+
+			s.ErrorIs(err, hoarderrors.ErrVersioningDisabled)
+		})
+	})
+}
+
+func (s *StoreTestSuite) TestCheckWrites() {
+	bucket := "some-bucket"
+	probeID := "some-probe-id"
+	probeVersion := "some-probe-version"
+	key := ".hoard-preflight/some-probe-id"
+
+	s.Run("writes and deletes marker", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, newPutObjectInputMatcher(&s3.PutObjectInput{
+				Bucket:            &bucket,
+				Key:               &key,
+				ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+				StorageClass:      types.StorageClassStandard,
+				Body:              bytes.NewReader([]byte{}),
+			})).
+			Return(&s3.PutObjectOutput{VersionId: &probeVersion}, nil)
+		s.mockClient.EXPECT().
+			DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket:    &bucket,
+				Key:       &key,
+				VersionId: &probeVersion,
+			}).
+			Return(&s3.DeleteObjectOutput{}, nil)
+
+		store := store.New(
+			s.mockClient, nil, bucket,
+			store.WithStorageClass(types.StorageClassGlacier),
+		)
+
+		err := store.CheckWrites(ctx, probeID)
+
+		s.NoError(err)
+	})
+
+	s.Run("handles error", func() {
+		expectedErr := errors.New("oh no")
+
+		s.Run("from marker write", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				PutObject(ctx, gomock.Any()).
+				Return(nil, expectedErr)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.CheckWrites(ctx, probeID)
+
+			s.ErrorIs(err, expectedErr)
+			s.NotErrorIs(err, hoarderrors.ErrProbeNotDeleted)
+		})
+		s.Run("from marker delete", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				PutObject(ctx, gomock.Any()).
+				Return(&s3.PutObjectOutput{}, nil)
+			s.mockClient.EXPECT().
+				DeleteObject(ctx, gomock.Any()).
+				Return(nil, expectedErr)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.CheckWrites(ctx, probeID)
+
+			s.ErrorIs(err, hoarderrors.ErrProbeNotDeleted)
+		})
+	})
+}
+
+func (s *StoreTestSuite) TestProbeWrites() {
+	bucket := "some-bucket"
+	probeID := "some-probe-id"
+	probeVersion := "some-probe-version"
+
+	standardKey := ".hoard-preflight/some-probe-id-STANDARD"
+	glacierKey := ".hoard-preflight/some-probe-id-GLACIER"
+
+	expectProbe := func(ctx context.Context, key string, sc types.StorageClass) {
+		s.mockClient.EXPECT().
+			PutObject(ctx, newPutObjectInputMatcher(&s3.PutObjectInput{
+				Bucket:            &bucket,
+				Key:               &key,
+				ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+				StorageClass:      sc,
+				Body:              bytes.NewReader([]byte{}),
+			})).
+			Return(&s3.PutObjectOutput{VersionId: &probeVersion}, nil)
+		s.mockClient.EXPECT().
+			DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket:    &bucket,
+				Key:       &key,
+				VersionId: &probeVersion,
+			}).
+			Return(&s3.DeleteObjectOutput{}, nil)
+	}
+
+	s.Run("writes and deletes probe for each storage class", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		expectProbe(ctx, standardKey, types.StorageClassStandard)
+		expectProbe(ctx, glacierKey, types.StorageClassGlacier)
+
+		store := store.New(
+			s.mockClient, nil, bucket,
+			store.WithStorageRules(
+				store.StorageRule{StorageClass: types.StorageClassGlacier},
+				store.StorageRule{StorageClass: types.StorageClassStandard},
+			),
+		)
+
+		err := store.ProbeWrites(ctx, probeID)
+
+		s.NoError(err)
+	})
+
+	s.Run("handles error", func() {
+		expectedErr := errors.New("oh no")
+
+		s.Run("from probe write", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				PutObject(ctx, gomock.Any()).
+				Return(nil, expectedErr)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.ProbeWrites(ctx, probeID)
+
+			s.ErrorIs(err, expectedErr)
+			s.ErrorContains(err, "STANDARD")
+		})
+		s.Run("from probe delete", func() {
+			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+			s.mockClient.EXPECT().
+				PutObject(ctx, gomock.Any()).
+				Return(&s3.PutObjectOutput{}, nil)
+			s.mockClient.EXPECT().
+				DeleteObject(ctx, gomock.Any()).
+				Return(nil, expectedErr)
+
+			store := store.New(s.mockClient, nil, bucket)
+
+			err := store.ProbeWrites(ctx, probeID)
+
+			s.ErrorIs(err, expectedErr)
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// Clock defines the interface required to fetch the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// StorageRule selects the storage class, and optionally the chunk size, used to
+// store files satisfying all of the rule's conditions. Zero-valued conditions
+// are ignored. The size range includes the minimum but excludes the maximum,
+// and the age of a file is measured from its modification time.
+type StorageRule struct {
+	Glob         string
+	MinSize      int64
+	MaxSize      int64
+	MinAge       time.Duration
+	MaxAge       time.Duration
+	StorageClass StorageClass
+	ChunkSize    int64
+}
+
+// Matches determines whether the file with the provided path and info satisfies
+// all conditions of the rule at the provided time. Globs without a path
+// separator are matched against the base name of the file, while globs with
+// one are matched against the full path.
+func (r *StorageRule) Matches(p string, info fs.FileInfo, now time.Time) bool {
+	if r.Glob != "" {
+		name := p
+		if !strings.Contains(r.Glob, "/") {
+			name = path.Base(p)
+		}
+		if ok, err := path.Match(r.Glob, name); err != nil || !ok {
+			return false
+		}
+	}
+
+	size := info.Size()
+	if size < r.MinSize {
+		return false
+	}
+	if r.MaxSize > 0 && size >= r.MaxSize {
+		return false
+	}
+
+	age := now.Sub(info.ModTime())
+	if age < r.MinAge {
+		return false
+	}
+	if r.MaxAge > 0 && age >= r.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// selectStorage returns the storage class and chunk size for the file with the
+// provided path and info, as chosen by the first matching rule, falling back
+// to the store's defaults.
+func (s *Store) selectStorage(p string, info fs.FileInfo) (StorageClass, int64) {
+	now := s.clock.Now()
+
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !rule.Matches(p, info, now) {
+			continue
+		}
+
+		sc := rule.StorageClass
+		if sc == "" {
+			sc = s.sc
+		}
+		chunksize := rule.ChunkSize
+		if chunksize == 0 {
+			chunksize = s.chunksize
+		}
+		return sc, chunksize
+	}
+
+	return s.sc, s.chunksize
+}
@@ -0,0 +1,170 @@
+package store_test
+
+import (
+	"context"
+	"io/fs"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+type fakeClock func() time.Time
+
+func (fn fakeClock) Now() time.Time {
+	return fn()
+}
+
+type fakeFileInfo struct {
+	fs.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (i fakeFileInfo) Size() int64        { return i.size }
+func (i fakeFileInfo) ModTime() time.Time { return i.modTime }
+
+func (s *StoreTestSuite) TestStorageRuleMatches() {
+	now := time.Unix(10000, 0)
+	info := fakeFileInfo{size: 100, modTime: now.Add(-time.Hour)}
+
+	testCases := []struct {
+		name     string
+		path     string
+		rule     store.StorageRule
+		expected bool
+	}{
+		{"empty rule", "foo.raw", store.StorageRule{}, true},
+		{"base name glob", "a/b/foo.raw", store.StorageRule{Glob: "*.raw"}, true},
+		{"base name glob mismatch", "a/b/foo.txt", store.StorageRule{Glob: "*.raw"}, false},
+		{"path glob", "a/b/foo.raw", store.StorageRule{Glob: "a/*/foo.raw"}, true},
+		{"path glob mismatch", "a/b/foo.raw", store.StorageRule{Glob: "b/*/foo.raw"}, false},
+		{"invalid glob", "foo.raw", store.StorageRule{Glob: "["}, false},
+		{"min size inclusive", "foo", store.StorageRule{MinSize: 100}, true},
+		{"below min size", "foo", store.StorageRule{MinSize: 101}, false},
+		{"max size exclusive", "foo", store.StorageRule{MaxSize: 100}, false},
+		{"below max size", "foo", store.StorageRule{MaxSize: 101}, true},
+		{"older than min age", "foo", store.StorageRule{MinAge: time.Minute}, true},
+		{"younger than min age", "foo", store.StorageRule{MinAge: 2 * time.Hour}, false},
+		{"younger than max age", "foo", store.StorageRule{MaxAge: 2 * time.Hour}, true},
+		{"older than max age", "foo", store.StorageRule{MaxAge: time.Minute}, false},
+		{
+			"all conditions",
+			"foo.raw",
+			store.StorageRule{Glob: "*.raw", MinSize: 10, MaxSize: 1000, MinAge: time.Minute},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.Equal(tc.expected, tc.rule.Matches(tc.path, info, now))
+		})
+	}
+}
+
+func (s *StoreTestSuite) TestUploadWithStorageRules() {
+	key := "some-key"
+	path := "some/path.raw"
+	body := []byte{0, 1, 2, 3}
+	bucket := "some-bucket"
+	eTag := "some-etag"
+	version := "some-version"
+
+	fs, err := newMemFS(map[string][]byte{path: body})
+	s.Require().NoError(err)
+
+	clock := fakeClock(func() time.Time { return time.Now() })
+
+	rules := []store.StorageRule{
+		{Glob: "*.txt", StorageClass: types.StorageClassGlacierIr},
+		{Glob: "*.raw", MaxSize: 10, StorageClass: types.StorageClassDeepArchive},
+		{StorageClass: types.StorageClassGlacier},
+	}
+
+	s.Run("uses storage class from first matching rule", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		expectedOutputFile := &processor.File{
+			Key:          key,
+			LocalPath:    path,
+			Bucket:       bucket,
+			StorageClass: string(types.StorageClassDeepArchive),
+			ETag:         eTag,
+			Version:      version,
+		}
+
+		file, err := fs.Open(path)
+		s.Require().NoError(err)
+		defer file.Close()
+
+		putObjectInput := newTestPutObjectInput(
+			expectedOutputFile,
+			bucket,
+			types.ChecksumAlgorithmCrc32,
+			file,
+		)
+		putObjectInput.StorageClass = types.StorageClassDeepArchive
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, newPutObjectInputMatcher(putObjectInput)).
+			Return(&s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		store := store.New(
+			s.mockClient,
+			fs,
+			bucket,
+			store.WithStorageRules(rules...),
+			store.WithClock(clock),
+		)
+
+		outputFile, err := store.Upload(ctx, &processor.File{Key: key, LocalPath: path})
+
+		s.Require().NoError(err)
+		s.Equal(expectedOutputFile, outputFile)
+	})
+	s.Run("uses chunk size from matching rule", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		uploadID := "some-upload-id"
+
+		s.mockClient.EXPECT().
+			CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+				Key:               &key,
+				Bucket:            &bucket,
+				ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+				StorageClass:      types.StorageClassGlacier,
+			}).
+			Return(&s3.CreateMultipartUploadOutput{UploadId: &uploadID}, nil)
+		s.mockClient.EXPECT().
+			UploadPart(ctx, newUploadPartInputMatcher(newTestUploadPartInput(
+				&processor.File{Key: key}, bucket, uploadID, types.ChecksumAlgorithmCrc32,
+				1, int64(len(body)), nil,
+			))).
+			DoAndReturn(s.makeDoUploadPart(body, int64(len(body)), eTag))
+		s.mockClient.EXPECT().
+			CompleteMultipartUpload(ctx, gomock.Any()).
+			Return(&s3.CompleteMultipartUploadOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		rules := []store.StorageRule{
+			{StorageClass: types.StorageClassGlacier, ChunkSize: int64(len(body))},
+		}
+
+		store := store.New(
+			s.mockClient,
+			fs,
+			bucket,
+			store.WithChunkSize(1024),
+			store.WithStorageRules(rules...),
+			store.WithClock(clock),
+		)
+
+		outputFile, err := store.Upload(ctx, &processor.File{Key: key, LocalPath: path})
+
+		s.Require().NoError(err)
+		s.Equal(string(types.StorageClassGlacier), outputFile.StorageClass)
+	})
+}
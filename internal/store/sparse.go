@@ -0,0 +1,300 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+// blockSize is the unit in which the number of blocks allocated to a file is
+// reported.
+const blockSize = 512
+
+// SparseWriter is the interface required to restore a sparse file, whose
+// extents are written at their offsets before the file is extended to its full
+// size.
+type SparseWriter interface {
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// dataExtents returns the extents of the provided file that hold data, found
+// using SEEK_DATA and SEEK_HOLE. Nil is returned if the file has fewer blocks
+// allocated than its size suggests, or if the filesystem cannot report holes.
+func dataExtents(f fs.File, info fs.FileInfo) ([]processor.Extent, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Blocks*blockSize >= info.Size() {
+		return nil, nil
+	}
+	seeker, ok := f.(io.Seeker)
+	if !ok {
+		return nil, nil
+	}
+
+	size := info.Size()
+	extents := []processor.Extent{}
+	for offset := int64(0); offset < size; {
+		start, err := seeker.Seek(offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			break
+		}
+		if errors.Is(err, unix.EINVAL) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		end, err := seeker.Seek(start, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, err
+		}
+		if end > size {
+			end = size
+		}
+		extents = append(extents, processor.Extent{Offset: start, Length: end - start})
+		offset = end
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if len(extents) == 1 && extents[0].Length == size {
+		return nil, nil
+	}
+
+	return extents, nil
+}
+
+// sparseFile reads only the extents of a sparse file, one after another. If a
+// hash is provided, the full contents of the file are written to it, including
+// the zeros in its holes, so that its checksum matches that of a file read in
+// full. Like hashingFile, it can seek within the extents without writing any
+// part of the file to the hash twice.
+type sparseFile struct {
+	fs.File
+	seeker  io.ReadSeeker
+	info    fs.FileInfo
+	extents []processor.Extent
+	hash    hash.Hash
+
+	extent     int
+	read       int64
+	pos        int64
+	positioned bool
+	hashed     int64
+	dataLen    int64
+}
+
+func newSparseFile(
+	f fs.File,
+	info fs.FileInfo,
+	extents []processor.Extent,
+	h hash.Hash,
+) (*sparseFile, error) {
+
+	seeker, ok := f.(io.ReadSeeker)
+	if !ok {
+		return nil, fmt.Errorf("unable to seek within file %q", info.Name())
+	}
+
+	var dataLen int64
+	for _, extent := range extents {
+		dataLen += extent.Length
+	}
+
+	return &sparseFile{
+		File:    f,
+		seeker:  seeker,
+		info:    info,
+		extents: extents,
+		hash:    h,
+		dataLen: dataLen,
+	}, nil
+}
+
+func (f *sparseFile) Read(bs []byte) (int, error) {
+	for f.extent < len(f.extents) {
+		extent := f.extents[f.extent]
+		if f.read == extent.Length {
+			f.extent++
+			f.read = 0
+			f.positioned = false
+			continue
+		}
+		if !f.positioned {
+			if _, err := f.seeker.Seek(extent.Offset+f.read, io.SeekStart); err != nil {
+				return 0, err
+			}
+			f.positioned = true
+		}
+
+		if remaining := extent.Length - f.read; int64(len(bs)) > remaining {
+			bs = bs[:remaining]
+		}
+		n, err := f.seeker.Read(bs)
+		f.hashData(f.extent, extent.Offset+f.read, bs[:n])
+		f.read += int64(n)
+		f.pos += int64(n)
+		if errors.Is(err, io.EOF) {
+			return n, io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	if f.hashed >= f.dataEnd(len(f.extents)-1) {
+		f.hashZeros(f.info.Size())
+	}
+	return 0, io.EOF
+}
+
+// Seek sets the offset for the next read within the extents of the file, which
+// is an offset into the object the file is stored as.
+func (f *sparseFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.dataLen
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.pos = offset
+	f.extent, f.read, f.positioned = 0, offset, false
+	for f.extent < len(f.extents) && f.read >= f.extents[f.extent].Length {
+		f.read -= f.extents[f.extent].Length
+		f.extent++
+	}
+
+	return offset, nil
+}
+
+// Stat reports the size of the file as the total length of its extents, which
+// is the size of the object it is stored as.
+func (f *sparseFile) Stat() (fs.FileInfo, error) {
+	return sparseInfo{FileInfo: f.info, size: f.dataLen}, nil
+}
+
+// hashData writes data read from the provided extent at the provided offset of
+// the file to the hash, preceded by the zeros in the hole before the extent.
+// Data is only written once everything before it has been written, and data
+// that has already been written is skipped.
+func (f *sparseFile) hashData(extent int, offset int64, bs []byte) {
+	if f.hash == nil {
+		return
+	}
+	if f.hashed < offset {
+		if offset != f.extents[extent].Offset || f.hashed < f.dataEnd(extent-1) {
+			return
+		}
+		f.hashZeros(offset)
+	}
+	if skip := f.hashed - offset; skip < int64(len(bs)) {
+		f.hash.Write(bs[skip:])
+		f.hashed = offset + int64(len(bs))
+	}
+}
+
+// dataEnd returns the offset of the end of the provided extent, or zero if
+// there is no such extent.
+func (f *sparseFile) dataEnd(extent int) int64 {
+	if extent < 0 || extent >= len(f.extents) {
+		return 0
+	}
+	return f.extents[extent].Offset + f.extents[extent].Length
+}
+
+// hashZeros writes zeros to the hash up to the provided offset.
+func (f *sparseFile) hashZeros(offset int64) {
+	if f.hash == nil || f.hashed >= offset {
+		return
+	}
+	writeZeros(f.hash, offset-f.hashed)
+	f.hashed = offset
+}
+
+// writeZeros writes the provided number of zeros to the provided writer.
+func writeZeros(w io.Writer, n int64) {
+	var zeros [32 * 1024]byte
+	for n > 0 {
+		chunk := n
+		if chunk > int64(len(zeros)) {
+			chunk = int64(len(zeros))
+		}
+		w.Write(zeros[:chunk])
+		n -= chunk
+	}
+}
+
+type sparseInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i sparseInfo) Size() int64 {
+	return i.size
+}
+
+// sparseWriter writes a stream holding the extents of a sparse file, one after
+// another, at the offsets of the extents. If a hash is provided, the full
+// contents of the file are written to it, including the zeros in its holes.
+type sparseWriter struct {
+	w       io.WriterAt
+	extents []processor.Extent
+	hash    io.Writer
+	extent  int
+	written int64
+	hashed  int64
+}
+
+func (w *sparseWriter) Write(bs []byte) (int, error) {
+	total := 0
+	for len(bs) > 0 {
+		if w.extent >= len(w.extents) {
+			return total, errors.New("object is longer than the extents of the sparse file")
+		}
+		extent := w.extents[w.extent]
+
+		chunk := bs
+		if remaining := extent.Length - w.written; int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		w.hashZeros(extent.Offset + w.written)
+		n, err := w.w.WriteAt(chunk, extent.Offset+w.written)
+		if w.hash != nil {
+			w.hash.Write(chunk[:n])
+			w.hashed += int64(n)
+		}
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		if w.written == extent.Length {
+			w.extent++
+			w.written = 0
+		}
+		bs = bs[n:]
+	}
+	return total, nil
+}
+
+// hashZeros writes zeros to the hash up to the provided offset.
+func (w *sparseWriter) hashZeros(offset int64) {
+	if w.hash == nil || w.hashed >= offset {
+		return
+	}
+	writeZeros(w.hash, offset-w.hashed)
+	w.hashed = offset
+}
@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/store"
+)
+
+func (s *StoreTestSuite) TestSparseFiles() {
+	bucket := "some-bucket"
+	eTag := "some-etag"
+	size := int64(4 * 1024 * 1024)
+	offset := int64(1024 * 1024)
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 16*1024)
+
+	dir := s.T().TempDir()
+	f, err := os.Create(filepath.Join(dir, "sparse"))
+	s.Require().NoError(err)
+	_, err = f.WriteAt(data, offset)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Truncate(size))
+	s.Require().NoError(f.Close())
+
+	contents, err := os.ReadFile(filepath.Join(dir, "sparse"))
+	s.Require().NoError(err)
+
+	fs := os.DirFS(dir)
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+	var uploaded []byte
+	var extents []processor.Extent
+
+	s.Run("uploads extents of sparse file", func() {
+		h := crc32.NewIEEE()
+		file := &processor.File{Key: "some-key", LocalPath: "sparse", Hash: h}
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, gomock.Any()).
+			DoAndReturn(func(
+				ctx context.Context,
+				input *s3.PutObjectInput,
+				optFns ...func(*s3.Options),
+			) (*s3.PutObjectOutput, error) {
+				body, err := io.ReadAll(input.Body)
+				s.Require().NoError(err)
+				uploaded = body
+				return &s3.PutObjectOutput{ETag: &eTag}, nil
+			})
+
+		store := store.New(s.mockClient, fs, bucket, store.WithChunkSize(size))
+
+		file, err := store.Upload(ctx, file)
+
+		s.Require().NoError(err)
+		if file.Extents == nil {
+			s.T().Skip("filesystem does not report holes")
+		}
+		extents = file.Extents
+		s.Less(int64(len(uploaded)), size)
+		s.Contains(string(uploaded), string(data))
+		s.Equal(crc32.ChecksumIEEE(contents), h.Sum32())
+	})
+	s.Run("restores sparse file", func() {
+		if extents == nil {
+			s.T().Skip("filesystem does not report holes")
+		}
+		sum := make(processor.Checksum, 4)
+		binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(contents))
+		file := &processor.File{
+			Key:      "some-key",
+			Bucket:   bucket,
+			Checksum: sum,
+			Size:     size,
+			Extents:  extents,
+
+			ChecksumAlgorithm: "CRC32",
+		}
+
+		s.mockClient.EXPECT().
+			GetObject(ctx, gomock.Any()).
+			Return(&s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(uploaded))}, nil)
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		restored, err := os.Create(filepath.Join(dir, "restored"))
+		s.Require().NoError(err)
+		defer restored.Close()
+
+		err = store.Download(ctx, file, restored)
+
+		s.Require().NoError(err)
+		restoredContents, err := os.ReadFile(filepath.Join(dir, "restored"))
+		s.Require().NoError(err)
+		s.Equal(contents, restoredContents)
+	})
+	s.Run("requires sparse writer to restore sparse file", func() {
+		file := &processor.File{Key: "some-key", Bucket: bucket, Extents: []processor.Extent{}}
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		err := store.Download(ctx, file, &bytes.Buffer{})
+
+		s.Error(err)
+	})
+}
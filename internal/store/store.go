@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"io/fs"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
@@ -12,7 +13,14 @@ import (
 
 //go:generate mockgen -destination=./mocks/store.go -package=mocks -source=$GOFILE
 
-const defaultChunkSize = 10 * 1024 * 1024
+const (
+	defaultChunkSize = 10 * 1024 * 1024
+	abortTimeout     = 30 * time.Second
+
+	// MinChunkSize is the smallest part size accepted by the storage backend
+	// for every part of a multipart upload except the last.
+	MinChunkSize = 5 * 1024 * 1024
+)
 
 // Client is the interface required to interact with a storage backend.
 type Client interface {
@@ -36,6 +44,47 @@ type Client interface {
 		input *s3.CompleteMultipartUploadInput,
 		optFns ...func(*s3.Options),
 	) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(
+		ctx context.Context,
+		input *s3.AbortMultipartUploadInput,
+		optFns ...func(*s3.Options),
+	) (*s3.AbortMultipartUploadOutput, error)
+	DeleteObject(
+		ctx context.Context,
+		input *s3.DeleteObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.DeleteObjectOutput, error)
+	GetObject(
+		ctx context.Context,
+		input *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+	CopyObject(
+		ctx context.Context,
+		input *s3.CopyObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.CopyObjectOutput, error)
+	HeadBucket(
+		ctx context.Context,
+		input *s3.HeadBucketInput,
+		optFns ...func(*s3.Options),
+	) (*s3.HeadBucketOutput, error)
+	GetBucketVersioning(
+		ctx context.Context,
+		input *s3.GetBucketVersioningInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetBucketVersioningOutput, error)
+}
+
+// Reporter is the interface required to report the progress of uploads.
+type Reporter interface {
+	BytesUploaded(ctx context.Context, n int64)
+}
+
+// UnverifiedReporter is implemented by reporters that count uploads which could
+// not be verified because the storage backend returned no checksum for them.
+type UnverifiedReporter interface {
+	UploadUnverified(ctx context.Context)
 }
 
 // Option defines the interface for configuring options on a Store instance.
@@ -44,13 +93,27 @@ type Option func(*Store)
 // Store encapsulates the logic required to store a file in a storage
 // bucket.
 type Store struct {
-	chunksize int64
-	bucket    string
-	log       *zap.SugaredLogger
-	client    Client
-	fs        fs.FS
-	csAlg     ChecksumAlgorithm
-	sc        StorageClass
+	chunksize   int64
+	bucket      string
+	log         *zap.SugaredLogger
+	client      Client
+	fs          fs.FS
+	csAlg       ChecksumAlgorithm
+	sc          StorageClass
+	rules       []StorageRule
+	lock        *ObjectLock
+	clock       Clock
+	reporter    Reporter
+	maxAttempts int
+}
+
+// ObjectLock contains the object lock settings applied to every object
+// uploaded by a store. Objects are retained for the provided retention period
+// from the time of upload, and may additionally be placed under legal hold.
+type ObjectLock struct {
+	Mode      ObjectLockMode
+	Retention time.Duration
+	LegalHold bool
 }
 
 // New instantiates a new file store with provided filesystem, uploader
@@ -63,13 +126,16 @@ func New(
 ) *Store {
 
 	store := &Store{
-		client:    client,
-		fs:        fs,
-		log:       util.MustNewLogger(),
-		bucket:    bucket,
-		chunksize: defaultChunkSize,
-		csAlg:     types.ChecksumAlgorithmCrc32,
-		sc:        types.StorageClassStandard,
+		client:      client,
+		fs:          fs,
+		log:         util.MustNewLogger(),
+		bucket:      bucket,
+		chunksize:   defaultChunkSize,
+		csAlg:       types.ChecksumAlgorithmCrc32,
+		sc:          types.StorageClassStandard,
+		clock:       &util.Clock{},
+		reporter:    nopReporter{},
+		maxAttempts: defaultMaxAttempts,
 	}
 	for _, opt := range opts {
 		opt(store)
@@ -101,3 +167,50 @@ func WithStorageClass(storageClass StorageClass) Option {
 		s.sc = storageClass
 	}
 }
+
+// WithStorageRules returns a Option that sets an ordered list of rules used
+// to select the storage class and chunk size for each file. The first matching
+// rule wins, and files matching no rule use the store's defaults. Rules do not
+// apply to pack objects, which always use the store's defaults.
+func WithStorageRules(rules ...StorageRule) Option {
+	return func(s *Store) {
+		s.rules = rules
+	}
+}
+
+// WithObjectLock returns a Option that applies the provided object lock
+// settings to every object uploaded by the store.
+func WithObjectLock(lock ObjectLock) Option {
+	return func(s *Store) {
+		s.lock = &lock
+	}
+}
+
+// WithClock returns a Option that sets the clock used to determine the age of
+// files when applying storage rules and retention periods when locking objects.
+func WithClock(clock Clock) Option {
+	return func(s *Store) {
+		s.clock = clock
+	}
+}
+
+// WithMaxAttempts returns a Option that sets the number of times the store
+// attempts to upload a file whose checksum does not match the checksum
+// computed by the storage backend.
+func WithMaxAttempts(attempts int) Option {
+	return func(s *Store) {
+		s.maxAttempts = attempts
+	}
+}
+
+// WithReporter returns a Option that sets the reporter notified of the number
+// of bytes sent by each put and part upload.
+func WithReporter(reporter Reporter) Option {
+	return func(s *Store) {
+		s.reporter = reporter
+	}
+}
+
+type nopReporter struct{}
+
+func (r nopReporter) BytesUploaded(ctx context.Context, n int64) {}
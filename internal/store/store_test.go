@@ -69,6 +69,22 @@ func (s *StoreTestSuite) makeDoUploadPart(
 	}
 }
 
+func (s *StoreTestSuite) makeDoPutObject(
+	output *s3.PutObjectOutput,
+) func(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+
+	return func(
+		ctx context.Context,
+		input *s3.PutObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.PutObjectOutput, error) {
+
+		_, err := io.ReadAll(input.Body)
+		s.Require().NoError(err)
+		return output, nil
+	}
+}
+
 func newMemFS(files map[string][]byte) (*memfs.FS, error) {
 	fs := memfs.New()
 
@@ -150,11 +166,14 @@ func (m *putObjectInputMatcher) Matches(actual interface{}) bool {
 	if bytes.Compare(expectedBody, actualBody) != 0 {
 		return false
 	}
+	if actualInput.ContentLength != int64(len(actualBody)) {
+		return false
+	}
 
 	return cmp.Equal(
 		m.expected, actualInput,
 		cmpopts.IgnoreUnexported(s3.PutObjectInput{}),
-		cmpopts.IgnoreFields(s3.PutObjectInput{}, "Body"),
+		cmpopts.IgnoreFields(s3.PutObjectInput{}, "Body", "ContentLength"),
 	)
 }
 
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -13,29 +16,120 @@ import (
 
 // Upload stores the contents of the provided file file in the storage backend.
 // The file is performed either as a single put operation or a multi-part file
-// file, depending on the size of the file.
+// file, depending on the size of the file. If the checksum computed by the
+// storage backend does not match the checksum computed locally, the uploaded
+// object version is deleted and the upload is retried, as it is if the contents
+// read during the upload do not match a checksum computed for the file
+// beforehand. Only the extents of a
+// sparse file are uploaded, and they are recorded on the file.
 func (s *Store) Upload(ctx context.Context, file *processor.File) (*processor.File, error) {
-	if s.chunksize == 0 {
+	if s.chunksize <= 0 {
 		return nil, errors.New("invalid chunk size")
 	}
 
+	for attempt := 1; ; attempt++ {
+		err := s.uploadFile(ctx, file)
+		if err == nil {
+			return file, nil
+		}
+		if !s.shouldRetry(err, file.Key, attempt) {
+			return nil, err
+		}
+	}
+}
+
+func (s *Store) uploadFile(ctx context.Context, file *processor.File) error {
 	f, err := s.fs.Open(file.LocalPath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if file.Hash != nil {
+		file.Hash.Reset()
+	}
+	contents, err := contentHash(file)
+	if err != nil {
+		return err
+	}
+	h := file.Hash
+	if contents != nil {
+		h = contents
+	}
+
+	extents, err := dataExtents(f, info)
+	if err != nil {
+		return err
 	}
 
+	var body fs.File = f
+	switch {
+	case extents != nil:
+		if body, err = newSparseFile(f, info, extents, h); err != nil {
+			return err
+		}
+		s.log.Infow("Uploading extents of sparse file", "path", file.LocalPath, "extents", len(extents))
+	case h != nil:
+		body = newHashingFile(f, h)
+	}
+	file.Extents = extents
+
+	sc, chunksize := s.selectStorage(file.LocalPath, info)
+
 	file.Bucket = s.bucket
-	storeFile := NewFileFromDomain(file, s.csAlg, s.sc, f)
+	file.StorageClass = string(sc)
+	storeFile := NewFileFromDomain(file, s.csAlg, sc, body)
+	storeFile.ChunkSize = chunksize
+	s.applyObjectLock(storeFile)
 
 	eTag, version, err := s.upload(ctx, storeFile)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if contents != nil {
+		if err := s.verifyContents(ctx, file, version, contents.Sum(nil)); err != nil {
+			return err
+		}
 	}
 
 	file.ETag = eTag
 	file.Version = version
+	file.RetainUntil = storeFile.RetainUntil
+	file.LegalHold = storeFile.LegalHold
+
+	return nil
+}
+
+// Delete removes the uploaded version of the provided file from the storage
+// backend.
+func (s *Store) Delete(ctx context.Context, file *processor.File) error {
+	input := &s3.DeleteObjectInput{Bucket: &file.Bucket, Key: &file.Key}
+	if file.Version != "" {
+		input.VersionId = &file.Version
+	}
+
+	if _, err := s.client.DeleteObject(ctx, input); err != nil {
+		return fmt.Errorf("unable to delete object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) applyObjectLock(file *File) {
+	if s.lock == nil {
+		return
+	}
 
-	return file, nil
+	if s.lock.Mode != "" {
+		file.ObjectLockMode = s.lock.Mode
+		file.RetainUntil = s.clock.Now().Add(s.lock.Retention).UTC()
+	}
+	file.LegalHold = s.lock.LegalHold
 }
 
 func (s *Store) upload(ctx context.Context, file *File) (string, string, error) {
@@ -44,14 +138,14 @@ func (s *Store) upload(ctx context.Context, file *File) (string, string, error)
 		return "", "", err
 	}
 
-	if size < s.chunksize {
-		return s.singleUpload(ctx, file)
+	if size < file.ChunkSize {
+		return s.singleUpload(ctx, size, file)
 	} else {
 		return s.multipartUpload(ctx, size, file)
 	}
 }
 
-func (s *Store) singleUpload(ctx context.Context, file *File) (string, string, error) {
+func (s *Store) singleUpload(ctx context.Context, size int64, file *File) (string, string, error) {
 	defer s.reportElapsedFileUploadTime(time.Now(), file)
 
 	s.log.Infow(
@@ -59,18 +153,30 @@ func (s *Store) singleUpload(ctx context.Context, file *File) (string, string, e
 		"key", file.Key,
 	)
 
+	// The body must have a known length, and be seekable where possible, for
+	// the client to sign and checksum it, so it is hashed as it is read rather
+	// than through a plain io.TeeReader.
 	input := file.ToPutObjectInput()
+	input.ContentLength = size
+	h := crc32.NewIEEE()
+	input.Body = newHashingFile(file.File, h)
 
 	output, err := s.client.PutObject(ctx, (*s3.PutObjectInput)(input))
 	if err != nil {
 		return "", "", fmt.Errorf("unable to put object: %w", err)
 	}
+	s.reporter.BytesUploaded(ctx, size)
 
 	version := ""
 	if output.VersionId != nil {
 		version = *output.VersionId
 	}
 
+	err = s.verifyChecksum(ctx, file, version, EncodeCRC32(h.Sum32()), output.ChecksumCRC32)
+	if err != nil {
+		return "", "", err
+	}
+
 	return *output.ETag, version, nil
 }
 
@@ -82,8 +188,8 @@ func (s *Store) multipartUpload(
 
 	defer s.reportElapsedFileUploadTime(time.Now(), file)
 
-	numChunks := int(size / s.chunksize)
-	if size%s.chunksize != 0 {
+	numChunks := int(size / file.ChunkSize)
+	if size%file.ChunkSize != 0 {
 		numChunks += 1
 	}
 
@@ -99,6 +205,7 @@ func (s *Store) multipartUpload(
 	}
 
 	uploadOutputs := make([]*UploadPartOutput, numChunks)
+	partChecksums := make([]uint32, numChunks)
 
 	for i := 0; i < numChunks; i++ {
 		partNum := int32(i + 1)
@@ -107,8 +214,9 @@ func (s *Store) multipartUpload(
 			"key", file.Key,
 			"part", partNum,
 		)
-		uploadOutput, err := s.uploadPart(ctx, uploadID, partNum, size, file)
+		uploadOutput, partChecksum, err := s.uploadPart(ctx, uploadID, partNum, size, file)
 		if err != nil {
+			s.abortMultipartUpload(uploadID, file)
 			return "", "", fmt.Errorf("unable to upload file part: %w", err)
 		}
 		s.log.Debugw(
@@ -117,9 +225,10 @@ func (s *Store) multipartUpload(
 			"part", partNum,
 		)
 		uploadOutputs[i] = uploadOutput
+		partChecksums[i] = partChecksum
 	}
 
-	return s.closeMultiPartUpload(ctx, uploadID, uploadOutputs, file)
+	return s.closeMultiPartUpload(ctx, uploadID, uploadOutputs, partChecksums, file)
 }
 
 func (s *Store) createMultiPartUpload(
@@ -143,28 +252,32 @@ func (s *Store) uploadPart(
 	partNum int32,
 	size int64,
 	file *File,
-) (*UploadPartOutput, error) {
+) (*UploadPartOutput, uint32, error) {
 
-	chunksize := s.chunksize
-	remainingBytes := size - int64(partNum-1)*(s.chunksize)
+	chunksize := file.ChunkSize
+	remainingBytes := size - int64(partNum-1)*(file.ChunkSize)
 	if remainingBytes < chunksize {
 		chunksize = remainingBytes
 	}
 
 	input := file.ToUploadPartInput(uploadID, partNum, chunksize)
+	h := crc32.NewIEEE()
+	input.Body = io.TeeReader(input.Body, h)
 
 	output, err := s.client.UploadPart(ctx, (*s3.UploadPartInput)(input))
 	if err != nil {
-		return nil, fmt.Errorf("unable to upload file multipart part: %w", err)
+		return nil, 0, fmt.Errorf("unable to upload file multipart part: %w", err)
 	}
+	s.reporter.BytesUploaded(ctx, chunksize)
 
-	return (*UploadPartOutput)(output), nil
+	return (*UploadPartOutput)(output), h.Sum32(), nil
 }
 
 func (s *Store) closeMultiPartUpload(
 	ctx context.Context,
 	uploadID string,
 	parts []*UploadPartOutput,
+	partChecksums []uint32,
 	file *File,
 ) (string, string, error) {
 
@@ -172,6 +285,7 @@ func (s *Store) closeMultiPartUpload(
 
 	output, err := s.client.CompleteMultipartUpload(ctx, (*s3.CompleteMultipartUploadInput)(input))
 	if err != nil {
+		s.abortMultipartUpload(uploadID, file)
 		return "", "", fmt.Errorf("unable to complete multipart file upload: %w", err)
 	}
 
@@ -180,9 +294,40 @@ func (s *Store) closeMultiPartUpload(
 		version = *output.VersionId
 	}
 
+	expected := CompositeCRC32(partChecksums)
+	err = s.verifyChecksum(ctx, file, version, expected, output.ChecksumCRC32)
+	if err != nil {
+		return "", "", err
+	}
+
 	return *output.ETag, version, nil
 }
 
+// abortMultipartUpload discards the parts of an incomplete multipart upload, so
+// that they are not left in the bucket indefinitely. The upload is often being
+// abandoned because its context was cancelled, so a fresh one is used.
+func (s *Store) abortMultipartUpload(uploadID string, file *File) {
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   &file.Bucket,
+		Key:      &file.Key,
+		UploadId: &uploadID,
+	})
+	if err != nil {
+		s.log.Warnw(
+			"Unable to abort multipart upload",
+			"key", file.Key,
+			"upload_id", uploadID,
+			"error", err,
+		)
+		return
+	}
+
+	s.log.Infow("Aborted multipart upload", "key", file.Key, "upload_id", uploadID)
+}
+
 func (s *Store) reportElapsedFileUploadTime(start time.Time, fileUpload *File) {
 	elapsed := time.Since(start)
 	s.log.Infow(
@@ -192,3 +337,48 @@ func (s *Store) reportElapsedFileUploadTime(start time.Time, fileUpload *File) {
 		"elapsed_time", elapsed,
 	)
 }
+
+// hashingFile wraps a file so that everything read from it is also written to
+// a hash. Bytes read again after seeking back are not written a second time, so
+// the hash holds the contents of the file exactly once however many times the
+// client rewinds the file to sign, checksum or retry a request.
+type hashingFile struct {
+	fs.File
+	h      io.Writer
+	pos    int64
+	hashed int64
+}
+
+// seekableHashingFile is a hashingFile wrapping a file that can seek, which
+// the client requires to sign the body of a request sent without TLS.
+type seekableHashingFile struct {
+	*hashingFile
+	seeker io.Seeker
+}
+
+func newHashingFile(f fs.File, h io.Writer) fs.File {
+	hf := &hashingFile{File: f, h: h}
+	if seeker, ok := f.(io.Seeker); ok {
+		return &seekableHashingFile{hashingFile: hf, seeker: seeker}
+	}
+	return hf
+}
+
+func (f *hashingFile) Read(bs []byte) (int, error) {
+	n, err := f.File.Read(bs)
+	if end := f.pos + int64(n); f.pos <= f.hashed && end > f.hashed {
+		f.h.Write(bs[f.hashed-f.pos : n])
+		f.hashed = end
+	}
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *seekableHashingFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.seeker.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	f.pos = pos
+	return pos, nil
+}
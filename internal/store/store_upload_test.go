@@ -3,15 +3,25 @@ package store_test
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/golang/mock/gomock"
 
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
 	"github.com/mspraggs/hoard/internal/processor"
 	"github.com/mspraggs/hoard/internal/store"
+	"github.com/mspraggs/hoard/internal/store/mocks"
 )
 
 func (s *StoreTestSuite) TestUpload() {
@@ -33,11 +43,12 @@ func (s *StoreTestSuite) TestUpload() {
 		LocalPath: path,
 	}
 	expectedOutputFile := &processor.File{
-		Key:       key,
-		LocalPath: path,
-		Bucket:    bucket,
-		ETag:      eTag,
-		Version:   version,
+		Key:          key,
+		LocalPath:    path,
+		Bucket:       bucket,
+		StorageClass: string(types.StorageClassStandard),
+		ETag:         eTag,
+		Version:      version,
 	}
 
 	s.Run("given file smaller than chunk size", func() {
@@ -81,10 +92,11 @@ func (s *StoreTestSuite) TestUpload() {
 			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
 
 			expectedOutputFile := &processor.File{
-				Key:       key,
-				LocalPath: path,
-				Bucket:    bucket,
-				ETag:      eTag,
+				Key:          key,
+				LocalPath:    path,
+				Bucket:       bucket,
+				StorageClass: string(types.StorageClassStandard),
+				ETag:         eTag,
 			}
 
 			file, err := fs.Open(path)
@@ -186,6 +198,11 @@ func (s *StoreTestSuite) TestUpload() {
 			ETag:      &eTag,
 			VersionId: &version,
 		}
+		abortUploadInput := &s3.AbortMultipartUploadInput{
+			Bucket:   &bucket,
+			Key:      &key,
+			UploadId: &uploadID,
+		}
 
 		s.Run("reads and uploads versioned file", func() {
 			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
@@ -236,10 +253,11 @@ func (s *StoreTestSuite) TestUpload() {
 			ctx := context.WithValue(context.Background(), contextKey("key"), "value")
 
 			expectedOutputFile := &processor.File{
-				Key:       key,
-				LocalPath: path,
-				Bucket:    bucket,
-				ETag:      eTag,
+				Key:          key,
+				LocalPath:    path,
+				Bucket:       bucket,
+				StorageClass: string(types.StorageClassStandard),
+				ETag:         eTag,
 			}
 
 			file, err := fs.Open(path)
@@ -343,6 +361,9 @@ func (s *StoreTestSuite) TestUpload() {
 						UploadPart(ctx, newUploadPartInputMatcher(uploadPartInputs[1])).
 						Return(nil, expectedErr),
 				)
+				s.mockClient.EXPECT().
+					AbortMultipartUpload(gomock.Any(), abortUploadInput).
+					Return(&s3.AbortMultipartUploadOutput{}, nil)
 
 				store := store.New(
 					s.mockClient,
@@ -388,6 +409,9 @@ func (s *StoreTestSuite) TestUpload() {
 				s.mockClient.EXPECT().
 					CompleteMultipartUpload(ctx, completeUploadInput).
 					Return(nil, expectedErr)
+				s.mockClient.EXPECT().
+					AbortMultipartUpload(gomock.Any(), abortUploadInput).
+					Return(&s3.AbortMultipartUploadOutput{}, nil)
 
 				store := store.New(
 					s.mockClient,
@@ -444,3 +468,554 @@ func (s *StoreTestSuite) TestUpload() {
 		s.ErrorContains(err, "invalid chunk size")
 	})
 }
+
+func (s *StoreTestSuite) TestUploadWithObjectLock() {
+	key := "some-key"
+	path := "some/path"
+	body := []byte{0, 1, 2, 3}
+	bucket := "some-bucket"
+	eTag := "some-etag"
+	version := "some-version"
+	now := time.Unix(1000, 0)
+	retainUntil := now.Add(24 * time.Hour).UTC()
+
+	fs, err := newMemFS(map[string][]byte{path: body})
+	s.Require().NoError(err)
+
+	clock := fakeClock(func() time.Time { return now })
+	lock := store.ObjectLock{
+		Mode:      types.ObjectLockModeCompliance,
+		Retention: 24 * time.Hour,
+		LegalHold: true,
+	}
+
+	s.Run("locks single part upload", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		expectedOutputFile := &processor.File{
+			Key:          key,
+			LocalPath:    path,
+			Bucket:       bucket,
+			StorageClass: string(types.StorageClassStandard),
+			ETag:         eTag,
+			Version:      version,
+			RetainUntil:  retainUntil,
+			LegalHold:    true,
+		}
+
+		file, err := fs.Open(path)
+		s.Require().NoError(err)
+		defer file.Close()
+
+		putObjectInput := newTestPutObjectInput(
+			expectedOutputFile,
+			bucket,
+			types.ChecksumAlgorithmCrc32,
+			file,
+		)
+		putObjectInput.ObjectLockMode = types.ObjectLockModeCompliance
+		putObjectInput.ObjectLockRetainUntilDate = &retainUntil
+		putObjectInput.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, newPutObjectInputMatcher(putObjectInput)).
+			Return(&s3.PutObjectOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		store := store.New(
+			s.mockClient,
+			fs,
+			bucket,
+			store.WithObjectLock(lock),
+			store.WithClock(clock),
+		)
+
+		outputFile, err := store.Upload(ctx, &processor.File{Key: key, LocalPath: path})
+
+		s.Require().NoError(err)
+		s.Equal(expectedOutputFile, outputFile)
+	})
+	s.Run("locks multipart upload", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		uploadID := "some-upload-id"
+
+		s.mockClient.EXPECT().
+			CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+				Key:                       &key,
+				Bucket:                    &bucket,
+				ChecksumAlgorithm:         types.ChecksumAlgorithmCrc32,
+				StorageClass:              types.StorageClassStandard,
+				ObjectLockMode:            types.ObjectLockModeCompliance,
+				ObjectLockRetainUntilDate: &retainUntil,
+				ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn,
+			}).
+			Return(&s3.CreateMultipartUploadOutput{UploadId: &uploadID}, nil)
+		s.mockClient.EXPECT().
+			UploadPart(ctx, gomock.Any()).
+			DoAndReturn(s.makeDoUploadPart(body, int64(len(body)), eTag))
+		s.mockClient.EXPECT().
+			CompleteMultipartUpload(ctx, gomock.Any()).
+			Return(&s3.CompleteMultipartUploadOutput{ETag: &eTag, VersionId: &version}, nil)
+
+		store := store.New(
+			s.mockClient,
+			fs,
+			bucket,
+			store.WithChunkSize(int64(len(body))),
+			store.WithObjectLock(lock),
+			store.WithClock(clock),
+		)
+
+		outputFile, err := store.Upload(ctx, &processor.File{Key: key, LocalPath: path})
+
+		s.Require().NoError(err)
+		s.Equal(retainUntil, outputFile.RetainUntil)
+		s.True(outputFile.LegalHold)
+	})
+}
+
+func (s *StoreTestSuite) TestUploadReportsProgress() {
+	path := "some/path"
+	body := []byte{0, 1, 2, 3}
+	eTag := "some-etag"
+	uploadID := "some-upload-id"
+
+	fs, err := newMemFS(map[string][]byte{path: body})
+	s.Require().NoError(err)
+
+	s.Run("for single part upload", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		reporter := mocks.NewMockReporter(s.controller)
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, gomock.Any()).
+			Return(&s3.PutObjectOutput{ETag: &eTag}, nil)
+		reporter.EXPECT().BytesUploaded(ctx, int64(len(body)))
+
+		store := store.New(
+			s.mockClient, fs, "some-bucket",
+			store.WithChunkSize(5),
+			store.WithReporter(reporter),
+		)
+
+		_, err := store.Upload(ctx, &processor.File{Key: "some-key", LocalPath: path})
+
+		s.Require().NoError(err)
+	})
+	s.Run("for each part of multipart upload", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		reporter := mocks.NewMockReporter(s.controller)
+
+		s.mockClient.EXPECT().
+			CreateMultipartUpload(ctx, gomock.Any()).
+			Return(&s3.CreateMultipartUploadOutput{UploadId: &uploadID}, nil)
+		s.mockClient.EXPECT().
+			UploadPart(ctx, gomock.Any()).
+			Return(&s3.UploadPartOutput{ETag: &eTag}, nil).
+			Times(2)
+		s.mockClient.EXPECT().
+			CompleteMultipartUpload(ctx, gomock.Any()).
+			Return(&s3.CompleteMultipartUploadOutput{ETag: &eTag}, nil)
+		gomock.InOrder(
+			reporter.EXPECT().BytesUploaded(ctx, int64(3)),
+			reporter.EXPECT().BytesUploaded(ctx, int64(1)),
+		)
+
+		store := store.New(
+			s.mockClient, fs, "some-bucket",
+			store.WithChunkSize(3),
+			store.WithReporter(reporter),
+		)
+
+		_, err := store.Upload(ctx, &processor.File{Key: "some-key", LocalPath: path})
+
+		s.Require().NoError(err)
+	})
+	s.Run("for upload without checksum", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		reporter := &unverifiedReporter{
+			MockReporter:           mocks.NewMockReporter(s.controller),
+			MockUnverifiedReporter: mocks.NewMockUnverifiedReporter(s.controller),
+		}
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, gomock.Any()).
+			Return(&s3.PutObjectOutput{ETag: &eTag}, nil)
+		reporter.MockReporter.EXPECT().BytesUploaded(ctx, int64(len(body)))
+		reporter.MockUnverifiedReporter.EXPECT().UploadUnverified(ctx)
+
+		store := store.New(
+			s.mockClient, fs, "some-bucket",
+			store.WithChunkSize(5),
+			store.WithReporter(reporter),
+		)
+
+		_, err := store.Upload(ctx, &processor.File{Key: "some-key", LocalPath: path})
+
+		s.Require().NoError(err)
+	})
+	s.Run("not for upload without requested checksum", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		reporter := &unverifiedReporter{
+			MockReporter:           mocks.NewMockReporter(s.controller),
+			MockUnverifiedReporter: mocks.NewMockUnverifiedReporter(s.controller),
+		}
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, gomock.Any()).
+			Return(&s3.PutObjectOutput{ETag: &eTag}, nil)
+		reporter.MockReporter.EXPECT().BytesUploaded(ctx, int64(len(body)))
+
+		store := store.New(
+			s.mockClient, fs, "some-bucket",
+			store.WithChunkSize(5),
+			store.WithChecksumAlgorithm(""),
+			store.WithReporter(reporter),
+		)
+
+		_, err := store.Upload(ctx, &processor.File{Key: "some-key", LocalPath: path})
+
+		s.Require().NoError(err)
+	})
+}
+
+type unverifiedReporter struct {
+	*mocks.MockReporter
+	*mocks.MockUnverifiedReporter
+}
+
+func (s *StoreTestSuite) TestUploadVerifiesChecksum() {
+	path := "some/path"
+	body := []byte{0, 1, 2, 3}
+	bucket := "some-bucket"
+	key := "some-key"
+	eTag := "some-etag"
+	version := "some-version"
+	uploadID := "some-upload-id"
+
+	fs, err := newMemFS(map[string][]byte{path: body})
+	s.Require().NoError(err)
+
+	checksum := crc32.ChecksumIEEE(body)
+	goodChecksum := store.EncodeCRC32(checksum)
+	badChecksum := store.EncodeCRC32(checksum + 1)
+
+	inputFile := func() *processor.File {
+		return &processor.File{Key: key, LocalPath: path}
+	}
+
+	s.Run("accepts matching single part checksum", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, gomock.Any()).
+			DoAndReturn(s.makeDoPutObject(&s3.PutObjectOutput{
+				ETag: &eTag, VersionId: &version, ChecksumCRC32: &goodChecksum,
+			}))
+
+		store := store.New(s.mockClient, fs, bucket, store.WithChunkSize(5))
+
+		file, err := store.Upload(ctx, inputFile())
+
+		s.Require().NoError(err)
+		s.Equal(version, file.Version)
+	})
+	s.Run("deletes and retries mismatched single part upload", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		badVersion := "bad-version"
+
+		gomock.InOrder(
+			s.mockClient.EXPECT().
+				PutObject(ctx, gomock.Any()).
+				DoAndReturn(s.makeDoPutObject(&s3.PutObjectOutput{
+					ETag: &eTag, VersionId: &badVersion, ChecksumCRC32: &badChecksum,
+				})),
+			s.mockClient.EXPECT().
+				DeleteObject(ctx, &s3.DeleteObjectInput{
+					Bucket: &bucket, Key: &key, VersionId: &badVersion,
+				}).
+				Return(&s3.DeleteObjectOutput{}, nil),
+			s.mockClient.EXPECT().
+				PutObject(ctx, gomock.Any()).
+				DoAndReturn(func(
+					ctx context.Context,
+					input *s3.PutObjectInput,
+					optFns ...func(*s3.Options),
+				) (*s3.PutObjectOutput, error) {
+					actual, err := io.ReadAll(input.Body)
+					s.Require().NoError(err)
+					s.Equal(body, actual)
+					return &s3.PutObjectOutput{
+						ETag: &eTag, VersionId: &version, ChecksumCRC32: &goodChecksum,
+					}, nil
+				}),
+		)
+
+		store := store.New(s.mockClient, fs, bucket, store.WithChunkSize(5))
+
+		file, err := store.Upload(ctx, inputFile())
+
+		s.Require().NoError(err)
+		s.Equal(version, file.Version)
+	})
+	s.Run("returns error after max attempts", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			PutObject(ctx, gomock.Any()).
+			DoAndReturn(s.makeDoPutObject(&s3.PutObjectOutput{
+				ETag: &eTag, VersionId: &version, ChecksumCRC32: &badChecksum,
+			})).
+			Times(2)
+		s.mockClient.EXPECT().
+			DeleteObject(ctx, gomock.Any()).
+			Return(&s3.DeleteObjectOutput{}, nil).
+			Times(2)
+
+		store := store.New(
+			s.mockClient, fs, bucket,
+			store.WithChunkSize(5),
+			store.WithMaxAttempts(2),
+		)
+
+		file, err := store.Upload(ctx, inputFile())
+
+		s.Nil(file)
+		s.ErrorIs(err, hoarderrors.ErrChecksumMismatch)
+	})
+	s.Run("compares contents with checksum computed before upload", func() {
+		testCases := []struct {
+			name        string
+			checksum    uint32
+			expectedErr error
+		}{
+			{"with matching checksum", checksum, nil},
+			{"with mismatched checksum", checksum + 1, hoarderrors.ErrChecksumMismatch},
+		}
+
+		for _, tc := range testCases {
+			s.Run(tc.name, func() {
+				ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+				s.mockClient.EXPECT().
+					PutObject(ctx, gomock.Any()).
+					DoAndReturn(s.makeDoPutObject(&s3.PutObjectOutput{
+						ETag: &eTag, VersionId: &version, ChecksumCRC32: &goodChecksum,
+					}))
+				if tc.expectedErr != nil {
+					s.mockClient.EXPECT().
+						DeleteObject(ctx, &s3.DeleteObjectInput{
+							Bucket: &bucket, Key: &key, VersionId: &version,
+						}).
+						Return(&s3.DeleteObjectOutput{}, nil)
+				}
+
+				store := store.New(
+					s.mockClient, fs, bucket,
+					store.WithChunkSize(5),
+					store.WithMaxAttempts(1),
+				)
+
+				file := inputFile()
+				file.Checksum = make([]byte, 4)
+				binary.BigEndian.PutUint32(file.Checksum, tc.checksum)
+				file.ChecksumAlgorithm = "CRC32"
+
+				_, err := store.Upload(ctx, file)
+
+				if tc.expectedErr != nil {
+					s.ErrorIs(err, tc.expectedErr)
+				} else {
+					s.NoError(err)
+				}
+			})
+		}
+	})
+	s.Run("compares composite checksum of multipart upload", func() {
+		h := crc32.NewIEEE()
+		for _, part := range [][]byte{body[:3], body[3:]} {
+			binary.Write(h, binary.BigEndian, crc32.ChecksumIEEE(part))
+		}
+		composite := store.EncodeCRC32(h.Sum32()) + "-2"
+
+		testCases := []struct {
+			name           string
+			actualChecksum string
+			expectedErr    error
+		}{
+			{"with matching checksum", composite, nil},
+			{"with mismatched checksum", badChecksum + "-2", hoarderrors.ErrChecksumMismatch},
+		}
+
+		for _, tc := range testCases {
+			s.Run(tc.name, func() {
+				ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+				s.mockClient.EXPECT().
+					CreateMultipartUpload(ctx, gomock.Any()).
+					Return(&s3.CreateMultipartUploadOutput{UploadId: &uploadID}, nil)
+				s.mockClient.EXPECT().
+					UploadPart(ctx, gomock.Any()).
+					DoAndReturn(func(
+						ctx context.Context,
+						input *s3.UploadPartInput,
+						optFns ...func(*s3.Options),
+					) (*s3.UploadPartOutput, error) {
+						_, err := io.ReadAll(input.Body)
+						s.Require().NoError(err)
+						return &s3.UploadPartOutput{ETag: &eTag}, nil
+					}).
+					Times(2)
+				s.mockClient.EXPECT().
+					CompleteMultipartUpload(ctx, gomock.Any()).
+					Return(&s3.CompleteMultipartUploadOutput{
+						ETag: &eTag, VersionId: &version, ChecksumCRC32: &tc.actualChecksum,
+					}, nil)
+				if tc.expectedErr != nil {
+					s.mockClient.EXPECT().
+						DeleteObject(ctx, gomock.Any()).
+						Return(&s3.DeleteObjectOutput{}, nil)
+				}
+
+				store := store.New(
+					s.mockClient, fs, bucket,
+					store.WithChunkSize(3),
+					store.WithMaxAttempts(1),
+				)
+
+				_, err := store.Upload(ctx, inputFile())
+
+				if tc.expectedErr != nil {
+					s.ErrorIs(err, tc.expectedErr)
+				} else {
+					s.NoError(err)
+				}
+			})
+		}
+	})
+}
+
+func (s *StoreTestSuite) TestUploadWritesContentsToHash() {
+	path := "some/path"
+	body := []byte{0, 1, 2, 3}
+	eTag := "some-etag"
+
+	fs, err := newMemFS(map[string][]byte{path: body})
+	s.Require().NoError(err)
+
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	h := crc32.NewIEEE()
+	h.Write([]byte("stale"))
+
+	s.mockClient.EXPECT().
+		PutObject(ctx, gomock.Any()).
+		DoAndReturn(s.makeDoPutObject(&s3.PutObjectOutput{ETag: &eTag}))
+
+	store := store.New(s.mockClient, fs, "some-bucket", store.WithChunkSize(5))
+
+	_, err = store.Upload(ctx, &processor.File{Key: "some-key", LocalPath: path, Hash: h})
+
+	s.Require().NoError(err)
+	s.Equal(crc32.ChecksumIEEE(body), h.Sum32())
+}
+
+func (s *StoreTestSuite) TestDelete() {
+	bucket := "some-bucket"
+	key := "some-key"
+	version := "some-version"
+
+	s.Run("deletes uploaded version", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+
+		s.mockClient.EXPECT().
+			DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: &bucket, Key: &key, VersionId: &version,
+			}).
+			Return(&s3.DeleteObjectOutput{}, nil)
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		err := store.Delete(ctx, &processor.File{Key: key, Bucket: bucket, Version: version})
+
+		s.Require().NoError(err)
+	})
+	s.Run("handles error from client", func() {
+		ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+		expectedErr := errors.New("oh no")
+
+		s.mockClient.EXPECT().DeleteObject(ctx, gomock.Any()).Return(nil, expectedErr)
+
+		store := store.New(s.mockClient, nil, bucket)
+
+		err := store.Delete(ctx, &processor.File{Key: key, Bucket: bucket, Version: version})
+
+		s.ErrorIs(err, expectedErr)
+	})
+}
+
+func (s *StoreTestSuite) TestUploadWithClient() {
+	bucket := "some-bucket"
+	eTag := `"some-etag"`
+	version := "some-version"
+	size := int64(4 * 1024 * 1024)
+	offset := int64(1024 * 1024)
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 16*1024)
+
+	dir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "regular"), data, 0o644))
+	f, err := os.Create(filepath.Join(dir, "sparse"))
+	s.Require().NoError(err)
+	_, err = f.WriteAt(data, offset)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Truncate(size))
+	s.Require().NoError(f.Close())
+
+	var received []byte
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		s.Require().NoError(err)
+		received = body
+		contentLength = r.ContentLength
+
+		w.Header().Set("ETag", eTag)
+		w.Header().Set("x-amz-version-id", version)
+		w.Header().Set("x-amz-checksum-crc32", store.EncodeCRC32(crc32.ChecksumIEEE(body)))
+	}))
+	defer server.Close()
+
+	client := s3.New(s3.Options{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		EndpointResolver: s3.EndpointResolverFromURL(server.URL),
+		UsePathStyle:     true,
+	})
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{"for regular file", "regular"},
+		{"for sparse file", "sparse"},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			contents, err := os.ReadFile(filepath.Join(dir, tc.path))
+			s.Require().NoError(err)
+
+			h := crc32.NewIEEE()
+			file := &processor.File{Key: "some-key", LocalPath: tc.path, Hash: h}
+
+			store := store.New(client, os.DirFS(dir), bucket, store.WithChunkSize(size+1))
+
+			file, err = store.Upload(context.Background(), file)
+
+			s.Require().NoError(err)
+			s.Equal(eTag, file.ETag)
+			s.Equal(version, file.Version)
+			s.Equal(int64(len(received)), contentLength)
+			s.Contains(string(received), string(data))
+			s.Equal(crc32.ChecksumIEEE(contents), h.Sum32())
+		})
+	}
+}
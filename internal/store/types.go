@@ -3,6 +3,7 @@ package store
 import (
 	"io"
 	"io/fs"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
@@ -13,6 +14,10 @@ import (
 // backend.
 type StorageClass = types.StorageClass
 
+// ObjectLockMode denotes the retention mode applied to locked objects, as
+// specified by the storage backend.
+type ObjectLockMode = types.ObjectLockMode
+
 // ChecksumAlgorithm defines the algorithm used to generate a checksum when
 // verifying uploads to the storage backend.
 type ChecksumAlgorithm = types.ChecksumAlgorithm
@@ -24,6 +29,11 @@ type File struct {
 	Bucket            string
 	ChecksumAlgorithm ChecksumAlgorithm
 	StorageClass      StorageClass
+	ChunkSize         int64
+	Metadata          map[string]string
+	ObjectLockMode    ObjectLockMode
+	RetainUntil       time.Time
+	LegalHold         bool
 	File              fs.File
 }
 
@@ -77,6 +87,11 @@ func (f *File) ToCreateMultipartUploadInput() *CreateMultipartUploadInput {
 		Key:               &f.Key,
 		ChecksumAlgorithm: f.ChecksumAlgorithm,
 		StorageClass:      f.StorageClass,
+		Metadata:          f.Metadata,
+
+		ObjectLockMode:            f.ObjectLockMode,
+		ObjectLockRetainUntilDate: f.retainUntilInput(),
+		ObjectLockLegalHoldStatus: f.legalHoldInput(),
 	}
 
 	return input
@@ -143,12 +158,31 @@ func (f *File) ToPutObjectInput() *PutObjectInput {
 		Key:               &f.Key,
 		ChecksumAlgorithm: f.ChecksumAlgorithm,
 		StorageClass:      f.StorageClass,
+		Metadata:          f.Metadata,
 		Body:              f.File,
+
+		ObjectLockMode:            f.ObjectLockMode,
+		ObjectLockRetainUntilDate: f.retainUntilInput(),
+		ObjectLockLegalHoldStatus: f.legalHoldInput(),
 	}
 
 	return input
 }
 
+func (f *File) retainUntilInput() *time.Time {
+	if f.ObjectLockMode == "" {
+		return nil
+	}
+	return &f.RetainUntil
+}
+
+func (f *File) legalHoldInput() types.ObjectLockLegalHoldStatus {
+	if !f.LegalHold {
+		return ""
+	}
+	return types.ObjectLockLegalHoldStatusOn
+}
+
 // Size returns the size of the file.
 func (f *File) Size() (int64, error) {
 	info, err := f.File.Stat()
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/mspraggs/hoard/internal/checksum"
+	hoarderrors "github.com/mspraggs/hoard/internal/errors"
+	"github.com/mspraggs/hoard/internal/processor"
+)
+
+const defaultMaxAttempts = 3
+
+// EncodeCRC32 encodes the provided CRC32 checksum in the base64 form used by
+// the storage backend.
+func EncodeCRC32(sum uint32) string {
+	bs := make([]byte, 4)
+	binary.BigEndian.PutUint32(bs, sum)
+	return base64.StdEncoding.EncodeToString(bs)
+}
+
+// CompositeCRC32 computes the checksum the storage backend reports for a
+// multi-part upload with parts having the provided CRC32 checksums, which is
+// the checksum of the concatenated part checksums followed by the number of
+// parts.
+func CompositeCRC32(parts []uint32) string {
+	h := crc32.NewIEEE()
+	for _, part := range parts {
+		binary.Write(h, binary.BigEndian, part)
+	}
+	return fmt.Sprintf("%s-%d", EncodeCRC32(h.Sum32()), len(parts))
+}
+
+// verifyChecksum compares the checksum computed locally for an uploaded object
+// with the checksum returned by the storage backend. If the two differ, the
+// uploaded object version is deleted and an error wrapping
+// ErrChecksumMismatch is returned. Nothing is compared if the backend returned
+// no checksum, but if one was requested the upload is logged and reported as
+// unverified.
+func (s *Store) verifyChecksum(
+	ctx context.Context,
+	file *File,
+	version string,
+	expected string,
+	actual *string,
+) error {
+
+	if expected == "" {
+		return nil
+	}
+	if actual == nil || *actual == "" {
+		if s.csAlg != "" {
+			s.log.Warnw(
+				"No checksum returned for uploaded object, unable to verify it",
+				"key", file.Key,
+				"version", version,
+			)
+			if r, ok := s.reporter.(UnverifiedReporter); ok {
+				r.UploadUnverified(ctx)
+			}
+		}
+		return nil
+	}
+	if trimPartCount(*actual) == trimPartCount(expected) {
+		return nil
+	}
+
+	s.log.Warnw(
+		"Checksum mismatch, deleting uploaded object",
+		"key", file.Key,
+		"version", version,
+		"expected_checksum", expected,
+		"actual_checksum", *actual,
+	)
+
+	if err := s.deleteMismatched(ctx, file.Bucket, file.Key, version); err != nil {
+		return err
+	}
+
+	return fmt.Errorf(
+		"%w: expected %s, got %s", hoarderrors.ErrChecksumMismatch, expected, *actual,
+	)
+}
+
+// contentHash returns a hash to which the contents of the provided file should
+// be written as they are uploaded, so that they can be compared with the
+// checksum computed before the upload. Nil is returned if there is no such
+// checksum, because it is being computed during the upload instead.
+func contentHash(file *processor.File) (hash.Hash, error) {
+	if file.Hash != nil || file.Checksum == nil {
+		return nil, nil
+	}
+	return checksum.Algorithm(file.ChecksumAlgorithm).New()
+}
+
+// checkContents compares the checksum of the contents read from a file with
+// the checksum computed for the file before it was uploaded. The two differ if
+// the file was modified or misread after its checksum was computed, in which
+// case an error wrapping ErrChecksumMismatch is returned.
+func checkContents(file *processor.File, sum []byte) error {
+	if bytes.Equal(file.Checksum, sum) {
+		return nil
+	}
+	return fmt.Errorf(
+		"%w: contents of %s differ from checksum computed before upload",
+		hoarderrors.ErrChecksumMismatch, file.LocalPath,
+	)
+}
+
+// verifyContents checks the contents uploaded for a file against the checksum
+// computed for the file before it was uploaded. If the two differ, the
+// uploaded object version is deleted and an error wrapping ErrChecksumMismatch
+// is returned.
+func (s *Store) verifyContents(
+	ctx context.Context,
+	file *processor.File,
+	version string,
+	sum []byte,
+) error {
+
+	err := checkContents(file, sum)
+	if err == nil {
+		return nil
+	}
+
+	s.log.Warnw(
+		"Uploaded contents differ from checksum, deleting uploaded object",
+		"key", file.Key,
+		"version", version,
+		"path", file.LocalPath,
+	)
+
+	if err := s.deleteMismatched(ctx, file.Bucket, file.Key, version); err != nil {
+		return err
+	}
+
+	return err
+}
+
+func (s *Store) deleteMismatched(ctx context.Context, bucket, key, version string) error {
+	input := &s3.DeleteObjectInput{Bucket: &bucket, Key: &key}
+	if version != "" {
+		input.VersionId = &version
+	}
+	if _, err := s.client.DeleteObject(ctx, input); err != nil {
+		return fmt.Errorf("unable to delete object with mismatched checksum: %w", err)
+	}
+	return nil
+}
+
+// shouldRetry reports whether an upload that failed with the provided error
+// on the provided attempt should be retried.
+func (s *Store) shouldRetry(err error, key string, attempt int) bool {
+	if !errors.Is(err, hoarderrors.ErrChecksumMismatch) || attempt >= s.maxAttempts {
+		return false
+	}
+
+	s.log.Warnw(
+		"Retrying upload after checksum mismatch",
+		"key", key,
+		"attempt", attempt,
+		"error", err,
+	)
+
+	return true
+}
+
+func trimPartCount(checksum string) string {
+	if i := strings.LastIndex(checksum, "-"); i >= 0 {
+		return checksum[:i]
+	}
+	return checksum
+}
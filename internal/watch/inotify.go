@@ -0,0 +1,195 @@
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+const (
+	inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB |
+		unix.IN_CREATE | unix.IN_MOVED_TO
+	inotifyBufferSize = 64 * (unix.SizeofInotifyEvent + unix.NAME_MAX + 1)
+	eventBufferSize   = 4096
+)
+
+// Inotify is a Source that reports changes beneath a root directory using the
+// Linux inotify API. Every directory in the hierarchy is watched, including
+// directories created after the watch starts.
+type Inotify struct {
+	log        *zap.SugaredLogger
+	root       string
+	fd         int
+	file       *os.File
+	events     chan Event
+	overflowed bool
+	mu         sync.Mutex
+	watches    map[int]string
+}
+
+// NewInotify starts watching the hierarchy beneath the provided root
+// directory. Directories that cannot be watched, for example because the limit
+// on the number of watches has been reached, are logged and skipped, leaving
+// their changes to be picked up by periodic rescans.
+func NewInotify(root string) (*Inotify, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	// The descriptor is non-blocking, so that reads are handled by the runtime
+	// poller and closing the file interrupts them. Calling Fd on the file would
+	// make it blocking again, so the descriptor is kept separately.
+	n := &Inotify{
+		log:     util.MustNewLogger(),
+		root:    root,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan Event, eventBufferSize),
+		watches: make(map[int]string),
+	}
+	if err := n.addTree(".", false); err != nil {
+		n.file.Close()
+		return nil, err
+	}
+
+	go n.read()
+
+	return n, nil
+}
+
+// Events returns the channel on which change events are delivered. The
+// channel is closed once the Inotify instance is closed.
+func (n *Inotify) Events() <-chan Event {
+	return n.events
+}
+
+// Close stops watching the hierarchy.
+func (n *Inotify) Close() error {
+	return n.file.Close()
+}
+
+// addTree watches the directory with the provided path relative to the root,
+// along with every directory beneath it. If emit is set, an event is sent for
+// every entry found, since they may have been created before the watch was
+// in place.
+func (n *Inotify) addTree(dir string, emit bool) error {
+	return filepath.WalkDir(n.fullPath(dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			n.log.Warnw("Unable to watch path", "error", err, "path", path)
+			return nil
+		}
+
+		rel, err := filepath.Rel(n.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if emit && rel != dir {
+			n.send(Event{Path: rel})
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		wd, err := unix.InotifyAddWatch(n.fd, path, inotifyMask)
+		if err != nil {
+			n.log.Warnw("Unable to watch directory", "error", err, "path", rel)
+			return fs.SkipDir
+		}
+
+		n.mu.Lock()
+		n.watches[wd] = rel
+		n.mu.Unlock()
+
+		return nil
+	})
+}
+
+// read decodes events from the inotify file descriptor until it is closed.
+func (n *Inotify) read() {
+	defer close(n.events)
+
+	buf := make([]byte, inotifyBufferSize)
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				n.log.Warnw("Unable to read change events", "error", err)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= size; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(raw.Len)], "\x00"))
+			offset = nameStart + int(raw.Len)
+
+			n.handle(int(raw.Wd), raw.Mask, name)
+		}
+	}
+}
+
+func (n *Inotify) handle(wd int, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		n.send(Event{Overflow: true})
+		return
+	}
+
+	n.mu.Lock()
+	dir, ok := n.watches[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(n.watches, wd)
+	}
+	n.mu.Unlock()
+	if !ok || name == "" {
+		return
+	}
+
+	path := name
+	if dir != "." {
+		path = dir + "/" + name
+	}
+	n.send(Event{Path: path})
+
+	if mask&unix.IN_ISDIR != 0 && mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		if err := n.addTree(path, true); err != nil {
+			n.log.Warnw("Unable to watch new directory", "error", err, "path", path)
+		}
+	}
+}
+
+// send delivers an event without blocking the reader. If the event buffer is
+// full, the event is dropped and an overflow is reported as soon as there is
+// room, so that the consumer rescans instead.
+func (n *Inotify) send(event Event) {
+	if n.overflowed {
+		select {
+		case n.events <- Event{Overflow: true}:
+			n.overflowed = false
+		default:
+			return
+		}
+	}
+
+	select {
+	case n.events <- event:
+	default:
+		n.overflowed = true
+	}
+}
+
+func (n *Inotify) fullPath(path string) string {
+	return filepath.Join(n.root, filepath.FromSlash(path))
+}
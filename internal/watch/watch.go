@@ -0,0 +1,368 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/progress"
+	"github.com/mspraggs/hoard/internal/util"
+)
+
+//go:generate mockgen -destination=./mocks/watch.go -package=mocks -source=$GOFILE
+
+const (
+	defaultDebounce         = 5 * time.Second
+	defaultRescanInterval   = 6 * time.Hour
+	defaultRetryInterval    = time.Minute
+	defaultOverflowInterval = time.Minute
+)
+
+// ErrSourceClosed is returned by a Watcher if its event source stops
+// delivering events.
+var ErrSourceClosed = errors.New("event source closed")
+
+// Event describes a change to the watched hierarchy. An overflow event
+// denotes that changes may have been missed, and carries no path.
+type Event struct {
+	Path     string
+	Overflow bool
+}
+
+// Source is the interface required to receive change events for the paths
+// within a watched hierarchy.
+type Source interface {
+	Events() <-chan Event
+}
+
+// Processor is the interface required to back up a single changed path.
+type Processor interface {
+	Process(ctx context.Context, path string) (*processor.File, error)
+}
+
+// Scanner is the interface required to back up the entire watched hierarchy.
+type Scanner interface {
+	Scan(ctx context.Context) error
+}
+
+// Resetter is implemented by processors that keep state across the paths they
+// process, such as the hard links found so far, which is cleared once each
+// batch of changed paths has been processed.
+type Resetter interface {
+	Reset()
+}
+
+// RunAdvancer is implemented by processors that check the checksums of a
+// different share of their files on each run, which is advanced before each
+// rescan so that a long-lived watcher rotates through every file.
+type RunAdvancer interface {
+	NextRun()
+}
+
+// Scheduler is the interface required to run the processing of changed paths
+// on a worker pool shared with other directories.
+type Scheduler interface {
+	Submit(ctx context.Context, size int64, fn func(worker int)) error
+	Wait()
+}
+
+// Clock defines the interface required to fetch the current time, against
+// which changes are debounced and retried.
+type Clock interface {
+	Now() time.Time
+}
+
+// Filter is the interface required to decide whether a changed path should be
+// left out of the backup.
+type Filter interface {
+	Excluded(path string) bool
+}
+
+// Option is the type used to implement the functional options pattern for the
+// Watcher type.
+type Option func(*Watcher)
+
+// Watcher backs up the paths within a directory hierarchy as they change.
+// Changes are debounced, so that a path is only processed once it has stopped
+// changing, and paths that could not be processed are held in a pending queue
+// and retried, so that transient outages of the storage backend or registry do
+// not lose changes. The whole hierarchy is rescanned periodically, and whenever
+// the event source reports that events were missed, though no more often than
+// the overflow interval allows. Scans run in the background, so that events
+// are still received during them, while changed paths are held until the scan
+// completes.
+type Watcher struct {
+	log              *zap.SugaredLogger
+	source           Source
+	processor        Processor
+	scanner          Scanner
+	filter           Filter
+	scheduler        Scheduler
+	clock            Clock
+	debounce         time.Duration
+	rescanInterval   time.Duration
+	retryInterval    time.Duration
+	overflowInterval time.Duration
+	pending          map[string]time.Time
+	lastScan         time.Time
+	overflowed       bool
+	scanned          bool
+	scanDone         chan struct{}
+}
+
+// New instantiates a new Watcher that processes the changes reported by the
+// provided source.
+func New(source Source, processor Processor, scanner Scanner, opts ...Option) *Watcher {
+	w := &Watcher{
+		log:              util.MustNewLogger(),
+		source:           source,
+		processor:        processor,
+		scanner:          scanner,
+		clock:            &util.Clock{},
+		debounce:         defaultDebounce,
+		rescanInterval:   defaultRescanInterval,
+		retryInterval:    defaultRetryInterval,
+		overflowInterval: defaultOverflowInterval,
+		pending:          make(map[string]time.Time),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// WithDebounce returns an option that sets how long a path must go without
+// changing before a Watcher processes it.
+func WithDebounce(debounce time.Duration) Option {
+	return func(w *Watcher) {
+		w.debounce = debounce
+	}
+}
+
+// WithRescanInterval returns an option that sets how often a Watcher rescans
+// the entire hierarchy to catch any changes it missed.
+func WithRescanInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.rescanInterval = interval
+	}
+}
+
+// WithRetryInterval returns an option that sets how long a Watcher waits
+// before retrying a path it could not process.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.retryInterval = interval
+	}
+}
+
+// WithOverflowInterval returns an option that sets the shortest time between
+// the start of one scan and a rescan prompted by missed events. Events missed
+// sooner than this are caught by a single rescan once the interval has passed.
+func WithOverflowInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.overflowInterval = interval
+	}
+}
+
+// WithScheduler returns an option that causes a Watcher to submit the changed
+// paths it processes to the provided scheduler rather than processing them one
+// at a time.
+func WithScheduler(scheduler Scheduler) Option {
+	return func(w *Watcher) {
+		w.scheduler = scheduler
+	}
+}
+
+// WithClock returns an option that sets the clock a Watcher uses to debounce
+// and retry changed paths and to limit rescans.
+func WithClock(clock Clock) Option {
+	return func(w *Watcher) {
+		w.clock = clock
+	}
+}
+
+// WithFilter returns an option that sets the filter a Watcher uses to skip
+// excluded paths.
+func WithFilter(filter Filter) Option {
+	return func(w *Watcher) {
+		w.filter = filter
+	}
+}
+
+// Run scans the entire hierarchy and then processes changes as they are
+// reported, until the provided context is canceled. Any scan in progress is
+// waited for before Run returns.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.waitForScan()
+	w.rescan(ctx)
+
+	tick := w.debounce / 2
+	if tick <= 0 {
+		tick = time.Second
+	}
+	flush := time.NewTicker(tick)
+	defer flush.Stop()
+	rescan := time.NewTicker(w.rescanInterval)
+	defer rescan.Stop()
+
+	events := w.source.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Infow("Stopped watching", "pending", len(w.pending))
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return ErrSourceClosed
+			}
+			if event.Overflow {
+				w.log.Warnw("Change events were missed, rescanning")
+				w.overflowed = true
+				w.rescanOverflowed(ctx)
+				continue
+			}
+			w.pending[event.Path] = w.clock.Now().Add(w.debounce)
+		case <-w.scanDone:
+			w.scanDone = nil
+		case <-flush.C:
+			w.rescanOverflowed(ctx)
+			if w.scanDone == nil {
+				w.processPending(ctx)
+			}
+		case <-rescan.C:
+			w.rescan(ctx)
+		}
+	}
+}
+
+// processPending processes every pending path that has stopped changing,
+// submitting them to the watcher's scheduler if it has one. Paths that could
+// not be processed are retried later, unless they no longer exist. The
+// processor is reset once every path has been processed.
+func (w *Watcher) processPending(ctx context.Context) {
+	now := w.clock.Now()
+	var ready []string
+	for path, at := range w.pending {
+		if at.After(now) {
+			continue
+		}
+		if w.filter != nil && w.filter.Excluded(path) {
+			w.log.Debugw("Skipping excluded path", "path", path)
+			delete(w.pending, path)
+			continue
+		}
+		ready = append(ready, path)
+	}
+	if len(ready) == 0 {
+		return
+	}
+
+	errs := make([]error, len(ready))
+	done := make([]bool, len(ready))
+	if w.scheduler == nil {
+		for i, path := range ready {
+			if ctx.Err() != nil {
+				break
+			}
+			errs[i], done[i] = w.process(ctx, path), true
+		}
+	} else {
+		for i, path := range ready {
+			i, path := i, path
+			err := w.scheduler.Submit(ctx, 0, func(worker int) {
+				errs[i], done[i] = w.process(progress.WithWorker(ctx, worker), path), true
+			})
+			if err != nil {
+				break
+			}
+		}
+		w.scheduler.Wait()
+	}
+
+	for i, path := range ready {
+		if !done[i] {
+			continue
+		}
+		if err := errs[i]; err != nil {
+			w.log.Warnw(
+				"Error processing changed path, will retry",
+				"error", err,
+				"path", path,
+				"retry_interval", w.retryInterval,
+			)
+			w.pending[path] = now.Add(w.retryInterval)
+			continue
+		}
+		delete(w.pending, path)
+	}
+
+	if r, ok := w.processor.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+// process processes the provided changed path, treating a path that no longer
+// exists as processed.
+func (w *Watcher) process(ctx context.Context, path string) error {
+	file, err := w.processor.Process(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		w.log.Infow("Skipping path that no longer exists", "path", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	w.log.Infow("Successfully processed changed file", "file", file)
+	return nil
+}
+
+// rescanOverflowed rescans the hierarchy if events were missed since the last
+// scan started and the overflow interval has passed since then.
+func (w *Watcher) rescanOverflowed(ctx context.Context) {
+	if !w.overflowed || w.clock.Now().Before(w.lastScan.Add(w.overflowInterval)) {
+		return
+	}
+	w.rescan(ctx)
+}
+
+// rescan starts scanning the hierarchy in the background, unless a scan is
+// already in progress. Every scan after the first is a new run as far as the
+// processor is concerned, so that periodic checksums rotate through the files.
+func (w *Watcher) rescan(ctx context.Context) {
+	if w.scanDone != nil {
+		w.log.Debugw("Skipping rescan while previous scan is in progress")
+		return
+	}
+
+	if r, ok := w.processor.(RunAdvancer); ok && w.scanned {
+		r.NextRun()
+	}
+	w.scanned = true
+
+	w.log.Infow("Rescanning watched directory")
+	w.lastScan = w.clock.Now()
+	w.overflowed = false
+
+	done := make(chan struct{})
+	w.scanDone = done
+	go func() {
+		defer close(done)
+		if err := w.scanner.Scan(ctx); err != nil {
+			w.log.Warnw("Error rescanning watched directory", "error", err)
+		}
+	}()
+}
+
+func (w *Watcher) waitForScan() {
+	if w.scanDone != nil {
+		<-w.scanDone
+		w.scanDone = nil
+	}
+}
@@ -0,0 +1,458 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mspraggs/hoard/internal/pool"
+	"github.com/mspraggs/hoard/internal/processor"
+	"github.com/mspraggs/hoard/internal/watch"
+	"github.com/mspraggs/hoard/internal/watch/mocks"
+)
+
+const debounce = 20 * time.Millisecond
+
+type WatchTestSuite struct {
+	suite.Suite
+	controller    *gomock.Controller
+	mockProcessor *mocks.MockProcessor
+	mockScanner   *mocks.MockScanner
+	mockFilter    *mocks.MockFilter
+}
+
+func TestWatchTestSuite(t *testing.T) {
+	suite.Run(t, new(WatchTestSuite))
+}
+
+func (s *WatchTestSuite) SetupTest() {
+	s.controller = gomock.NewController(s.T())
+	s.mockProcessor = mocks.NewMockProcessor(s.controller)
+	s.mockScanner = mocks.NewMockScanner(s.controller)
+	s.mockFilter = mocks.NewMockFilter(s.controller)
+}
+
+type fakeSource chan watch.Event
+
+func (s fakeSource) Events() <-chan watch.Event {
+	return s
+}
+
+func (s *WatchTestSuite) TestRun() {
+	file := &processor.File{LocalPath: "foo"}
+
+	s.Run("processes changed paths once they settle", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource, 3)
+		source <- watch.Event{Path: "foo"}
+		source <- watch.Event{Path: "foo"}
+
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+		s.mockProcessor.EXPECT().
+			Process(ctx, "foo").
+			DoAndReturn(func(context.Context, string) (*processor.File, error) {
+				cancel()
+				return file, nil
+			})
+
+		watcher := watch.New(source, s.mockProcessor, s.mockScanner, watch.WithDebounce(debounce))
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("retries paths that fail", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource, 1)
+		source <- watch.Event{Path: "foo"}
+
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+		gomock.InOrder(
+			s.mockProcessor.EXPECT().Process(ctx, "foo").Return(nil, errors.New("oh no")),
+			s.mockProcessor.EXPECT().
+				Process(ctx, "foo").
+				DoAndReturn(func(context.Context, string) (*processor.File, error) {
+					cancel()
+					return file, nil
+				}),
+		)
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithRetryInterval(debounce),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("drops paths that no longer exist", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource, 2)
+		source <- watch.Event{Path: "bar"}
+
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+		s.mockProcessor.EXPECT().
+			Process(ctx, "bar").
+			DoAndReturn(func(context.Context, string) (*processor.File, error) {
+				source <- watch.Event{Path: "foo"}
+				return nil, fmt.Errorf("stat failed: %w", fs.ErrNotExist)
+			})
+		s.mockProcessor.EXPECT().
+			Process(ctx, "foo").
+			DoAndReturn(func(context.Context, string) (*processor.File, error) {
+				cancel()
+				return file, nil
+			})
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithRetryInterval(time.Millisecond),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("skips excluded paths", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource, 2)
+		source <- watch.Event{Path: "foo.tmp"}
+		source <- watch.Event{Path: "foo"}
+
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+		s.mockFilter.EXPECT().Excluded("foo.tmp").Return(true)
+		s.mockFilter.EXPECT().Excluded("foo").Return(false)
+		s.mockProcessor.EXPECT().
+			Process(ctx, "foo").
+			DoAndReturn(func(context.Context, string) (*processor.File, error) {
+				cancel()
+				return file, nil
+			})
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithFilter(s.mockFilter),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("rescans when events are missed", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource, 1)
+		source <- watch.Event{Overflow: true}
+
+		gomock.InOrder(
+			s.mockScanner.EXPECT().Scan(ctx).Return(nil),
+			s.mockScanner.EXPECT().Scan(ctx).DoAndReturn(func(context.Context) error {
+				cancel()
+				return nil
+			}),
+		)
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithOverflowInterval(0),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("limits rescans when events are missed", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource)
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		rescanAt := clock.Now().Add(time.Minute)
+
+		gomock.InOrder(
+			s.mockScanner.EXPECT().Scan(ctx).Return(nil),
+			s.mockScanner.EXPECT().Scan(ctx).DoAndReturn(func(context.Context) error {
+				s.False(clock.Now().Before(rescanAt))
+				cancel()
+				return nil
+			}),
+		)
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithOverflowInterval(time.Minute),
+			watch.WithClock(clock),
+		)
+
+		errs := make(chan error)
+		go func() { errs <- watcher.Run(ctx) }()
+		source <- watch.Event{Overflow: true}
+		source <- watch.Event{Overflow: true}
+		clock.Advance(time.Minute)
+
+		s.Require().NoError(<-errs)
+	})
+	s.Run("waits for changed paths to settle", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource)
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		settledAt := clock.Now().Add(debounce)
+
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+		s.mockProcessor.EXPECT().
+			Process(ctx, "foo").
+			DoAndReturn(func(context.Context, string) (*processor.File, error) {
+				s.False(clock.Now().Before(settledAt))
+				cancel()
+				return file, nil
+			})
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithClock(clock),
+		)
+
+		errs := make(chan error)
+		go func() { errs <- watcher.Run(ctx) }()
+		source <- watch.Event{Path: "foo"}
+		time.Sleep(2 * debounce)
+		clock.Advance(debounce)
+
+		s.Require().NoError(<-errs)
+	})
+	s.Run("processes changed paths with scheduler and resets processor", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource, 2)
+		source <- watch.Event{Path: "foo"}
+		source <- watch.Event{Path: "bar"}
+		workers := pool.New(2)
+		defer workers.Close()
+		resetter := mocks.NewMockResetter(s.controller)
+
+		processed := int32(0)
+		processFile := func(context.Context, string) (*processor.File, error) {
+			if atomic.AddInt32(&processed, 1) == 2 {
+				cancel()
+			}
+			return file, nil
+		}
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+		s.mockProcessor.EXPECT().Process(gomock.Any(), "foo").DoAndReturn(processFile)
+		s.mockProcessor.EXPECT().Process(gomock.Any(), "bar").DoAndReturn(processFile)
+		resetter.EXPECT().Reset().MinTimes(1)
+
+		watcher := watch.New(
+			source,
+			&resettingProcessor{s.mockProcessor, resetter},
+			s.mockScanner,
+			watch.WithDebounce(debounce),
+			watch.WithScheduler(workers.NewQueue()),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("rescans periodically", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource)
+
+		gomock.InOrder(
+			s.mockScanner.EXPECT().Scan(ctx).Return(nil),
+			s.mockScanner.EXPECT().Scan(ctx).DoAndReturn(func(context.Context) error {
+				cancel()
+				return nil
+			}),
+		)
+
+		watcher := watch.New(
+			source,
+			s.mockProcessor,
+			s.mockScanner,
+			watch.WithRescanInterval(debounce),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("advances run before each rescan", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource)
+		advancer := mocks.NewMockRunAdvancer(s.controller)
+
+		gomock.InOrder(
+			s.mockScanner.EXPECT().Scan(ctx).Return(nil),
+			advancer.EXPECT().NextRun(),
+			s.mockScanner.EXPECT().Scan(ctx).DoAndReturn(func(context.Context) error {
+				cancel()
+				return nil
+			}),
+		)
+
+		watcher := watch.New(
+			source,
+			&advancingProcessor{s.mockProcessor, advancer},
+			s.mockScanner,
+			watch.WithRescanInterval(debounce),
+		)
+
+		err := watcher.Run(ctx)
+
+		s.Require().NoError(err)
+	})
+	s.Run("receives events while scanning", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		source := make(fakeSource)
+		scanned := make(chan struct{})
+
+		s.mockScanner.EXPECT().Scan(ctx).DoAndReturn(func(context.Context) error {
+			<-scanned
+			return nil
+		})
+		s.mockProcessor.EXPECT().
+			Process(ctx, "foo").
+			DoAndReturn(func(context.Context, string) (*processor.File, error) {
+				cancel()
+				return file, nil
+			})
+
+		watcher := watch.New(source, s.mockProcessor, s.mockScanner, watch.WithDebounce(debounce))
+
+		errs := make(chan error)
+		go func() { errs <- watcher.Run(ctx) }()
+		source <- watch.Event{Path: "foo"}
+		close(scanned)
+
+		s.Require().NoError(<-errs)
+	})
+	s.Run("returns error when source closes", func() {
+		ctx := context.Background()
+		source := make(fakeSource)
+		close(source)
+
+		s.mockScanner.EXPECT().Scan(ctx).Return(nil)
+
+		watcher := watch.New(source, s.mockProcessor, s.mockScanner)
+
+		err := watcher.Run(ctx)
+
+		s.ErrorIs(err, watch.ErrSourceClosed)
+	})
+}
+
+type resettingProcessor struct {
+	*mocks.MockProcessor
+	*mocks.MockResetter
+}
+
+type advancingProcessor struct {
+	*mocks.MockProcessor
+	*mocks.MockRunAdvancer
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (s *WatchTestSuite) TestInotify() {
+	root := s.T().TempDir()
+
+	source, err := watch.NewInotify(root)
+	s.Require().NoError(err)
+	defer source.Close()
+
+	s.Run("reports changed files", func() {
+		err := os.WriteFile(filepath.Join(root, "foo"), []byte("foo"), 0644)
+		s.Require().NoError(err)
+
+		s.Require().Equal("foo", s.nextPath(source))
+	})
+	s.Run("watches new directories", func() {
+		s.drain(source)
+		err := os.Mkdir(filepath.Join(root, "dir"), 0755)
+		s.Require().NoError(err)
+		s.Require().Equal("dir", s.nextPath(source))
+
+		err = os.WriteFile(filepath.Join(root, "dir", "bar"), []byte("bar"), 0644)
+		s.Require().NoError(err)
+
+		s.Require().Equal("dir/bar", s.nextPath(source))
+	})
+	s.Run("closes events on close", func() {
+		s.Require().NoError(source.Close())
+
+		for range source.Events() {
+		}
+	})
+}
+
+func (s *WatchTestSuite) nextPath(source *watch.Inotify) string {
+	select {
+	case event := <-source.Events():
+		return event.Path
+	case <-time.After(time.Second):
+		s.FailNow("timed out waiting for change event")
+		return ""
+	}
+}
+
+func (s *WatchTestSuite) drain(source *watch.Inotify) {
+	for {
+		select {
+		case <-source.Events():
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
@@ -0,0 +1,6 @@
+DROP INDEX files.files_pack_id_idx;
+
+ALTER TABLE files.files
+    DROP COLUMN pack_id,
+    DROP COLUMN pack_offset,
+    DROP COLUMN pack_length;
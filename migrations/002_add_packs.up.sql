@@ -0,0 +1,6 @@
+ALTER TABLE files.files
+    ADD COLUMN pack_id      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN pack_offset  BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN pack_length  BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX files_pack_id_idx ON files.files (pack_id) WHERE pack_id <> '';
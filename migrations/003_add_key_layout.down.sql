@@ -0,0 +1,2 @@
+ALTER TABLE files.files
+    DROP COLUMN key_layout;
@@ -0,0 +1,2 @@
+ALTER TABLE files.files
+    ADD COLUMN key_layout TEXT NOT NULL DEFAULT 'uuid';
@@ -0,0 +1,2 @@
+ALTER TABLE files.files
+    ADD COLUMN storage_class TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+DROP INDEX files.files_retain_until_idx;
+
+ALTER TABLE files.files
+    DROP COLUMN retain_until,
+    DROP COLUMN legal_hold;
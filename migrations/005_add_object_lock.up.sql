@@ -0,0 +1,5 @@
+ALTER TABLE files.files
+    ADD COLUMN retain_until  TIMESTAMPTZ,
+    ADD COLUMN legal_hold    BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX files_retain_until_idx ON files.files (retain_until) WHERE retain_until IS NOT NULL;
@@ -0,0 +1,5 @@
+UPDATE files.files SET checksum = '\x00000000' WHERE checksum_algorithm <> 'CRC32';
+
+ALTER TABLE files.files
+    ALTER COLUMN checksum TYPE BIGINT USING ('x' || encode(checksum, 'hex'))::BIT(32)::BIGINT,
+    DROP COLUMN checksum_algorithm;
@@ -0,0 +1,3 @@
+ALTER TABLE files.files
+    ADD COLUMN checksum_algorithm TEXT NOT NULL DEFAULT 'CRC32',
+    ALTER COLUMN checksum TYPE BYTEA USING substring(int8send(checksum) FROM 5);
@@ -0,0 +1,5 @@
+DROP TABLE files.runs;
+
+ALTER TABLE files.files
+    DROP COLUMN size,
+    DROP COLUMN modify_time;
@@ -0,0 +1,8 @@
+ALTER TABLE files.files
+    ADD COLUMN size        BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN modify_time TIMESTAMPTZ NOT NULL DEFAULT 'epoch';
+
+CREATE TABLE files.runs (
+    id                   BIGSERIAL PRIMARY KEY,
+    started_at_timestamp TIMESTAMPTZ NOT NULL
+);
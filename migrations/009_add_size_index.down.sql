@@ -0,0 +1 @@
+DROP INDEX files.files_bucket_size_idx;
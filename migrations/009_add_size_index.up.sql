@@ -0,0 +1 @@
+CREATE INDEX files_bucket_size_idx ON files.files (bucket, size);
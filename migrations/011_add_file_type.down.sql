@@ -0,0 +1,5 @@
+ALTER TABLE files.files
+    DROP COLUMN file_type,
+    DROP COLUMN link_target,
+    DROP COLUMN device_major,
+    DROP COLUMN device_minor;
@@ -0,0 +1,5 @@
+ALTER TABLE files.files
+    ADD COLUMN file_type    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN link_target  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN device_major BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN device_minor BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE files.files
+    ADD COLUMN extents JSONB;
@@ -0,0 +1 @@
+DROP INDEX files.files_bucket_local_path_created_at_idx;
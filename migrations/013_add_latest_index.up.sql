@@ -0,0 +1 @@
+CREATE INDEX files_bucket_local_path_created_at_idx ON files.files (bucket, local_path, created_at_timestamp DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE files.runs
+    DROP COLUMN finished_at_timestamp,
+    DROP COLUMN status;
@@ -0,0 +1,3 @@
+ALTER TABLE files.runs
+    ADD COLUMN finished_at_timestamp TIMESTAMPTZ,
+    ADD COLUMN status                TEXT;
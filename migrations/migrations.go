@@ -0,0 +1,19 @@
+// Package migrations embeds the database migrations for the file registry, so
+// that they can be applied by the binary itself.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLite returns the migrations for SQLite registries, named in the layout
+// used by golang-migrate.
+func SQLite() fs.FS {
+	// The directory is embedded above, so it always exists.
+	sub, _ := fs.Sub(sqliteFiles, "sqlite")
+	return sub
+}
@@ -0,0 +1,41 @@
+CREATE TABLE files (
+    id                    TEXT PRIMARY KEY,
+    key                   TEXT NOT NULL,
+    local_path            TEXT NOT NULL,
+    checksum              BLOB NOT NULL,
+    change_time           TIMESTAMP NOT NULL,
+    etag                  TEXT NOT NULL,
+    bucket                TEXT NOT NULL,
+    version               TEXT NOT NULL,
+    pack_id               TEXT NOT NULL DEFAULT '',
+    pack_offset           INTEGER NOT NULL DEFAULT 0,
+    pack_length           INTEGER NOT NULL DEFAULT 0,
+    key_layout            TEXT NOT NULL DEFAULT 'uuid',
+    storage_class         TEXT NOT NULL DEFAULT '',
+    retain_until          TIMESTAMP,
+    legal_hold            BOOLEAN NOT NULL DEFAULT FALSE,
+    checksum_algorithm    TEXT NOT NULL DEFAULT 'CRC32',
+    size                  INTEGER NOT NULL DEFAULT 0,
+    modify_time           TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00',
+    inconsistent          BOOLEAN NOT NULL DEFAULT FALSE,
+    metadata              TEXT,
+    file_type             TEXT NOT NULL DEFAULT '',
+    link_target           TEXT NOT NULL DEFAULT '',
+    device_major          INTEGER NOT NULL DEFAULT 0,
+    device_minor          INTEGER NOT NULL DEFAULT 0,
+    extents               TEXT,
+    created_at_timestamp  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX files_local_path_idx ON files (local_path);
+CREATE INDEX files_pack_id_idx ON files (pack_id) WHERE pack_id <> '';
+CREATE INDEX files_retain_until_idx ON files (retain_until) WHERE retain_until IS NOT NULL;
+CREATE INDEX files_bucket_size_idx ON files (bucket, size);
+CREATE INDEX files_bucket_local_path_created_at_idx ON files (bucket, local_path, created_at_timestamp DESC);
+
+CREATE TABLE runs (
+    id                    INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at_timestamp  TIMESTAMP NOT NULL,
+    finished_at_timestamp TIMESTAMP,
+    status                TEXT
+);